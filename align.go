@@ -0,0 +1,51 @@
+package main
+
+import "fmt"
+
+// maxAlignment is MAXALIGN of the platform that wrote the files, set with
+// --maxalign: 8 on 64-bit platforms, 4 on 32-bit ones such as i386, where
+// double and int64 fields are only 4-byte aligned as well. The page header
+// is 24 bytes either way, so page contents start at the same offset; what
+// moves is every 8-byte field of the meta structs and tuple alignment.
+var maxAlignment = 8
+
+func parseMaxAlign(s string) (int, error) {
+	switch s {
+	case "4":
+		return 4, nil
+	case "8":
+		return 8, nil
+	}
+	return 0, fmt.Errorf("unsupported maxalign %q (4 or 8)", s)
+}
+
+// maxAlign is MAXALIGN: n rounded up to a multiple of maxAlignment.
+func maxAlign(n int) int { return (n + maxAlignment - 1) &^ (maxAlignment - 1) }
+
+// sgltHdrSize is SGLTHDRSZ, MAXALIGN(sizeof(SpGistLeafTupleData)), with
+// the null bitmap added for leaf tuples that have one.
+func sgltHdrSize(hasNulls bool) int {
+	if hasNulls {
+		return maxAlign(12 + indexNullBitmapSize)
+	}
+	return maxAlign(12)
+}
+
+// indexDataOff is where key data starts in an IndexTupleData: after the
+// null bitmap when INDEX_NULL_MASK is set (IndexInfoFindDataOffset).
+func indexDataOff(hasNulls bool) int {
+	if hasNulls {
+		return maxAlign(IndexTupleHdrSize + indexNullBitmapSize)
+	}
+	return IndexTupleHdrSize
+}
+
+// BTMetaPageData ends with btm_last_cleanup_num_heap_tuples (float8) and,
+// from PostgreSQL 13, btm_allequalimage; these are where they fall.
+func btMetaHeapTuplesOff() int    { return maxAlign(28) }
+func btMetaAllEqualImageOff() int { return btMetaHeapTuplesOff() + 8 }
+
+// GinMetaPageData's nEntries (int64) follows nDataPages at 32, and
+// ginVersion follows nEntries.
+func ginMetaEntriesOff() int { return maxAlign(36) }
+func ginMetaVersionOff() int { return ginMetaEntriesOff() + 8 }
@@ -12,16 +12,17 @@ var binLE = binary.LittleEndian
 // JSON response types shared between web server and Wails bindings.
 
 type PageSummary struct {
-	PageNum     int    `json:"page_num"`
+	PageNum     int64  `json:"page_num"`
 	Type        string `json:"type"`
 	NumItems    int    `json:"num_items"`
 	FreeSpace   int    `json:"free_space"`
 	SpecialSize int    `json:"special_size"`
+	Anomalies   int    `json:"anomalies,omitempty"`
 }
 
 type FileInfo struct {
 	Filename   string        `json:"filename"`
-	TotalPages int           `json:"total_pages"`
+	TotalPages int64         `json:"total_pages"`
 	FileType   string        `json:"file_type"`
 	Pages      []PageSummary `json:"pages"`
 }
@@ -62,7 +63,7 @@ type MetaField struct {
 }
 
 type PageDetail struct {
-	PageNum      int               `json:"page_num"`
+	PageNum      int64             `json:"page_num"`
 	Type         string            `json:"type"`
 	PageSubtype  string            `json:"page_subtype,omitempty"`
 	Header       map[string]string `json:"header"`
@@ -71,12 +72,36 @@ type PageDetail struct {
 	Tuples       []TupleInfo       `json:"tuples"`
 	SpecialInfo  map[string]string `json:"special_info,omitempty"`
 	MetaFields   []MetaField       `json:"meta_fields,omitempty"`
+	Annotations  []Annotation      `json:"annotations,omitempty"`
+}
+
+// ByteInfo is the binary inspector's view of one byte of a page: the
+// structure and field it belongs to, and the integers that start at it.
+type ByteInfo struct {
+	Offset int         `json:"offset"`
+	Value  int         `json:"value"`
+	Detail []string    `json:"detail"`
+	Values []ByteValue `json:"values"`
+}
+
+type ByteValue struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+func buildByteInfo(p *Page, off int) ByteInfo {
+	return ByteInfo{
+		Offset: off,
+		Value:  int(p.Data[off]),
+		Detail: whereDetail(p, off),
+		Values: byteValues(p, off),
+	}
 }
 
 type FileEntry struct {
 	Index      int    `json:"index"`
 	Filename   string `json:"filename"`
-	TotalPages int    `json:"total_pages"`
+	TotalPages int64  `json:"total_pages"`
 	FileType   string `json:"file_type"`
 }
 
@@ -240,6 +265,7 @@ func buildPageDetail(p *Page) PageDetail {
 		Tuples:       tuples,
 		SpecialInfo:  specialInfo,
 		MetaFields:   metaFields,
+		Annotations:  p.Annotations,
 	}
 }
 
@@ -263,7 +289,7 @@ func specialSubtypeRegionName(pageType PageType, subtype string, size int) strin
 func buildMetaFields(p *Page, subtype string) []MetaField {
 	d := p.Data[:]
 	le := binLE
-	base := PageHeaderSize // content starts at offset 24
+	base := maxAlign(PageHeaderSize) // PageGetContents
 
 	switch subtype {
 	case "meta":
@@ -282,31 +308,24 @@ func buildMetaStructFields(p *Page, d []byte, le binary.ByteOrder, base int) []M
 		if len(d) < base+44 {
 			return nil
 		}
-		return []MetaField{
+		fields := []MetaField{
 			metaU32(d, le, base, 0, "btm_magic", "0x%08X"),
 			metaU32(d, le, base, 4, "btm_version", "%d"),
 			metaU32(d, le, base, 8, "btm_root", "%d"),
 			metaU32(d, le, base, 12, "btm_level", "%d"),
 			metaU32(d, le, base, 16, "btm_fastroot", "%d"),
 			metaU32(d, le, base, 20, "btm_fastlevel", "%d"),
-			metaU32(d, le, base, 24, "btm_last_cleanup_num_delpages", "%d"),
-			{
-				Name:      "padding",
-				Value:     "",
-				StartByte: base + 28,
-				EndByte:   base + 32,
-				Size:      4,
-			},
-			metaF64(d, le, base, 32, "btm_last_cleanup_num_heap_tuples"),
-			metaBool(d, base, 40, "btm_allequalimage"),
-			{
-				Name:      "padding",
-				Value:     "",
-				StartByte: base + 41,
-				EndByte:   base + 48,
-				Size:      7,
-			},
+			metaU32(d, le, base, 24, btMetaCleanupFieldName(), "%d"),
 		}
+		nht, aei := btMetaHeapTuplesOff(), btMetaAllEqualImageOff()
+		fields = appendPadding(fields, base, 28, nht)
+		fields = append(fields, metaF64(d, le, base, nht, "btm_last_cleanup_num_heap_tuples"))
+		end := aei
+		if btMetaHasAllEqualImage() {
+			fields = append(fields, metaBool(d, base, aei, "btm_allequalimage"))
+			end++
+		}
+		return appendPadding(fields, base, end, maxAlign(aei+1))
 
 	case PageTypeHash:
 		if len(d) < base+48 {
@@ -328,19 +347,19 @@ func buildMetaStructFields(p *Page, d []byte, le binary.ByteOrder, base int) []M
 			metaU32(d, le, base, 44, "hashm_nmaps", "%d"),
 			metaU32(d, le, base, 48, "hashm_procid", "%d"),
 		}
-		// hashm_spares[HASH_MAX_SPLITPOINTS] = uint32[32] at offset 52
-		sparesOff := 52
-		if len(d) >= base+sparesOff+32*4 {
+		// hashm_spares[HASH_MAX_SPLITPOINTS] = uint32[98] at offset 52
+		sparesOff := hashMetaSparesOff
+		if len(d) >= base+sparesOff+HashMaxSplitpoints*4 {
 			fields = append(fields, MetaField{
-				Name:      "hashm_spares[32]",
-				Value:     fmt.Sprintf("uint32[32] array"),
+				Name:      fmt.Sprintf("hashm_spares[%d]", HashMaxSplitpoints),
+				Value:     fmt.Sprintf("uint32[%d] array", HashMaxSplitpoints),
 				StartByte: base + sparesOff,
-				EndByte:   base + sparesOff + 32*4,
-				Size:      32 * 4,
+				EndByte:   base + sparesOff + HashMaxSplitpoints*4,
+				Size:      HashMaxSplitpoints * 4,
 			})
 		}
-		// hashm_mapp[HASH_MAX_BITMAPS] at offset 180
-		mappOff := 52 + 32*4
+		// hashm_mapp[HASH_MAX_BITMAPS] at offset 444
+		mappOff := hashMetaMappOff
 		linpEnd := int(p.Header.Lower)
 		if linpEnd > base+mappOff {
 			mappSize := linpEnd - (base + mappOff)
@@ -359,7 +378,7 @@ func buildMetaStructFields(p *Page, d []byte, le binary.ByteOrder, base int) []M
 		if len(d) < base+48 {
 			return nil
 		}
-		return []MetaField{
+		fields := []MetaField{
 			metaU32(d, le, base, 0, "head", "%d"),
 			metaU32(d, le, base, 4, "tail", "%d"),
 			metaU32(d, le, base, 8, "tailFreeSize", "%d"),
@@ -368,23 +387,13 @@ func buildMetaStructFields(p *Page, d []byte, le binary.ByteOrder, base int) []M
 			metaU32(d, le, base, 24, "nTotalPages", "%d"),
 			metaU32(d, le, base, 28, "nEntryPages", "%d"),
 			metaU32(d, le, base, 32, "nDataPages", "%d"),
-			{
-				Name:      "padding",
-				Value:     "",
-				StartByte: base + 36,
-				EndByte:   base + 40,
-				Size:      4,
-			},
-			metaI64(d, le, base, 40, "nEntries"),
-			metaU32(d, le, base, 48, "ginVersion", "%d"),
-			{
-				Name:      "padding",
-				Value:     "",
-				StartByte: base + 52,
-				EndByte:   base + 56,
-				Size:      4,
-			},
 		}
+		ne, gv := ginMetaEntriesOff(), ginMetaVersionOff()
+		fields = appendPadding(fields, base, 36, ne)
+		fields = append(fields,
+			metaI64(d, le, base, ne, "nEntries"),
+			metaU32(d, le, base, gv, "ginVersion", "%d"))
+		return appendPadding(fields, base, gv+4, maxAlign(gv+4))
 
 	case PageTypeBRIN:
 		if len(d) < base+16 {
@@ -495,6 +504,21 @@ func buildRevmapFields(p *Page, d []byte, le binary.ByteOrder, base int) []MetaF
 
 // Helper functions for building MetaField entries from raw bytes.
 
+// appendPadding adds the alignment padding between struct offsets from and
+// to, if there is any.
+func appendPadding(fields []MetaField, base, from, to int) []MetaField {
+	if to <= from {
+		return fields
+	}
+	return append(fields, MetaField{
+		Name:      "padding",
+		Value:     "",
+		StartByte: base + from,
+		EndByte:   base + to,
+		Size:      to - from,
+	})
+}
+
 func metaU32(d []byte, le binary.ByteOrder, base, off int, name, format string) MetaField {
 	v := le.Uint32(d[base+off : base+off+4])
 	return MetaField{
@@ -680,7 +704,18 @@ func buildTupleInfos(p *Page, isIndex bool, subtype string) []TupleInfo {
 		ti.StartByte = int(lp.Offset())
 		ti.EndByte = int(lp.Offset()) + int(lp.Length())
 
-		if isIndex {
+		if isIndex && p.Detected == PageTypeSPGiST && subtype == "leaf" {
+			if lp.Length() >= 12 {
+				lt := p.ParseSpGistLeafTuple(lp.Offset())
+				ti.Properties["tupstate"] = lt.StateStr()
+				ti.Properties["nextOffset"] = fmt.Sprintf("%d", lt.NextOffset())
+				if lt.TupState == SPGistRedirect {
+					ti.Properties["redirect_to"] = fmt.Sprintf("(%d, %d)", lt.HeapBlock, lt.HeapOffset)
+				} else {
+					ti.Properties["heapPtr"] = fmt.Sprintf("(%d, %d)", lt.HeapBlock, lt.HeapOffset)
+				}
+			}
+		} else if isIndex {
 			if lp.Length() >= uint16(IndexTupleHdrSize) {
 				it := p.ParseIndexTupleHeader(lp.Offset())
 				if subtype == "internal" && p.Detected == PageTypeBTree {
@@ -697,8 +732,14 @@ func buildTupleInfos(p *Page, isIndex bool, subtype string) []TupleInfo {
 			t := p.ParseHeapTupleHeader(lp.Offset())
 			ti.Properties["t_xmin"] = fmt.Sprintf("%d", t.Xmin)
 			ti.Properties["t_xmax"] = fmt.Sprintf("%d", t.Xmax)
-			ti.Properties["t_cid"] = fmt.Sprintf("%d", t.Field3)
-			ti.Properties["t_ctid"] = fmt.Sprintf("(%d, %d)", t.CtidBlock, t.CtidOffset)
+			ti.Properties[t.Field3Name()] = fmt.Sprintf("%d", t.Field3)
+			if t.IsMovedByVacuum() {
+				ti.Properties["warning"] = "MOVED_OFF/MOVED_IN set - pre-9.0 VACUUM FULL layout"
+			}
+			ti.Properties["t_ctid"] = t.CtidStr()
+			if t.IsSpeculative() {
+				ti.Properties["speculative"] = "INSERT ... ON CONFLICT not yet confirmed"
+			}
 			ti.Properties["t_infomask"] = fmt.Sprintf("0x%04X", t.Infomask)
 			ti.Properties["t_infomask2"] = fmt.Sprintf("0x%04X (natts: %d)", t.Infomask2, t.NAttrs())
 			ti.Properties["t_hoff"] = fmt.Sprintf("%d", t.Hoff)
@@ -742,22 +783,30 @@ func buildSpecialInfo(p *Page, subtype string) map[string]string {
 			le := binLE
 			info["btpo_prev"] = blockStr(le.Uint32(special[0:4]))
 			info["btpo_next"] = blockStr(le.Uint32(special[4:8]))
-			info["btpo_level"] = fmt.Sprintf("%d", le.Uint32(special[8:12]))
 			flags := le.Uint16(special[12:14])
+			if flags&BTPDeleted != 0 && btDeletedUsesLevelXact() {
+				info["btpo.xact"] = fmt.Sprintf("%d", le.Uint32(special[8:12]))
+			} else {
+				info["btpo_level"] = fmt.Sprintf("%d", le.Uint32(special[8:12]))
+			}
 			info["btpo_flags"] = fmt.Sprintf("0x%04X", flags)
 			if fl := btreeFlags(flags); len(fl) > 0 {
 				info["btpo_flags_decoded"] = strings.Join(fl, " | ")
 			}
 		}
-		if subtype == "meta" && len(p.Data) >= PageHeaderSize+44 {
-			d := p.Data[PageHeaderSize:]
-			le := binLE
-			info["btm_magic"] = fmt.Sprintf("0x%08X", le.Uint32(d[0:4]))
-			info["btm_version"] = fmt.Sprintf("%d", le.Uint32(d[4:8]))
-			info["btm_root"] = fmt.Sprintf("%d", le.Uint32(d[8:12]))
-			info["btm_level"] = fmt.Sprintf("%d", le.Uint32(d[12:16]))
-			info["btm_fastroot"] = fmt.Sprintf("%d", le.Uint32(d[16:20]))
-			info["btm_fastlevel"] = fmt.Sprintf("%d", le.Uint32(d[20:24]))
+		if subtype == "meta" {
+			m := parseBTMetaData(p)
+			info["btm_magic"] = fmt.Sprintf("0x%08X", m.Magic)
+			info["btm_version"] = fmt.Sprintf("%d", m.Version)
+			info["btm_root"] = fmt.Sprintf("%d", m.Root)
+			info["btm_level"] = fmt.Sprintf("%d", m.Level)
+			info["btm_fastroot"] = fmt.Sprintf("%d", m.FastRoot)
+			info["btm_fastlevel"] = fmt.Sprintf("%d", m.FastLevel)
+			info[btMetaCleanupFieldName()] = fmt.Sprintf("%d", m.LastCleanup)
+			info["btm_last_cleanup_num_heap_tuples"] = fmt.Sprintf("%g", m.NumHeapTuples)
+			if btMetaHasAllEqualImage() {
+				info["btm_allequalimage"] = fmt.Sprintf("%t", m.AllEqualImage)
+			}
 		}
 	case PageTypeHash:
 		if len(special) >= HashOpaqueSize {
@@ -814,7 +863,8 @@ func buildSpecialInfo(p *Page, subtype string) map[string]string {
 			info["nTotalPages"] = fmt.Sprintf("%d", le.Uint32(d[24:28]))
 			info["nEntryPages"] = fmt.Sprintf("%d", le.Uint32(d[28:32]))
 			info["nDataPages"] = fmt.Sprintf("%d", le.Uint32(d[32:36]))
-			info["nEntries"] = fmt.Sprintf("%d", int64(le.Uint64(d[40:48])))
+			ne := ginMetaEntriesOff()
+			info["nEntries"] = fmt.Sprintf("%d", int64(le.Uint64(d[ne:ne+8])))
 		}
 	case PageTypeSPGiST:
 		if len(special) >= SPGistOpaqueSize {
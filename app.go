@@ -16,20 +16,20 @@ type App struct {
 
 type AppFile struct {
 	Filename   string
-	TotalPages int
+	TotalPages int64
 	FileType   string
 }
 
 func NewApp(filenames []string) (*App, error) {
 	files := make([]AppFile, 0, len(filenames))
 	for _, fn := range filenames {
-		fi, err := os.Stat(fn)
+		size, err := fileSize(fn)
 		if err != nil {
 			return nil, fmt.Errorf("cannot stat %s: %w", fn, err)
 		}
-		totalPages := int(fi.Size() / PageSize)
-		if fi.Size()%PageSize != 0 {
-			fmt.Fprintf(os.Stderr, "Warning: %s size %d is not a multiple of %d\n", fn, fi.Size(), PageSize)
+		totalPages := size / PageSize
+		if size%PageSize != 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %s size %d is not a multiple of %d\n", fn, size, PageSize)
 		}
 		fileType := "unknown"
 		if totalPages > 0 {
@@ -37,7 +37,7 @@ func NewApp(filenames []string) (*App, error) {
 				fileType = pg.Detected.String()
 			}
 		}
-		files = append(files, AppFile{Filename: fn, TotalPages: totalPages, FileType: fileType})
+		files = append(files, AppFile{Filename: fn, TotalPages: totalPages, FileType: fileTypeLabel(fn, fileType, totalPages)})
 	}
 	return &App{files: files}, nil
 }
@@ -65,7 +65,7 @@ func (a *App) GetFileInfo(fileIdx int) (*FileInfo, error) {
 	fileType := "unknown"
 	pages := make([]PageSummary, 0, f.TotalPages)
 
-	for i := 0; i < f.TotalPages; i++ {
+	for i := int64(0); i < f.TotalPages; i++ {
 		pg, err := ReadPage(f.Filename, i)
 		if err != nil {
 			pages = append(pages, PageSummary{PageNum: i, Type: "error"})
@@ -89,13 +89,14 @@ func (a *App) GetFileInfo(fileIdx int) (*FileInfo, error) {
 			NumItems:    numItems,
 			FreeSpace:   freeSpace,
 			SpecialSize: pg.SpecialSize(),
+			Anomalies:   len(pg.Annotations),
 		})
 	}
 
 	return &FileInfo{
 		Filename:   f.Filename,
 		TotalPages: f.TotalPages,
-		FileType:   fileType,
+		FileType:   fileTypeLabel(f.Filename, fileType, f.TotalPages),
 		Pages:      pages,
 	}, nil
 }
@@ -121,13 +122,13 @@ func (a *App) OpenFile() ([]FileEntry, error) {
 		}
 	}
 
-	fi, err := os.Stat(path)
+	size, err := fileSize(path)
 	if err != nil {
 		return nil, fmt.Errorf("cannot stat %s: %w", path, err)
 	}
-	totalPages := int(fi.Size() / PageSize)
-	if fi.Size()%PageSize != 0 {
-		fmt.Fprintf(os.Stderr, "Warning: %s size %d is not a multiple of %d\n", path, fi.Size(), PageSize)
+	totalPages := size / PageSize
+	if size%PageSize != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s size %d is not a multiple of %d\n", path, size, PageSize)
 	}
 
 	fileType := "unknown"
@@ -137,7 +138,7 @@ func (a *App) OpenFile() ([]FileEntry, error) {
 		}
 	}
 
-	a.files = append(a.files, AppFile{Filename: path, TotalPages: totalPages, FileType: fileType})
+	a.files = append(a.files, AppFile{Filename: path, TotalPages: totalPages, FileType: fileTypeLabel(path, fileType, totalPages)})
 	return a.GetFiles(), nil
 }
 
@@ -152,7 +153,7 @@ func (a *App) CloseFile(fileIdx int) ([]FileEntry, error) {
 }
 
 // GetPageDetail returns full page detail for a specific file and page number.
-func (a *App) GetPageDetail(fileIdx int, pageNum int) (*PageDetail, error) {
+func (a *App) GetPageDetail(fileIdx int, pageNum int64) (*PageDetail, error) {
 	if fileIdx < 0 || fileIdx >= len(a.files) {
 		return nil, fmt.Errorf("invalid file index: %d", fileIdx)
 	}
@@ -169,3 +170,26 @@ func (a *App) GetPageDetail(fileIdx int, pageNum int) (*PageDetail, error) {
 	detail := buildPageDetail(page)
 	return &detail, nil
 }
+
+// GetByteInfo decodes the field that byte offset of a page belongs to, for
+// the page view's cursor.
+func (a *App) GetByteInfo(fileIdx int, pageNum int64, offset int) (*ByteInfo, error) {
+	if fileIdx < 0 || fileIdx >= len(a.files) {
+		return nil, fmt.Errorf("invalid file index: %d", fileIdx)
+	}
+	f := a.files[fileIdx]
+	if pageNum < 0 || pageNum >= f.TotalPages {
+		return nil, fmt.Errorf("invalid page number: %d", pageNum)
+	}
+	if offset < 0 || offset >= PageSize {
+		return nil, fmt.Errorf("invalid offset: %d", offset)
+	}
+
+	page, err := ReadPage(f.Filename, pageNum)
+	if err != nil {
+		return nil, err
+	}
+
+	info := buildByteInfo(page, offset)
+	return &info, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import "fmt"
+
+// archSamplePages is how many pages from the start of a file the
+// architecture check reads.
+const archSamplePages = 32
+
+// byteSwappedHeader reports whether pd_pagesize_version only makes sense
+// byte-swapped, as on a page written by a big-endian platform.
+func byteSwappedHeader(p *Page) bool {
+	v := p.RawHeader.PageSizeVer
+	swapped := v>>8 | v<<8
+	return v&0xFF00 != PageSize && swapped&0xFF00 == PageSize && swapped&0x00FF == 4 // PG_PAGE_LAYOUT_VERSION
+}
+
+// halfAlignedItems counts the LP_NORMAL items of a page that start on a
+// 4-byte but not an 8-byte boundary. ok is false when some item is not
+// even 4-byte aligned, since then the page is damaged rather than packed
+// with a different MAXALIGN.
+func halfAlignedItems(p *Page) (n int, ok bool) {
+	for _, lp := range p.Items {
+		if lp.Flags() != LPNormal {
+			continue
+		}
+		switch lp.Offset() % 8 {
+		case 0:
+		case 4:
+			n++
+		default:
+			return 0, false
+		}
+	}
+	return n, true
+}
+
+// archWarnings looks at the first pages of a file for signs it was
+// written by a different architecture than the one being assumed: headers
+// that are valid only byte-swapped, or tuples placed at 4-byte boundaries
+// while --maxalign is 8. Left alone, such files decode as pages of type
+// unknown or full of line pointer anomalies.
+func archWarnings(filename string, totalPages int64) []string {
+	var swapped, native, halfAligned, withItems int
+	for i := int64(0); i < min(totalPages, archSamplePages); i++ {
+		p, err := readPageRaw(filename, i)
+		if err != nil || isNewPage(p) {
+			continue
+		}
+		if byteSwappedHeader(p) {
+			swapped++
+			continue
+		}
+		if p.RawHeader.PageSz() == PageSize {
+			native++
+		}
+		if len(p.Anomalies(AnnotationHeader, AnnotationItem)) > 0 {
+			continue
+		}
+		if n, ok := halfAlignedItems(p); ok && len(p.Items) > 0 {
+			withItems++
+			if n > 0 {
+				halfAligned++
+			}
+		}
+	}
+	var warnings []string
+	if swapped > 0 && swapped >= native {
+		warnings = append(warnings, fmt.Sprintf(
+			"%d of the first %d pages have a byte-swapped page header; the file looks like it was written "+
+				"by a big-endian platform, which this tool cannot decode (every field is read little-endian)",
+			swapped, min(totalPages, archSamplePages)))
+	}
+	if maxAlignment == 8 && halfAligned > 0 && 2*halfAligned >= withItems {
+		warnings = append(warnings, fmt.Sprintf(
+			"%d of %d pages with tuples place them at 4-byte boundaries; the file looks like it was written "+
+				"by a 32-bit platform, try --maxalign 4", halfAligned, withItems))
+	}
+	return warnings
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// assertShowPages is how many failing pages an assert names.
+const assertShowPages = 10
+
+// CmdAssert checks an invariant over the pages in range and prints one
+// line saying whether it holds. The assertion is one of
+//
+//	checksum ok          every initialized page verifies
+//	maxlsn <op> <lsn>    the highest pd_lsn compares as given
+//	<cond>               every page matches a select where condition
+//
+// It returns false when the assertion fails or is malformed, so batch
+// mode can exit with a nonzero status.
+func CmdAssert(filename string, totalPages int64, sr scanRange, arg string) bool {
+	arg = strings.TrimSpace(arg)
+	f := strings.Fields(arg)
+	if len(f) == 0 {
+		fmt.Println("Usage: assert checksum ok | maxlsn <op> <lsn> | <cond>")
+		return false
+	}
+
+	var failed []int64
+	nfailed := 0
+	fail := func(i int64) {
+		if nfailed < assertShowPages {
+			failed = append(failed, i)
+		}
+		nfailed++
+	}
+	detail := ""
+
+	switch {
+	case len(f) == 2 && strings.EqualFold(f[0], "checksum") && strings.EqualFold(f[1], "ok"):
+		first, end := sr.bounds(totalPages)
+		for i := first; i < end; i++ {
+			pg, err := ReadPage(filename, i)
+			if err != nil {
+				fail(i)
+				continue
+			}
+			if sr.skips(pg) {
+				continue
+			}
+			if pg.ChecksumProblem() != "" {
+				fail(i)
+			}
+		}
+
+	case strings.EqualFold(f[0], "maxlsn"):
+		if len(f) != 3 || !isCompareOp(f[1]) {
+			fmt.Println("Usage: assert maxlsn <op> <lsn>")
+			return false
+		}
+		limit, err := parseLSN(f[2])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+		var maxLSN uint64
+		scanMeta(filename, totalPages, sr, func(i int64, m pageMeta, err error) {
+			if err == nil && m.LSN > maxLSN {
+				maxLSN = m.LSN
+			}
+		})
+		c := 0
+		if maxLSN < limit {
+			c = -1
+		} else if maxLSN > limit {
+			c = 1
+		}
+		if !compareHolds(f[1], c) {
+			nfailed = 1
+		}
+		detail = "highest pd_lsn " + lsnStr(maxLSN)
+
+	default:
+		where, err := parseQueryCondition(arg)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+		scanMeta(filename, totalPages, sr, func(i int64, m pageMeta, err error) {
+			if err != nil {
+				fail(i)
+				return
+			}
+			if !sr.skipsLSN(m.LSN) && !where.eval(i, m) {
+				fail(i)
+			}
+		})
+	}
+
+	if nfailed == 0 {
+		if detail != "" {
+			fmt.Printf("assert %s: ok (%s)\n", arg, detail)
+		} else {
+			fmt.Printf("assert %s: ok\n", arg)
+		}
+		return true
+	}
+	if detail == "" {
+		pages := make([]string, len(failed))
+		for j, i := range failed {
+			pages[j] = fmt.Sprint(i)
+		}
+		detail = fmt.Sprintf("%d page(s): %s", nfailed, strings.Join(pages, ", "))
+		if nfailed > len(failed) {
+			detail += ", ..."
+		}
+	}
+	fmt.Printf("assert %s: FAILED (%s)\n", arg, detail)
+	return false
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ---- BRIN tuple constants ----
+
+const (
+	SizeOfBrinTuple     = 5
+	BRINOffsetMask      = 0x1F
+	BRINEmptyRangeMask  = 0x20
+	BRINPlaceholderMask = 0x40
+	BRINNullsMask       = 0x80
+
+	// REVMAP_PAGE_MAXITEMS for 8 KB pages
+	RevmapPageMaxItems = (PageSize - PageHeaderSize - BRINSpecialSize) / 6
+)
+
+// BRINMetaPage holds BrinMetaPageData.
+type BRINMetaPage struct {
+	Magic          uint32
+	Version        uint32
+	PagesPerRange  uint32
+	LastRevmapPage uint32
+}
+
+// ParseBRINMeta decodes BrinMetaPageData. The second return value is
+// false if p is not a BRIN meta page.
+func ParseBRINMeta(p *Page) (BRINMetaPage, bool) {
+	if p.Detected != PageTypeBRIN || !isMeta(p) {
+		return BRINMetaPage{}, false
+	}
+	d := p.Data[PageHeaderSize:]
+	le := binary.LittleEndian
+	return BRINMetaPage{
+		Magic:          le.Uint32(d[0:4]),
+		Version:        le.Uint32(d[4:8]),
+		PagesPerRange:  le.Uint32(d[8:12]),
+		LastRevmapPage: le.Uint32(d[12:16]),
+	}, true
+}
+
+// BrinTuple mirrors the BrinTuple header plus its raw column data.
+type BrinTuple struct {
+	BlkNo uint32
+	Info  uint8
+	Raw   []byte // whole tuple, header included
+}
+
+func (t *BrinTuple) DataOffset() int     { return int(t.Info & BRINOffsetMask) }
+func (t *BrinTuple) IsPlaceholder() bool { return t.Info&BRINPlaceholderMask != 0 }
+func (t *BrinTuple) IsEmptyRange() bool  { return t.Info&BRINEmptyRangeMask != 0 }
+func (t *BrinTuple) HasNulls() bool      { return t.Info&BRINNullsMask != 0 }
+
+// brinTupleAt returns the BRIN tuple stored at the 1-based offset number.
+func brinTupleAt(p *Page, offnum int) (BrinTuple, error) {
+	if offnum < 1 || offnum > len(p.Items) {
+		return BrinTuple{}, fmt.Errorf("offset %d out of range (1-%d)", offnum, len(p.Items))
+	}
+	lp := p.Items[offnum-1]
+	if lp.Flags() != LPNormal {
+		return BrinTuple{}, fmt.Errorf("line pointer %d is %s", offnum, lp.FlagsStr())
+	}
+	start, end := int(lp.Offset()), int(lp.Offset())+int(lp.Length())
+	if lp.Length() < SizeOfBrinTuple || end > PageSize {
+		return BrinTuple{}, fmt.Errorf("line pointer %d has bad length %d", offnum, lp.Length())
+	}
+	raw := p.Data[start:end]
+	return BrinTuple{
+		BlkNo: binary.LittleEndian.Uint32(raw[0:4]),
+		Info:  raw[4],
+		Raw:   raw,
+	}, nil
+}
+
+// brinOpclasses are the summary layouts brinValues decodes: minmax stores
+// the minimum and maximum; inclusion stores the union of the values
+// (inet, range and box types) and two flags (brin_inclusion.c).
+var brinOpclasses = []string{"minmax", "inclusion"}
+
+// parseBRINOpclasses parses brinranges' opclass argument, one name per
+// schema column or a single name for all of them; the default is minmax.
+func parseBRINOpclasses(arg string, schema []string) ([]string, error) {
+	names := strings.Split(arg, ",")
+	if arg == "" {
+		names = []string{"minmax"}
+	}
+	for _, n := range names {
+		if !slices.Contains(brinOpclasses, n) {
+			return nil, fmt.Errorf("unknown BRIN opclass %q (supported: %s)", n, strings.Join(brinOpclasses, ", "))
+		}
+	}
+	switch {
+	case len(names) == 1:
+		opclasses := make([]string, len(schema))
+		for i := range opclasses {
+			opclasses[i] = names[0]
+		}
+		return opclasses, nil
+	case len(names) != len(schema):
+		return nil, fmt.Errorf("%d opclasses for %d schema columns", len(names), len(schema))
+	}
+	return names, nil
+}
+
+// brinValues decodes the summary of every column of a BRIN tuple according
+// to schema and each column's opclass, returning "[min .. max]" for minmax
+// columns and "union <value>" for inclusion ones.
+func brinValues(t BrinTuple, schema, opclasses []string) ([]string, error) {
+	natts := len(schema)
+	var nullBits []byte
+	if t.HasNulls() {
+		nbytes := (2*natts + 7) / 8
+		if SizeOfBrinTuple+nbytes > len(t.Raw) {
+			return nil, fmt.Errorf("null bitmap beyond tuple")
+		}
+		nullBits = t.Raw[SizeOfBrinTuple : SizeOfBrinTuple+nbytes]
+	}
+	bit := func(n int) bool { return nullBits != nil && nullBits[n/8]&(1<<(n%8)) != 0 }
+
+	off := t.DataOffset()
+	vals := make([]string, 0, natts)
+	for col, typ := range schema {
+		if bit(col) {
+			vals = append(vals, "all nulls")
+			continue
+		}
+		var v string
+		if opclasses[col] == "inclusion" {
+			union, next, err := DecodeDatumAt(typ, t.Raw, off)
+			if err != nil {
+				return vals, fmt.Errorf("column %d union: %w", col+1, err)
+			}
+			unmergeable, next, err := DecodeDatumAt("bool", t.Raw, next)
+			if err != nil {
+				return vals, fmt.Errorf("column %d unmergeable flag: %w", col+1, err)
+			}
+			containsEmpty, next, err := DecodeDatumAt("bool", t.Raw, next)
+			if err != nil {
+				return vals, fmt.Errorf("column %d contains-empty flag: %w", col+1, err)
+			}
+			off = next
+			v = "union " + union.String()
+			if unmergeable.Int != 0 {
+				v += " +unmergeable"
+			}
+			if containsEmpty.Int != 0 {
+				v += " +empty"
+			}
+		} else {
+			lo, next, err := DecodeDatumAt(typ, t.Raw, off)
+			if err != nil {
+				return vals, fmt.Errorf("column %d min: %w", col+1, err)
+			}
+			hi, next, err := DecodeDatumAt(typ, t.Raw, next)
+			if err != nil {
+				return vals, fmt.Errorf("column %d max: %w", col+1, err)
+			}
+			off = next
+			v = fmt.Sprintf("[%s .. %s]", lo, hi)
+		}
+		if bit(natts + col) {
+			v += " +nulls"
+		}
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+// CmdBRINRanges walks the revmap of a BRIN index and prints one row per
+// block range with its summary tuple location, flags and values. Values
+// are decoded per column opclass when a schema is set, or shown as hex.
+func CmdBRINRanges(filename string, totalPages int64, schema, opclasses []string) {
+	metaPg, err := ReadPage(filename, 0)
+	if err != nil {
+		fmt.Printf("Error reading meta page: %v\n", err)
+		return
+	}
+	meta, ok := ParseBRINMeta(metaPg)
+	if !ok {
+		fmt.Println("Block 0 is not a BRIN meta page.")
+		return
+	}
+
+	fmt.Println()
+	fmt.Printf("=== BRIN Ranges (pagesPerRange: %d, revmap pages: 1-%d) ===\n", meta.PagesPerRange, meta.LastRevmapPage)
+	if len(schema) == 0 {
+		fmt.Println("  (no schema set - values shown as hex; use 'schema <types>' to decode summary values)")
+	}
+	fmt.Printf("  %-7s %-15s %-11s %-13s %s\n", "Range", "Heap blocks", "Tuple", "Flags", "Values")
+	fmt.Printf("  %-7s %-15s %-11s %-13s %s\n", "-----", "-----------", "-----", "-----", "------")
+
+	pages := map[uint32]*Page{}
+	loadPage := func(blk uint32) (*Page, error) {
+		if pg, ok := pages[blk]; ok {
+			return pg, nil
+		}
+		if int64(blk) >= totalPages {
+			return nil, fmt.Errorf("block %d beyond end of file", blk)
+		}
+		pg, err := ReadPage(filename, int64(blk))
+		if err != nil {
+			return nil, err
+		}
+		pages[blk] = pg
+		return pg, nil
+	}
+
+	summarized, unsummarized, placeholders, empty, broken := 0, 0, 0, 0, 0
+	nextRange := uint32(0) // ranges before this have been accounted for
+	le := binary.LittleEndian
+
+	for revBlk := uint32(1); revBlk <= meta.LastRevmapPage; revBlk++ {
+		rev, err := ReadPage(filename, int64(revBlk))
+		if err != nil {
+			fmt.Printf("  error reading revmap page %d: %v\n", revBlk, err)
+			break
+		}
+		if detectPageSubtype(rev) != "revmap" {
+			fmt.Printf("  block %d is not a revmap page\n", revBlk)
+			break
+		}
+		for i := 0; i < RevmapPageMaxItems; i++ {
+			off := PageHeaderSize + i*6
+			blk := uint32(le.Uint16(rev.Data[off:off+2]))<<16 | uint32(le.Uint16(rev.Data[off+2:off+4]))
+			posid := le.Uint16(rev.Data[off+4 : off+6])
+
+			rangeNo := uint32(revBlk-1)*RevmapPageMaxItems + uint32(i)
+			heapStart := rangeNo * meta.PagesPerRange
+			heapRange := fmt.Sprintf("%d-%d", heapStart, heapStart+meta.PagesPerRange-1)
+
+			if blk == 0 && posid == 0 {
+				continue
+			}
+			// Empty slots are only unsummarized ranges if a later range
+			// is summarized; trailing slots are simply unused.
+			unsummarized += int(rangeNo - nextRange)
+			nextRange = rangeNo + 1
+
+			tidStr := fmt.Sprintf("(%d,%d)", blk, posid)
+			pg, err := loadPage(blk)
+			if err != nil {
+				broken++
+				fmt.Printf("  %-7d %-15s %-11s %-13s %v\n", rangeNo, heapRange, tidStr, "BROKEN", err)
+				continue
+			}
+			t, err := brinTupleAt(pg, int(posid))
+			if err != nil {
+				broken++
+				fmt.Printf("  %-7d %-15s %-11s %-13s %v\n", rangeNo, heapRange, tidStr, "BROKEN", err)
+				continue
+			}
+
+			var flags []string
+			if t.IsPlaceholder() {
+				flags = append(flags, "placeholder")
+				placeholders++
+			}
+			if t.IsEmptyRange() {
+				flags = append(flags, "empty")
+				empty++
+			}
+			if t.BlkNo != heapStart {
+				flags = append(flags, fmt.Sprintf("blkno=%d!", t.BlkNo))
+			}
+			flagStr := "-"
+			if len(flags) > 0 {
+				flagStr = strings.Join(flags, ",")
+			}
+			summarized++
+
+			var values string
+			switch {
+			case t.IsEmptyRange():
+				values = "(empty range)"
+			case t.IsPlaceholder():
+				values = "(summarization in progress)"
+			case len(schema) > 0:
+				vals, err := brinValues(t, schema, opclasses)
+				values = strings.Join(vals, "; ")
+				if err != nil {
+					values += fmt.Sprintf(" <%v>", err)
+				}
+			case t.DataOffset() > len(t.Raw):
+				values = fmt.Sprintf("<data offset %d beyond tuple>", t.DataOffset())
+			default:
+				data := t.Raw[t.DataOffset():]
+				if len(data) > 24 {
+					values = fmt.Sprintf("% x ...", data[:24])
+				} else {
+					values = fmt.Sprintf("% x", data)
+				}
+			}
+			fmt.Printf("  %-7d %-15s %-11s %-13s %s\n", rangeNo, heapRange, tidStr, flagStr, values)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("  Summarized ranges  : %d\n", summarized)
+	fmt.Printf("  Placeholder tuples : %d\n", placeholders)
+	fmt.Printf("  Empty ranges       : %d\n", empty)
+	fmt.Printf("  Broken revmap refs : %d\n", broken)
+	fmt.Printf("  Unsummarized ranges: %d (before the last summarized range)\n", unsummarized)
+	fmt.Println()
+}
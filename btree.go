@@ -0,0 +1,488 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// BTMetaPage holds the BTMetaPageData fields of a btree meta page.
+// LastCleanup is btm_oldest_btpo_xact before PostgreSQL 14 and
+// btm_last_cleanup_num_delpages from 14 (see btMetaCleanupFieldName);
+// AllEqualImage exists from 13.
+type BTMetaPage struct {
+	Magic         uint32
+	Version       uint32
+	Root          uint32
+	Level         uint32
+	FastRoot      uint32
+	FastLevel     uint32
+	LastCleanup   uint32
+	NumHeapTuples float64
+	AllEqualImage bool
+}
+
+// parseBTMetaData decodes BTMetaPageData from the page contents.
+func parseBTMetaData(p *Page) BTMetaPage {
+	d := p.Data[maxAlign(PageHeaderSize):]
+	le := binary.LittleEndian
+	nht := btMetaHeapTuplesOff()
+	return BTMetaPage{
+		Magic:         le.Uint32(d[0:4]),
+		Version:       le.Uint32(d[4:8]),
+		Root:          le.Uint32(d[8:12]),
+		Level:         le.Uint32(d[12:16]),
+		FastRoot:      le.Uint32(d[16:20]),
+		FastLevel:     le.Uint32(d[20:24]),
+		LastCleanup:   le.Uint32(d[24:28]),
+		NumHeapTuples: math.Float64frombits(le.Uint64(d[nht : nht+8])),
+		AllEqualImage: btMetaHasAllEqualImage() && d[btMetaAllEqualImageOff()] != 0,
+	}
+}
+
+// btMetaCleanupNote explains btm_last_cleanup_num_delpages or
+// btm_oldest_btpo_xact for VACUUM debugging.
+func btMetaCleanupNote(m BTMetaPage) string {
+	if pgVersion < 14 {
+		if m.LastCleanup == 0 {
+			return "no deleted pages awaiting recycling"
+		}
+		return "deleted pages are recyclable once this XID is older than every snapshot"
+	}
+	if m.LastCleanup == 0 {
+		return "no deleted pages awaiting recycling"
+	}
+	return "pages deleted by the last VACUUM; cleanup-only scans recur while they exceed 5% of the index"
+}
+
+// btMetaHeapTuplesNote explains btm_last_cleanup_num_heap_tuples, which
+// drove vacuum_cleanup_index_scale_factor in 12 and 13 and is unused from 14.
+func btMetaHeapTuplesNote(m BTMetaPage) string {
+	switch {
+	case pgVersion >= 14:
+		return "unused since PostgreSQL 14"
+	case m.NumHeapTuples < 0:
+		return "unknown: no cleanup-only VACUUM recorded yet"
+	}
+	return "heap tuples at the last cleanup, compared with vacuum_cleanup_index_scale_factor"
+}
+
+// btMetaVersionNote explains btm_version: 4 indexes (PostgreSQL 13+) can
+// use deduplication, 3 ones (11-12) have suffix truncation, 2 neither.
+func btMetaVersionNote(v uint32) string {
+	switch v {
+	case 2:
+		return "pre-11 layout, no heap TID key; REINDEX to upgrade"
+	case 3:
+		return "11-12 layout, no deduplication; REINDEX to upgrade"
+	case 4:
+		return "13+ layout"
+	}
+	return "unknown version"
+}
+
+// ParseBTreeMeta reads BTMetaPageData from the content area of a btree
+// meta page. The second return value is false if p is not a meta page.
+func ParseBTreeMeta(p *Page) (BTMetaPage, bool) {
+	op, ok := p.BTreeOpaque()
+	if !ok || op.Flags&BTPMeta == 0 {
+		return BTMetaPage{}, false
+	}
+	return parseBTMetaData(p), true
+}
+
+// CmdBTLevels scans every page of a btree file and prints a per-level
+// page count together with root information and problem-page counters.
+func CmdBTLevels(filename string, totalPages int64) {
+	var meta BTMetaPage
+	metaBlock := int64(-1)
+	levels := map[uint32]int{}
+	var rootFlagged []int64
+	deleted, halfDead, incomplete, other := 0, 0, 0, 0
+
+	for i := int64(0); i < totalPages; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil {
+			other++
+			continue
+		}
+		op, ok := pg.BTreeOpaque()
+		if !ok {
+			other++
+			continue
+		}
+		if op.Flags&BTPMeta != 0 {
+			if m, ok := ParseBTreeMeta(pg); ok && metaBlock < 0 {
+				meta = m
+				metaBlock = i
+			}
+			continue
+		}
+		if op.Flags&BTPDeleted != 0 {
+			deleted++
+			continue
+		}
+		if op.Flags&BTPHalfDead != 0 {
+			halfDead++
+		}
+		if op.Flags&BTPIncompleteSplit != 0 {
+			incomplete++
+		}
+		if op.Flags&BTPRoot != 0 {
+			rootFlagged = append(rootFlagged, i)
+		}
+		levels[op.Level]++
+	}
+
+	fmt.Println()
+	fmt.Println("=== B-tree Level Map ===")
+	if metaBlock < 0 {
+		fmt.Println("  Meta page          : not found")
+	} else {
+		fmt.Printf("  Meta page          : block %d", metaBlock)
+		if meta.Magic != BTreeMagic {
+			fmt.Printf(" (INVALID magic 0x%06X)", meta.Magic)
+		}
+		fmt.Println()
+		if meta.Root == 0 {
+			// P_NONE: the index has never had a root page
+			fmt.Println("  Root               : none (empty index)")
+		} else {
+			fmt.Printf("  Root               : %d (level %d)\n", meta.Root, meta.Level)
+			fmt.Printf("  Fast root          : %d (level %d)\n", meta.FastRoot, meta.FastLevel)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("  %-7s %s\n", "Level", "Pages")
+	fmt.Printf("  %-7s %s\n", "-----", "-----")
+	keys := make([]uint32, 0, len(levels))
+	for lvl := range levels {
+		keys = append(keys, lvl)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] > keys[j] })
+	for _, lvl := range keys {
+		if lvl == 0 {
+			fmt.Printf("  %-7d %d (leaf)\n", lvl, levels[lvl])
+		} else {
+			fmt.Printf("  %-7d %d\n", lvl, levels[lvl])
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("  Deleted pages      : %d\n", deleted)
+	fmt.Printf("  Half-dead pages    : %d\n", halfDead)
+	fmt.Printf("  Incomplete splits  : %d\n", incomplete)
+	fmt.Printf("  Non-btree pages    : %d\n", other)
+
+	// Sanity checks against the meta page
+	var warnings []string
+	if metaBlock >= 0 && meta.Root != 0 {
+		if len(keys) > 0 && keys[0] != meta.Level {
+			warnings = append(warnings, fmt.Sprintf("highest level found is %d but btm_level is %d", keys[0], meta.Level))
+		}
+		if len(keys) > 0 && levels[keys[0]] != 1 {
+			warnings = append(warnings, fmt.Sprintf("%d pages at top level %d (expected 1)", levels[keys[0]], keys[0]))
+		}
+		rootOK := false
+		for _, blk := range rootFlagged {
+			if uint32(blk) == meta.Root {
+				rootOK = true
+			}
+		}
+		if !rootOK {
+			warnings = append(warnings, fmt.Sprintf("btm_root block %d does not carry BTP_ROOT", meta.Root))
+		}
+	}
+	if len(rootFlagged) > 1 {
+		warnings = append(warnings, fmt.Sprintf("%d pages carry BTP_ROOT: %v", len(rootFlagged), rootFlagged))
+	}
+	if halfDead > 0 || incomplete > 0 {
+		warnings = append(warnings, "interrupted page deletion or split detected (VACUUM/next insert should finish it)")
+	}
+
+	if len(warnings) > 0 {
+		fmt.Println()
+		fmt.Println("  Warnings:")
+		for _, w := range warnings {
+			fmt.Printf("    - %s\n", w)
+		}
+	}
+	fmt.Println()
+}
+
+// btreeKeyAt decodes the first key column of the index tuple at item idx
+// (0-based). ok is false when the tuple carries no key: unused/dead line
+// pointers, NULL keys, or truncated pivot tuples such as the "minus
+// infinity" downlink.
+func btreeKeyAt(p *Page, idx int, typ string) (key Datum, ok bool, err error) {
+	lp := p.Items[idx]
+	if lp.Flags() != LPNormal || lp.Length() < uint16(IndexTupleHdrSize) {
+		return Datum{}, false, nil
+	}
+	start := int(lp.Offset())
+	end := start + int(lp.Length())
+	if end > PageSize {
+		return Datum{}, false, fmt.Errorf("tuple extends beyond page")
+	}
+	it := p.ParseIndexTupleHeader(lp.Offset())
+	if it.HasNulls() {
+		return Datum{}, false, nil
+	}
+	keyStart := start + IndexTupleHdrSize
+	if keyStart >= end {
+		return Datum{}, false, nil
+	}
+	key, _, err = DecodeDatum(typ, p.Data[keyStart:end])
+	if err != nil {
+		return Datum{}, false, err
+	}
+	return key, true, nil
+}
+
+// btreeTIDLines explains t_tid of btree tuple idx (0-based), a heap TID
+// only in plain leaf tuples: pivot tuples keep a downlink and their number
+// of key attributes there, possibly with a heap TID suffix, and posting
+// list tuples the location of their TIDs. nkeys is the index's number of
+// key columns (the schema), or 0 if unknown.
+func btreeTIDLines(p *Page, idx int, it IndexTupleHeader, lp ItemId, nkeys int) []string {
+	op, _ := p.BTreeOpaque()
+	highKey := idx == 0 && op.Next != 0
+	tid := fmt.Sprintf("(%d, %d)", it.TidBlock, it.TidOffset)
+	if it.Info&IndexAMReservedBit == 0 {
+		if op.Level > 0 && !highKey {
+			return []string{fmt.Sprintf("t_tid        : %s  -> downlink to block %d", tid, it.TidBlock)}
+		}
+		return []string{fmt.Sprintf("t_tid        : %s  -> heap ctid", tid)}
+	}
+
+	start, end := int(lp.Offset()), int(lp.Offset())+int(lp.Length())
+	if it.TidOffset&BTIsPosting != 0 {
+		n, off := int(it.TidOffset&BTOffsetMask), int(it.TidBlock)
+		lines := []string{fmt.Sprintf("t_tid        : (%d, 0x%04X)  -> posting list of %d heap TIDs at tuple offset %d", it.TidBlock, it.TidOffset, n, off)}
+		var tids []string
+		for j := 0; j < n && start+off+(j+1)*ItemPointerSz <= end; j++ {
+			if j == 16 {
+				tids = append(tids, fmt.Sprintf("... (%d more)", n-j))
+				break
+			}
+			tids = append(tids, readTID(p.Data[start+off+j*ItemPointerSz:]).String())
+		}
+		return append(lines, "heap TIDs    : "+strings.Join(tids, " "))
+	}
+
+	natts := int(it.TidOffset & BTOffsetMask)
+	role := "pivot tuple"
+	switch {
+	case highKey:
+		role = "high key"
+	case op.Level > 0:
+		role = fmt.Sprintf("pivot tuple, downlink to block %d", it.TidBlock)
+	}
+	lines := []string{fmt.Sprintf("t_tid        : (%d, 0x%04X)  -> %s", it.TidBlock, it.TidOffset, role)}
+	attrs := fmt.Sprintf("key attrs    : %d", natts)
+	switch {
+	case natts == 0:
+		attrs += " (minus infinity)"
+	case nkeys > natts:
+		attrs += fmt.Sprintf(" of %d (%d truncated)", nkeys, nkeys-natts)
+	}
+	lines = append(lines, attrs)
+	if it.TidOffset&BTPivotHeapTIDAttr != 0 {
+		if end-ItemPointerSz < start+IndexTupleHdrSize {
+			lines = append(lines, "heap TID     : (suffix beyond tuple)")
+		} else {
+			lines = append(lines, fmt.Sprintf("heap TID     : %s (suffix, the tiebreaker key attribute)", readTID(p.Data[end-ItemPointerSz:])))
+		}
+	} else if natts > 0 {
+		lines = append(lines, "heap TID     : none (truncated, or an index older than v4)")
+	}
+	return lines
+}
+
+// indexNullBitmapSize is sizeof(IndexAttributeBitMapData), the null bitmap
+// that follows IndexTupleData when INDEX_NULL_MASK is set. It has room for
+// INDEX_MAX_KEYS columns whatever the index's column count; a set bit is a
+// non-NULL column, and the bits past the last column are left clear.
+const indexNullBitmapSize = 4
+
+// indexNullColumns lists the 1-based columns, of the first natts, that a
+// null bitmap marks NULL.
+func indexNullColumns(bitmap []byte, natts int) []int {
+	var cols []int
+	for i := 0; i < natts && i/8 < len(bitmap); i++ {
+		if bitmap[i/8]&(1<<(i%8)) == 0 {
+			cols = append(cols, i+1)
+		}
+	}
+	return cols
+}
+
+// bitmapHighestSet returns the 1-based position of the highest set bit of
+// a null bitmap, 0 if none is set.
+func bitmapHighestSet(bitmap []byte) int {
+	for i := len(bitmap)*8 - 1; i >= 0; i-- {
+		if bitmap[i/8]&(1<<(i%8)) != 0 {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// pageIndexNatts infers the number of columns of an index from the null
+// bitmaps of the tuples on one page, when no schema gives it. The highest
+// non-NULL column seen in any bitmap is a lower bound: tuples whose last
+// columns are all NULL can hide the true count.
+func pageIndexNatts(p *Page) int {
+	natts := 0
+	for _, lp := range p.Items {
+		if lp.Flags() != LPNormal || lp.Length() < uint16(IndexTupleHdrSize+indexNullBitmapSize) ||
+			int(lp.Offset())+int(lp.Length()) > PageSize {
+			continue
+		}
+		if it := p.ParseIndexTupleHeader(lp.Offset()); it.HasNulls() {
+			start := int(lp.Offset()) + IndexTupleHdrSize
+			natts = max(natts, bitmapHighestSet(p.Data[start:start+indexNullBitmapSize]))
+		}
+	}
+	return natts
+}
+
+// nullBitmapLine describes an index tuple null bitmap for data: the bytes
+// and which of the natts columns are NULL; from says where natts came from.
+func nullBitmapLine(bitmap []byte, natts int, from string) string {
+	cols := indexNullColumns(bitmap, natts)
+	nulls := "none"
+	if len(cols) > 0 {
+		nulls = strings.Trim(fmt.Sprint(cols), "[]")
+	}
+	return fmt.Sprintf("null bitmap  : % x (NULL columns: %s; %d columns, %s)", bitmap, nulls, natts, from)
+}
+
+// btreeTupleKeys decodes the key columns of the btree tuple at lp with the
+// given column types, NULLs included. Pivot tuples (high keys and internal
+// page items) may keep fewer columns than the index has, suffix truncation
+// leaving the rest out, and the first item of an internal page keeps none
+// (minus infinity); posting list tuples hold their key before the TIDs.
+func btreeTupleKeys(p *Page, lp ItemId, schema []string) (keys []string, truncated bool, err error) {
+	start, end := int(lp.Offset()), int(lp.Offset())+int(lp.Length())
+	if lp.Length() < uint16(IndexTupleHdrSize) || end > PageSize {
+		return nil, false, fmt.Errorf("no tuple")
+	}
+	if len(schema) > indexNullBitmapSize*8 {
+		return nil, false, fmt.Errorf("%d columns; an index has at most %d", len(schema), indexNullBitmapSize*8)
+	}
+	it := p.ParseIndexTupleHeader(lp.Offset())
+	natts := len(schema)
+	if it.Info&IndexAMReservedBit != 0 {
+		if it.TidOffset&BTIsPosting != 0 {
+			end = min(end, start+int(it.TidBlock))
+		} else {
+			if it.TidOffset&BTPivotHeapTIDAttr != 0 {
+				end -= ItemPointerSz
+			}
+			if n := int(it.TidOffset & BTOffsetMask); n < natts {
+				natts, truncated = n, true
+			}
+		}
+	}
+	dataOff := indexDataOff(it.HasNulls())
+	if start+dataOff > end {
+		return nil, truncated, fmt.Errorf("tuple too short for its header")
+	}
+	var nulls []byte
+	if it.HasNulls() {
+		nulls = p.Data[start+IndexTupleHdrSize : start+IndexTupleHdrSize+indexNullBitmapSize]
+	}
+	data := p.Data[start+dataOff : end]
+	off := 0
+	for i := 0; i < natts; i++ {
+		if nulls != nil && nulls[i/8]&(1<<(i%8)) == 0 {
+			keys = append(keys, "NULL")
+			continue
+		}
+		var d Datum
+		d, off, err = DecodeDatumAt(schema[i], data, off)
+		if err != nil {
+			return keys, truncated, fmt.Errorf("column %d (%s): %w", i+1, schema[i], err)
+		}
+		keys = append(keys, d.String())
+	}
+	return keys, truncated, nil
+}
+
+// CmdBTCheck verifies that the keys of a single-column btree of the given
+// type are sorted within each page and bounded by the page's high key.
+func CmdBTCheck(filename string, totalPages int64, typ string) {
+	pagesChecked, itemsChecked, undecodable, violations := 0, 0, 0, 0
+
+	fmt.Println()
+	fmt.Printf("=== B-tree Key Order Check (type: %s) ===\n", typ)
+	if typ == "text" {
+		fmt.Println("  (text is compared bytewise; indexes using a non-C collation will report false positives)")
+	}
+
+	for i := int64(0); i < totalPages; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil {
+			continue
+		}
+		op, ok := pg.BTreeOpaque()
+		if !ok || op.Flags&(BTPMeta|BTPDeleted) != 0 {
+			continue
+		}
+		pagesChecked++
+
+		// Non-rightmost pages keep their high key in the first slot.
+		var hikey Datum
+		hasHikey := false
+		first := 0
+		if op.Next != 0 {
+			first = 1
+			if len(pg.Items) > 0 {
+				k, ok, err := btreeKeyAt(pg, 0, typ)
+				if err != nil {
+					undecodable++
+					fmt.Printf("  page %d item 1 (high key): cannot decode: %v\n", i, err)
+				}
+				hikey, hasHikey = k, ok
+			}
+		}
+
+		var prev Datum
+		hasPrev := false
+		for idx := first; idx < len(pg.Items); idx++ {
+			key, ok, err := btreeKeyAt(pg, idx, typ)
+			if err != nil {
+				undecodable++
+				fmt.Printf("  page %d item %d: cannot decode: %v\n", i, idx+1, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			itemsChecked++
+			if hasPrev && CompareDatums(key, prev) < 0 {
+				violations++
+				fmt.Printf("  page %d item %d: key %s < previous key %s\n", i, idx+1, key, prev)
+			}
+			if hasHikey && CompareDatums(key, hikey) > 0 {
+				violations++
+				fmt.Printf("  page %d item %d: key %s > high key %s\n", i, idx+1, key, hikey)
+			}
+			prev, hasPrev = key, true
+		}
+	}
+
+	if violations == 0 && undecodable == 0 {
+		fmt.Println("  No ordering problems found.")
+	}
+	fmt.Println()
+	fmt.Printf("  Pages checked : %d\n", pagesChecked)
+	fmt.Printf("  Keys checked  : %d\n", itemsChecked)
+	fmt.Printf("  Undecodable   : %d\n", undecodable)
+	fmt.Printf("  Violations    : %d\n", violations)
+	fmt.Println()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// tupleHintBits are the t_infomask bits a backend may set without WAL
+// (unless wal_log_hints or checksums) once it learns a transaction's
+// outcome.
+const tupleHintBits = HeapXminCommitted | HeapXminInvalid | HeapXmaxCommitted | HeapXmaxInvalid
+
+// clearHintBits clears the hint bits of every heap tuple in data.
+// XMIN_FROZEN (both xmin bits) is kept, since freezing is WAL-logged, so
+// a frozen tuple only loses its xmax hints. It returns the number of
+// tuples changed and of bits cleared.
+func clearHintBits(p *Page, data *[PageSize]byte) (tuples, cleared int) {
+	for _, lp := range p.Items {
+		if lp.Flags() != LPNormal || lp.Length() < HeapTupleHdrSize || int(lp.Offset())+HeapTupleHdrSize > PageSize {
+			continue
+		}
+		t := p.ParseHeapTupleHeader(lp.Offset())
+		hints := uint16(tupleHintBits)
+		if t.Infomask&HeapXminFrozen == HeapXminFrozen {
+			hints &^= HeapXminFrozen
+		}
+		if t.Infomask&hints == 0 {
+			continue
+		}
+		tuples++
+		cleared += bits.OnesCount16(t.Infomask & hints)
+		t.Infomask &^= hints
+		putHeapTupleHeader(data[lp.Offset():], t)
+	}
+	return tuples, cleared
+}
+
+// CmdClearHints clears the tuple hint bits of heap page pageNum, so that
+// pages can be compared byte for byte or hint-bit setting observed from a
+// clean state. Without --allow-writes it only reports what would change.
+// It returns whether the page was written.
+func CmdClearHints(filename string, pageNum int64, p *Page) bool {
+	if p.Detected != PageTypeHeap {
+		fmt.Printf("Error: page %d is not a heap page (%s)\n", pageNum, p.TypeLabel())
+		return false
+	}
+	data := p.Data
+	tuples, cleared := clearHintBits(p, &data)
+
+	fmt.Println()
+	fmt.Printf("=== Clear Hint Bits (page %d) ===\n", pageNum)
+	fmt.Printf("  Tuples changed     : %d of %d line pointers\n", tuples, len(p.Items))
+	fmt.Printf("  Hint bits cleared  : %d\n", cleared)
+	if cleared == 0 {
+		fmt.Println()
+		return false
+	}
+	return commitPage(filename, pageNum, p, data)
+}
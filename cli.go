@@ -0,0 +1,772 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// subcommand is a non-interactive entry point: pgpageshell <name> [args].
+type subcommand struct {
+	name  string
+	args  string
+	about string
+	run   func(args []string) error
+}
+
+// subcommandList is kept in help order; subcommands indexes it by name.
+var (
+	subcommandList []subcommand
+	subcommands    = map[string]subcommand{}
+)
+
+func init() {
+	subcommandList = []subcommand{
+		{"gui", "[file ...]", "open files in the desktop GUI (default)", runGUI},
+		{"shell", "<file>", "interactive page inspector", cliShell},
+		{"verify", "<file> [...]", "check page headers and checksums; exit 1 on failures", cliVerify},
+		{"manifest-verify", "<backup_manifest> [dir]", "check a base backup's files and pages against its manifest", cliManifestVerify},
+		{"diff-rel", "<fileA> <fileB>", "compare two copies of a relation: blocks, tuples and free space per block", cliDiffRel},
+		{"triage", "<file>", "classify problem pages into corruption categories", cliTriage},
+		{"xcheck", "<index> <heap>", "report index entries whose heap TID is dangling", cliXCheck},
+		{"findtid", "<index> <block> [offset]", "list index entries pointing at a heap block or exact TID", cliFindTID},
+		{"indexcheck", "<index> <heap> <types> <col>", "report live heap keys missing from a btree", cliIndexCheck},
+		{"freezeaudit", "<file> [xid [mxid]]", "report tuples older than relfrozenxid/relminmxid", cliFreezeAudit},
+		{"toastreport", "<heap> <toast> [types]", "external values per column, missing and orphaned TOAST values", cliToastReport},
+		{"multixact", "<file> [mxid]", "list tuples locked or updated by a multixact, with its members", cliMultiXact},
+		{"tid", "<ctid|hex|block> [file]", "convert a ctid to/from ItemPointerData hex and locate its segment file", cliTID},
+		{"checksum", "[--block N] <file|-> [page]", "print pg_checksum_page() of one page image (stdin with -)", cliChecksum},
+		{"carve", "<file> [page]", "scan raw bytes for heap tuples on one or all pages", cliCarve},
+		{"settuple", "<file> <page> <item> <edit> ...", "edit a heap tuple header (preview unless --allow-writes)", cliSetTuple},
+		{"setlp", "<file> <page> <item> <edit> ...", "patch a line pointer (preview unless --allow-writes)", cliSetLP},
+		{"setflag", "<file> <page> <flag>[,...]", "set pd_flags bits (preview unless --allow-writes)", cliSetFlag},
+		{"clearflag", "<file> <page> <flag>[,...]", "clear pd_flags bits (preview unless --allow-writes)", cliClearFlag},
+		{"clearhints", "<file> <page>", "clear tuple hint bits of a heap page (preview unless --allow-writes)", cliClearHints},
+		{"copypage", "[--keep-checksum] <file>:<page> <file>:<page>", "copy a page image onto another block (preview unless --allow-writes)", cliCopyPage},
+		{"truncate", "<file> <npages>", "cut a file down to npages pages (preview unless --allow-writes)", cliTruncate},
+		{"extend", "<file> <npages>", "append npages zeroed pages (preview unless --allow-writes)", cliExtend},
+		{"rebuildlp", "<file> <page> [--write]", "propose (or, with --allow-writes, write) a rebuilt line pointer array", cliRebuildLP},
+		{"map", "<file> [width]", "print one character per page as a minimap of the file", cliMap},
+		{"heatmap", "<file> dead|lsn [width]", "per-page dead tuple density or pd_lsn recency", cliHeatmap},
+		{"exporter", "[--listen ADDR] <file|pgdata> [...]", "serve page-level metrics for Prometheus", cliExporter},
+		{"stats", "[--sample N|P%] <file>", "whole-file statistics, or estimates from a random sample", cliStats},
+		{"fillfactor", "<file> [expected]", "estimate the fillfactor heap pages were packed to", cliFillfactor},
+		{"visibility", "[--mismatches] <file>", "pg_visibility() rows with VM/page mismatches; exit 1 on any", cliVisibility},
+		{"pgstattuple", "<file>", "pgstattuple() fields of a heap or B-tree file, computed offline", cliPgstattuple},
+		{"sidecar", "<file> [...]", "scan files and cache their page summaries for pages/stats/select", cliSidecar},
+		{"export", "<file> <page>", "print one page's decoded structure as JSON", cliExportPage},
+		{"export-json", "<file> [...]", "export all pages of the files as JSON", runExportJSON},
+		{"help", "", "show this help", func([]string) error { printUsage(); return nil }},
+	}
+	for _, sc := range subcommandList {
+		subcommands[sc.name] = sc
+	}
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: pgpageshell [--pg-version N] <command> [args]")
+	fmt.Fprintln(os.Stderr, "       pgpageshell [--pg-version N] [--shell|--export-json] <file> [file2 ...]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, sc := range subcommandList {
+		fmt.Fprintf(os.Stderr, "  %-26s %s\n", sc.name+" "+sc.args, sc.about)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--pg-version N", fmt.Sprintf("decode with the PostgreSQL N layout (%d-%d)", MinPGVersion, LatestPGVersion))
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--maxalign 4|8", "MAXALIGN of the platform that wrote the files (default 8; 4 for 32-bit)")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--pgdata DIR", "name relations <db>/<relfilenode> or <spc>/<db>/<relfilenode>")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--dsn DSN", "fetch catalog values (freezeaudit) with psql from DSN")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--relation NAME", "with --dsn, open the table's files, indexes and TOAST by name")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--offset N, --limit N", "scan only the page range (verify, triage, map, ...)")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--image-offset N", "read a raw disk or image whose relation file starts at byte N (s/K/M/G)")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--image-length N", "stop the raw disk or image N bytes after --image-offset")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--direct-io", "read with O_DIRECT, bypassing the OS page cache (Linux)")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--drop-cache", "drop pages a scan has read from the OS page cache (Linux)")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--decrypt-cmd CMD", "decrypt pages of a TDE cluster with helper CMD before parsing")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--decrypt-key FILE", "key file passed to the helper as PGPS_KEY_FILE")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--live", "retry torn reads of files a running server is writing")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--since-lsn X/X", "scan only pages changed since the LSN (verify, stats, ...)")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--allow-writes", "let rebuildlp --write, settuple, setflag, ... modify files (server stopped!)")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--deterministic", "fixed widths, no color or cache paths, for golden-file tests")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--robust", "clamp corrupt header bounds instead of trusting them")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--type T", "force page type T instead of auto-detecting")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--page N", "shell: load page N at startup")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--schema T,...", "shell: start with this schema (typed btree, GiST, BRIN keys)")
+	fmt.Fprintf(os.Stderr, "  %-26s %s\n", "--once \"<cmd>\"", "shell: run commands (separated by ';') and exit")
+}
+
+// countPages returns the number of whole pages in a data file, warning on
+// stderr if the size is not page aligned.
+func countPages(filename string) (int64, error) {
+	size, err := fileSize(filename)
+	if err != nil {
+		return 0, err
+	}
+	if size%PageSize != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: file size %d is not a multiple of %d\n", size, PageSize)
+	}
+	return size / PageSize, nil
+}
+
+// parsePageNumber parses a page number and checks it against the file size.
+func parsePageNumber(s string, totalPages int64) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 || n >= totalPages {
+		return 0, fmt.Errorf("invalid page number %q (0-%d)", s, totalPages-1)
+	}
+	return n, nil
+}
+
+func cliShell(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: pgpageshell shell <file>")
+	}
+	runShell(args[0])
+	return nil
+}
+
+func cliVerify(args []string) error {
+	args, sr, err := parseScanRange(args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: pgpageshell verify <file> [...]")
+	}
+	failed := 0
+	for _, fn := range args {
+		totalPages, err := countPages(fn)
+		if err != nil {
+			return err
+		}
+		if len(args) > 1 {
+			fmt.Printf("%s:\n", fn)
+		}
+		failed += CmdVerify(fn, totalPages, sr)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d page(s) failed verification", failed)
+	}
+	return nil
+}
+
+func cliManifestVerify(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: pgpageshell manifest-verify <backup_manifest> [backup-dir]")
+	}
+	dir := filepath.Dir(args[0])
+	if len(args) == 2 {
+		dir = args[1]
+	}
+	problems, err := CmdManifestVerify(args[0], dir)
+	if err != nil {
+		return err
+	}
+	if problems > 0 {
+		return fmt.Errorf("%d problem(s) found", problems)
+	}
+	return nil
+}
+
+func cliDiffRel(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: pgpageshell diff-rel <fileA> <fileB>")
+	}
+	pagesA, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	pagesB, err := countPages(args[1])
+	if err != nil {
+		return err
+	}
+	CmdDiffRel(args[0], args[1], pagesA, pagesB)
+	return nil
+}
+
+func cliTriage(args []string) error {
+	args, sr, err := parseScanRange(args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: pgpageshell triage <file>")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	CmdTriage(args[0], totalPages, sr)
+	return nil
+}
+
+func cliXCheck(args []string) error {
+	args, sr, err := parseScanRange(args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("usage: pgpageshell xcheck <index> <heap>")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(args[1]); err != nil {
+		return err
+	}
+	CmdXCheck(args[0], totalPages, sr, args[1])
+	return nil
+}
+
+func cliToastReport(args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		return fmt.Errorf("usage: pgpageshell toastreport <heap> <toast> [types]")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(args[1]); err != nil {
+		return err
+	}
+	var schema []string
+	if len(args) == 3 {
+		if schema, err = ParseSchema(args[2]); err != nil {
+			return err
+		}
+	}
+	CmdToastReport(args[0], totalPages, args[1], schema)
+	return nil
+}
+
+func cliFindTID(args []string) error {
+	args, sr, err := parseScanRange(args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 || len(args) > 3 {
+		return fmt.Errorf("usage: pgpageshell findtid <index> <block> [offset]")
+	}
+	block, offset, err := parseFindTID(args[1:])
+	if err != nil {
+		return err
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	CmdFindTID(args[0], totalPages, sr, block, offset)
+	return nil
+}
+
+func cliIndexCheck(args []string) error {
+	if len(args) != 4 {
+		return fmt.Errorf("usage: pgpageshell indexcheck <index> <heap> <types> <col>")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	schema, err := ParseSchema(args[2])
+	if err != nil {
+		return err
+	}
+	col, err := strconv.Atoi(args[3])
+	if err != nil || col < 1 || col > len(schema) {
+		return fmt.Errorf("invalid column %q (1-%d)", args[3], len(schema))
+	}
+	CmdIndexCheck(args[0], totalPages, args[1], schema, col)
+	return nil
+}
+
+func cliMultiXact(args []string) error {
+	args, sr, err := parseScanRange(args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: pgpageshell multixact <file> [mxid]")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	mxid, err := parseMultiXactArg(args[1:])
+	if err != nil {
+		return err
+	}
+	CmdMultiXact(args[0], totalPages, sr, mxid)
+	return nil
+}
+
+func cliFreezeAudit(args []string) error {
+	args, sr, err := parseScanRange(args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 || len(args) > 3 {
+		return fmt.Errorf("usage: pgpageshell [--dsn DSN] freezeaudit <file> [relfrozenxid [relminmxid]]")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	frozenXid, minMxid, err := freezeLimits(args[0], args[1:])
+	if err != nil {
+		return err
+	}
+	CmdFreezeAudit(args[0], totalPages, sr, frozenXid, minMxid)
+	return nil
+}
+
+func cliRebuildLP(args []string) error {
+	write := len(args) == 3 && args[2] == "--write"
+	if len(args) != 2 && !write {
+		return fmt.Errorf("usage: pgpageshell [--allow-writes] rebuildlp <file> <page> [--write]")
+	}
+	if write && !allowWrites {
+		return fmt.Errorf("rebuildlp --write needs --allow-writes")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	n, err := parsePageNumber(args[1], totalPages)
+	if err != nil {
+		return err
+	}
+	pg, err := ReadPage(args[0], n)
+	if err != nil {
+		return err
+	}
+	if !CmdRebuildLP(args[0], n, pg, write) && write {
+		return fmt.Errorf("page %d not written", n)
+	}
+	return nil
+}
+
+func cliSetTuple(args []string) error {
+	if len(args) < 4 {
+		return fmt.Errorf("usage: pgpageshell [--allow-writes] settuple <file> <page> <item> <field>=<value> ...")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	n, err := parsePageNumber(args[1], totalPages)
+	if err != nil {
+		return err
+	}
+	item, err := strconv.Atoi(args[2])
+	if err != nil {
+		return fmt.Errorf("invalid item number %q", args[2])
+	}
+	pg, err := ReadPage(args[0], n)
+	if err != nil {
+		return err
+	}
+	if !CmdSetTuple(args[0], n, pg, item, args[3:]) && allowWrites {
+		return fmt.Errorf("page %d not written", n)
+	}
+	return nil
+}
+
+func cliSetLP(args []string) error {
+	if len(args) < 4 {
+		return fmt.Errorf("usage: pgpageshell [--allow-writes] setlp <file> <page> <item> offset=N|len=N|flags=NAME ...")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	n, err := parsePageNumber(args[1], totalPages)
+	if err != nil {
+		return err
+	}
+	item, err := strconv.Atoi(args[2])
+	if err != nil {
+		return fmt.Errorf("invalid item number %q", args[2])
+	}
+	pg, err := ReadPage(args[0], n)
+	if err != nil {
+		return err
+	}
+	if !CmdSetLP(args[0], n, pg, item, args[3:]) && allowWrites {
+		return fmt.Errorf("page %d not written", n)
+	}
+	return nil
+}
+
+func cliSetFlag(args []string) error   { return cliPageFlags("setflag", args) }
+func cliClearFlag(args []string) error { return cliPageFlags("clearflag", args) }
+
+func cliPageFlags(cmd string, args []string) error {
+	if len(args) < 3 {
+		return fmt.Errorf("usage: pgpageshell [--allow-writes] %s <file> <page> %s", cmd, pdFlagUsage)
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	n, err := parsePageNumber(args[1], totalPages)
+	if err != nil {
+		return err
+	}
+	pg, err := ReadPage(args[0], n)
+	if err != nil {
+		return err
+	}
+	if !CmdSetPageFlags(args[0], n, pg, cmd == "setflag", strings.Join(args[2:], " ")) && allowWrites {
+		return fmt.Errorf("page %d not written", n)
+	}
+	return nil
+}
+
+func cliClearHints(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: pgpageshell [--allow-writes] clearhints <file> <page>")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	n, err := parsePageNumber(args[1], totalPages)
+	if err != nil {
+		return err
+	}
+	pg, err := ReadPage(args[0], n)
+	if err != nil {
+		return err
+	}
+	if !CmdClearHints(args[0], n, pg) && allowWrites {
+		return fmt.Errorf("page %d not written", n)
+	}
+	return nil
+}
+
+func cliCarve(args []string) error {
+	args, sr, err := parseScanRange(args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: pgpageshell carve <file> [page]")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	first, end := sr.bounds(totalPages)
+	last := end - 1
+	if len(args) == 2 {
+		n, err := parsePageNumber(args[1], totalPages)
+		if err != nil {
+			return err
+		}
+		first, last = n, n
+	}
+	for i := first; i <= last; i++ {
+		pg, err := ReadPage(args[0], i)
+		if err != nil {
+			return err
+		}
+		if first != last && sr.skips(pg) {
+			continue
+		}
+		if first != last {
+			fmt.Printf("\nPage %d:", i)
+		}
+		CmdCarve(pg, absBlockNumber(args[0], i))
+	}
+	return nil
+}
+
+func cliMap(args []string) error {
+	args, sr, err := parseScanRange(args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: pgpageshell map <file> [width]")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	width := mapWidth(screenWidth(), totalPages)
+	if len(args) == 2 {
+		width, err = strconv.Atoi(args[1])
+		if err != nil || width < 1 {
+			return fmt.Errorf("invalid width %q", args[1])
+		}
+	}
+	CmdMap(args[0], totalPages, sr, width)
+	return nil
+}
+
+func cliHeatmap(args []string) error {
+	args, sr, err := parseScanRange(args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 || len(args) > 3 || (args[1] != "dead" && args[1] != "lsn") {
+		return fmt.Errorf("usage: pgpageshell heatmap <file> dead|lsn [width]")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	width := mapWidth(screenWidth(), totalPages)
+	if len(args) == 3 {
+		width, err = strconv.Atoi(args[2])
+		if err != nil || width < 1 {
+			return fmt.Errorf("invalid width %q", args[2])
+		}
+	}
+	color := colorOutput()
+	if args[1] == "lsn" {
+		CmdHeatmapLSN(args[0], totalPages, sr, width, color)
+	} else {
+		CmdHeatmapDead(args[0], totalPages, sr, width, color)
+	}
+	return nil
+}
+
+func cliSidecar(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: pgpageshell sidecar <file> [...]")
+	}
+	if !sidecarUsable() {
+		return fmt.Errorf("sidecar indexes are not used with --live, --direct-io, --decrypt-cmd, --image-offset or --type")
+	}
+	for _, fn := range args {
+		sc, err := writeSidecar(fn)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d pages -> %s\n", fn, sc.Header.Pages, shownPath(sc.Path))
+	}
+	return nil
+}
+
+func cliStats(args []string) error {
+	args, sr, err := parseScanRange(args)
+	if err != nil {
+		return err
+	}
+	args, size, err := cutSampleOption(args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: pgpageshell stats [--sample N|P%%] <file>")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	if size == "" {
+		CmdStats(args[0], totalPages, sr)
+		return nil
+	}
+	n, err := sampleSizeFor(size, totalPages, sr)
+	if err != nil {
+		return err
+	}
+	CmdStatsSample(args[0], totalPages, sr, n)
+	return nil
+}
+
+func cliFillfactor(args []string) error {
+	args, sr, err := parseScanRange(args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: pgpageshell fillfactor <file> [expected]")
+	}
+	expected := 0
+	if len(args) == 2 {
+		expected, err = strconv.Atoi(strings.TrimSuffix(args[1], "%"))
+		if err != nil || expected < 10 || expected > 100 {
+			return fmt.Errorf("invalid fillfactor %q (10-100)", args[1])
+		}
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	CmdFillfactor(args[0], totalPages, sr, expected)
+	return nil
+}
+
+func cliVisibility(args []string) error {
+	args, sr, err := parseScanRange(args)
+	if err != nil {
+		return err
+	}
+	onlyMismatches := len(args) > 0 && args[0] == "--mismatches"
+	if onlyMismatches {
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: pgpageshell visibility [--mismatches] <file>")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	if n := CmdVisibility(args[0], totalPages, sr, onlyMismatches); n > 0 {
+		return fmt.Errorf("%d block(s) with visibility mismatches", n)
+	}
+	return nil
+}
+
+func cliPgstattuple(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: pgpageshell pgstattuple <file>")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	CmdPgstattuple(args[0], totalPages)
+	return nil
+}
+
+func cliExportPage(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: pgpageshell export <file> <page>")
+	}
+	totalPages, err := countPages(args[0])
+	if err != nil {
+		return err
+	}
+	n, err := parsePageNumber(args[1], totalPages)
+	if err != nil {
+		return err
+	}
+	pg, err := ReadPage(args[0], n)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(os.Stdout).Encode(buildPageDetail(pg))
+}
+
+func cliCopyPage(args []string) error {
+	keep := len(args) > 0 && args[0] == "--keep-checksum"
+	if keep {
+		args = args[1:]
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("usage: pgpageshell [--allow-writes] copypage [--keep-checksum] <file>:<page> <file>:<page>")
+	}
+	src, err := parsePageRef(args[0], "")
+	if err != nil {
+		return err
+	}
+	dst, err := parsePageRef(args[1], "")
+	if err != nil {
+		return err
+	}
+	if !CmdCopyPage(src, dst, keep) && allowWrites {
+		return fmt.Errorf("%s not written", dst)
+	}
+	return nil
+}
+
+func cliTruncate(args []string) error { return cliResize("truncate", args) }
+func cliExtend(args []string) error   { return cliResize("extend", args) }
+
+func cliResize(cmd string, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: pgpageshell [--allow-writes] %s <file> <npages>", cmd)
+	}
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid page count %q", args[1])
+	}
+	resized := CmdExtend
+	if cmd == "truncate" {
+		resized = CmdTruncate
+	}
+	if !resized(args[0], n) && allowWrites {
+		return fmt.Errorf("%s not changed", args[0])
+	}
+	return nil
+}
+
+// cliTID converts a ctid to or from ItemPointerData hex and locates its block.
+func cliTID(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: pgpageshell tid <ctid|hex|block> [file]")
+	}
+	filename := ""
+	if len(args) == 2 {
+		filename = args[1]
+	}
+	return CmdTID(args[0], filename)
+}
+
+// cliChecksum prints the checksum PostgreSQL would compute for one page
+// image, read from a file or stdin, so scripts can fix up pages they build
+// or edit. The block number defaults to the page's own for a relation file
+// and to 0 for stdin.
+func cliChecksum(args []string) error {
+	usage := fmt.Errorf("usage: pgpageshell checksum [--block N] <file|-> [page]")
+	block := int64(-1)
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] != "--block" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return usage
+		}
+		i++
+		n, err := strconv.ParseUint(args[i], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid block number %q", args[i])
+		}
+		block = int64(n)
+	}
+	if len(rest) < 1 || len(rest) > 2 || (rest[0] == "-" && len(rest) == 2) {
+		return usage
+	}
+
+	var data [PageSize]byte
+	if rest[0] == "-" {
+		if _, err := io.ReadFull(os.Stdin, data[:]); err != nil {
+			return fmt.Errorf("stdin: %w (a page is %d bytes)", err, PageSize)
+		}
+		block = max(block, 0)
+	} else {
+		totalPages, err := countPages(rest[0])
+		if err != nil {
+			return err
+		}
+		var n int64
+		if len(rest) == 2 {
+			if n, err = parsePageNumber(rest[1], totalPages); err != nil {
+				return err
+			}
+		} else if totalPages == 0 {
+			return fmt.Errorf("%s: no complete page", rest[0])
+		}
+		if data, err = readPageData(rest[0], n); err != nil {
+			return err
+		}
+		if block < 0 {
+			block = int64(absBlockNumber(rest[0], n))
+		}
+	}
+	fmt.Printf("0x%04X\n", PageChecksum(&data, uint32(block)))
+	return nil
+}
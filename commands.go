@@ -3,35 +3,69 @@ package main
 import (
 	"encoding/binary"
 	"fmt"
+	"sort"
 	"strings"
 )
 
-// CmdCat prints a hex dump of the page.
-func CmdCat(p *Page) {
-	for i := 0; i < PageSize; i += 16 {
-		fmt.Printf("%08x: ", i)
-		for j := 0; j < 16; j++ {
-			if j == 8 {
-				fmt.Print(" ")
+// CmdCat prints a hex dump of the page with the given number of bytes per
+// row (16 or 32), grouped in blocks of 8. Bytes set in mark are
+// highlighted: in reverse video when color is true, otherwise bracketed.
+func CmdCat(p *Page, bytesPerRow int, mark []bool, color bool) {
+	marked := func(i int) bool { return mark != nil && i >= 0 && i < PageSize && mark[i] }
+
+	for i := 0; i < PageSize; i += bytesPerRow {
+		var line strings.Builder
+		fmt.Fprintf(&line, "%08x:", i)
+		for j := 0; j < bytesPerRow; j++ {
+			off := i + j
+			// The separator before each byte becomes '[' or ']' at
+			// the edges of a highlighted run when not using color.
+			sep := " "
+			if j > 0 && j%8 == 0 {
+				sep = "  "
 			}
-			fmt.Printf("%02x", p.Data[i+j])
-			if j < 15 {
-				fmt.Print(" ")
+			if !color {
+				switch {
+				case marked(off) && !marked(off-1) || marked(off) && j == 0:
+					sep = sep[:len(sep)-1] + "["
+				case !marked(off) && marked(off-1) && j > 0:
+					sep = "]" + sep[1:]
+				}
 			}
+			line.WriteString(sep)
+			if color && marked(off) {
+				fmt.Fprintf(&line, "\x1b[7m%02x\x1b[0m", p.Data[off])
+			} else {
+				fmt.Fprintf(&line, "%02x", p.Data[off])
+			}
+		}
+		if !color && marked(i+bytesPerRow-1) {
+			line.WriteString("] |")
+		} else {
+			line.WriteString("  |")
 		}
-		fmt.Print("  |")
-		for j := 0; j < 16; j++ {
+		for j := 0; j < bytesPerRow; j++ {
 			b := p.Data[i+j]
+			c := "."
 			if b >= 0x20 && b <= 0x7e {
-				fmt.Printf("%c", b)
-			} else {
-				fmt.Print(".")
+				c = string(rune(b))
+			}
+			if color && marked(i+j) {
+				c = "\x1b[7m" + c + "\x1b[0m"
 			}
+			line.WriteString(c)
 		}
-		fmt.Println("|")
+		line.WriteString("|")
+		fmt.Println(line.String())
 	}
 }
 
+// catLineWidth returns the printed width of one CmdCat row.
+func catLineWidth(bytesPerRow int) int {
+	// offset + hex bytes + group gaps + "  |" + ASCII + "|"
+	return 10 + bytesPerRow*3 - 1 + (bytesPerRow/8 - 1) + 3 + bytesPerRow + 1
+}
+
 // CmdFormat prints an ASCII art visualization of the page layout.
 func CmdFormat(p *Page) {
 	h := &p.Header
@@ -89,6 +123,10 @@ func CmdFormat(p *Page) {
 		region(fmt.Sprintf("Special Space (%s)", p.Detected), tupleEnd, specialEnd)
 	}
 	fmt.Println(bar)
+	if p.IsCorrupt() {
+		fmt.Println()
+		printAnomalies(p)
+	}
 
 	// Proportional view
 	fmt.Println()
@@ -148,21 +186,57 @@ func CmdFormat(p *Page) {
 	fmt.Println()
 }
 
+// Verbosity levels for the info command.
+const (
+	InfoQuiet = iota
+	InfoNormal
+	InfoVerbose
+)
+
 // CmdInfo prints human-readable header and special region information.
-func CmdInfo(p *Page) {
+// InfoQuiet prints a compact summary instead; InfoVerbose adds raw header
+// bytes, derived region offsets and the page type detection reasoning.
+// CmdInfo prints the page header and the decoded special region; with
+// rawSpecial the special region is also hex dumped, so the decoded fields
+// can be checked against the bytes when the page is suspect.
+func CmdInfo(p *Page, verbosity int, rawSpecial bool) {
+	if verbosity == InfoQuiet {
+		printInfoQuiet(p)
+		if rawSpecial {
+			printRawSpecial(p)
+			fmt.Println()
+		}
+		return
+	}
 	h := &p.Header
 
 	fmt.Println()
-	fmt.Printf("=== Page Header (detected type: %s) ===\n", p.Detected)
+	if p.IsOverridden() {
+		fmt.Printf("=== Page Header (type: %s, forced; auto-detected: %s) ===\n", p.TypeLabel(), p.AutoDetected)
+	} else {
+		fmt.Printf("=== Page Header (detected type: %s) ===\n", p.TypeLabel())
+	}
 	fmt.Printf("  pd_lsn             : %X/%08X\n", h.LSN>>32, h.LSN&0xFFFFFFFF)
-	fmt.Printf("  pd_checksum        : 0x%04X (%d)\n", h.Checksum, h.Checksum)
+	fmt.Printf("  pd_checksum        : 0x%04X (%d)", h.Checksum, h.Checksum)
+	if note := checksumNote(p); note != "" {
+		fmt.Printf(" - %s", note)
+	}
+	fmt.Println()
 	fmt.Printf("  pd_flags           : 0x%04X [%s]\n", h.Flags, FlagsString(h.Flags))
-	fmt.Printf("  pd_lower           : %d (0x%04X)\n", h.Lower, h.Lower)
-	fmt.Printf("  pd_upper           : %d (0x%04X)\n", h.Upper, h.Upper)
-	fmt.Printf("  pd_special         : %d (0x%04X)\n", h.Special, h.Special)
-	fmt.Printf("  pd_pagesize_version: 0x%04X (size: %d, version: %d)\n",
-		h.PageSizeVer, h.PageSz(), h.LayoutVersion())
+	raw := &p.RawHeader
+	clamped := func(rawV, v uint16) string {
+		if rawV == v {
+			return ""
+		}
+		return fmt.Sprintf(" -> clamped to %d", v)
+	}
+	fmt.Printf("  pd_lower           : %d (0x%04X)%s\n", raw.Lower, raw.Lower, clamped(raw.Lower, h.Lower))
+	fmt.Printf("  pd_upper           : %d (0x%04X)%s\n", raw.Upper, raw.Upper, clamped(raw.Upper, h.Upper))
+	fmt.Printf("  pd_special         : %d (0x%04X)%s\n", raw.Special, raw.Special, clamped(raw.Special, h.Special))
+	fmt.Printf("  pd_pagesize_version: 0x%04X (size: %d, version: %d)%s\n",
+		raw.PageSizeVer, raw.PageSz(), raw.LayoutVersion(), clamped(raw.PageSizeVer, h.PageSizeVer))
 	fmt.Printf("  pd_prune_xid       : %d\n", h.PruneXID)
+	printAnomalies(p)
 
 	numItems := 0
 	if h.Lower > PageHeaderSize {
@@ -178,6 +252,21 @@ func CmdInfo(p *Page) {
 	fmt.Printf("  Line pointers      : %d\n", numItems)
 	fmt.Printf("  Free space         : %d bytes\n", freeSpace)
 	fmt.Printf("  Special space size : %d bytes\n", p.SpecialSize())
+	if liveReads {
+		switch {
+		case p.Unstable:
+			fmt.Printf("  Live read          : UNSTABLE, still changing after %d reads (torn read, contents unreliable)\n", p.ReadAttempts)
+		case p.ReadAttempts > 1:
+			fmt.Printf("  Live read          : consistent after %d reads\n", p.ReadAttempts)
+		default:
+			fmt.Println("  Live read          : consistent")
+		}
+	}
+	printDetection(p)
+
+	if verbosity == InfoVerbose {
+		printInfoVerbose(p)
+	}
 
 	// Decode special region based on detected type
 	fmt.Println()
@@ -190,31 +279,34 @@ func CmdInfo(p *Page) {
 		fmt.Printf("  Size: %d bytes at offset %d\n", p.SpecialSize(), h.Special)
 		fmt.Println()
 
-		switch p.Detected {
-		case PageTypeBTree:
+		switch {
+		case p.Template != nil:
+			DecodeTemplateSpecial(p)
+		case p.Detected == PageTypeBTree:
 			DecodeBTreeSpecial(special)
+			DecodeBTreeDeleted(p)
 			// If meta page, also decode meta content
 			btFlags := binary.LittleEndian.Uint16(special[12:14])
 			if btFlags&BTPMeta != 0 {
 				DecodeBTreeMeta(p)
 			}
-		case PageTypeHash:
+		case p.Detected == PageTypeHash:
 			DecodeHashSpecial(special)
 			hashFlag := binary.LittleEndian.Uint16(special[12:14])
 			if hashFlag&LHMetaPage != 0 {
 				DecodeHashMeta(p)
 			}
-		case PageTypeGiST:
+		case p.Detected == PageTypeGiST:
 			DecodeGiSTSpecial(special)
-		case PageTypeGIN:
+		case p.Detected == PageTypeGIN:
 			DecodeGINSpecial(special)
 			ginFlags := binary.LittleEndian.Uint16(special[6:8])
 			if ginFlags&GINMeta != 0 {
 				DecodeGINMeta(p)
 			}
-		case PageTypeSPGiST:
+		case p.Detected == PageTypeSPGiST:
 			DecodeSPGiSTSpecial(special)
-		case PageTypeBRIN:
+		case p.Detected == PageTypeBRIN:
 			DecodeBRINSpecial(special)
 			brinType := binary.LittleEndian.Uint16(special[6:8])
 			if brinType == BRINPageTypeMeta {
@@ -231,27 +323,146 @@ func CmdInfo(p *Page) {
 			}
 			fmt.Println()
 		}
+		if rawSpecial {
+			printRawSpecial(p)
+		}
 	}
 	fmt.Println()
 }
 
+// printRawSpecial hex dumps the special region at its page offsets.
+func printRawSpecial(p *Page) {
+	special := p.SpecialData()
+	fmt.Println()
+	fmt.Println("=== Special Region (raw) ===")
+	if len(special) == 0 {
+		fmt.Println("  (empty)")
+		return
+	}
+	printHexBlock(special, int(p.Header.Special), "  ")
+}
+
+func printInfoVerbose(p *Page) {
+	h := &p.Header
+	pageSize := int(h.PageSz())
+	if pageSize == 0 {
+		pageSize = PageSize
+	}
+
+	fmt.Println()
+	fmt.Println("=== Raw Header Bytes ===")
+	for _, f := range pageHeaderFields {
+		fmt.Printf("  [%2d-%2d] %-20s: % x\n", f.Start, f.End-1, f.Name, p.Data[f.Start:f.End])
+	}
+
+	fmt.Println()
+	fmt.Println("=== Derived Offsets ===")
+	region := func(name string, start, end int) {
+		if end < start {
+			fmt.Printf("  %-18s : [%5d - %5d)  INVALID (end before start)\n", name, start, end)
+			return
+		}
+		fmt.Printf("  %-18s : [%5d - %5d)  %5d bytes\n", name, start, end, end-start)
+	}
+	region("Page header", 0, PageHeaderSize)
+	region("Line pointers", PageHeaderSize, int(h.Lower))
+	region("Free space", int(h.Lower), int(h.Upper))
+	region("Tuple area", int(h.Upper), int(h.Special))
+	region("Special space", int(h.Special), pageSize)
+
+	fmt.Println()
+	fmt.Println("=== Detection ===")
+	for _, r := range p.DetectionReasons() {
+		fmt.Printf("  - %s\n", r)
+	}
+}
+
+// printInfoQuiet prints a compact summary of the page header and special
+// region, a few lines per page.
+func printInfoQuiet(p *Page) {
+	h := &p.Header
+	freeSpace := 0
+	if h.Upper > h.Lower {
+		freeSpace = int(h.Upper - h.Lower)
+	}
+	kind := p.TypeLabel()
+	if sub := detectPageSubtype(p); sub != "" {
+		kind += " " + sub
+	}
+	fmt.Printf("page %d: %s  lsn=%X/%08X  checksum=0x%04X  flags=%s\n",
+		p.PageNum, kind, h.LSN>>32, h.LSN&0xFFFFFFFF, h.Checksum, FlagsString(h.Flags))
+	fmt.Printf("  lower=%d upper=%d special=%d  items=%d  free=%d\n",
+		h.Lower, h.Upper, h.Special, len(p.Items), freeSpace)
+	if p.IsCorrupt() {
+		fmt.Printf("  anomalies: %s\n", p.AnnotationSummary())
+	}
+
+	info := buildSpecialInfo(p, detectPageSubtype(p))
+	if len(info) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(info))
+	for k := range info {
+		if k != "size" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + info[k]
+	}
+	fmt.Printf("  special: %s\n", strings.Join(pairs, " "))
+}
+
+// printAnomalies prints the annotations recorded when the page was read.
+func printAnomalies(p *Page) {
+	for _, a := range p.Annotations {
+		fmt.Printf("  %s: %s\n", annotationLabels[a.Kind], a)
+	}
+	if !robustParsing && len(p.Anomalies(AnnotationHeader)) > 0 {
+		fmt.Println("  (decoding uses the raw header values; 'set robust on' clamps them)")
+	}
+}
+
+// corruptNote summarizes a page's anomalies for one-line status messages.
+func corruptNote(p *Page) string {
+	if p.Encrypted {
+		return liveNote(p) + fmt.Sprintf(", entropy %.2f bits/byte: looks encrypted, see info", p.Entropy())
+	}
+	if !p.IsCorrupt() {
+		return liveNote(p)
+	}
+	return liveNote(p) + fmt.Sprintf(", CORRUPT: %s anomalies, see info", p.AnnotationSummary())
+}
+
 // CmdData prints item pointers and tuple data with metadata.
-func CmdData(p *Page) {
+func CmdData(p *Page, schema []string) {
 	h := &p.Header
 	isIndex := p.Detected != PageTypeHeap && p.Detected != PageTypeUnknown
 
-	fmt.Println()
-	fmt.Printf("=== Line Pointers (Item IDs) [page type: %s] ===\n", p.Detected)
-	fmt.Printf("  %-6s %-8s %-10s %-8s %-8s\n", "Index", "Status", "Offset", "Length", "Raw")
-	fmt.Printf("  %-6s %-8s %-10s %-8s %-8s\n", "-----", "--------", "----------", "--------", "--------")
+	// GIN posting tree pages keep their data up to pd_lower, not line
+	// pointers.
+	noItems := p.Detected == PageTypeGIN && strings.HasPrefix(detectPageSubtype(p), "data-")
 
-	for i, lp := range p.Items {
-		fmt.Printf("  %-6d %-8s %-10d %-8d 0x%08X\n",
-			i+1, lp.FlagsStr(), lp.Offset(), lp.Length(), lp.Raw)
+	fmt.Println()
+	fmt.Printf("=== Line Pointers (Item IDs) [page type: %s] ===\n", p.TypeLabel())
+	if noItems {
+		fmt.Println("  (GIN posting tree page: no line pointers, pd_lower ends the posting data)")
+	} else {
+		fmt.Printf("  %-6s %-8s %-10s %-8s %-8s\n", "Index", "Status", "Offset", "Length", "Raw")
+		fmt.Printf("  %-6s %-8s %-10s %-8s %-8s\n", "-----", "--------", "----------", "--------", "--------")
+		for i, lp := range p.Items {
+			fmt.Printf("  %-6d %-8s %-10d %-8d 0x%08X\n",
+				i+1, lp.FlagsStr(), lp.Offset(), lp.Length(), lp.Raw)
+		}
+		printAnomalies(p)
 	}
 
-	if isIndex {
-		printIndexTuples(p)
+	if p.Template != nil && len(p.Template.Tuple) > 0 {
+		printTemplateTuples(p)
+	} else if isIndex {
+		printIndexTuples(p, schema)
 	} else {
 		printHeapTuples(p)
 	}
@@ -272,9 +483,11 @@ func CmdData(p *Page) {
 			redirect++
 		}
 	}
-	fmt.Printf("  Total line pointers: %d\n", len(p.Items))
-	fmt.Printf("  NORMAL: %d, DEAD: %d, UNUSED: %d, REDIRECT: %d\n",
-		normal, dead, unused, redirect)
+	if !noItems {
+		fmt.Printf("  Total line pointers: %d\n", len(p.Items))
+		fmt.Printf("  NORMAL: %d, DEAD: %d, UNUSED: %d, REDIRECT: %d\n",
+			normal, dead, unused, redirect)
+	}
 	freeSpace := 0
 	if h.Upper > h.Lower {
 		freeSpace = int(h.Upper - h.Lower)
@@ -323,8 +536,16 @@ func printHeapTuples(p *Page) {
 			fmt.Print(" (INVALID)")
 		}
 		fmt.Println()
-		fmt.Printf("    t_cid        : %d\n", t.Field3)
-		fmt.Printf("    t_ctid       : (%d, %d)\n", t.CtidBlock, t.CtidOffset)
+		if t.IsMovedByVacuum() {
+			fmt.Printf("    t_xvac       : %d (XID of the VACUUM FULL that moved this tuple)\n", t.Field3)
+			fmt.Println("    WARNING: MOVED_OFF/MOVED_IN set - pre-9.0 VACUUM FULL layout (pg_upgraded data?)")
+		} else {
+			fmt.Printf("    t_cid        : %d\n", t.Field3)
+		}
+		fmt.Printf("    t_ctid       : %s\n", t.CtidStr())
+		if t.IsSpeculative() {
+			fmt.Println("    NOTE: INSERT ... ON CONFLICT still in progress or aborted; not yet a confirmed row")
+		}
 		fmt.Printf("    t_infomask2  : 0x%04X (natts: %d", t.Infomask2, t.NAttrs())
 		if flags := t.Infomask2Flags(); len(flags) > 0 {
 			fmt.Printf(", %s", strings.Join(flags, " | "))
@@ -369,7 +590,9 @@ func printHeapTuples(p *Page) {
 	}
 }
 
-func printIndexTuples(p *Page) {
+// printIndexTuples prints the index tuples of p; with a schema (the key
+// column types) btree keys and SP-GiST prefixes and labels are decoded too.
+func printIndexTuples(p *Page, schema []string) {
 	fmt.Println()
 	fmt.Printf("=== Index Tuples (%s) ===\n", p.Detected)
 
@@ -381,6 +604,19 @@ func printIndexTuples(p *Page) {
 		return
 	}
 
+	if isSPGistLeaf(p) {
+		printSPGistLeafTuples(p)
+		return
+	}
+	if isSPGistInner(p) {
+		printSPGistInnerTuples(p, schema)
+		return
+	}
+	if p.Detected == PageTypeGIN && detectPageSubtype(p) == "data-leaf" {
+		printGINDataLeaf(p)
+		return
+	}
+
 	for i, lp := range p.Items {
 		fmt.Printf("\n--- Item %d (offset %d, length %d) ---\n", i+1, lp.Offset(), lp.Length())
 
@@ -417,12 +653,19 @@ func printIndexTuples(p *Page) {
 		it := p.ParseIndexTupleHeader(lp.Offset())
 
 		fmt.Println("  Index Tuple Header (IndexTupleData):")
-		fmt.Printf("    t_tid        : (%d, %d)  -> heap ctid\n", it.TidBlock, it.TidOffset)
+		tidLines := []string{fmt.Sprintf("t_tid        : (%d, %d)  -> heap ctid", it.TidBlock, it.TidOffset)}
+		if p.Detected == PageTypeBTree {
+			tidLines = btreeTIDLines(p, i, it, lp, len(schema))
+		}
+		fmt.Printf("    %s\n", tidLines[0])
 		fmt.Printf("    t_info       : 0x%04X (size: %d", it.Info, it.Size())
 		if flags := it.InfoFlags(); len(flags) > 0 {
 			fmt.Printf(", %s", strings.Join(flags, " | "))
 		}
 		fmt.Println(")")
+		for _, l := range tidLines[1:] {
+			fmt.Printf("    %s\n", l)
+		}
 
 		// Key data follows the 8-byte header (possibly with null bitmap)
 		keyStart := int(lp.Offset()) + IndexTupleHdrSize
@@ -432,11 +675,26 @@ func printIndexTuples(p *Page) {
 		}
 		keyLen := keyEnd - keyStart
 
-		if it.HasNulls() {
-			// Null bitmap is right after the header, before key data
-			// For index tuples, bitmap size depends on number of index columns
-			// We don't know the column count, so just note it
-			fmt.Println("    (has null bitmap before key data)")
+		if it.HasNulls() && keyStart+indexNullBitmapSize <= keyEnd {
+			natts, from := len(schema), "schema"
+			if natts == 0 {
+				natts, from = pageIndexNatts(p), "at least, from the null bitmaps on this page"
+			}
+			fmt.Printf("    %s\n", nullBitmapLine(p.Data[keyStart:keyStart+indexNullBitmapSize], natts, from))
+			keyStart = min(int(lp.Offset())+indexDataOff(true), keyEnd)
+			keyLen = keyEnd - keyStart
+		}
+		if p.Detected == PageTypeBTree && len(schema) > 0 {
+			printBTreeKeys(p, lp, schema)
+		}
+		if p.Detected == PageTypeGiST && len(schema) > 0 {
+			keys, err := gistTupleKeys(p, lp, schema)
+			if len(keys) > 0 {
+				fmt.Printf("    Keys         : (%s)\n", strings.Join(keys, ", "))
+			}
+			if err != nil {
+				fmt.Printf("    Keys         : cannot decode %v\n", err)
+			}
 		}
 
 		if keyLen > 0 {
@@ -452,6 +710,26 @@ func printIndexTuples(p *Page) {
 	}
 }
 
+// printBTreeKeys prints the decoded key columns of a btree tuple as a row,
+// as SELECT would show the indexed values.
+func printBTreeKeys(p *Page, lp ItemId, schema []string) {
+	keys, truncated, err := btreeTupleKeys(p, lp, schema)
+	switch {
+	case len(keys) == 0 && truncated:
+		fmt.Println("    Keys         : -inf (no key columns: first item of an internal page)")
+		return
+	case len(keys) > 0:
+		fmt.Printf("    Keys         : (%s)", strings.Join(keys, ", "))
+		if truncated && err == nil {
+			fmt.Printf(" (%d of %d columns, the rest truncated)", len(keys), len(schema))
+		}
+		fmt.Println()
+	}
+	if err != nil {
+		fmt.Printf("    Keys         : cannot decode %v\n", err)
+	}
+}
+
 // isMeta checks if the current page is a meta page for its index type.
 func isMeta(p *Page) bool {
 	special := p.SpecialData()
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chzyer/readline"
+)
+
+// Config holds user preferences read from the config file. Each line has
+// the form "key = value"; blank lines and lines starting with '#' are
+// ignored.
+type Config struct {
+	InfoVerbosity int
+	Robust        bool // clamp corrupt header bounds while parsing
+
+	// Line editing
+	EditMode    string        // "emacs" or "vi"
+	HistorySize int           // entries kept in the history file
+	Bindings    map[rune]rune // control key -> key of the bound readline action
+
+	// Special-area layouts of unknown access methods, by name
+	Templates map[string]*specialTemplate
+}
+
+// DefaultConfig returns the settings used when no config file exists.
+func DefaultConfig() Config {
+	return Config{
+		InfoVerbosity: InfoNormal,
+		EditMode:      "emacs",
+		HistorySize:   500,
+		Bindings:      map[rune]rune{},
+	}
+}
+
+// bindActions maps the editing actions a control key can be bound to onto
+// the control key readline handles them with by default.
+var bindActions = map[string]rune{
+	"line-start":     readline.CharLineStart,
+	"line-end":       readline.CharLineEnd,
+	"backward":       readline.CharBackward,
+	"forward":        readline.CharForward,
+	"delete":         readline.CharDelete,
+	"kill-line":      readline.CharKill,
+	"kill-to-start":  readline.CharCtrlU,
+	"kill-word":      readline.CharCtrlW,
+	"yank":           readline.CharCtrlY,
+	"transpose":      readline.CharTranspose,
+	"history-prev":   readline.CharPrev,
+	"history-next":   readline.CharNext,
+	"search-back":    readline.CharBckSearch,
+	"search-forward": readline.CharFwdSearch,
+	"clear-screen":   readline.CharCtrlL,
+	"complete":       readline.CharTab,
+}
+
+// parseCtrlKey parses a key name of the form "C-x" into its control rune.
+func parseCtrlKey(s string) (rune, error) {
+	if len(s) == 3 && (s[0] == 'C' || s[0] == 'c') && s[1] == '-' {
+		c := s[2] | 0x20
+		if c >= 'a' && c <= 'z' {
+			return rune(c - 'a' + 1), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid key %q (expected C-a .. C-z)", s)
+}
+
+func ctrlKeyName(r rune) string { return fmt.Sprintf("C-%c", 'a'+r-1) }
+
+// FilterInputRune applies the configured key bindings; it is installed as
+// readline's FuncFilterInputRune.
+func (c *Config) FilterInputRune(r rune) (rune, bool) {
+	if to, ok := c.Bindings[r]; ok {
+		return to, true
+	}
+	return r, true
+}
+
+// configPath returns the location of the config file
+// ($XDG_CONFIG_HOME/pgpageshell/config or the platform equivalent).
+func configPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pgpageshell", "config")
+}
+
+// LoadConfig reads the config file, falling back to defaults for missing
+// keys. A missing file is not an error.
+func LoadConfig() (Config, error) {
+	cfg := DefaultConfig()
+	path := configPath()
+	if path == "" {
+		return cfg, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return cfg, fmt.Errorf("%s:%d: expected key = value", path, lineNo)
+		}
+		if err := cfg.Set(strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+			return cfg, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+	}
+	return cfg, sc.Err()
+}
+
+// Set assigns a single config key.
+func (c *Config) Set(key, value string) error {
+	switch key {
+	case "info_verbosity":
+		v, err := parseVerbosity(value)
+		if err != nil {
+			return err
+		}
+		c.InfoVerbosity = v
+	case "robust":
+		switch value {
+		case "on", "true", "1":
+			c.Robust = true
+		case "off", "false", "0":
+			c.Robust = false
+		default:
+			return fmt.Errorf("invalid robust %q (on, off)", value)
+		}
+	case "edit_mode":
+		if value != "vi" && value != "emacs" {
+			return fmt.Errorf("invalid edit_mode %q (vi, emacs)", value)
+		}
+		c.EditMode = value
+	case "history_size":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid history_size %q", value)
+		}
+		c.HistorySize = n
+	default:
+		if k, ok := strings.CutPrefix(key, "bind."); ok {
+			return c.bind(k, value)
+		}
+		if k, ok := strings.CutPrefix(key, "special."); ok {
+			return c.setTemplate(k, value)
+		}
+		return fmt.Errorf("unknown config key %q", key)
+	}
+	return nil
+}
+
+// bind binds control key k to a readline action, or restores the default
+// behaviour of k if action is "default".
+func (c *Config) bind(k, action string) error {
+	r, err := parseCtrlKey(k)
+	if err != nil {
+		return err
+	}
+	if action == "default" {
+		delete(c.Bindings, r)
+		return nil
+	}
+	to, ok := bindActions[action]
+	if !ok {
+		names := make([]string, 0, len(bindActions))
+		for name := range bindActions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown action %q (%s, default)", action, strings.Join(names, ", "))
+	}
+	c.Bindings[r] = to
+	return nil
+}
+
+// Settings lists the current settings as key/value pairs in the form
+// accepted by Set.
+func (c *Config) Settings() [][2]string {
+	verbosity := map[int]string{InfoQuiet: "quiet", InfoNormal: "normal", InfoVerbose: "verbose"}
+	settings := [][2]string{
+		{"info_verbosity", verbosity[c.InfoVerbosity]},
+		{"robust", map[bool]string{false: "off", true: "on"}[c.Robust]},
+		{"edit_mode", c.EditMode},
+		{"history_size", strconv.Itoa(c.HistorySize)},
+	}
+	actionNames := map[rune]string{}
+	for name, r := range bindActions {
+		actionNames[r] = name
+	}
+	keys := make([]rune, 0, len(c.Bindings))
+	for k := range c.Bindings {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for _, k := range keys {
+		settings = append(settings, [2]string{"bind." + ctrlKeyName(k), actionNames[c.Bindings[k]]})
+	}
+	return append(settings, c.templateSettings()...)
+}
+
+func parseVerbosity(s string) (int, error) {
+	switch s {
+	case "quiet", "q":
+		return InfoQuiet, nil
+	case "normal":
+		return InfoNormal, nil
+	case "verbose", "v":
+		return InfoVerbose, nil
+	}
+	return 0, fmt.Errorf("invalid verbosity %q (quiet, normal, verbose)", s)
+}
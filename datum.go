@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Datum is a decoded column value for the small set of built-in types
+// pgpageshell knows how to interpret. Types without a natural integer
+// form (inet, ranges, point, box) keep their output text in Text.
+type Datum struct {
+	Type string
+	Int  int64
+	Text string
+}
+
+// String formats the value the way psql prints it (text quoted).
+func (d Datum) String() string {
+	switch d.Type {
+	case "text":
+		return fmt.Sprintf("%q", d.Text)
+	case "inet", "cidr", "point", "box", "int4range", "int8range", "daterange", "tsrange", "tstzrange":
+		return d.Text
+	case "bool":
+		if d.Int != 0 {
+			return "t"
+		}
+		return "f"
+	case "date":
+		switch d.Int {
+		case math.MinInt32:
+			return "-infinity"
+		case math.MaxInt32:
+			return "infinity"
+		}
+		return pgEpoch.AddDate(0, 0, int(d.Int)).Format("2006-01-02")
+	case "timestamp", "timestamptz":
+		switch d.Int {
+		case math.MinInt64:
+			return "-infinity"
+		case math.MaxInt64:
+			return "infinity"
+		}
+		s := time.UnixMicro(pgEpoch.UnixMicro() + d.Int).UTC().Format("2006-01-02 15:04:05.999999")
+		if d.Type == "timestamptz" {
+			s += "+00"
+		}
+		return s
+	}
+	return fmt.Sprintf("%d", d.Int)
+}
+
+// pgEpoch is the zero point of PostgreSQL dates and timestamps.
+var pgEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// knownTypes lists the type names accepted by DecodeDatum.
+var knownTypes = []string{"int2", "int4", "int8", "oid", "bool", "date", "timestamp", "timestamptz", "text",
+	"inet", "cidr", "point", "box", "int4range", "int8range", "daterange", "tsrange", "tstzrange"}
+
+// orderedTypes are the known types CompareDatums orders as their btree
+// opclass does (text only under the "C" collation).
+var orderedTypes = []string{"int2", "int4", "int8", "oid", "bool", "date", "timestamp", "timestamptz", "text"}
+
+// rangeSubtypes maps the known range types to their element type.
+var rangeSubtypes = map[string]string{
+	"int4range": "int4", "int8range": "int8", "daterange": "date", "tsrange": "timestamp", "tstzrange": "timestamptz",
+}
+
+// isVarlenaType reports whether values of typ are stored as varlenas.
+func isVarlenaType(typ string) bool {
+	return typ == "text" || typ == "inet" || typ == "cidr" || rangeSubtypes[typ] != ""
+}
+
+func isKnownType(typ string) bool {
+	for _, t := range knownTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeDatum decodes a single value of the given type from the start
+// of data. It returns the decoded value and the number of bytes consumed.
+func DecodeDatum(typ string, data []byte) (Datum, int, error) {
+	le := binary.LittleEndian
+	switch typ {
+	case "int2":
+		if len(data) < 2 {
+			return Datum{}, 0, fmt.Errorf("int2 needs 2 bytes, have %d", len(data))
+		}
+		return Datum{Type: typ, Int: int64(int16(le.Uint16(data)))}, 2, nil
+	case "int4", "date":
+		if len(data) < 4 {
+			return Datum{}, 0, fmt.Errorf("%s needs 4 bytes, have %d", typ, len(data))
+		}
+		return Datum{Type: typ, Int: int64(int32(le.Uint32(data)))}, 4, nil
+	case "oid":
+		if len(data) < 4 {
+			return Datum{}, 0, fmt.Errorf("oid needs 4 bytes, have %d", len(data))
+		}
+		return Datum{Type: typ, Int: int64(le.Uint32(data))}, 4, nil
+	case "int8", "timestamp", "timestamptz":
+		if len(data) < 8 {
+			return Datum{}, 0, fmt.Errorf("%s needs 8 bytes, have %d", typ, len(data))
+		}
+		return Datum{Type: typ, Int: int64(le.Uint64(data))}, 8, nil
+	case "bool":
+		if len(data) < 1 {
+			return Datum{}, 0, fmt.Errorf("bool needs 1 byte, have 0")
+		}
+		return Datum{Type: typ, Int: int64(data[0])}, 1, nil
+	case "text":
+		payload, n, err := varlenaPayload(data)
+		if err != nil {
+			return Datum{}, 0, err
+		}
+		return Datum{Type: typ, Text: string(payload)}, n, nil
+	case "inet", "cidr":
+		payload, n, err := varlenaPayload(data)
+		if err != nil {
+			return Datum{}, 0, err
+		}
+		text, err := inetString(payload, typ == "cidr")
+		return Datum{Type: typ, Text: text}, n, err
+	case "point":
+		if len(data) < 16 {
+			return Datum{}, 0, fmt.Errorf("point needs 16 bytes, have %d", len(data))
+		}
+		f := func(i int) string {
+			return strconv.FormatFloat(math.Float64frombits(le.Uint64(data[8*i:])), 'g', -1, 64)
+		}
+		return Datum{Type: typ, Text: fmt.Sprintf("(%s,%s)", f(0), f(1))}, 16, nil
+	case "box":
+		if len(data) < 32 {
+			return Datum{}, 0, fmt.Errorf("box needs 32 bytes, have %d", len(data))
+		}
+		f := func(i int) string {
+			return strconv.FormatFloat(math.Float64frombits(le.Uint64(data[8*i:])), 'g', -1, 64)
+		}
+		return Datum{Type: typ, Text: fmt.Sprintf("(%s,%s),(%s,%s)", f(0), f(1), f(2), f(3))}, 32, nil
+	case "int4range", "int8range", "daterange", "tsrange", "tstzrange":
+		payload, n, err := varlenaPayload(data)
+		if err != nil {
+			return Datum{}, 0, err
+		}
+		text, err := rangeString(payload, rangeSubtypes[typ])
+		return Datum{Type: typ, Text: text}, n, err
+	}
+	return Datum{}, 0, fmt.Errorf("unsupported type %q", typ)
+}
+
+// inetString formats the payload of an inet or cidr varlena (inet_struct:
+// family, bits, address) as inet_out and cidr_out do.
+func inetString(payload []byte, cidr bool) (string, error) {
+	if len(payload) < 2 {
+		return "", fmt.Errorf("truncated inet")
+	}
+	family, bits, addr := payload[0], int(payload[1]), payload[2:]
+	size := map[byte]int{2: 4, 3: 16}[family] // PGSQL_AF_INET, PGSQL_AF_INET6
+	if size == 0 || len(addr) < size || bits > size*8 {
+		return "", fmt.Errorf("bad inet (family %d, %d bits, %d address bytes)", family, bits, len(addr))
+	}
+	s := net.IP(addr[:size]).String()
+	if cidr || bits != size*8 {
+		s += "/" + strconv.Itoa(bits)
+	}
+	return s, nil
+}
+
+// Range flag bits stored in the last byte of a RangeType (rangetypes.h).
+const (
+	rangeEmpty = 0x01
+	rangeLBInc = 0x02
+	rangeUBInc = 0x04
+	rangeLBInf = 0x08
+	rangeUBInf = 0x10
+)
+
+// rangeString formats the payload of a range varlena (range type OID,
+// bounds of type sub, flags byte) as range_out does.
+func rangeString(payload []byte, sub string) (string, error) {
+	if len(payload) < 5 {
+		return "", fmt.Errorf("truncated range")
+	}
+	flags := payload[len(payload)-1]
+	if flags&rangeEmpty != 0 {
+		return "empty", nil
+	}
+	// Bounds follow the 4-byte header and OID, aligned as in the
+	// unpacked value, whatever header the varlena was stored with.
+	const hdr = 4
+	data := make([]byte, hdr+len(payload)-1)
+	copy(data[hdr:], payload[:len(payload)-1])
+	off := hdr + 4
+	bound := func(present bool) (string, error) {
+		if !present {
+			return "", nil
+		}
+		d, next, err := DecodeDatumAt(sub, data, off)
+		off = next
+		s := d.String()
+		if strings.ContainsAny(s, " ,") {
+			s = `"` + s + `"`
+		}
+		return s, err
+	}
+	lo, err := bound(flags&rangeLBInf == 0)
+	if err != nil {
+		return "", err
+	}
+	hi, err := bound(flags&rangeUBInf == 0)
+	if err != nil {
+		return "", err
+	}
+	lb, ub := "(", ")"
+	if flags&rangeLBInc != 0 {
+		lb = "["
+	}
+	if flags&rangeUBInc != 0 {
+		ub = "]"
+	}
+	return lb + lo + "," + hi + ub, nil
+}
+
+// varlenaPayload returns the inline payload of an uncompressed varlena
+// and the total size including its header.
+func varlenaPayload(data []byte) ([]byte, int, error) {
+	if len(data) == 0 {
+		return nil, 0, fmt.Errorf("empty varlena")
+	}
+	b := data[0]
+	switch {
+	case b == 0x01:
+		return nil, 0, fmt.Errorf("external TOAST pointer")
+	case b&0x01 == 0x01:
+		// 1-byte header: length includes the header byte
+		n := int(b >> 1)
+		if n < 1 || n > len(data) {
+			return nil, 0, fmt.Errorf("bad short varlena length %d", n)
+		}
+		return data[1:n], n, nil
+	case b&0x03 == 0x02:
+		return nil, 0, fmt.Errorf("compressed varlena")
+	default:
+		if len(data) < 4 {
+			return nil, 0, fmt.Errorf("truncated varlena header")
+		}
+		n := int(binary.LittleEndian.Uint32(data) >> 2)
+		if n < 4 || n > len(data) {
+			return nil, 0, fmt.Errorf("bad varlena length %d", n)
+		}
+		return data[4:n], n, nil
+	}
+}
+
+// CompareDatums orders two datums of the same type. Text is compared
+// bytewise, which matches the "C" collation only; types kept as output
+// text (inet, ranges, box) compare by it, which only tells equality.
+func CompareDatums(a, b Datum) int {
+	if isVarlenaType(a.Type) || a.Type == "box" {
+		return bytes.Compare([]byte(a.Text), []byte(b.Text))
+	}
+	switch {
+	case a.Int < b.Int:
+		return -1
+	case a.Int > b.Int:
+		return 1
+	}
+	return 0
+}
+
+// typeAlign returns the typalign of a known type in bytes.
+func typeAlign(typ string) int {
+	switch typ {
+	case "bool":
+		return 1
+	case "int2":
+		return 2
+	case "int8", "timestamp", "timestamptz", "point", "box":
+		return maxAlignment
+	}
+	return 4
+}
+
+// DecodeDatumAt decodes a value stored at offset off of a tuple's data
+// area, applying the type's alignment the way heap_fill_tuple lays values
+// out. Short (1-byte header) varlenas are stored unaligned. It returns the
+// value and the offset just past it.
+func DecodeDatumAt(typ string, data []byte, off int) (Datum, int, error) {
+	if !isVarlenaType(typ) || off >= len(data) || data[off] == 0 {
+		a := typeAlign(typ)
+		off = (off + a - 1) &^ (a - 1)
+	}
+	if off > len(data) {
+		return Datum{}, off, fmt.Errorf("value offset %d beyond data (%d bytes)", off, len(data))
+	}
+	d, n, err := DecodeDatum(typ, data[off:])
+	if err != nil {
+		return Datum{}, off, err
+	}
+	return d, off + n, nil
+}
+
+// ParseSchema parses a comma-separated list of column types.
+func ParseSchema(s string) ([]string, error) {
+	var cols []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if !isKnownType(t) {
+			return nil, fmt.Errorf("unknown type %q (supported: %s)", t, strings.Join(knownTypes, ", "))
+		}
+		cols = append(cols, t)
+	}
+	return cols, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// pageDecrypter turns the on-disk image of a page of a TDE-enabled cluster
+// into the plain page before it is parsed. blkno is the block number
+// within the relation fork, which encryption schemes use in the IV or
+// tweak; path tells the relation and fork apart.
+type pageDecrypter interface {
+	decryptPage(path string, blkno uint32, data *[PageSize]byte) error
+}
+
+// pageDecryption decrypts every page read when set (--decrypt-cmd).
+var pageDecryption pageDecrypter
+
+// decryptCommand runs an external decryption helper for the whole session
+// and asks it for one page at a time. Each request is a line
+//
+//	<path>\t<block>\n
+//
+// followed by the 8192-byte page image, and the helper answers with the
+// 8192 bytes of the decrypted page (the image unchanged if it cannot
+// decrypt it). --decrypt-key is passed to it as PGPS_KEY_FILE.
+type decryptCommand struct {
+	mu   sync.Mutex
+	cmd  *exec.Cmd
+	in   *bufio.Writer
+	out  io.Reader
+	argv []string
+	key  string
+	err  error
+}
+
+func newDecryptCommand(command, keyFile string) (*decryptCommand, error) {
+	argv := strings.Fields(command)
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("--decrypt-cmd is empty")
+	}
+	if keyFile != "" {
+		if _, err := os.Stat(keyFile); err != nil {
+			return nil, fmt.Errorf("key file: %w", err)
+		}
+	}
+	return &decryptCommand{argv: argv, key: keyFile}, nil
+}
+
+// start launches the helper on first use, so sessions that never read a
+// page do not run it.
+func (d *decryptCommand) start() error {
+	cmd := exec.Command(d.argv[0], d.argv[1:]...)
+	cmd.Env = os.Environ()
+	if d.key != "" {
+		cmd.Env = append(cmd.Env, "PGPS_KEY_FILE="+d.key)
+	}
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start %s: %w", d.argv[0], err)
+	}
+	d.cmd, d.in, d.out = cmd, bufio.NewWriter(in), bufio.NewReader(out)
+	return nil
+}
+
+func (d *decryptCommand) decryptPage(path string, blkno uint32, data *[PageSize]byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// A helper that died or broke the protocol stays failed: its output
+	// can no longer be matched to requests.
+	if d.err != nil {
+		return d.err
+	}
+	if d.cmd == nil {
+		if d.err = d.start(); d.err != nil {
+			return d.err
+		}
+	}
+	fmt.Fprintf(d.in, "%s\t%d\n", path, blkno)
+	d.in.Write(data[:])
+	if err := d.in.Flush(); err != nil {
+		d.err = fmt.Errorf("decryption helper: %w", err)
+		return d.err
+	}
+	var plain [PageSize]byte
+	if n, err := io.ReadFull(d.out, plain[:]); err != nil {
+		d.err = fmt.Errorf("decryption helper returned %d of %d bytes: %w", n, PageSize, err)
+		return d.err
+	}
+	*data = plain
+	return nil
+}
+
+// decryptPageData applies pageDecryption to a page image just read.
+// All-zero pages are left alone: relations are extended with zeroed
+// blocks, which are written unencrypted.
+func decryptPageData(filename string, pageNum int64, data *[PageSize]byte) error {
+	if pageDecryption == nil || *data == ([PageSize]byte{}) {
+		return nil
+	}
+	if err := pageDecryption.decryptPage(filename, absBlockNumber(filename, pageNum), data); err != nil {
+		return fmt.Errorf("decrypt page %d: %w", pageNum, err)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// DetectionCandidate is one page type the special area is consistent with.
+// Strong candidates are identified by a page id or page type magic; weak
+// ones only by flag bits that happen to be valid.
+type DetectionCandidate struct {
+	Type     PageType
+	Strong   bool
+	Evidence string
+}
+
+// DetectionCandidates evaluates every layout that fits the special area
+// size, independently of the order detectPageType tries them in.
+func (p *Page) DetectionCandidates() []DetectionCandidate {
+	special := p.SpecialData()
+	le := binary.LittleEndian
+	var c []DetectionCandidate
+	add := func(pt PageType, strong bool, format string, args ...interface{}) {
+		c = append(c, DetectionCandidate{pt, strong, fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case p.SpecialSize() == 0:
+		add(PageTypeHeap, true, "no special space")
+
+	case len(special) == 8:
+		id := le.Uint16(special[6:8])
+		switch id {
+		case BRINPageTypeMeta, BRINPageTypeRevmap, BRINPageTypeRegular:
+			add(PageTypeBRIN, true, "special[6:8] = 0x%04X is a BRIN page type", id)
+		case SPGistPageID:
+			add(PageTypeSPGiST, true, "special[6:8] = 0x%04X is SPGIST_PAGE_ID", id)
+		}
+		if id == 0 || (id&0xFF00 == 0 && id&0x00FF != 0) {
+			add(PageTypeGIN, false, "special[6:8] = 0x%04X is a valid GIN flag set", id)
+		}
+
+	case len(special) == 16:
+		id := le.Uint16(special[14:16])
+		switch id {
+		case HashPageID:
+			add(PageTypeHash, true, "special[14:16] = 0x%04X is HASHO_PAGE_ID", id)
+		case GistPageID:
+			add(PageTypeGiST, true, "special[14:16] = 0x%04X is GIST_PAGE_ID", id)
+		}
+		flags := le.Uint16(special[12:14])
+		if flags&0xFE00 == 0 {
+			level := le.Uint32(special[8:12])
+			if (flags&BTPLeaf != 0) == (level == 0) {
+				add(PageTypeBTree, false, "btpo_flags 0x%04X valid and consistent with btpo_level %d", flags, level)
+			} else {
+				add(PageTypeBTree, false, "btpo_flags 0x%04X valid but BTP_LEAF disagrees with btpo_level %d", flags, level)
+			}
+		}
+	}
+	return c
+}
+
+// DetectionConfidence rates how certain the detected type is: "high" when
+// exactly one layout is identified by its magic, "medium" for a single
+// flag-based match, "low" when several layouts fit equally well, and
+// "none" when nothing does.
+func (p *Page) DetectionConfidence() string {
+	strong, weak := 0, 0
+	for _, c := range p.DetectionCandidates() {
+		if c.Strong {
+			strong++
+		} else {
+			weak++
+		}
+	}
+	switch {
+	case strong == 1:
+		return "high"
+	case strong == 0 && weak == 1:
+		return "medium"
+	case strong+weak == 0:
+		return "none"
+	}
+	return "low"
+}
+
+// fileTypeSampleLimit bounds the pages read when voting on a file's type.
+const fileTypeSampleLimit = 1024
+
+var (
+	fileTypesMu sync.Mutex
+	fileTypes   = map[string]PageType{}
+)
+
+// inferredFileType returns the relation type of a file, computed once per
+// file: the type of its metapage if block 0 is one, otherwise the type
+// that a majority of confidently detected pages agree on.
+func inferredFileType(filename string) PageType {
+	fileTypesMu.Lock()
+	defer fileTypesMu.Unlock()
+	if pt, ok := fileTypes[filename]; ok {
+		return pt
+	}
+	pt := inferFileType(filename)
+	fileTypes[filename] = pt
+	return pt
+}
+
+// forgetFileType drops the cached relation type of filename; writes that
+// may change its metapage or its pages call it.
+func forgetFileType(filename string) {
+	fileTypesMu.Lock()
+	delete(fileTypes, filename)
+	fileTypesMu.Unlock()
+}
+
+func inferFileType(filename string) PageType {
+	size, err := fileSize(filename)
+	if err != nil {
+		return PageTypeUnknown
+	}
+	totalPages := size / PageSize
+	if totalPages == 0 {
+		return PageTypeUnknown
+	}
+	if pg0, err := readPageRaw(filename, 0); err == nil && !pg0.Forced && isMeta(pg0) && metaMagicOK(pg0) {
+		return pg0.AutoDetected
+	}
+
+	step := int64(1)
+	if totalPages > fileTypeSampleLimit {
+		step = totalPages / fileTypeSampleLimit
+	}
+	votes := map[PageType]int{}
+	total := 0
+	for i := int64(0); i < totalPages; i += step {
+		pg, err := readPageRaw(filename, i)
+		if err != nil || isNewPage(pg) {
+			continue
+		}
+		if c := pg.DetectionConfidence(); c != "high" && c != "medium" {
+			continue
+		}
+		votes[pg.AutoDetected]++
+		total++
+	}
+	for pt, n := range votes {
+		if 2*n > total {
+			return pt
+		}
+	}
+	return PageTypeUnknown
+}
+
+// metaMagicOK reports whether a metapage carries its access method's magic
+// number. GIN metapages have none and are accepted on their flags alone.
+func metaMagicOK(p *Page) bool {
+	magic := binary.LittleEndian.Uint32(p.Data[PageHeaderSize:])
+	switch p.AutoDetected {
+	case PageTypeBTree:
+		return magic == BTreeMagic
+	case PageTypeHash:
+		return magic == HashMagic
+	case PageTypeBRIN:
+		return magic == BRINMetaMagic
+	case PageTypeSPGiST:
+		return magic == SPGistMagic
+	case PageTypeGIN:
+		return true
+	}
+	return false
+}
+
+// applyFileType decodes a page that does not identify itself confidently
+// as the file's type, provided its special area has that type's size.
+func (p *Page) applyFileType(ft PageType) {
+	if p.Forced || p.Template != nil || ft == PageTypeUnknown || ft == PageTypeHeap || ft == p.Detected {
+		return
+	}
+	if p.DetectionConfidence() == "high" || len(p.SpecialData()) != minSpecialSize(ft) {
+		return
+	}
+	p.Detected = ft
+	p.FromFileType = true
+}
+
+// printDetection prints the detection confidence and any alternative
+// interpretations of the page.
+func printDetection(p *Page) {
+	if p.FromFileType {
+		fmt.Printf("  Detection          : %s (inferred from the rest of the file; page alone: %s, confidence: %s)\n",
+			p.Detected, p.AutoDetected, p.DetectionConfidence())
+	} else {
+		fmt.Printf("  Detection          : %s (confidence: %s)\n", p.AutoDetected, p.DetectionConfidence())
+	}
+	if p.Template != nil {
+		fmt.Printf("  Template           : %s (special area layout from the config file)\n", p.Template.Name)
+	}
+	if byteSwappedHeader(p) {
+		fmt.Println("  Architecture       : pd_pagesize_version is only valid byte-swapped (big-endian page?)")
+	}
+	if p.Encrypted {
+		fmt.Printf("  Encryption         : looks encrypted (entropy %.2f bits/byte, header does not parse)\n", p.Entropy())
+	}
+	for _, c := range p.DetectionCandidates() {
+		if c.Type == p.AutoDetected || c.Type == p.Detected {
+			continue
+		}
+		fmt.Printf("    also plausible   : %s - %s\n", c.Type, c.Evidence)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// directIO is set by --direct-io: pages are read with the OS page cache
+// bypassed, so what is verified is what the storage returns rather than a
+// cached copy that may be newer than (or differ from) the disk contents.
+var directIO bool
+
+// directIOAlign is the buffer, offset and length alignment direct reads
+// need; 4 KB satisfies every common logical block size.
+const directIOAlign = 4096
+
+// readPageDirect reads one page with the page cache bypassed.
+func readPageDirect(filename string, pageNum int64) ([PageSize]byte, error) {
+	var data [PageSize]byte
+	offset, err := pageFileOffset(filename, pageNum)
+	if err != nil {
+		return data, err
+	}
+	f, err := openDirect(filename)
+	if err != nil {
+		return data, fmt.Errorf("open for direct I/O: %w", err)
+	}
+	defer f.Close()
+
+	raw := make([]byte, PageSize+directIOAlign)
+	skip := 0
+	if r := int(uintptr(unsafe.Pointer(&raw[0])) % directIOAlign); r != 0 {
+		skip = directIOAlign - r
+	}
+	buf := raw[skip : skip+PageSize]
+	n, err := f.ReadAt(buf, offset)
+	if n < PageSize {
+		return data, fmt.Errorf("read page %d (got %d bytes): %w", pageNum, n, err)
+	}
+	copy(data[:], buf)
+	return data, nil
+}
@@ -0,0 +1,14 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+const directIOSupported = true
+
+func openDirect(filename string) (*os.File, error) {
+	return os.OpenFile(filename, os.O_RDONLY|syscall.O_DIRECT, 0)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+const directIOSupported = false
+
+func openDirect(filename string) (*os.File, error) {
+	return nil, errors.New("direct I/O is only supported on Linux")
+}
@@ -0,0 +1,56 @@
+package main
+
+import "math"
+
+// encryptedEntropy is the byte entropy, in bits per byte, above which a
+// page that does not parse is taken for ciphertext. Random 8 KB pages
+// measure about 7.98; PostgreSQL pages, even full of compressed data,
+// have a header, line pointers and alignment padding that keep them well
+// below it.
+const encryptedEntropy = 7.8
+
+// byteEntropy is the Shannon entropy of b in bits per byte (0 to 8).
+func byteEntropy(b []byte) float64 {
+	if len(b) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, c := range b {
+		counts[c]++
+	}
+	h := 0.0
+	for _, n := range counts {
+		if n > 0 {
+			f := float64(n) / float64(len(b))
+			h -= f * math.Log2(f)
+		}
+	}
+	return h
+}
+
+// Entropy is the byte entropy of the whole page.
+func (p *Page) Entropy() float64 { return byteEntropy(p.Data[:]) }
+
+// looksEncrypted reports whether the page looks like the output of a
+// transparent data encryption fork (Percona, EDB, pg_tde, ...) that
+// encrypts whole blocks: its header does not hold together and its bytes
+// are as random as ciphertext. Pages that parse are never flagged, so
+// forks keeping the header in clear text are not detected.
+func (p *Page) looksEncrypted() bool {
+	if len(p.Anomalies(AnnotationHeader, AnnotationFlags)) == 0 && p.AutoDetected != PageTypeUnknown {
+		return false
+	}
+	return p.Entropy() >= encryptedEntropy
+}
+
+// TypeLabel is the page type for display: "encrypted?" for pages that
+// look encrypted instead of the type their random bytes decode as.
+func (p *Page) TypeLabel() string {
+	if p.Encrypted {
+		return "encrypted?"
+	}
+	if p.Template != nil {
+		return p.Template.Name
+	}
+	return p.Detected.String()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -8,17 +9,18 @@ import (
 	"strings"
 )
 
-type ExportFileData struct {
-	Filename string     `json:"filename"`
-	FileType string     `json:"file_type"`
-	Info     FileInfo   `json:"info"`
-	Pages    []PageDetail `json:"pages"`
-}
-
+// runExportJSON writes one object per file to stdout as a JSON array:
+//
+//	{"filename", "file_type", "info": FileInfo, "pages": [PageDetail, ...]}
+//
+// Pages are encoded as they are read, so memory use does not grow with the
+// file size. The file is read twice, once for the info summaries and once
+// for the details.
 func runExportJSON(filenames []string) error {
-	result := make([]ExportFileData, 0, len(filenames))
+	w := bufio.NewWriter(os.Stdout)
+	w.WriteString("[")
 
-	for _, arg := range filenames {
+	for idx, arg := range filenames {
 		// Support "name=path" format for custom display names
 		fn := arg
 		displayName := ""
@@ -27,26 +29,34 @@ func runExportJSON(filenames []string) error {
 			fn = arg[idx+1:]
 		}
 
-		fi, err := os.Stat(fn)
+		size, err := fileSize(fn)
 		if err != nil {
 			return fmt.Errorf("cannot stat %s: %w", fn, err)
 		}
-		totalPages := int(fi.Size() / PageSize)
+		totalPages := size / PageSize
 
 		fileType := "unknown"
-		pages := make([]PageSummary, 0, totalPages)
-		details := make([]PageDetail, 0, totalPages)
-
-		for i := 0; i < totalPages; i++ {
-			pg, err := ReadPage(fn, i)
-			if err != nil {
-				pages = append(pages, PageSummary{PageNum: i, Type: "error"})
-				details = append(details, PageDetail{PageNum: i, Type: "error"})
-				continue
-			}
-			if i == 0 {
+		if totalPages > 0 {
+			if pg, err := ReadPage(fn, 0); err == nil {
 				fileType = pg.Detected.String()
 			}
+		}
+		fileType = fileTypeLabel(fn, fileType, totalPages)
+
+		name := displayName
+		if name == "" {
+			name = filepath.Base(fn)
+		}
+
+		if idx > 0 {
+			w.WriteString(",")
+		}
+		fmt.Fprintf(w, `{"filename":%s,"file_type":%s,"info":{"filename":%s,"total_pages":%d,"file_type":%s,"pages":[`,
+			jsonString(name), jsonString(fileType), jsonString(name), totalPages, jsonString(fileType))
+		if err := exportPages(w, fn, totalPages, func(i int64, pg *Page) any {
+			if pg == nil {
+				return PageSummary{PageNum: i, Type: "error"}
+			}
 			h := &pg.Header
 			numItems := 0
 			if h.Lower > PageHeaderSize {
@@ -56,36 +66,54 @@ func runExportJSON(filenames []string) error {
 			if h.Upper > h.Lower {
 				freeSpace = int(h.Upper - h.Lower)
 			}
-			pages = append(pages, PageSummary{
+			return PageSummary{
 				PageNum:     i,
 				Type:        pg.Detected.String(),
 				NumItems:    numItems,
 				FreeSpace:   freeSpace,
 				SpecialSize: pg.SpecialSize(),
-			})
-			details = append(details, buildPageDetail(pg))
+				Anomalies:   len(pg.Annotations),
+			}
+		}); err != nil {
+			return err
 		}
-
-		name := displayName
-		if name == "" {
-			name = filepath.Base(fn)
+		w.WriteString(`]},"pages":[`)
+		if err := exportPages(w, fn, totalPages, func(i int64, pg *Page) any {
+			if pg == nil {
+				return PageDetail{PageNum: i, Type: "error"}
+			}
+			return buildPageDetail(pg)
+		}); err != nil {
+			return err
 		}
+		w.WriteString("]}")
+	}
 
-		info := FileInfo{
-			Filename:   name,
-			TotalPages: totalPages,
-			FileType:   fileType,
-			Pages:      pages,
-		}
+	w.WriteString("]\n")
+	return w.Flush()
+}
 
-		result = append(result, ExportFileData{
-			Filename: name,
-			FileType: fileType,
-			Info:     info,
-			Pages:    details,
-		})
+// exportPages writes the comma-separated JSON encoding of conv for every
+// page of the file; conv gets a nil page for pages that cannot be read.
+func exportPages(w *bufio.Writer, fn string, totalPages int64, conv func(i int64, pg *Page) any) error {
+	for i := int64(0); i < totalPages; i++ {
+		pg, err := ReadPage(fn, i)
+		if err != nil {
+			pg = nil
+		}
+		b, err := json.Marshal(conv(i, pg))
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			w.WriteString(",")
+		}
+		w.Write(b)
 	}
+	return nil
+}
 
-	enc := json.NewEncoder(os.Stdout)
-	return enc.Encode(result)
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
 }
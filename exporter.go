@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// relationMetrics is what one scan of a relation file yields.
+type relationMetrics struct {
+	Pages            int64
+	Unreadable       int
+	DeadTuples       int // XMAX_COMMITTED set and not a lock-only xmax
+	DeadLinePointers int
+	FreeBytes        int
+	ChecksumFailures int
+	MaxLSN           uint64
+}
+
+// heapTupleDead reports whether a normal line pointer on a heap page points
+// to a tuple whose deleting transaction is hinted committed (and whose xmax
+// is not a mere lock), i.e. one only waiting for VACUUM.
+func heapTupleDead(p *Page, lp ItemId) bool {
+	if p.Detected != PageTypeHeap || lp.Flags() != LPNormal || lp.Length() < HeapTupleHdrSize ||
+		int(lp.Offset())+int(lp.Length()) > PageSize {
+		return false
+	}
+	t := p.ParseHeapTupleHeader(lp.Offset())
+	return t.Infomask&HeapXmaxCommitted != 0 && t.Infomask&HeapXmaxLockOnly == 0
+}
+
+// scanRelationMetrics reads every page of a relation file once.
+func scanRelationMetrics(filename string) (relationMetrics, error) {
+	var m relationMetrics
+	totalPages, err := countPages(filename)
+	if err != nil {
+		return m, err
+	}
+	m.Pages = totalPages
+	for i := int64(0); i < totalPages; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil {
+			m.Unreadable++
+			continue
+		}
+		h := &pg.Header
+		if h.LSN > m.MaxLSN {
+			m.MaxLSN = h.LSN
+		}
+		if pg.ChecksumProblem() != "" {
+			m.ChecksumFailures++
+		}
+		if isNewPage(pg) {
+			continue
+		}
+		if h.Upper > h.Lower {
+			m.FreeBytes += int(h.Upper - h.Lower)
+		}
+		for _, lp := range pg.Items {
+			switch lp.Flags() {
+			case LPDead:
+				m.DeadLinePointers++
+			case LPNormal:
+				if heapTupleDead(pg, lp) {
+					m.DeadTuples++
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+// exporterRelations expands the exporter's arguments: relation files are
+// taken as they are, a data directory stands for the main-fork segments
+// under base/, global/ and its tablespaces.
+func exporterRelations(args []string) ([]string, error) {
+	var files []string
+	for _, a := range args {
+		fi, err := os.Stat(a)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, a)
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(a, "global", PGControlFile)); err != nil {
+			return nil, fmt.Errorf("%s is not a data directory (no global/%s)", a, PGControlFile)
+		}
+		dirs := []string{filepath.Join(a, "base"), filepath.Join(a, "global")}
+		spcs, err := tablespaces(a)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range spcs {
+			dirs = append(dirs, t.Dir)
+		}
+		for _, dir := range dirs {
+			err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if _, fork, _, ok := parseRelFileName(path); ok && fork == ForkMain && d.Type().IsRegular() {
+					files = append(files, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// metricsExporter rescans its relations every interval and serves the
+// last results in the Prometheus text exposition format.
+type metricsExporter struct {
+	files    []string
+	interval time.Duration
+
+	mu       sync.Mutex
+	results  map[string]relationMetrics
+	errors   map[string]string
+	lastScan time.Time
+	duration time.Duration
+}
+
+func (e *metricsExporter) scan() {
+	start := time.Now()
+	results := make(map[string]relationMetrics, len(e.files))
+	errors := map[string]string{}
+	for _, fn := range e.files {
+		m, err := scanRelationMetrics(fn)
+		if err != nil {
+			errors[fn] = err.Error()
+			continue
+		}
+		results[fn] = m
+	}
+	e.mu.Lock()
+	e.results, e.errors = results, errors
+	e.lastScan, e.duration = start, time.Since(start)
+	e.mu.Unlock()
+}
+
+func (e *metricsExporter) run() {
+	for {
+		e.scan()
+		time.Sleep(e.interval)
+	}
+}
+
+// promLabel escapes a label value for the text exposition format.
+func promLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func (e *metricsExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	metric := func(name, kind, help string, value func(relationMetrics) float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, fn := range e.files {
+			if m, ok := e.results[fn]; ok {
+				fmt.Fprintf(w, "%s{file=\"%s\"} %g\n", name, promLabel(fn), value(m))
+			}
+		}
+	}
+	metric("pgpageshell_pages", "gauge", "Pages in the relation file.",
+		func(m relationMetrics) float64 { return float64(m.Pages) })
+	metric("pgpageshell_unreadable_pages", "gauge", "Pages that could not be read.",
+		func(m relationMetrics) float64 { return float64(m.Unreadable) })
+	metric("pgpageshell_dead_tuples", "gauge", "Heap tuples with a committed, non-lock xmax.",
+		func(m relationMetrics) float64 { return float64(m.DeadTuples) })
+	metric("pgpageshell_dead_line_pointers", "gauge", "LP_DEAD line pointers.",
+		func(m relationMetrics) float64 { return float64(m.DeadLinePointers) })
+	metric("pgpageshell_free_bytes", "gauge", "Free space between pd_lower and pd_upper, summed over pages.",
+		func(m relationMetrics) float64 { return float64(m.FreeBytes) })
+	metric("pgpageshell_checksum_failures", "gauge", "Pages whose pd_checksum does not verify.",
+		func(m relationMetrics) float64 { return float64(m.ChecksumFailures) })
+	metric("pgpageshell_max_lsn", "gauge", "Highest pd_lsn in the relation file, as a byte position.",
+		func(m relationMetrics) float64 { return float64(m.MaxLSN) })
+
+	fmt.Fprintln(w, "# HELP pgpageshell_scan_errors Relation files the last scan could not open.")
+	fmt.Fprintln(w, "# TYPE pgpageshell_scan_errors gauge")
+	fmt.Fprintf(w, "pgpageshell_scan_errors %d\n", len(e.errors))
+	if !e.lastScan.IsZero() {
+		fmt.Fprintln(w, "# HELP pgpageshell_last_scan_timestamp_seconds Start time of the last completed scan.")
+		fmt.Fprintln(w, "# TYPE pgpageshell_last_scan_timestamp_seconds gauge")
+		fmt.Fprintf(w, "pgpageshell_last_scan_timestamp_seconds %d\n", e.lastScan.Unix())
+		fmt.Fprintln(w, "# HELP pgpageshell_last_scan_duration_seconds Duration of the last completed scan.")
+		fmt.Fprintln(w, "# TYPE pgpageshell_last_scan_duration_seconds gauge")
+		fmt.Fprintf(w, "pgpageshell_last_scan_duration_seconds %g\n", e.duration.Seconds())
+	}
+}
+
+func cliExporter(args []string) error {
+	usage := fmt.Errorf("usage: pgpageshell exporter [--listen ADDR] [--interval DURATION] <file|pgdata> [...]")
+	listen, interval := ":9300", time.Minute
+	var paths []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--listen", "--interval":
+			if i+1 >= len(args) {
+				return usage
+			}
+			if args[i] == "--listen" {
+				listen = args[i+1]
+			} else {
+				d, err := time.ParseDuration(args[i+1])
+				if err != nil || d <= 0 {
+					return fmt.Errorf("invalid interval %q", args[i+1])
+				}
+				interval = d
+			}
+			i++
+		default:
+			paths = append(paths, args[i])
+		}
+	}
+	if len(paths) == 0 {
+		return usage
+	}
+	files, err := exporterRelations(paths)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no relation files found")
+	}
+
+	e := &metricsExporter{files: files, interval: interval}
+	go e.run()
+	http.Handle("/metrics", e)
+	fmt.Fprintf(os.Stderr, "Serving metrics for %d relation file(s) on %s/metrics, rescanning every %s\n", len(files), listen, interval)
+	return http.ListenAndServe(listen, nil)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"sync"
+)
+
+// dropCache is set by --drop-cache: pages a sequential scan has read are
+// dropped from the OS page cache again behind it.
+var dropCache bool
+
+// readaheadWindow is how many pages ahead of a sequential scan are hinted
+// with FADV_WILLNEED, and how many it leaves behind before dropping them.
+const readaheadWindow = 128 // 1 MB
+
+// scanHints tracks sequential reads of one file. Pages are read through a
+// fresh descriptor each, so FADV_SEQUENTIAL (which tunes the readahead of
+// one descriptor) would not help; the readahead is done explicitly instead
+// with WILLNEED, which like DONTNEED applies to the file as a whole.
+type scanHints struct {
+	last   int64 // last page read
+	ahead  int64 // pages before this have been hinted
+	behind int64 // pages before this have been dropped
+	total  int64 // file size in pages when last hinted
+}
+
+var (
+	scanHintsMu sync.Mutex
+	scanHintsBy = map[string]*scanHints{}
+)
+
+// adviseRead is called after each buffered page read. Once reads of a file
+// are sequential it hints the next window of pages, and with --drop-cache
+// drops the pages already read, so a big scan neither waits on each page
+// nor leaves the production database's cache full of its pages. Re-reads
+// of the page read last, as --live retries a torn read, are no step of the
+// scan and leave its state alone.
+func adviseRead(filename string, pageNum int64) {
+	scanHintsMu.Lock()
+	defer scanHintsMu.Unlock()
+	h := scanHintsBy[filename]
+	if h != nil && pageNum == h.last {
+		return
+	}
+	if h == nil || pageNum != h.last+1 {
+		scanHintsBy[filename] = &scanHints{last: pageNum, ahead: pageNum + 1, behind: pageNum}
+		return
+	}
+	h.last = pageNum
+
+	willNeed := h.ahead-pageNum < readaheadWindow/2
+	drop := dropCache && (pageNum+1-h.behind >= readaheadWindow || pageNum+1 == h.total)
+	if !willNeed && !drop {
+		return
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	base := imageWindows[filename].Offset
+	if willNeed {
+		fadviseWillNeed(f, base+h.ahead*PageSize, (pageNum+readaheadWindow-h.ahead)*PageSize)
+		h.ahead = pageNum + readaheadWindow
+		if size, err := fileSize(filename); err == nil {
+			h.total = size / PageSize
+		}
+	}
+	if drop {
+		fadviseDontNeed(f, base+h.behind*PageSize, (pageNum+1-h.behind)*PageSize)
+		h.behind = pageNum + 1
+	}
+}
@@ -0,0 +1,17 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func fadviseWillNeed(f *os.File, offset, length int64) {
+	unix.Fadvise(int(f.Fd()), offset, length, unix.FADV_WILLNEED)
+}
+
+func fadviseDontNeed(f *os.File, offset, length int64) {
+	unix.Fadvise(int(f.Fd()), offset, length, unix.FADV_DONTNEED)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// Page cache hints are only issued on Linux.
+
+func fadviseWillNeed(f *os.File, offset, length int64) {}
+
+func fadviseDontNeed(f *os.File, offset, length int64) {}
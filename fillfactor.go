@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fillReserve is the free space heap inserts leave on a page for a given
+// fillfactor (RelationGetTargetPageFreeSpace).
+func fillReserve(fillfactor int) int64 {
+	return PageSize * int64(100-fillfactor) / 100
+}
+
+// packedPage is a heap page whose free space is still what the last insert
+// left: RelationGetBufferForTuple took it while its free space covered a
+// tuple plus the reserve, and moved on when the next tuple did not fit.
+type packedPage struct {
+	free    int64 // PageGetHeapFreeSpace
+	nextLen int64 // estimated size of the tuple that did not fit
+}
+
+// fits reports whether the page could have been packed with fillfactor:
+// the reserve is within the free space, but the next tuple plus the
+// reserve was not.
+func (p packedPage) fits(fillfactor int) bool {
+	r := fillReserve(fillfactor)
+	return r <= p.free && p.free < r+p.nextLen
+}
+
+// CmdFillfactor estimates the fillfactor heap pages were packed to. Only
+// pages nothing happened to since their last insert are used: no
+// PD_PAGE_FULL, no dead, unused or redirected line pointers, and no tuple
+// updated, deleted or placed by a HOT update. Their free space brackets
+// the reserve, and the fillfactor most of them agree on is the effective
+// one. With expected > 0 the packed pages are checked against it.
+func CmdFillfactor(filename string, totalPages int64, sr scanRange, expected int) {
+	fmt.Println()
+	fmt.Printf("=== Effective Fillfactor (%s) ===\n", sr.String(totalPages))
+
+	var buckets [11]int
+	var packed []packedPage
+	heapPages, pageFull, touched, skipped := 0, 0, 0, 0
+	first, end := sr.bounds(totalPages)
+	for i := first; i < end; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil || pg.Detected != PageTypeHeap || isNewPage(pg) {
+			continue
+		}
+		if sr.skips(pg) {
+			skipped++
+			continue
+		}
+		heapPages++
+		free := heapFreeSpace(pg)
+		buckets[(PageSize-free)*10/PageSize]++
+
+		if pg.Header.Flags&PDPageFull != 0 {
+			pageFull++
+			continue
+		}
+		clean, n, size := true, int64(0), int64(0)
+		for _, lp := range pg.Items {
+			if lp.Flags() != LPNormal || lp.Length() < HeapTupleHdrSize ||
+				int(lp.Offset())+int(lp.Length()) > PageSize {
+				clean = false
+				break
+			}
+			t := pg.ParseHeapTupleHeader(lp.Offset())
+			if t.Infomask2&HeapOnlyTuple != 0 ||
+				t.Xmax != InvalidXID && t.Infomask&(HeapXmaxInvalid|HeapXmaxLockOnly) == 0 {
+				clean = false
+				break
+			}
+			n++
+			size += int64(maxAlign(int(lp.Length())))
+		}
+		// The last page of the file may still be filling
+		if !clean || n == 0 || i == totalPages-1 {
+			touched++
+			continue
+		}
+		packed = append(packed, packedPage{free: free, nextLen: size / n})
+	}
+
+	fmt.Printf("  %-9s %6s\n", "Fill", "Pages")
+	fmt.Printf("  %-9s %6s\n", "----", "-----")
+	most := max(buckets[0], 1)
+	for _, b := range buckets {
+		most = max(most, b)
+	}
+	for b, n := range buckets {
+		label := fmt.Sprintf("%d-%d%%", b*10, b*10+9)
+		if b == 10 {
+			label = "100%"
+		}
+		line := fmt.Sprintf("  %-9s %6d  %s", label, n, strings.Repeat("#", (n*40+most-1)/most))
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+	fmt.Println()
+	fmt.Printf("  Heap pages         : %d\n", heapPages)
+	if sr.SinceLSN != 0 {
+		fmt.Printf("  Skipped (older)    : %d\n", skipped)
+	}
+	fmt.Printf("  Packed by inserts  : %d\n", len(packed))
+	fmt.Printf("  Changed since      : %d (updates, deletes, pruning, or the last page)\n", touched)
+	fmt.Printf("  PD_PAGE_FULL       : %d (an update found no room; the reserve is used up)\n", pageFull)
+	if len(packed) == 0 {
+		fmt.Println()
+		fmt.Println("  No page is still as the last insert left it; nothing to estimate from.")
+		fmt.Println()
+		return
+	}
+
+	// The fillfactor(s) most packed pages are consistent with
+	best, lo, hi := 0, 0, 0
+	for ff := 10; ff <= 100; ff++ {
+		n := 0
+		for _, p := range packed {
+			if p.fits(ff) {
+				n++
+			}
+		}
+		switch {
+		case n > best:
+			best, lo, hi = n, ff, ff
+		case n == best && n > 0 && ff == hi+1:
+			hi = ff
+		}
+	}
+	switch {
+	case best == 0:
+		fmt.Println("  Estimate           : no single fillfactor fits the packed pages")
+	case lo == hi:
+		fmt.Printf("  Estimate           : %d%% (fits %d of %d packed pages)\n", lo, best, len(packed))
+	default:
+		fmt.Printf("  Estimate           : %d-%d%% (fits %d of %d packed pages)\n", lo, hi, best, len(packed))
+	}
+
+	if expected > 0 {
+		r := fillReserve(expected)
+		over, short, fits := 0, 0, 0
+		for _, p := range packed {
+			switch {
+			case p.free < r:
+				over++
+			case p.free >= r+p.nextLen:
+				short++
+			default:
+				fits++
+			}
+		}
+		fmt.Println()
+		fmt.Printf("  Fillfactor %d%% leaves %d bytes free per page\n", expected, r)
+		fmt.Printf("  Packed as expected : %d\n", fits)
+		fmt.Printf("  Packed past it     : %d (inserts went into the reserve)\n", over)
+		fmt.Printf("  Left short of it   : %d (room for another tuple was left)\n", short)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,70 @@
+package main
+
+import "fmt"
+
+// ToastTupleThreshold is TOAST_TUPLE_THRESHOLD for 8 KB pages: heap tuples
+// above it are compressed and then moved out of line until they fit.
+const ToastTupleThreshold = 2032
+
+// CmdFindBig reports every tuple, heap or index, whose line pointer length
+// exceeds minSize bytes. Heap tuples above the TOAST threshold that carry
+// no TOAST pointer are pointed out: their columns are stored PLAIN or MAIN,
+// or there are too many of them to toast.
+func CmdFindBig(filename string, totalPages int64, sr scanRange, minSize int) {
+	fmt.Println()
+	fmt.Printf("=== Tuples Larger Than %d Bytes (%s) ===\n", minSize, sr.String(totalPages))
+	fmt.Printf("  %-14s %6s  %-8s %s\n", "TID", "Bytes", "Type", "Note")
+	fmt.Printf("  %-14s %6s  %-8s %s\n", "---", "-----", "----", "----")
+
+	checked, found, largest := 0, 0, 0
+	largestTID := ""
+	first, end := sr.bounds(totalPages)
+	for i := first; i < end; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil || sr.skips(pg) {
+			continue
+		}
+		// Meta pages keep their metadata below pd_lower, not line pointers
+		switch detectPageSubtype(pg) {
+		case "meta", "bitmap", "revmap":
+			continue
+		}
+		for n, lp := range pg.Items {
+			if lp.Flags() != LPNormal || int(lp.Offset())+int(lp.Length()) > PageSize {
+				continue
+			}
+			checked++
+			size := int(lp.Length())
+			tid := fmt.Sprintf("(%d,%d)", absBlockNumber(filename, i), n+1)
+			if size > largest {
+				largest, largestTID = size, tid
+			}
+			if size <= minSize {
+				continue
+			}
+			found++
+			note := ""
+			if pg.Detected == PageTypeHeap && size >= HeapTupleHdrSize {
+				t := pg.ParseHeapTupleHeader(lp.Offset())
+				switch {
+				case t.Infomask&HeapHasExternal != 0:
+					note = "has TOAST pointers"
+				case size > ToastTupleThreshold:
+					note = fmt.Sprintf("above TOAST threshold (%d), nothing toasted", ToastTupleThreshold)
+				}
+			}
+			fmt.Printf("  %-14s %6d  %-8s %s\n", tid, size, pg.Detected, note)
+		}
+	}
+
+	if found == 0 {
+		fmt.Println("  (none)")
+	}
+	fmt.Println()
+	fmt.Printf("  Tuples checked     : %d\n", checked)
+	fmt.Printf("  Larger than %-6d : %d\n", minSize, found)
+	if largestTID != "" {
+		fmt.Printf("  Largest            : %d bytes at %s\n", largest, largestTID)
+	}
+	fmt.Println()
+}
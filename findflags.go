@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pdFlagNames maps the names accepted by findflags to pd_flags bits.
+var pdFlagNames = map[string]uint16{
+	"HAS_FREE_LINES": PDHasFreeLines,
+	"PAGE_FULL":      PDPageFull,
+	"ALL_VISIBLE":    PDAllVisible,
+}
+
+// parseFlagSpec parses a findflags argument such as "ALL_VISIBLE",
+// "!PAGE_FULL" or "ALL_VISIBLE,!HAS_FREE_LINES" into the bits that must be
+// set and the bits that must be clear. Names may carry the PD_ prefix and
+// be separated by commas, '|' or spaces.
+func parseFlagSpec(arg string) (set, clear uint16, err error) {
+	fields := strings.FieldsFunc(arg, func(r rune) bool { return r == ',' || r == '|' || r == ' ' })
+	if len(fields) == 0 {
+		return 0, 0, fmt.Errorf("no flags given")
+	}
+	for _, f := range fields {
+		negate := strings.HasPrefix(f, "!")
+		name := strings.TrimPrefix(strings.ToUpper(strings.TrimPrefix(f, "!")), "PD_")
+		bit, ok := pdFlagNames[name]
+		if !ok {
+			return 0, 0, fmt.Errorf("unknown flag %q (ALL_VISIBLE, PAGE_FULL, HAS_FREE_LINES)", f)
+		}
+		if negate {
+			clear |= bit
+		} else {
+			set |= bit
+		}
+	}
+	if set&clear != 0 {
+		return 0, 0, fmt.Errorf("%s is required both set and clear", FlagsString(set&clear))
+	}
+	return set, clear, nil
+}
+
+// CmdFindFlags lists the initialized pages whose pd_flags have all bits of
+// set and none of clear, as ranges of consecutive pages. When the spec
+// involves ALL_VISIBLE and the relation has a visibility map, each heap
+// page's VM bits are shown alongside, flagging disagreements.
+func CmdFindFlags(filename string, totalPages int64, sr scanRange, set, clear uint16) {
+	var spec []string
+	if set != 0 {
+		spec = append(spec, FlagsString(set))
+	}
+	if clear != 0 {
+		spec = append(spec, "not "+FlagsString(clear))
+	}
+	fmt.Println()
+	fmt.Printf("=== Pages With %s (%s) ===\n", strings.Join(spec, ", "), sr.String(totalPages))
+
+	var vm *VisibilityMap
+	if (set|clear)&PDAllVisible != 0 {
+		vm = findVisibilityMap(filename)
+	}
+	base := absBlockNumber(filename, 0)
+
+	// Consecutive matching pages with the same VM state print as one run
+	runStart, runEnd, runLabel := int64(-1), int64(-1), ""
+	flush := func() {
+		if runStart < 0 {
+			return
+		}
+		r := fmt.Sprint(runStart)
+		if runEnd > runStart {
+			r = fmt.Sprintf("%d-%d", runStart, runEnd)
+		}
+		fmt.Printf("  %-17s %s\n", r, runLabel)
+		runStart = -1
+	}
+
+	matched, uninitialized, skipped, vmMissing, vmInconsistent := 0, 0, 0, 0, 0
+	scanMeta(filename, totalPages, sr, func(i int64, m pageMeta, err error) {
+		switch {
+		case err != nil:
+			return
+		case sr.skipsLSN(m.LSN):
+			skipped++
+			return
+		case m.Upper == 0:
+			uninitialized++
+			return
+		case m.Flags&set != set || m.Flags&clear != 0:
+			return
+		}
+		matched++
+		label := ""
+		if vm != nil && m.PageType() == PageTypeHeap {
+			v, f := vm.Status(base + uint32(i))
+			pdVisible := m.Flags&PDAllVisible != 0
+			switch {
+			case v && !pdVisible:
+				vmInconsistent++
+				label = "VM all-visible (INCONSISTENT: without PD_ALL_VISIBLE)"
+			case !v && pdVisible:
+				vmMissing++
+				label = "VM clear (next VACUUM sets it)"
+			case v && f:
+				label = "VM all-visible, all-frozen"
+			case v:
+				label = "VM all-visible"
+			default:
+				label = "VM clear"
+			}
+		}
+		if runStart >= 0 && i == runEnd+1 && label == runLabel {
+			runEnd = i
+			return
+		}
+		flush()
+		runStart, runEnd, runLabel = i, i, label
+	})
+	flush()
+
+	if matched == 0 {
+		fmt.Println("  (none)")
+	}
+	fmt.Println()
+	fmt.Printf("  Matching pages     : %d\n", matched)
+	fmt.Printf("  Uninitialized      : %d (not matched)\n", uninitialized)
+	if sr.SinceLSN != 0 {
+		fmt.Printf("  Skipped (older)    : %d\n", skipped)
+	}
+	if vm != nil {
+		fmt.Printf("  Visibility map     : %s\n", shownPath(vm.Filename))
+		fmt.Printf("  PD set, VM clear   : %d\n", vmMissing)
+		fmt.Printf("  VM set, PD clear   : %d", vmInconsistent)
+		if vmInconsistent > 0 {
+			fmt.Print(" (INCONSISTENT: VM bit set without PD_ALL_VISIBLE)")
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+)
+
+// splitCommands splits a command sequence at the semicolons outside
+// braces, dropping empty commands. The semicolons of an if command's
+// unbraced branch belong to the branch, as they do when the if is typed on
+// its own line, so an if only ends at a semicolon after a braced branch.
+func splitCommands(s string) []string {
+	var cmds []string
+	depth, start := 0, 0
+	for i := 0; i <= len(s); i++ {
+		switch {
+		case i < len(s) && s[i] == '{':
+			depth++
+		case i < len(s) && s[i] == '}':
+			depth--
+		case i == len(s) || s[i] == ';' && depth == 0:
+			cmd := strings.TrimSpace(s[start:i])
+			if i < len(s) && isIfCommand(cmd) && !strings.HasSuffix(cmd, "}") {
+				continue
+			}
+			if cmd != "" {
+				cmds = append(cmds, cmd)
+			}
+			start = i + 1
+		}
+	}
+	return cmds
+}
+
+// isIfCommand reports whether cmd is an if command.
+func isIfCommand(cmd string) bool {
+	f := strings.Fields(cmd)
+	return len(f) > 0 && strings.EqualFold(f[0], "if")
+}
+
+// ifLines evaluates an if command on the current page,
+//
+//	if <cond> then <cmd>; ... [else <cmd>; ...]
+//
+// and returns the commands of the branch taken. The condition is a select
+// where clause; either branch may be wrapped in braces, and commands after
+// a braced last branch run whichever branch is taken, split the same way
+// as --once splits them.
+func ifLines(pageNum int64, p *Page, arg string) ([]string, error) {
+	cmds := splitCommands("if " + arg)
+	arg, after := strings.TrimPrefix(cmds[0], "if "), cmds[1:]
+	cond, rest, ok := strings.Cut(arg, " then ")
+	if !ok {
+		return nil, fmt.Errorf("usage: if <cond> then <cmd>; ... [else <cmd>; ...]")
+	}
+	thenPart, elsePart, _ := strings.Cut(rest, " else ")
+	where, err := parseQueryCondition(cond)
+	if err != nil {
+		return nil, err
+	}
+	branch := thenPart
+	if !where.eval(pageNum, newPageMeta(p)) {
+		branch = elsePart
+	}
+	branch = strings.TrimSpace(branch)
+	if strings.HasPrefix(branch, "{") && strings.HasSuffix(branch, "}") {
+		branch = branch[1 : len(branch)-1]
+	}
+	return append(splitCommands(branch), after...), nil
+}
+
+// foreachLoop runs a foreach command,
+//
+//	foreach page [<first>..<last>] [where <cond>] { <cmd>; <cmd> ... }
+//
+// one page at a time: each call to next yields "page N" followed by the
+// body for the following selected page. The range defaults to the whole
+// file and is inclusive; the condition is a select where clause. Arguments
+// of the body are expanded when each line runs, so $curpage is the page
+// being visited. While the loop runs, Ctrl-C stops it after the current
+// page instead of ending the shell.
+type foreachLoop struct {
+	body      []string
+	page      int64
+	last      int64
+	where     queryExpr
+	meta      *metaReader
+	interrupt chan os.Signal
+}
+
+func newForeachLoop(filename string, totalPages int64, arg string) (*foreachLoop, error) {
+	open, end := strings.Index(arg, "{"), strings.LastIndex(arg, "}")
+	if open < 0 || end < open || strings.TrimSpace(arg[end+1:]) != "" {
+		return nil, fmt.Errorf("usage: foreach page [a..b] [where <cond>] { <cmd>; ... }")
+	}
+	body := splitCommands(arg[open+1 : end])
+	for _, cmd := range body {
+		if strings.Fields(cmd)[0] == "foreach" {
+			return nil, fmt.Errorf("foreach cannot be nested")
+		}
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("foreach needs at least one command")
+	}
+
+	head := strings.Fields(arg[:open])
+	if len(head) == 0 || head[0] != "page" {
+		return nil, fmt.Errorf("usage: foreach page [a..b] [where <cond>] { <cmd>; ... }")
+	}
+	head = head[1:]
+	first, last := int64(0), totalPages-1
+	if len(head) > 0 && head[0] != "where" {
+		a, b, ok := strings.Cut(head[0], "..")
+		var errA, errB error
+		first, errA = strconv.ParseInt(a, 10, 64)
+		last, errB = strconv.ParseInt(b, 10, 64)
+		if !ok || errA != nil || errB != nil || first < 0 || last < first {
+			return nil, fmt.Errorf("invalid page range %q (expected a..b)", head[0])
+		}
+		last = min(last, totalPages-1)
+		head = head[1:]
+	}
+	l := &foreachLoop{body: body, page: first, last: last}
+	if len(head) > 0 {
+		if head[0] != "where" || len(head) == 1 {
+			return nil, fmt.Errorf("unexpected %q before {", strings.Join(head, " "))
+		}
+		var err error
+		if l.where, err = parseQueryCondition(strings.Join(head[1:], " ")); err != nil {
+			return nil, err
+		}
+		l.meta = newMetaReader(filename, first)
+	}
+	l.interrupt = make(chan os.Signal, 1)
+	signal.Notify(l.interrupt, os.Interrupt)
+	return l, nil
+}
+
+// next returns the lines of the following iteration, or nil when the loop
+// is done. An error (Ctrl-C, or a page whose summary cannot be read for
+// the condition) ends the loop as well.
+func (l *foreachLoop) next() ([]string, error) {
+	for ; l.page <= l.last; l.page++ {
+		select {
+		case <-l.interrupt:
+			return nil, fmt.Errorf("foreach interrupted before page %d", l.page)
+		default:
+		}
+		if l.where != nil {
+			m, err := l.meta.read(l.page)
+			if err != nil {
+				return nil, fmt.Errorf("foreach stopped at page %d: %w", l.page, err)
+			}
+			if !l.where.eval(l.page, m) {
+				continue
+			}
+		}
+		lines := append([]string{fmt.Sprintf("page %d", l.page)}, l.body...)
+		l.page++
+		return lines, nil
+	}
+	return nil, nil
+}
+
+// stop releases the loop's Ctrl-C handler and sidecar reader.
+func (l *foreachLoop) stop() {
+	signal.Stop(l.interrupt)
+	if l.meta != nil {
+		l.meta.Close()
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Relation fork names, matching the file suffixes used by PostgreSQL.
+const (
+	ForkMain = "main"
+	ForkFSM  = "fsm"
+	ForkVM   = "vm"
+	ForkInit = "init"
+)
+
+// RelSegSize is the number of blocks per 1 GB segment file (RELSEG_SIZE).
+const RelSegSize = 131072
+
+// parseRelFileName splits a relation file name of the form
+// <relfilenode>[_fork][.segment]. ok is false if the name does not follow
+// that scheme.
+func parseRelFileName(filename string) (relfilenode string, fork string, segment int, ok bool) {
+	base := filepath.Base(filename)
+	if i := strings.LastIndexByte(base, '.'); i > 0 {
+		n, err := strconv.Atoi(base[i+1:])
+		if err != nil {
+			return "", "", 0, false
+		}
+		segment = n
+		base = base[:i]
+	}
+	fork = ForkMain
+	if i := strings.IndexByte(base, '_'); i > 0 {
+		switch base[i+1:] {
+		case ForkFSM, ForkVM, ForkInit:
+			fork = base[i+1:]
+		default:
+			return "", "", 0, false
+		}
+		base = base[:i]
+	}
+	if _, err := strconv.ParseUint(base, 10, 32); err != nil {
+		return "", "", 0, false
+	}
+	return base, fork, segment, true
+}
+
+// relFork returns the fork a relation data file belongs to. Files that do
+// not follow PostgreSQL's naming scheme are treated as main forks.
+func relFork(filename string) string {
+	if _, fork, _, ok := parseRelFileName(filename); ok {
+		return fork
+	}
+	return ForkMain
+}
+
+// relSegment returns the segment number encoded in a relation file name
+// (the ".N" suffix), or 0 for the first segment.
+func relSegment(filename string) int {
+	if _, _, seg, ok := parseRelFileName(filename); ok {
+		return seg
+	}
+	return 0
+}
+
+// relForkPath returns the path of the given fork's first segment for the
+// relation that filename belongs to, or "" if filename does not follow
+// the naming scheme.
+func relForkPath(filename, fork string) string {
+	node, _, _, ok := parseRelFileName(filename)
+	if !ok {
+		return ""
+	}
+	if fork == ForkMain {
+		return filepath.Join(filepath.Dir(filename), node)
+	}
+	return filepath.Join(filepath.Dir(filename), node+"_"+fork)
+}
+
+// relForkOrder lists the forks in the order the banner and fork show them.
+var relForkOrder = []string{ForkMain, ForkFSM, ForkVM, ForkInit}
+
+// relSegmentPaths returns the segment files of a fork that exist, from its
+// first segment path: the file itself, then .1, .2, ... up to the first
+// missing one.
+func relSegmentPaths(first string) []string {
+	if _, err := os.Stat(first); err != nil {
+		return nil
+	}
+	segs := []string{first}
+	for n := 1; ; n++ {
+		p := fmt.Sprintf("%s.%d", first, n)
+		if _, err := os.Stat(p); err != nil {
+			return segs
+		}
+		segs = append(segs, p)
+	}
+}
+
+// relationForks returns the segment files of every fork of filename's
+// relation that exists on disk, keyed by fork, or nil if filename does not
+// follow the naming scheme.
+func relationForks(filename string) map[string][]string {
+	if _, _, _, ok := parseRelFileName(filename); !ok {
+		return nil
+	}
+	forks := map[string][]string{}
+	for _, fork := range relForkOrder {
+		if segs := relSegmentPaths(relForkPath(filename, fork)); len(segs) > 0 {
+			forks[fork] = segs
+		}
+	}
+	return forks
+}
+
+// forksSummary describes the forks found for the banner and fork, e.g.
+// "main (3 segments), fsm, vm", marking the current one with *.
+func forksSummary(forks map[string][]string, current string) string {
+	var parts []string
+	for _, fork := range relForkOrder {
+		segs, ok := forks[fork]
+		if !ok {
+			continue
+		}
+		s := fork
+		if fork == current {
+			s += "*"
+		}
+		if len(segs) > 1 {
+			s += fmt.Sprintf(" (%d segments)", len(segs))
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// fileTypeLabel describes a file for banners and the file list. Init forks
+// of unlogged relations are labelled as such: they are empty for tables
+// and hold only the metapage for indexes, which is expected.
+func fileTypeLabel(filename string, detected string, totalPages int64) string {
+	if relFork(filename) != ForkInit {
+		return detected
+	}
+	if totalPages == 0 {
+		return "empty init fork"
+	}
+	return detected + " init fork"
+}
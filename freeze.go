@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const FirstNormalTransactionId = 3
+
+// dsn is the libpq connection string given with --dsn. Commands that need
+// catalog values fetch them through psql when they are not typed in.
+var dsn string
+
+// xidPrecedes mirrors TransactionIdPrecedes: normal XIDs compare modulo
+// 2^32, permanent ones (< 3) by value.
+func xidPrecedes(a, b uint32) bool {
+	if a < FirstNormalTransactionId || b < FirstNormalTransactionId {
+		return a < b
+	}
+	return int32(a-b) < 0
+}
+
+// mxidPrecedes mirrors MultiXactIdPrecedes.
+func mxidPrecedes(a, b uint32) bool { return int32(a-b) < 0 }
+
+// psqlQuery runs a query through psql against dsn and returns the fields
+// of the single result row.
+func psqlQuery(dsn, query string) ([]string, error) {
+	rows, err := psqlRows(dsn, query)
+	if err != nil {
+		return nil, err
+	}
+	return rows[0], nil
+}
+
+// psqlRows runs a query through psql against dsn and returns the fields
+// of every result row; no rows is an error.
+func psqlRows(dsn, query string) ([][]string, error) {
+	out, err := exec.Command("psql", "-X", "-A", "-t", "-q", "-F", "|", "-d", dsn, "-c", query).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			return nil, fmt.Errorf("psql: %s", strings.TrimSpace(string(ee.Stderr)))
+		}
+		return nil, fmt.Errorf("psql: %w", err)
+	}
+	text := strings.TrimSpace(string(out))
+	if text == "" {
+		return nil, fmt.Errorf("query returned no rows")
+	}
+	var rows [][]string
+	for _, line := range strings.Split(text, "\n") {
+		rows = append(rows, strings.Split(line, "|"))
+	}
+	return rows, nil
+}
+
+// fetchFreezeLimits looks up relfrozenxid and relminmxid of the relation
+// whose relfilenode the file name carries.
+func fetchFreezeLimits(dsn, filename string) (relname string, frozenXid, minMxid uint32, err error) {
+	node, _, _, ok := parseRelFileName(filename)
+	if !ok {
+		return "", 0, 0, fmt.Errorf("%s is not named <relfilenode>[.segment]", filename)
+	}
+	row, err := psqlQuery(dsn, fmt.Sprintf(
+		"SELECT relname, relfrozenxid, relminmxid FROM pg_class WHERE pg_relation_filenode(oid) = %s", node))
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if len(row) != 3 {
+		return "", 0, 0, fmt.Errorf("unexpected psql output %q", strings.Join(row, "|"))
+	}
+	x, err1 := strconv.ParseUint(row[1], 10, 32)
+	m, err2 := strconv.ParseUint(row[2], 10, 32)
+	if err1 != nil || err2 != nil {
+		return "", 0, 0, fmt.Errorf("unexpected psql output %q", strings.Join(row, "|"))
+	}
+	return row[0], uint32(x), uint32(m), nil
+}
+
+// freezeLimits resolves the relfrozenxid/relminmxid for a freeze audit from
+// typed-in arguments, falling back to --dsn. minMxid is 0 when unknown.
+func freezeLimits(filename string, args []string) (frozenXid, minMxid uint32, err error) {
+	if len(args) == 0 {
+		if dsn == "" {
+			return 0, 0, fmt.Errorf("give relfrozenxid [relminmxid] or start with --dsn to fetch them")
+		}
+		relname, x, m, err := fetchFreezeLimits(dsn, filename)
+		if err != nil {
+			return 0, 0, err
+		}
+		fmt.Printf("Fetched from %s: relfrozenxid=%d relminmxid=%d\n", relname, x, m)
+		return x, m, nil
+	}
+	x, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid relfrozenxid %q", args[0])
+	}
+	if len(args) > 1 {
+		m, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid relminmxid %q", args[1])
+		}
+		minMxid = uint32(m)
+	}
+	return uint32(x), minMxid, nil
+}
+
+// CmdFreezeAudit reports heap tuples that VACUUM would reject with "found
+// xmin/xmax from before relfrozenxid" or "found multixact from before
+// relminmxid": unfrozen xmins and any normal xmax older than the limits.
+func CmdFreezeAudit(filename string, totalPages int64, sr scanRange, frozenXid, minMxid uint32) {
+	fmt.Println()
+	fmt.Printf("=== Freeze Audit (relfrozenxid %d", frozenXid)
+	if minMxid != 0 {
+		fmt.Printf(", relminmxid %d", minMxid)
+	}
+	fmt.Println(") ===")
+
+	tuples, badXmin, badXmax, badMulti := 0, 0, 0, 0
+	first, end := sr.bounds(totalPages)
+	for i := first; i < end; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil || pg.Detected != PageTypeHeap || sr.skips(pg) {
+			continue
+		}
+		for n, lp := range pg.Items {
+			if lp.Flags() != LPNormal || lp.Length() < HeapTupleHdrSize || int(lp.Offset())+HeapTupleHdrSize > PageSize {
+				continue
+			}
+			tuples++
+			t := pg.ParseHeapTupleHeader(lp.Offset())
+			if t.Infomask&HeapXminFrozen != HeapXminFrozen && t.Xmin >= FirstNormalTransactionId && xidPrecedes(t.Xmin, frozenXid) {
+				badXmin++
+				note := ""
+				if t.Infomask&HeapXminFrozen == HeapXminInvalid {
+					note = " (aborted)"
+				}
+				fmt.Printf("  page %d item %d: xmin %d%s precedes relfrozenxid and is not frozen\n", i, n+1, t.Xmin, note)
+			}
+			switch {
+			case t.Infomask&HeapXmaxIsMulti != 0:
+				if minMxid != 0 && t.Xmax != 0 && mxidPrecedes(t.Xmax, minMxid) {
+					badMulti++
+					fmt.Printf("  page %d item %d: multixact %d precedes relminmxid\n", i, n+1, t.Xmax)
+				}
+			case t.Xmax >= FirstNormalTransactionId && xidPrecedes(t.Xmax, frozenXid):
+				badXmax++
+				fmt.Printf("  page %d item %d: xmax %d precedes relfrozenxid\n", i, n+1, t.Xmax)
+			}
+		}
+	}
+
+	if badXmin+badXmax+badMulti == 0 {
+		fmt.Println("  No tuples older than the freeze limits.")
+	}
+	fmt.Println()
+	fmt.Printf("  Tuples checked     : %d\n", tuples)
+	fmt.Printf("  Unfrozen old xmin  : %d\n", badXmin)
+	fmt.Printf("  Old xmax           : %d\n", badXmax)
+	if minMxid != 0 {
+		fmt.Printf("  Old multixact      : %d\n", badMulti)
+	}
+	fmt.Println()
+}
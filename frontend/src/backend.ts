@@ -1,4 +1,4 @@
-import type { FileInfo, PageDetail } from "./types";
+import type { ByteInfo, FileInfo, PageDetail } from "./types";
 
 export interface FileEntry {
   index: number;
@@ -11,6 +11,7 @@ export interface DataBackend {
   getFiles(): Promise<FileEntry[]>;
   getFileInfo(fileIdx: number): Promise<FileInfo>;
   getPageDetail(fileIdx: number, pageNum: number): Promise<PageDetail>;
+  getByteInfo?(fileIdx: number, pageNum: number, offset: number): Promise<ByteInfo>;
   openFile?(): Promise<FileEntry[]>;
   closeFile?(fileIdx: number): Promise<FileEntry[]>;
 }
@@ -1,9 +1,10 @@
 import type { DataBackend, FileEntry } from "../backend";
-import type { FileInfo, PageDetail } from "../types";
+import type { ByteInfo, FileInfo, PageDetail } from "../types";
 import {
   GetFiles as WailsGetFiles,
   GetFileInfo as WailsGetFileInfo,
   GetPageDetail as WailsGetPageDetail,
+  GetByteInfo as WailsGetByteInfo,
   OpenFile as WailsOpenFile,
   CloseFile as WailsCloseFile,
 } from "../../wailsjs/go/main/App";
@@ -18,6 +19,9 @@ export const wailsBackend: DataBackend = {
   getPageDetail(fileIdx: number, pageNum: number): Promise<PageDetail> {
     return WailsGetPageDetail(fileIdx, pageNum);
   },
+  getByteInfo(fileIdx: number, pageNum: number, offset: number): Promise<ByteInfo> {
+    return WailsGetByteInfo(fileIdx, pageNum, offset);
+  },
   openFile(): Promise<FileEntry[]> {
     return WailsOpenFile();
   },
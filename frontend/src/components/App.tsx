@@ -1,5 +1,5 @@
-import { useCallback, useEffect, useState } from "react";
-import type { FileInfo, PageDetail, TooltipContent, TooltipState, SelectedElement } from "../types";
+import { useCallback, useEffect, useRef, useState } from "react";
+import type { ByteInfo, FileInfo, PageDetail, TooltipContent, TooltipState, SelectedElement } from "../types";
 import type { DataBackend, FileEntry } from "../backend";
 import { Sidebar } from "./Sidebar";
 import { PageSVG } from "./PageSVG";
@@ -19,12 +19,15 @@ export function App({ backend, repoUrl }: AppProps) {
   const [pageDetail, setPageDetail] = useState<PageDetail | null>(null);
   const [tooltip, setTooltip] = useState<TooltipState | null>(null);
   const [selectedElement, setSelectedElement] = useState<SelectedElement | null>(null);
+  const [byteInfo, setByteInfo] = useState<ByteInfo | null>(null);
+  const cursorOffset = useRef<number | null>(null);
 
   const loadFile = useCallback((fileIdx: number) => {
     setSelectedFileIdx(fileIdx);
     setPageDetail(null);
     setSelectedPage(0);
     setSelectedElement(null);
+    setByteInfo(null);
     backend.getFileInfo(fileIdx).then((data) => {
       setFileInfo(data);
       if (data.total_pages > 0) {
@@ -36,6 +39,7 @@ export function App({ backend, repoUrl }: AppProps) {
   const loadPage = useCallback((n: number) => {
     setSelectedPage(n);
     setSelectedElement(null);
+    setByteInfo(null);
     backend.getPageDetail(selectedFileIdx, n).then(setPageDetail);
   }, [backend, selectedFileIdx]);
 
@@ -46,6 +50,16 @@ export function App({ backend, repoUrl }: AppProps) {
     });
   }, [backend, loadFile]);
 
+  // Only the answer for the latest cursor position is shown; replies to
+  // earlier moves that arrive late are dropped.
+  const handleCursor = useCallback((offset: number) => {
+    if (!backend.getByteInfo) return;
+    cursorOffset.current = offset;
+    backend.getByteInfo(selectedFileIdx, selectedPage, offset).then((info) => {
+      if (cursorOffset.current === offset) setByteInfo(info);
+    });
+  }, [backend, selectedFileIdx, selectedPage]);
+
   const showTooltip = useCallback((evt: React.MouseEvent, content: TooltipContent) => {
     setTooltip({ x: evt.clientX + 12, y: evt.clientY + 12, content });
   }, []);
@@ -148,6 +162,7 @@ export function App({ backend, repoUrl }: AppProps) {
           onSelect={loadPage}
           selectedElement={selectedElement}
           pageDetail={pageDetail}
+          byteInfo={byteInfo}
         />
         <div className="viewer">
           {pageDetail ? (
@@ -156,6 +171,7 @@ export function App({ backend, repoUrl }: AppProps) {
               showTooltip={showTooltip}
               hideTooltip={hideTooltip}
               onSelect={setSelectedElement}
+              onCursor={backend.getByteInfo ? handleCursor : undefined}
             />
           ) : (
             <div className="loading">Select a page</div>
@@ -170,7 +186,7 @@ export function App({ backend, repoUrl }: AppProps) {
             >
               ✕
             </button>
-            <DetailPanel element={selectedElement} detail={pageDetail} />
+            <DetailPanel element={selectedElement} detail={pageDetail} byteInfo={byteInfo} />
           </div>
         )}
       </div>
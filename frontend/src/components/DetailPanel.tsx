@@ -1,16 +1,17 @@
 import { PAGE_SIZE } from "../colors";
-import type { PageDetail, SelectedElement } from "../types";
+import type { ByteInfo, PageDetail, SelectedElement } from "../types";
 
 interface DetailPanelProps {
-  element: SelectedElement;
+  element: SelectedElement | null;
   detail: PageDetail;
+  byteInfo?: ByteInfo | null;
 }
 
-export function DetailPanel({ element, detail }: DetailPanelProps) {
+export function DetailPanel({ element, detail, byteInfo }: DetailPanelProps) {
   let title = "";
   const rows: [string, string | number][] = [];
 
-  if (element.type === "region") {
+  if (element?.type === "region") {
     const r = element.data;
     title = r.name;
     rows.push(
@@ -29,7 +30,7 @@ export function DetailPanel({ element, detail }: DetailPanelProps) {
         rows.push([k, v]);
       }
     }
-  } else if (element.type === "linp") {
+  } else if (element?.type === "linp") {
     const lp = element.data;
     title = `Line Pointer #${lp.index}`;
     rows.push(
@@ -37,7 +38,7 @@ export function DetailPanel({ element, detail }: DetailPanelProps) {
       ["Points to offset", lp.offset],
       ["Points to length", `${lp.length} bytes`]
     );
-  } else if (element.type === "tuple") {
+  } else if (element?.type === "tuple") {
     const t = element.data;
     title = `Tuple ${t.index}`;
     rows.push(["Status", t.status], ["Offset", t.offset], ["Length", `${t.length} bytes`]);
@@ -50,17 +51,50 @@ export function DetailPanel({ element, detail }: DetailPanelProps) {
 
   return (
     <div className="detail-panel">
-      <h3>{title}</h3>
+      {element && (
+        <>
+          <h3>{title}</h3>
+          <table>
+            <tbody>
+              {rows.map((row, i) => (
+                <tr key={i}>
+                  <td>{row[0]}</td>
+                  <td>{String(row[1])}</td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        </>
+      )}
+      {byteInfo && <ByteInspector info={byteInfo} />}
+    </div>
+  );
+}
+
+// ByteInspector shows the field under the page view's cursor, decoded the
+// way the shell's where command does.
+function ByteInspector({ info }: { info: ByteInfo }) {
+  const hex = (n: number, w: number) => n.toString(16).toUpperCase().padStart(w, "0");
+  return (
+    <>
+      <h3>
+        Byte {info.offset} (0x{hex(info.offset, 4)}) = 0x{hex(info.value, 2)}
+      </h3>
       <table>
         <tbody>
-          {rows.map((row, i) => (
-            <tr key={i}>
-              <td>{row[0]}</td>
-              <td>{String(row[1])}</td>
+          {(info.detail ?? []).map((line, i) => (
+            <tr key={`d-${i}`}>
+              <td colSpan={2}>{line}</td>
+            </tr>
+          ))}
+          {(info.values ?? []).map((v) => (
+            <tr key={v.label}>
+              <td>{v.label}</td>
+              <td>{v.value}</td>
             </tr>
           ))}
         </tbody>
       </table>
-    </div>
+    </>
   );
 }
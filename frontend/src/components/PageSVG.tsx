@@ -1,4 +1,4 @@
-import { useMemo, useState, useCallback } from "react";
+import { useMemo, useState, useCallback, useRef } from "react";
 import type React from "react";
 import { REGION_COLORS, statusColor, PAGE_SIZE } from "../colors";
 import type {
@@ -11,6 +11,8 @@ interface PageSVGProps {
   showTooltip: (evt: React.MouseEvent, content: TooltipContent) => void;
   hideTooltip: () => void;
   onSelect: (element: SelectedElement) => void;
+  // Called with the byte offset under the mouse as it moves over the grid
+  onCursor?: (offset: number) => void;
 }
 
 // Grid layout: 32 columns × 64 rows = 2048 cells, each cell = 4 bytes
@@ -231,6 +233,7 @@ export function PageSVG({
   showTooltip,
   hideTooltip,
   onSelect,
+  onCursor,
 }: PageSVGProps) {
   const cellMap = useMemo(() => buildCellMap(detail), [detail]);
   const regionTypes = useMemo(
@@ -256,6 +259,22 @@ export function PageSVG({
     setHoveredRegion(null);
   }, []);
 
+  // A cell spans BYTES_PER_CELL bytes; the mouse position within it picks
+  // the byte the cursor is on.
+  const cursorOffset = useRef<number | null>(null);
+  const onCellMove = useCallback(
+    (evt: React.MouseEvent<SVGRectElement>, cellIdx: number) => {
+      if (!onCursor) return;
+      const box = evt.currentTarget.getBoundingClientRect();
+      const k = Math.floor(((evt.clientX - box.left) / box.width) * BYTES_PER_CELL);
+      const offset = cellIdx * BYTES_PER_CELL + Math.min(Math.max(k, 0), BYTES_PER_CELL - 1);
+      if (offset === cursorOffset.current) return;
+      cursorOffset.current = offset;
+      onCursor(offset);
+    },
+    [onCursor]
+  );
+
   return (
     <div className="page-view">
       {/* Left: grid */}
@@ -320,7 +339,10 @@ export function PageSVG({
                 stroke={isHighlighted ? color.text : color.stroke}
                 strokeWidth={isHighlighted ? 1.5 : 0.5}
                 cursor="pointer"
-                onMouseMove={(evt) => showTooltip(evt, cell.tooltip)}
+                onMouseMove={(evt) => {
+                  showTooltip(evt, cell.tooltip);
+                  onCellMove(evt, i);
+                }}
                 onMouseLeave={() => {
                   hideTooltip();
                   onUnhover();
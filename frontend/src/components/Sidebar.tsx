@@ -1,4 +1,4 @@
-import type { FileInfo, PageDetail, SelectedElement } from "../types";
+import type { ByteInfo, FileInfo, PageDetail, SelectedElement } from "../types";
 import { DetailPanel } from "./DetailPanel";
 
 interface SidebarProps {
@@ -7,9 +7,10 @@ interface SidebarProps {
   onSelect: (pageNum: number) => void;
   selectedElement: SelectedElement | null;
   pageDetail: PageDetail | null;
+  byteInfo: ByteInfo | null;
 }
 
-export function Sidebar({ fileInfo, selectedPage, onSelect, selectedElement, pageDetail }: SidebarProps) {
+export function Sidebar({ fileInfo, selectedPage, onSelect, selectedElement, pageDetail, byteInfo }: SidebarProps) {
   return (
     <div className="sidebar">
       <div className="sidebar-header">Pages</div>
@@ -27,8 +28,8 @@ export function Sidebar({ fileInfo, selectedPage, onSelect, selectedElement, pag
         ))}
       </ul>
       <div className="sidebar-detail">
-        {selectedElement && pageDetail ? (
-          <DetailPanel element={selectedElement} detail={pageDetail} />
+        {(selectedElement || byteInfo) && pageDetail ? (
+          <DetailPanel element={selectedElement} detail={pageDetail} byteInfo={byteInfo} />
         ) : (
           <div className="sidebar-placeholder">
             Select a block to see its details
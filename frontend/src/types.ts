@@ -4,6 +4,7 @@ export interface PageSummary {
   num_items: number;
   free_space: number;
   special_size: number;
+  anomalies?: number;
 }
 
 export interface FileInfo {
@@ -46,6 +47,12 @@ export interface MetaField {
   size: number;
 }
 
+export interface Annotation {
+  kind: string;
+  item?: number;
+  message: string;
+}
+
 export interface PageDetail {
   page_num: number;
   type: string;
@@ -56,6 +63,19 @@ export interface PageDetail {
   tuples: TupleInfo[];
   special_info?: Record<string, string>;
   meta_fields?: MetaField[];
+  annotations?: Annotation[];
+}
+
+export interface ByteValue {
+  label: string;
+  value: string;
+}
+
+export interface ByteInfo {
+  offset: number;
+  value: number;
+  detail: string[];
+  values: ByteValue[];
 }
 
 export interface TooltipContent {
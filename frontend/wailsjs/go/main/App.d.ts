@@ -5,3 +5,4 @@ export function GetFileInfo(fileIdx: number): Promise<main.FileInfo>;
 export function GetPageDetail(fileIdx: number, pageNum: number): Promise<main.PageDetail>;
 export function OpenFile(): Promise<main.FileEntry[]>;
 export function CloseFile(fileIdx: number): Promise<main.FileEntry[]>;
+export function GetByteInfo(fileIdx: number, pageNum: number, offset: number): Promise<main.ByteInfo>;
@@ -21,3 +21,7 @@ export function OpenFile() {
 export function CloseFile(arg1) {
   return window['go']['main']['App']['CloseFile'](arg1);
 }
+
+export function GetByteInfo(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetByteInfo'](arg1, arg2, arg3);
+}
@@ -12,6 +12,7 @@ export namespace main {
     num_items: number;
     free_space: number;
     special_size: number;
+    anomalies?: number;
   }
 
   export interface FileInfo {
@@ -46,6 +47,12 @@ export namespace main {
     properties: Record<string, string>;
   }
 
+  export interface Annotation {
+    kind: string;
+    item?: number;
+    message: string;
+  }
+
   export interface PageDetail {
     page_num: number;
     type: string;
@@ -54,5 +61,18 @@ export namespace main {
     line_pointers: LinePointerInfo[];
     tuples: TupleInfo[];
     special_info?: Record<string, string>;
+    annotations?: Annotation[];
+  }
+
+  export interface ByteValue {
+    label: string;
+    value: string;
+  }
+
+  export interface ByteInfo {
+    offset: number;
+    value: number;
+    detail: string[];
+    values: ByteValue[];
   }
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// GIN data leaf pages (ginblock.h): after the page header, a MAXALIGNed
+// right bound item pointer, then, on GIN_COMPRESSED pages (9.4+), the
+// posting list segments up to pd_lower. Each GinPostingList is its first
+// item pointer, a uint16 byte count and that many bytes of varbyte-encoded
+// deltas between TIDs packed as block<<11 | offset (ginpostinglist.c).
+// Older pages hold a plain array of maxoff item pointers instead.
+const (
+	ginDataStart              = PageHeaderSize + 8 // PageGetContents + MAXALIGN(ItemPointerData)
+	ginPostingListHdrSize     = 8
+	ginSegmentMinSize         = 128 // GinPostingListSegmentMinSize
+	ginMaxHeapTuplesPerPgBits = 11
+)
+
+// ginSegment is one GinPostingListSegment of a compressed data leaf page.
+type ginSegment struct {
+	Offset      int // page offset of the segment
+	NBytes      int // length of the varbyte-encoded deltas
+	First, Last HeapTID
+	Count       int    // TIDs, the first included
+	Problem     string // why decoding stopped or the TIDs are out of order
+}
+
+// Size is the segment's footprint on the page, header included and
+// SHORTALIGNed (SizeOfGinPostingList).
+func (s ginSegment) Size() int { return (ginPostingListHdrSize + s.NBytes + 1) &^ 1 }
+
+func ginTIDValue(t HeapTID) uint64 {
+	return uint64(t.Block)<<ginMaxHeapTuplesPerPgBits | uint64(t.Offset)
+}
+
+// ginLeafSegments decodes the posting list segments of a compressed GIN
+// data leaf page; a segment whose header runs past pd_lower ends the list.
+func ginLeafSegments(p *Page) []ginSegment {
+	var segs []ginSegment
+	end := min(int(p.Header.Lower), PageSize)
+	for off := ginDataStart; off+ginPostingListHdrSize <= end; {
+		s := ginSegment{
+			Offset: off,
+			First:  readTID(p.Data[off:]),
+			NBytes: int(binary.LittleEndian.Uint16(p.Data[off+6:])),
+			Count:  1,
+		}
+		s.Last = s.First
+		data := p.Data[off+ginPostingListHdrSize : min(off+ginPostingListHdrSize+s.NBytes, end)]
+		if len(data) < s.NBytes {
+			s.Problem = fmt.Sprintf("%d bytes run past pd_lower", s.NBytes)
+		}
+		val := ginTIDValue(s.First)
+		for i := 0; i < len(data) && s.Problem == ""; {
+			var delta uint64
+			shift := 0
+			for ; i < len(data); i++ {
+				delta |= uint64(data[i]&0x7F) << shift
+				shift += 7
+				if data[i]&0x80 == 0 {
+					break
+				}
+			}
+			if i == len(data) {
+				s.Problem = "truncated varbyte"
+				break
+			}
+			i++
+			if delta == 0 {
+				s.Problem = fmt.Sprintf("zero delta after %d TIDs", s.Count)
+				break
+			}
+			val += delta
+			s.Count++
+		}
+		s.Last = HeapTID{uint32(val >> ginMaxHeapTuplesPerPgBits), uint16(val & (1<<ginMaxHeapTuplesPerPgBits - 1))}
+		if n := len(segs); n > 0 && s.Problem == "" && ginTIDValue(s.First) <= ginTIDValue(segs[n-1].Last) {
+			s.Problem = fmt.Sprintf("first TID not after %s", segs[n-1].Last)
+		}
+		segs = append(segs, s)
+		off += s.Size()
+	}
+	return segs
+}
+
+// printGINDataLeaf lists the TIDs of a GIN posting tree leaf: segment by
+// segment on compressed pages, so fill and fragmentation show, or as the
+// count of the plain item pointer array on pre-9.4 pages.
+func printGINDataLeaf(p *Page) {
+	special := p.SpecialData()
+	flags := binary.LittleEndian.Uint16(special[6:8])
+	rightBound := readTID(p.Data[PageHeaderSize:]).String()
+	if binary.LittleEndian.Uint32(special[0:4]) == InvalidBlock {
+		rightBound = "none (rightmost page)"
+	}
+	if flags&GINCompressed == 0 {
+		n := int(binary.LittleEndian.Uint16(special[4:6]))
+		fmt.Printf("  Uncompressed posting list (pre-9.4): %d item pointers, right bound %s\n", n, rightBound)
+		if n > 0 && ginDataStart+n*ItemPointerSz <= PageSize {
+			fmt.Printf("  First / last TID   : %s / %s\n", readTID(p.Data[ginDataStart:]), readTID(p.Data[ginDataStart+(n-1)*ItemPointerSz:]))
+		}
+		return
+	}
+
+	segs := ginLeafSegments(p)
+	fmt.Printf("  Right bound        : %s\n", rightBound)
+	fmt.Println()
+	fmt.Printf("  %-4s %-6s %-5s %-6s %-14s %-14s %-6s %s\n", "Seg", "Offset", "Size", "Bytes", "First TID", "Last TID", "TIDs", "Bytes/TID")
+	fmt.Printf("  %-4s %-6s %-5s %-6s %-14s %-14s %-6s %s\n", "---", "------", "----", "-----", "---------", "--------", "----", "---------")
+	tids, used, small := 0, 0, 0
+	for i, s := range segs {
+		fmt.Printf("  %-4d %-6d %-5d %-6d %-14s %-14s %-6d %.2f", i+1, s.Offset, s.Size(), s.NBytes, s.First, s.Last, s.Count, float64(s.Size())/float64(s.Count))
+		if s.Problem != "" {
+			fmt.Printf("  <%s>", s.Problem)
+		}
+		fmt.Println()
+		tids += s.Count
+		used += s.Size()
+		if s.Size() < ginSegmentMinSize && i < len(segs)-1 {
+			small++
+		}
+	}
+	fmt.Println()
+	fmt.Printf("  Segments           : %d (%d below %d bytes, not counting the last)\n", len(segs), small, ginSegmentMinSize)
+	fmt.Printf("  TIDs               : %d\n", tids)
+	fmt.Printf("  Segment bytes      : %d (free %d)\n", used, max(int(p.Header.Upper)-int(p.Header.Lower), 0))
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GistRootBlkno is GIST_ROOT_BLKNO; the GiST root never moves.
+const GistRootBlkno = 0
+
+// gistStoredType is the key type the built-in GiST opclass for typ stores
+// in index tuples: point_ops keeps each point as a degenerate box (and a
+// bounding box on internal pages), box_ops and range_ops the value itself.
+func gistStoredType(typ string) (string, bool) {
+	switch {
+	case typ == "point":
+		return "box", true
+	case typ == "box", rangeSubtypes[typ] != "":
+		return typ, true
+	}
+	return "", false
+}
+
+// gistTupleKeys decodes the key columns of a GiST tuple according to
+// schema. Leaf keys of point_ops are shown as the point they hold; union
+// keys of internal pages are shown as stored.
+func gistTupleKeys(p *Page, lp ItemId, schema []string) ([]string, error) {
+	start, end := int(lp.Offset()), int(lp.Offset())+int(lp.Length())
+	if lp.Length() < uint16(IndexTupleHdrSize) || end > PageSize {
+		return nil, fmt.Errorf("no tuple")
+	}
+	op, _ := p.GiSTOpaque()
+	leaf := op.Flags&GistFLeaf != 0
+	it := p.ParseIndexTupleHeader(lp.Offset())
+	var nulls []byte
+	dataOff := IndexTupleHdrSize
+	if it.HasNulls() {
+		nulls = p.Data[start+IndexTupleHdrSize : start+IndexTupleHdrSize+indexNullBitmapSize]
+		dataOff = indexDataOff(true)
+	}
+	if start+dataOff > end {
+		return nil, fmt.Errorf("tuple too short for its header")
+	}
+	data := p.Data[start+dataOff : end]
+	var keys []string
+	off := 0
+	for i, typ := range schema {
+		if nulls != nil && nulls[i/8]&(1<<(i%8)) == 0 {
+			keys = append(keys, "NULL")
+			continue
+		}
+		stored, ok := gistStoredType(typ)
+		if !ok {
+			return keys, fmt.Errorf("column %d: no built-in GiST opclass decoding for %s (supported: point, box, range types)", i+1, typ)
+		}
+		d, next, err := DecodeDatumAt(stored, data, off)
+		if err != nil {
+			return keys, fmt.Errorf("column %d (%s): %w", i+1, typ, err)
+		}
+		off = next
+		s := d.String()
+		if typ == "point" && leaf {
+			// A leaf point is the box (p,p)
+			if lo, hi, ok := strings.Cut(s, "),("); ok && lo+")" == "("+hi {
+				s = lo + ")"
+			}
+		}
+		keys = append(keys, s)
+	}
+	return keys, nil
+}
+
+func lsnStr(lsn uint64) string {
+	return fmt.Sprintf("%X/%08X", lsn>>32, lsn&0xFFFFFFFF)
+}
+
+// CmdGiSTCheck scans a GiST file and verifies rightlinks and NSNs,
+// reporting pages left with F_FOLLOW_RIGHT by an incomplete split.
+func CmdGiSTCheck(filename string, totalPages int64) {
+	fmt.Println()
+	fmt.Println("=== GiST Follow-Right / NSN Check ===")
+
+	checked, followRight, issues := 0, 0, 0
+	report := func(blk int64, format string, args ...interface{}) {
+		issues++
+		fmt.Printf("  page %d: %s\n", blk, fmt.Sprintf(format, args...))
+	}
+
+	for i := int64(0); i < totalPages; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil {
+			continue
+		}
+		op, ok := pg.GiSTOpaque()
+		if !ok {
+			continue
+		}
+		checked++
+		lsn := pg.Header.LSN
+
+		if op.NSN > lsn && lsn != 0 {
+			report(i, "NSN %s is ahead of page LSN %s", lsnStr(op.NSN), lsnStr(lsn))
+		}
+
+		if i == GistRootBlkno && op.Rightlink != InvalidBlock {
+			report(i, "root page has rightlink %d", op.Rightlink)
+		}
+
+		if op.Rightlink != InvalidBlock {
+			switch {
+			case int64(op.Rightlink) == i:
+				report(i, "rightlink points to itself")
+			case int64(op.Rightlink) >= totalPages:
+				report(i, "rightlink %d is beyond end of file (%d pages)", op.Rightlink, totalPages)
+			default:
+				rop, ok := GiSTPageOpaque{}, false
+				if right, err := ReadPage(filename, int64(op.Rightlink)); err == nil {
+					rop, ok = right.GiSTOpaque()
+				}
+				if !ok {
+					report(i, "rightlink %d is not a GiST page", op.Rightlink)
+				} else {
+					if (op.Flags&GistFLeaf != 0) != (rop.Flags&GistFLeaf != 0) {
+						report(i, "rightlink %d is on a different level (leaf flag differs)", op.Rightlink)
+					}
+					if rop.Flags&GistFDeleted != 0 && op.Flags&GistFDeleted == 0 {
+						report(i, "rightlink %d points to a deleted page", op.Rightlink)
+					}
+				}
+			}
+		}
+
+		if op.Flags&GistFFollowRight != 0 {
+			followRight++
+			switch {
+			case op.Rightlink == InvalidBlock:
+				report(i, "F_FOLLOW_RIGHT set but rightlink is invalid")
+			case op.Flags&GistFDeleted != 0:
+				report(i, "F_FOLLOW_RIGHT set on a deleted page")
+			case i == GistRootBlkno:
+				report(i, "F_FOLLOW_RIGHT set on the root page")
+			default:
+				fmt.Printf("  page %d: incomplete split, downlink for right sibling %d missing in parent (NSN %s)\n",
+					i, op.Rightlink, lsnStr(op.NSN))
+			}
+		}
+	}
+
+	if issues == 0 && followRight == 0 {
+		fmt.Println("  No problems found.")
+	}
+	fmt.Println()
+	fmt.Printf("  GiST pages checked : %d\n", checked)
+	fmt.Printf("  Incomplete splits  : %d (F_FOLLOW_RIGHT)\n", followRight)
+	fmt.Printf("  Issues             : %d\n", issues)
+	fmt.Println()
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// Hash index layout since PostgreSQL 10 (hash.h): the metapage holds
+// hashm_spares[HASH_MAX_SPLITPOINTS] at offset 52 and hashm_mapp after it.
+// Overflow pages (bitmap pages included) are numbered by bit: bit n of the
+// free-overflow bitmap is set while overflow page n is in use.
+const (
+	HashMaxSplitpoints = 98
+	HashMaxBitmaps     = 1024
+
+	hashSplitpointPhaseBits          = 2
+	hashSplitpointGroupsWithOnePhase = 10
+
+	hashMetaSparesOff = 52
+	hashMetaMappOff   = hashMetaSparesOff + HashMaxSplitpoints*4
+)
+
+// HashMeta is the part of HashMetaPageData needed to map buckets and
+// overflow bits to blocks.
+type HashMeta struct {
+	BMSize    uint16
+	BMShift   uint16
+	MaxBucket uint32
+	OvflPoint uint32
+	FirstFree uint32
+	NMaps     uint32
+	Spares    [HashMaxSplitpoints]uint32
+	Mapp      []uint32 // the nmaps bitmap page blocks
+}
+
+// parseHashMeta decodes the hash metapage p.
+func parseHashMeta(p *Page) (HashMeta, error) {
+	d := p.Data[PageHeaderSize:]
+	le := binary.LittleEndian
+	if magic := le.Uint32(d[0:4]); magic != HashMagic {
+		return HashMeta{}, fmt.Errorf("bad hashm_magic 0x%08X", magic)
+	}
+	m := HashMeta{
+		BMSize:    le.Uint16(d[20:22]),
+		BMShift:   le.Uint16(d[22:24]),
+		MaxBucket: le.Uint32(d[24:28]),
+		OvflPoint: le.Uint32(d[36:40]),
+		FirstFree: le.Uint32(d[40:44]),
+		NMaps:     le.Uint32(d[44:48]),
+	}
+	switch {
+	case m.OvflPoint >= HashMaxSplitpoints:
+		return m, fmt.Errorf("hashm_ovflpoint %d out of range", m.OvflPoint)
+	case m.NMaps > HashMaxBitmaps:
+		return m, fmt.Errorf("hashm_nmaps %d out of range", m.NMaps)
+	case m.BMSize == 0 || 1<<m.BMShift != int(m.BMSize)*8:
+		return m, fmt.Errorf("hashm_bmshift %d does not match hashm_bmsize %d", m.BMShift, m.BMSize)
+	}
+	for i := range m.Spares {
+		m.Spares[i] = le.Uint32(d[hashMetaSparesOff+4*i:])
+	}
+	for i := 0; i < int(m.NMaps); i++ {
+		m.Mapp = append(m.Mapp, le.Uint32(d[hashMetaMappOff+4*i:]))
+	}
+	return m, nil
+}
+
+// printHashArrays prints hashm_spares up to hashm_ovflpoint, one row per
+// splitpoint phase with the buckets it allocated and the block the first
+// of them starts at, so bucket numbers can be mapped to blocks by hand;
+// then hashm_mapp. Nonzero spares past hashm_ovflpoint are listed too,
+// as they should not exist.
+func printHashArrays(m HashMeta) {
+	fmt.Println("    hashm_spares     : overflow pages allocated up to each splitpoint phase")
+	fmt.Printf("      %5s  %-19s  %11s  %6s\n", "phase", "buckets", "first block", "spares")
+	for i := uint32(0); i <= m.OvflPoint; i++ {
+		first := uint32(0)
+		if i > 0 {
+			first = hashTotalBuckets(i - 1)
+		}
+		last := min(hashTotalBuckets(i)-1, m.MaxBucket)
+		buckets := fmt.Sprintf("%d-%d", first, last)
+		if first == last {
+			buckets = fmt.Sprint(first)
+		}
+		block := fmt.Sprint(m.BucketBlock(first))
+		if first > m.MaxBucket {
+			buckets, block = "(none yet)", "-"
+		}
+		fmt.Printf("      %5d  %-19s  %11s  %6d\n", i, buckets, block, m.Spares[i])
+	}
+	for i := m.OvflPoint + 1; i < HashMaxSplitpoints; i++ {
+		if m.Spares[i] != 0 {
+			fmt.Printf("      %5d  %-19s  %11s  %6d  (past hashm_ovflpoint!)\n", i, "-", "-", m.Spares[i])
+		}
+	}
+	fmt.Printf("    hashm_mapp       : %v (bitmap page blocks)\n", m.Mapp)
+}
+
+// hashTotalBuckets is _hash_get_totalbuckets: the number of buckets
+// allocated once the given splitpoint phase is complete.
+func hashTotalBuckets(phase uint32) uint32 {
+	if phase < hashSplitpointGroupsWithOnePhase {
+		return 1 << phase
+	}
+	group := hashSplitpointGroupsWithOnePhase + (phase-hashSplitpointGroupsWithOnePhase)>>hashSplitpointPhaseBits
+	total := uint32(1) << (group - 1)
+	phases := (phase-hashSplitpointGroupsWithOnePhase)&(1<<hashSplitpointPhaseBits-1) + 1
+	return total + (total>>hashSplitpointPhaseBits)*phases
+}
+
+// hashSpareIndex is _hash_spareindex: the splitpoint phase that
+// allocates bucket number numBucket (1-based).
+func hashSpareIndex(numBucket uint32) uint32 {
+	group := uint32(bits.Len32(numBucket - 1)) // pg_ceil_log2_32
+	if group < hashSplitpointGroupsWithOnePhase {
+		return group
+	}
+	phases := hashSplitpointGroupsWithOnePhase + (group-hashSplitpointGroupsWithOnePhase)<<hashSplitpointPhaseBits
+	return phases + (numBucket-1)>>(group-(hashSplitpointPhaseBits+1))&(1<<hashSplitpointPhaseBits-1)
+}
+
+// BucketBlock is BUCKET_TO_BLKNO: the primary page of bucket b.
+func (m HashMeta) BucketBlock(b uint32) uint32 {
+	blk := b + 1
+	if b > 0 {
+		blk += m.Spares[hashSpareIndex(b+1)-1]
+	}
+	return blk
+}
+
+// OvflBitBlock is bitno_to_blkno: the block of overflow page bit n.
+func (m HashMeta) OvflBitBlock(n uint32) uint32 {
+	n++
+	i := uint32(1)
+	for i < m.OvflPoint && n > m.Spares[i] {
+		i++
+	}
+	return hashTotalBuckets(i) + n
+}
+
+// OvflBlockBit is _hash_ovflblkno_to_bitno; ok is false if blk is not
+// where an overflow page can be.
+func (m HashMeta) OvflBlockBit(blk uint32) (n uint32, ok bool) {
+	for i := uint32(1); i <= m.OvflPoint; i++ {
+		if blk <= hashTotalBuckets(i) {
+			break
+		}
+		n := blk - hashTotalBuckets(i)
+		if n > m.Spares[i-1] && n <= m.Spares[i] {
+			return n - 1, true
+		}
+	}
+	return 0, false
+}
+
+// CmdHashBitmap reads the free-overflow bitmap of a hash index with the
+// metapage's spares and bitmap page list, reports which overflow pages are
+// free and which are in use, and checks that against the bucket chains:
+// an in-use page no chain reaches is leaked, a free one still chained will
+// be handed out twice.
+func CmdHashBitmap(filename string, totalPages int64) {
+	fmt.Println()
+	fmt.Println("=== Hash Overflow Bitmap ===")
+	metaPage, err := ReadPage(filename, 0)
+	if err != nil {
+		fmt.Printf("  Error reading metapage: %v\n", err)
+		fmt.Println()
+		return
+	}
+	m, err := parseHashMeta(metaPage)
+	if err != nil {
+		fmt.Printf("  Error: metapage: %v\n", err)
+		fmt.Println()
+		return
+	}
+
+	issues := 0
+	report := func(format string, args ...interface{}) {
+		issues++
+		fmt.Printf("  %s\n", fmt.Sprintf(format, args...))
+	}
+	hashFlag := func(blk uint32) (uint16, bool) {
+		if int64(blk) >= totalPages {
+			return 0, false
+		}
+		pg, err := ReadPage(filename, int64(blk))
+		if err != nil || isNewPage(pg) {
+			return 0, false
+		}
+		special := pg.SpecialData()
+		if len(special) < HashOpaqueSize || binary.LittleEndian.Uint16(special[14:16]) != HashPageID {
+			return 0, false
+		}
+		return binary.LittleEndian.Uint16(special[12:14]), true
+	}
+
+	// The bitmap: one bit per overflow page up to spares[ovflpoint].
+	maxBits := m.Spares[m.OvflPoint]
+	bitsPerMap := uint32(m.BMSize) * 8
+	if maxBits > m.NMaps*bitsPerMap {
+		report("%d overflow pages but %d bitmap pages cover only %d", maxBits, m.NMaps, m.NMaps*bitsPerMap)
+	}
+	maps := make([][]byte, m.NMaps)
+	isBitmap := map[uint32]bool{}
+	for i, blk := range m.Mapp {
+		isBitmap[blk] = true
+		if flag, ok := hashFlag(blk); !ok || flag&0x000F != LHBitmapPage {
+			report("hashm_mapp[%d] = block %d is not a bitmap page", i, blk)
+			continue
+		}
+		pg, _ := ReadPage(filename, int64(blk))
+		maps[i] = pg.Data[PageHeaderSize : PageHeaderSize+int(m.BMSize)]
+	}
+	inUse := func(n uint32) (used, known bool) {
+		page, bit := n>>m.BMShift, n&(bitsPerMap-1)
+		if int(page) >= len(maps) || maps[page] == nil {
+			return false, false
+		}
+		return maps[page][bit/8]&(1<<(bit%8)) != 0, true
+	}
+
+	// Follow every bucket chain, noting which bucket reaches each
+	// overflow page.
+	chained := map[uint32]uint32{}
+	for b := uint32(0); b <= m.MaxBucket; b++ {
+		blk := m.BucketBlock(b)
+		if flag, ok := hashFlag(blk); !ok || flag&0x000F != LHBucketPage {
+			report("bucket %d: block %d is not a bucket page", b, blk)
+			continue
+		}
+		for {
+			pg, err := ReadPage(filename, int64(blk))
+			if err != nil {
+				break
+			}
+			next := binary.LittleEndian.Uint32(pg.SpecialData()[4:8])
+			if next == InvalidBlock {
+				break
+			}
+			if prev, seen := chained[next]; seen {
+				report("bucket %d: overflow block %d already chained from bucket %d", b, next, prev)
+				break
+			}
+			flag, ok := hashFlag(next)
+			if !ok || flag&0x000F != LHOverflowPage {
+				report("bucket %d: block %d links to block %d, not an overflow page", b, blk, next)
+				break
+			}
+			chained[next] = b
+			if _, ok := m.OvflBlockBit(next); !ok {
+				report("bucket %d: overflow block %d has no bit in the bitmap", b, next)
+			}
+			blk = next
+		}
+	}
+
+	var free, used []int64
+	var bitmapPages, lowestFree int64 = 0, -1
+	for n := uint32(0); n < maxBits; n++ {
+		blk := m.OvflBitBlock(n)
+		u, known := inUse(n)
+		if !known {
+			continue
+		}
+		_, isChained := chained[blk]
+		switch {
+		case !u:
+			free = append(free, int64(blk))
+			if lowestFree < 0 {
+				lowestFree = int64(n)
+			}
+			if isChained {
+				report("block %d (bit %d) is free but chained from bucket %d", blk, n, chained[blk])
+			} else if flag, ok := hashFlag(blk); ok && flag&0x000F != 0 {
+				report("block %d (bit %d) is free but flagged %s", blk, n, hashFlags(flag)[0])
+			}
+		case isBitmap[blk]:
+			bitmapPages++
+		default:
+			used = append(used, int64(blk))
+			if !isChained {
+				report("block %d (bit %d) is in use but no bucket chain reaches it (leaked)", blk, n)
+			}
+		}
+	}
+	if lowestFree >= 0 && int64(m.FirstFree) > lowestFree {
+		report("hashm_firstfree %d is past free bit %d", m.FirstFree, lowestFree)
+	}
+
+	if issues == 0 {
+		fmt.Println("  No problems found.")
+	}
+	fmt.Println()
+	fmt.Printf("  Buckets            : %d (hashm_maxbucket %d)\n", m.MaxBucket+1, m.MaxBucket)
+	fmt.Printf("  Overflow bits      : %d (hashm_spares[%d]), %d per bitmap page\n", maxBits, m.OvflPoint, bitsPerMap)
+	fmt.Printf("  Bitmap pages       : %d (hashm_nmaps) at %s\n", m.NMaps, blockRanges(u32Blocks(m.Mapp), 10))
+	fmt.Printf("  hashm_firstfree    : %d\n", m.FirstFree)
+	fmt.Printf("  In use             : %d overflow pages, %d bitmap pages\n", len(used), bitmapPages)
+	fmt.Printf("  Free               : %d (%s)\n", len(free), blockRanges(free, 10))
+	fmt.Printf("  Issues             : %d\n", issues)
+	fmt.Println()
+}
+
+func u32Blocks(blks []uint32) []int64 {
+	out := make([]int64, len(blks))
+	for i, b := range blks {
+		out[i] = int64(b)
+	}
+	return out
+}
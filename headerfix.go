@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// headerBounds are values for pd_lower, pd_upper and pd_special, with the
+// evidence each was derived from.
+type headerBounds struct {
+	Lower, Upper, Special          int
+	LowerWhy, UpperWhy, SpecialWhy string
+}
+
+// plausibleLinePointer reports whether lp, the line pointer in slot i,
+// could have been written by PostgreSQL on a page whose special area
+// starts at special.
+func plausibleLinePointer(lp ItemId, i, special int) bool {
+	off, n := int(lp.Offset()), int(lp.Length())
+	switch lp.Flags() {
+	case LPUnused:
+		return lp.Raw == 0
+	case LPRedirect:
+		return n == 0 && off > 0 && off <= MaxHeapTuplesPerPage
+	case LPDead:
+		if n == 0 && off == 0 {
+			return true
+		}
+	}
+	// Tuples are MAXALIGNed and lie between the line pointer array and
+	// the special area.
+	return n > 0 && off%maxAlignment == 0 && off >= PageHeaderSize+(i+1)*ItemIdSize && off+n <= special
+}
+
+// inferHeaderBounds derives the header bounds a page must have had from
+// its contents: pd_special from the special area size of its type,
+// pd_lower from the line pointers that are plausible, and pd_upper from
+// the lowest tuple they point at. Trailing unused line pointers are not
+// counted, so a pd_lower that VACUUM left past them comes out lower; the
+// page is still valid with the suggested value.
+func inferHeaderBounds(p *Page) headerBounds {
+	var b headerBounds
+	raw := p.RawHeader
+
+	b.Special, b.SpecialWhy = int(raw.Special), "as stored"
+	if b.Special < PageHeaderSize || b.Special > PageSize {
+		size := maxAlign(minSpecialSize(p.AutoDetected))
+		b.Special = PageSize - size
+		switch {
+		case p.AutoDetected == PageTypeUnknown:
+			b.SpecialWhy = "page type unknown, assuming no special area"
+		case size == 0:
+			b.SpecialWhy = fmt.Sprintf("%s pages have no special area", p.AutoDetected)
+		default:
+			b.SpecialWhy = fmt.Sprintf("%d-byte %s special area", size, p.AutoDetected)
+		}
+	}
+
+	used, lowest := 0, b.Special
+	for i := 0; PageHeaderSize+(i+1)*ItemIdSize <= lowest; i++ {
+		off := PageHeaderSize + i*ItemIdSize
+		lp := ItemId{Raw: binary.LittleEndian.Uint32(p.Data[off : off+ItemIdSize])}
+		if !plausibleLinePointer(lp, i, b.Special) {
+			break
+		}
+		if lp.Raw != 0 {
+			used = i + 1
+		}
+		if lp.Length() > 0 && (lp.Flags() == LPNormal || lp.Flags() == LPDead) {
+			lowest = min(lowest, int(lp.Offset()))
+		}
+	}
+	b.Lower = PageHeaderSize + used*ItemIdSize
+	b.LowerWhy = fmt.Sprintf("%d plausible line pointer(s)", used)
+	b.Upper = lowest
+	if lowest == b.Special {
+		b.UpperWhy = "no tuples, the tuple area is empty"
+	} else {
+		b.UpperWhy = "start of the lowest tuple"
+	}
+	return b
+}
+
+// boundsBroken reports whether the stored header bounds break
+// 24 <= lower <= upper <= special <= 8192 or put pd_lower between line
+// pointers.
+func boundsBroken(h PageHeader) bool {
+	return h.Special < PageHeaderSize || h.Special > PageSize || h.Upper > h.Special ||
+		h.Lower < PageHeaderSize || h.Lower > h.Upper || (h.Lower-PageHeaderSize)%ItemIdSize != 0
+}
+
+// headerFixes returns one line per header bound of p whose stored value
+// differs from the inferred one, or nil if the bounds are consistent or
+// the page keeps no line pointer array (meta, bitmap and revmap pages).
+func headerFixes(p *Page) []string {
+	raw := p.RawHeader
+	if raw.Upper == 0 || !boundsBroken(raw) {
+		return nil
+	}
+	switch detectPageSubtype(p) {
+	case "meta", "bitmap", "revmap":
+		return nil
+	}
+	b := inferHeaderBounds(p)
+	var fixes []string
+	add := func(field string, stored, want int, why string) {
+		if stored != want {
+			fixes = append(fixes, fmt.Sprintf("%s %d (stored %d): %s", field, want, stored, why))
+		}
+	}
+	add("pd_lower", int(raw.Lower), b.Lower, b.LowerWhy)
+	add("pd_upper", int(raw.Upper), b.Upper, b.UpperWhy)
+	add("pd_special", int(raw.Special), b.Special, b.SpecialWhy)
+	return fixes
+}
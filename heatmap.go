@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// heatRamp holds the heatmap characters from coolest to hottest, and
+// heatColors the matching 256-color palette entries (green to red) used
+// when printing to a terminal.
+var (
+	heatRamp   = []byte(".:-=+*#%@")
+	heatColors = []int{28, 34, 70, 106, 142, 178, 172, 166, 160}
+)
+
+// Heatmap cells that carry no value.
+const (
+	heatNone       = -1 // page type the heatmap does not apply to
+	heatUnreadable = -2
+)
+
+// heatLevel maps a fraction in [0,1] onto the ramp: 0 gets the first
+// level of its own, the rest are split evenly over the remaining ones.
+func heatLevel(frac float64) int {
+	if frac <= 0 {
+		return 0
+	}
+	n := len(heatRamp) - 1
+	l := 1 + int(frac*float64(n))
+	return min(l, n)
+}
+
+// heatLevelRange describes the fractions a level stands for.
+func heatLevelRange(l int) string {
+	if l == 0 {
+		return "0%"
+	}
+	n := float64(len(heatRamp) - 1)
+	lo, hi := float64(l-1)/n*100, float64(l)/n*100
+	return fmt.Sprintf("%.0f-%.0f%%", lo, hi)
+}
+
+// heatmapPrinter prints one cell per page in the same row layout as the
+// map command as pages are scanned, counting pages per level for the scale.
+type heatmapPrinter struct {
+	width, digits int
+	color         bool
+	counts        []int
+	row           strings.Builder
+	rowStart      int64
+	cells         int
+}
+
+func newHeatmapPrinter(totalPages int64, width int, color bool) *heatmapPrinter {
+	return &heatmapPrinter{
+		width:  width,
+		digits: len(fmt.Sprint(max(totalPages-1, 0))),
+		color:  color,
+		counts: make([]int, len(heatRamp)),
+	}
+}
+
+// add appends the cell of page pageNum at level l.
+func (hp *heatmapPrinter) add(pageNum int64, l int) {
+	if hp.cells == 0 {
+		hp.rowStart = pageNum
+	}
+	switch {
+	case l == heatUnreadable:
+		hp.row.WriteByte('!')
+	case l == heatNone:
+		hp.row.WriteByte(' ')
+	case hp.color:
+		fmt.Fprintf(&hp.row, "\x1b[38;5;%dm%c\x1b[0m", heatColors[l], heatRamp[l])
+	default:
+		hp.row.WriteByte(heatRamp[l])
+	}
+	if l >= 0 {
+		hp.counts[l]++
+	}
+	hp.cells++
+	if hp.cells == hp.width {
+		hp.flush()
+	}
+}
+
+func (hp *heatmapPrinter) flush() {
+	if hp.cells > 0 {
+		fmt.Printf("  %*d %s\n", hp.digits, hp.rowStart, hp.row.String())
+		hp.row.Reset()
+		hp.cells = 0
+	}
+}
+
+// finish prints the last row and the scale with the number of pages at
+// each level.
+func (hp *heatmapPrinter) finish(scale func(l int) string) {
+	hp.flush()
+	fmt.Println()
+	for l, c := range heatRamp {
+		sym := string(c)
+		if hp.color {
+			sym = fmt.Sprintf("\x1b[38;5;%dm%c\x1b[0m", heatColors[l], c)
+		}
+		fmt.Printf("  %s %-18s: %d\n", sym, scale(l), hp.counts[l])
+	}
+}
+
+// keepTop inserts v into list, which holds at most n elements ordered by
+// before, so a report's "top pages" stay bounded however large the file.
+func keepTop[T any](list []T, v T, n int, before func(a, b T) bool) []T {
+	i := sort.Search(len(list), func(i int) bool { return before(v, list[i]) })
+	if i >= n {
+		return list
+	}
+	list = slices.Insert(list, i, v)
+	return list[:min(len(list), n)]
+}
+
+// CmdHeatmapDead shows, per page, the share of line pointers that are
+// LP_DEAD or point to a dead heap tuple. Runs of hot pages mark regions
+// where updates outpace VACUUM.
+func CmdHeatmapDead(filename string, totalPages int64, sr scanRange, width int, color bool) {
+	fmt.Println()
+	fmt.Printf("=== Dead Tuple Heatmap (%s) ===\n", sr.String(totalPages))
+
+	type hotPage struct {
+		page        int64
+		dead, items int
+	}
+	denser := func(a, b hotPage) bool { return a.dead*b.items > b.dead*a.items }
+	var hot []hotPage
+	hp := newHeatmapPrinter(totalPages, width, color)
+	dead, items := 0, 0
+	first, end := sr.bounds(totalPages)
+	for i := first; i < end; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil {
+			hp.add(i, heatUnreadable)
+			continue
+		}
+		if sr.skips(pg) {
+			hp.add(i, heatNone)
+			continue
+		}
+		// Meta pages keep their metadata below pd_lower, not line pointers
+		switch detectPageSubtype(pg) {
+		case "meta", "bitmap", "revmap":
+			hp.add(i, heatNone)
+			continue
+		}
+		if len(pg.Items) == 0 {
+			hp.add(i, heatNone)
+			continue
+		}
+		n := 0
+		for _, lp := range pg.Items {
+			if lp.Flags() == LPDead || heapTupleDead(pg, lp) {
+				n++
+			}
+		}
+		hp.add(i, heatLevel(float64(n)/float64(len(pg.Items))))
+		dead += n
+		items += len(pg.Items)
+		if n > 0 {
+			hot = keepTop(hot, hotPage{i, n, len(pg.Items)}, 5, denser)
+		}
+	}
+
+	hp.finish(heatLevelRange)
+	fmt.Println()
+	fmt.Printf("  Dead             : %d of %d line pointers\n", dead, items)
+	if len(hot) > 0 {
+		fmt.Println("  Densest pages    :")
+		for _, h := range hot {
+			fmt.Printf("    page %-8d : %d/%d dead\n", h.page, h.dead, h.items)
+		}
+	}
+	fmt.Println()
+}
+
+// CmdHeatmapLSN shows how recently each page was modified: pd_lsn placed
+// between the lowest and highest LSN in the file, the hottest level being
+// the pages written last. The file is read twice, first for the LSN range.
+func CmdHeatmapLSN(filename string, totalPages int64, sr scanRange, width int, color bool) {
+	fmt.Println()
+	fmt.Printf("=== LSN Recency Heatmap (%s) ===\n", sr.String(totalPages))
+
+	type pageLSN struct {
+		page int64
+		lsn  uint64
+	}
+	newer := func(a, b pageLSN) bool { return a.lsn > b.lsn }
+	var recent []pageLSN
+	var lo, hi uint64
+	seen := false
+	first, end := sr.bounds(totalPages)
+	for i := first; i < end; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil || isNewPage(pg) || sr.skips(pg) {
+			continue
+		}
+		lsn := pg.Header.LSN
+		if !seen || lsn < lo {
+			lo = lsn
+		}
+		if !seen || lsn > hi {
+			hi = lsn
+		}
+		seen = true
+		recent = keepTop(recent, pageLSN{i, lsn}, 5, newer)
+	}
+	if !seen {
+		fmt.Println("  No initialized pages.")
+		fmt.Println()
+		return
+	}
+
+	// Unlike densities, LSNs get no level of their own for the minimum
+	n := float64(len(heatRamp))
+	span := float64(hi-lo) + 1
+	hp := newHeatmapPrinter(totalPages, width, color)
+	for i := first; i < end; i++ {
+		pg, err := ReadPage(filename, i)
+		switch {
+		case err != nil:
+			hp.add(i, heatUnreadable)
+		case isNewPage(pg), sr.skips(pg):
+			hp.add(i, heatNone)
+		default:
+			hp.add(i, min(int(float64(pg.Header.LSN-lo)/span*n), len(heatRamp)-1))
+		}
+	}
+
+	hp.finish(func(l int) string {
+		return "from " + lsnStr(lo+uint64(span*float64(l)/n))
+	})
+	fmt.Println()
+	fmt.Printf("  Oldest pd_lsn    : %s\n", lsnStr(lo))
+	fmt.Printf("  Newest pd_lsn    : %s\n", lsnStr(hi))
+	fmt.Println("  Most recent      :")
+	for _, r := range recent {
+		fmt.Printf("    page %-8d : %s\n", r.page, lsnStr(r.lsn))
+	}
+	fmt.Println()
+}
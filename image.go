@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// imageWindow is the byte range of a raw disk, partition or image file
+// that holds a relation file's pages, set with --image-offset and
+// --image-length for files whose filesystem cannot be mounted. A zero
+// Length runs to the end of the device.
+type imageWindow struct {
+	Offset int64
+	Length int64
+}
+
+// imageWindows maps the files opened from the command line to their
+// window while --image-offset or --image-length is in effect.
+var imageWindows = map[string]imageWindow{}
+
+// parseByteSize parses a byte count with an optional suffix: s for
+// 512-byte sectors (as fdisk and LVM report them), or K, M, G, T (powers
+// of 1024).
+func parseByteSize(s string) (int64, error) {
+	mult := int64(1)
+	num := s
+	if n := len(s); n > 0 {
+		switch strings.ToUpper(s[n-1:]) {
+		case "S":
+			mult = 512
+		case "K":
+			mult = 1 << 10
+		case "M":
+			mult = 1 << 20
+		case "G":
+			mult = 1 << 30
+		case "T":
+			mult = 1 << 40
+		}
+		if mult != 1 {
+			num = s[:n-1]
+		}
+	}
+	v, err := strconv.ParseInt(num, 0, 64)
+	if err != nil || v < 0 || v > (1<<62)/mult {
+		return 0, fmt.Errorf("invalid byte count %q (bytes, or with an s, K, M, G or T suffix)", s)
+	}
+	return v * mult, nil
+}
+
+// fileSize returns the number of bytes of filename that hold pages: the
+// image window's length, or the whole file. Block devices report a zero
+// size to stat, so their size is found by seeking to the end.
+func fileSize(filename string) (int64, error) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return 0, err
+	}
+	size := fi.Size()
+	if fi.Mode()&os.ModeDevice != 0 {
+		f, err := os.Open(filename)
+		if err != nil {
+			return 0, err
+		}
+		size, err = f.Seek(0, io.SeekEnd)
+		f.Close()
+		if err != nil {
+			return 0, err
+		}
+	}
+	w, ok := imageWindows[filename]
+	if !ok {
+		return size, nil
+	}
+	if w.Offset > size {
+		return 0, fmt.Errorf("%s: image offset %d is past the end (%d bytes)", filename, w.Offset, size)
+	}
+	size -= w.Offset
+	if w.Length > 0 {
+		size = min(size, w.Length)
+	}
+	return size, nil
+}
+
+// pageFileOffset returns where page pageNum of filename starts in the
+// underlying file, refusing pages past the end of its image window.
+func pageFileOffset(filename string, pageNum int64) (int64, error) {
+	w, ok := imageWindows[filename]
+	if !ok {
+		return pageNum * PageSize, nil
+	}
+	if w.Length > 0 && (pageNum+1)*PageSize > w.Length {
+		return 0, fmt.Errorf("page %d is past the image length (%d bytes)", pageNum, w.Length)
+	}
+	return w.Offset + pageNum*PageSize, nil
+}
+
+// setImageWindow applies w to path when it is a file or device; directories
+// (a --pgdata, a backup) are left alone.
+func setImageWindow(path string, w imageWindow) {
+	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+		imageWindows[path] = w
+	}
+}
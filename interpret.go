@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// interpretMaxLen caps the bytes one interpret shows, so every row still
+// fits a line or two of the terminal.
+const interpretMaxLen = 64
+
+// CmdInterpret shows n bytes of the page starting at off read as every
+// common integer and float width in both byte orders, with hex and ASCII,
+// like a debugger memory window. Each row has one value per whole element
+// of that width in the range; widths longer than the range are left out.
+func CmdInterpret(p *Page, off, n int) {
+	n = min(n, PageSize-off, interpretMaxLen)
+	d := p.Data[off : off+n]
+	fmt.Println()
+	fmt.Printf("=== Interpret %d byte(s) at %d (0x%04X) ===\n", n, off, off)
+	if detail := whereDetail(p, off); len(detail) > 0 {
+		fmt.Printf("  %-18s : %s\n", "Starts in", detail[0])
+	}
+	hex := make([]string, n)
+	var ascii strings.Builder
+	for i, b := range d {
+		hex[i] = fmt.Sprintf("%02x", b)
+		if b >= 0x20 && b < 0x7F {
+			ascii.WriteByte(b)
+		} else {
+			ascii.WriteByte('.')
+		}
+	}
+	fmt.Printf("  %-18s : %s\n", "hex", strings.Join(hex, " "))
+	fmt.Printf("  %-18s : %s\n", "ASCII", ascii.String())
+
+	orders := []struct {
+		name string
+		bo   binary.ByteOrder
+	}{{"LE", binary.LittleEndian}, {"BE", binary.BigEndian}}
+	row := func(label string, width int, format func(b []byte) string) {
+		if n < width {
+			return
+		}
+		var vals []string
+		for i := 0; i+width <= n; i += width {
+			vals = append(vals, format(d[i:i+width]))
+		}
+		fmt.Printf("  %-18s : %s\n", label, strings.Join(vals, "  "))
+	}
+	for _, o := range orders {
+		bo := o.bo
+		row("int16 "+o.name, 2, func(b []byte) string { return strconv.Itoa(int(int16(bo.Uint16(b)))) })
+		row("uint16 "+o.name, 2, func(b []byte) string { return strconv.Itoa(int(bo.Uint16(b))) })
+		row("int32 "+o.name, 4, func(b []byte) string { return strconv.Itoa(int(int32(bo.Uint32(b)))) })
+		row("uint32 "+o.name, 4, func(b []byte) string { return strconv.FormatUint(uint64(bo.Uint32(b)), 10) })
+		row("int64 "+o.name, 8, func(b []byte) string { return strconv.FormatInt(int64(bo.Uint64(b)), 10) })
+		row("uint64 "+o.name, 8, func(b []byte) string { return strconv.FormatUint(bo.Uint64(b), 10) })
+		row("float4 "+o.name, 4, func(b []byte) string {
+			return strconv.FormatFloat(float64(math.Float32frombits(bo.Uint32(b))), 'g', -1, 32)
+		})
+		row("float8 "+o.name, 8, func(b []byte) string {
+			return strconv.FormatFloat(math.Float64frombits(bo.Uint64(b)), 'g', -1, 64)
+		})
+	}
+	fmt.Println()
+}
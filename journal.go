@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// journalMaxEntries caps the session journal; the oldest loads are
+// dropped first.
+const journalMaxEntries = 10000
+
+// journalEntry is what a page looked like when the shell loaded it.
+type journalEntry struct {
+	At    time.Time
+	File  string
+	Page  int64
+	LSN   uint64
+	Items int
+	Free  int64
+}
+
+// pageJournal records every page load of a shell session, so pages
+// watched on a live system can be compared over time.
+type pageJournal struct {
+	Start   time.Time
+	entries []journalEntry
+	last    *Page
+}
+
+func newPageJournal() *pageJournal { return &pageJournal{Start: time.Now()} }
+
+// journalKey identifies a page across the files a session switches
+// between with fork.
+type journalKey struct {
+	File string
+	Page int64
+}
+
+// journalFork labels the file of an entry by its fork, with the segment
+// number past the first segment.
+func journalFork(filename string) string {
+	if seg := relSegment(filename); seg > 0 {
+		return fmt.Sprintf("%s.%d", relFork(filename), seg)
+	}
+	return relFork(filename)
+}
+
+// record adds page p of filename to the journal unless it is the page
+// recorded last; every load, reload after a write included, yields a new
+// *Page.
+func (j *pageJournal) record(filename string, p *Page) {
+	if p == nil || p == j.last {
+		return
+	}
+	j.last = p
+	j.entries = append(j.entries, journalEntry{
+		At:    time.Now(),
+		File:  filename,
+		Page:  p.PageNum,
+		LSN:   p.Header.LSN,
+		Items: len(p.Items),
+		Free:  diffFreeSpace(p),
+	})
+	if len(j.entries) > journalMaxEntries {
+		j.entries = j.entries[len(j.entries)-journalMaxEntries:]
+	}
+}
+
+// journalChange describes how a page changed since it was last loaded.
+func journalChange(prev *journalEntry, e journalEntry) string {
+	if prev == nil {
+		return "first load"
+	}
+	var changes []string
+	if e.LSN != prev.LSN {
+		changes = append(changes, "lsn "+lsnStr(prev.LSN)+" -> "+lsnStr(e.LSN))
+	}
+	if e.Items != prev.Items {
+		changes = append(changes, fmt.Sprintf("items %+d", e.Items-prev.Items))
+	}
+	if e.Free != prev.Free {
+		changes = append(changes, fmt.Sprintf("free %+d", e.Free-prev.Free))
+	}
+	if len(changes) == 0 {
+		return "unchanged"
+	}
+	return strings.Join(changes, ", ")
+}
+
+// CmdJournal prints the page loads of the session in order, or those of
+// one page of filename when page is not -1, each with what changed since
+// the previous load of the same page of the same file. Times are relative
+// to the start of the session, and left out with --deterministic.
+func (j *pageJournal) CmdJournal(filename string, page int64) {
+	fmt.Println()
+	if page >= 0 {
+		fmt.Printf("=== Page Journal (page %d) ===\n", page)
+	} else {
+		fmt.Println("=== Page Journal ===")
+	}
+	prev := map[journalKey]*journalEntry{}
+	pages := map[journalKey]bool{}
+	loads, changed := 0, 0
+	for i := range j.entries {
+		e := j.entries[i]
+		key := journalKey{e.File, e.Page}
+		change := journalChange(prev[key], e)
+		prev[key] = &j.entries[i]
+		if page >= 0 && (e.File != filename || e.Page != page) {
+			continue
+		}
+		if loads == 0 {
+			fmt.Printf("  %-10s %-6s %8s  %-17s %6s %6s  %s\n", "Time", "Fork", "Page", "pd_lsn", "Items", "Free", "Since last load")
+		}
+		loads++
+		pages[key] = true
+		if change != "first load" && change != "unchanged" {
+			changed++
+		}
+		at := fmt.Sprintf("+%.1fs", e.At.Sub(j.Start).Seconds())
+		if deterministic {
+			at = "-"
+		}
+		fmt.Printf("  %-10s %-6s %8d  %-17s %6d %6d  %s\n", at, journalFork(e.File), e.Page, lsnStr(e.LSN), e.Items, e.Free, change)
+	}
+	if loads == 0 {
+		fmt.Println("  No page loads recorded.")
+	}
+	fmt.Println()
+	fmt.Printf("  %-18s : %d\n", "Loads", loads)
+	fmt.Printf("  %-18s : %d\n", "Pages", len(pages))
+	fmt.Printf("  %-18s : %d\n", "Changed reloads", changed)
+	fmt.Println()
+}
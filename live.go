@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// liveReads is set by --live: the files are being written by a running
+// PostgreSQL, so a read can return a page halfway through being
+// overwritten (a torn read) and is checked and retried before use.
+var liveReads bool
+
+const (
+	liveReadAttempts = 5
+	liveRetryDelay   = 10 * time.Millisecond
+)
+
+// readPageLive reads a page of a file that may be written concurrently. A
+// read is accepted once its checksum verifies or, when the page has none,
+// once two consecutive reads return the same bytes; an identical re-read of
+// a bad page is genuine on-disk damage, not a torn read. unstable is true
+// if the page was still changing after liveReadAttempts reads, in which
+// case the last read is returned. Without --live the page is read once.
+func readPageLive(filename string, pageNum int64) (data [PageSize]byte, reads int, unstable bool, err error) {
+	data, err = readPageData(filename, pageNum)
+	reads = 1
+	if err != nil || !liveReads || liveChecksumOK(&data, filename, pageNum) {
+		return data, reads, false, err
+	}
+	for reads < liveReadAttempts {
+		time.Sleep(time.Duration(reads) * liveRetryDelay)
+		next, err := readPageData(filename, pageNum)
+		reads++
+		if err != nil {
+			return next, reads, false, err
+		}
+		if next == data || liveChecksumOK(&next, filename, pageNum) {
+			return next, reads, false, nil
+		}
+		data = next
+	}
+	return data, reads, true, nil
+}
+
+// liveChecksumOK reports whether the page carries a checksum that verifies,
+// which proves a single read was not torn.
+func liveChecksumOK(data *[PageSize]byte, filename string, pageNum int64) bool {
+	stored := binary.LittleEndian.Uint16(data[8:10])
+	if stored == 0 || clusterChecksums(filename) == checksumsDisabled {
+		return false
+	}
+	return PageChecksum(data, absBlockNumber(filename, pageNum)) == stored
+}
+
+// liveNote describes a page's live read for status messages.
+func liveNote(p *Page) string {
+	if p.Unstable {
+		return fmt.Sprintf(", UNSTABLE: still changing after %d reads", p.ReadAttempts)
+	}
+	return ""
+}
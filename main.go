@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/chzyer/readline"
 	"github.com/wailsapp/wails/v2"
@@ -20,68 +23,331 @@ var assets embed.FS
 func main() {
 	shellMode := false
 	exportJSON := false
-	var filenames []string
+	decryptCmd, decryptKey := "", ""
+	var imageWin imageWindow
+	useImage := false
+	var args []string
 
-	args := os.Args[1:]
-	for i := 0; i < len(args); i++ {
-		if args[i] == "--shell" {
+	for i := 1; i < len(os.Args); i++ {
+		a := os.Args[i]
+		if a == "--shell" {
 			shellMode = true
-		} else if args[i] == "--export-json" {
+		} else if a == "--export-json" {
 			exportJSON = true
+		} else if a == "--page" || a == "--once" {
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "%s requires an argument\n", a)
+				os.Exit(1)
+			}
+			i++
+			if a == "--once" {
+				shellOpts.Once = os.Args[i]
+				continue
+			}
+			n, err := strconv.ParseInt(os.Args[i], 10, 64)
+			if err != nil || n < 0 {
+				fmt.Fprintf(os.Stderr, "invalid page number %q\n", os.Args[i])
+				os.Exit(1)
+			}
+			shellOpts.StartPage = n
+		} else if a == "--schema" {
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "--schema requires a list of types")
+				os.Exit(1)
+			}
+			i++
+			cols, err := ParseSchema(os.Args[i])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "--schema: %v\n", err)
+				os.Exit(1)
+			}
+			shellOpts.Schema = cols
+		} else if a == "--dsn" {
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "--dsn requires a connection string")
+				os.Exit(1)
+			}
+			i++
+			dsn = os.Args[i]
+		} else if a == "--relation" {
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "--relation requires a table name")
+				os.Exit(1)
+			}
+			i++
+			relationName = os.Args[i]
+		} else if a == "--pgdata" {
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "--pgdata requires a data directory")
+				os.Exit(1)
+			}
+			i++
+			pgdataDir = os.Args[i]
+		} else if a == "--image-offset" || a == "--image-length" {
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "%s requires a byte count\n", a)
+				os.Exit(1)
+			}
+			i++
+			n, err := parseByteSize(os.Args[i])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", a, err)
+				os.Exit(1)
+			}
+			if a == "--image-offset" {
+				imageWin.Offset = n
+			} else {
+				imageWin.Length = n
+			}
+			useImage = true
+		} else if a == "--direct-io" {
+			if !directIOSupported {
+				fmt.Fprintln(os.Stderr, "--direct-io is only supported on Linux")
+				os.Exit(1)
+			}
+			directIO = true
+		} else if a == "--drop-cache" {
+			dropCache = true
+		} else if a == "--deterministic" {
+			deterministic = true
+		} else if a == "--allow-writes" {
+			allowWrites = true
+		} else if a == "--decrypt-cmd" || a == "--decrypt-key" {
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "%s requires an argument\n", a)
+				os.Exit(1)
+			}
+			i++
+			if a == "--decrypt-cmd" {
+				decryptCmd = os.Args[i]
+			} else {
+				decryptKey = os.Args[i]
+			}
+		} else if a == "--live" {
+			liveReads = true
+		} else if a == "--robust" {
+			robustParsing = true
+		} else if a == "--type" {
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "--type requires a page type")
+				os.Exit(1)
+			}
+			i++
+			pt, err := ParsePageType(os.Args[i])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			pageTypeOverride = pt
+		} else if a == "--pg-version" {
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "--pg-version requires a major version number")
+				os.Exit(1)
+			}
+			i++
+			v, err := parsePGVersion(os.Args[i])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			pgVersion = v
+		} else if a == "--maxalign" {
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "--maxalign requires 4 or 8")
+				os.Exit(1)
+			}
+			i++
+			n, err := parseMaxAlign(os.Args[i])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			maxAlignment = n
 		} else {
-			filenames = append(filenames, args[i])
+			args = append(args, a)
 		}
 	}
 
-	if (shellMode || exportJSON) && len(filenames) == 0 {
-		fmt.Fprintf(os.Stderr, "Usage: pgpageshell [--shell|--export-json] <postgres-data-file> [file2 ...]\n")
+	if decryptKey != "" && decryptCmd == "" {
+		fmt.Fprintln(os.Stderr, "--decrypt-key needs --decrypt-cmd")
 		os.Exit(1)
 	}
-
-	if exportJSON {
-		if err := runExportJSON(filenames); err != nil {
+	if allowWrites && decryptCmd != "" {
+		// Pages would go back to disk in clear text
+		fmt.Fprintln(os.Stderr, "--allow-writes cannot be used with --decrypt-cmd")
+		os.Exit(1)
+	}
+	if useImage {
+		if allowWrites {
+			// A write through a mis-set window would land on the wrong bytes of the device
+			fmt.Fprintln(os.Stderr, "--allow-writes cannot be used with --image-offset or --image-length")
+			os.Exit(1)
+		}
+		if directIO && imageWin.Offset%directIOAlign != 0 {
+			fmt.Fprintf(os.Stderr, "--direct-io needs an --image-offset that is a multiple of %d\n", directIOAlign)
+			os.Exit(1)
+		}
+	}
+	if decryptCmd != "" {
+		d, err := newDecryptCommand(decryptCmd, decryptKey)
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		return
+		pageDecryption = d
+	}
+
+	// With --pgdata, relations may be named by OID instead of path
+	if err := resolveRelationArgs(pgdataDir, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	if !shellMode {
-		app, err := NewApp(filenames)
+	// With --relation, the table's files come from the server: the table
+	// stands in for a subcommand's first file, or all of them are opened
+	var relFiles []relationFile
+	if relationName != "" {
+		if dsn == "" {
+			fmt.Fprintln(os.Stderr, "--relation needs --dsn")
+			os.Exit(1)
+		}
+		files, err := resolveRelationFiles(dsn, pgdataDir, relationName)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+		printRelationFiles(relationName, files)
+		relFiles = files
+	}
+	if useImage {
+		for _, a := range args {
+			setImageWindow(a, imageWin)
+		}
+		for _, f := range relFiles {
+			setImageWindow(f.Path, imageWin)
+		}
+	}
 
-		err = wails.Run(&options.App{
-			Title:  "pgpageshell",
-			Width:  1280,
-			Height: 900,
-			AssetServer: &assetserver.Options{
-				Assets: assets,
-			},
-			OnStartup: app.startup,
-			Bind: []interface{}{
-				app,
-			},
-		})
-		if err != nil {
+	// Subcommand form: pgpageshell <command> [args]
+	if !shellMode && !exportJSON && len(args) > 0 {
+		if sc, ok := subcommands[args[0]]; ok {
+			cmdArgs := args[1:]
+			if relFiles != nil {
+				cmdArgs = append([]string{relFiles[0].Path}, cmdArgs...)
+			}
+			if err := sc.run(cmdArgs); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// Legacy flag form: [--shell|--export-json] <files>
+	filenames := args
+	for _, f := range relFiles {
+		if exportJSON {
+			filenames = append(filenames, f.Label()+"="+f.Path)
+		} else {
+			filenames = append(filenames, f.Path)
+		}
+	}
+	if (shellMode || exportJSON) && len(filenames) == 0 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	if exportJSON {
+		if err := runExportJSON(filenames); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 		return
 	}
 
-	filename := filenames[0]
-	fi, err := os.Stat(filename)
+	if shellMode {
+		runShell(filenames[0])
+		return
+	}
+	if err := runGUI(filenames); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runGUI starts the Wails desktop app with the given files open.
+func runGUI(filenames []string) error {
+	app, err := NewApp(filenames)
+	if err != nil {
+		return err
+	}
+	return wails.Run(&options.App{
+		Title:  "pgpageshell",
+		Width:  1280,
+		Height: 900,
+		AssetServer: &assetserver.Options{
+			Assets: assets,
+		},
+		OnStartup: app.startup,
+		Bind: []interface{}{
+			app,
+		},
+	})
+}
+
+// shellOptions holds the startup flags of the interactive shell.
+type shellOptions struct {
+	StartPage int64    // page loaded at startup (--page)
+	Once      string   // run these commands and exit instead of prompting (--once)
+	Schema    []string // key column types set at startup (--schema)
+}
+
+var shellOpts shellOptions
+
+// runShell starts the interactive page inspector on one file.
+func runShell(filename string) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v (using defaults)\n", err)
+	}
+	if robustParsing {
+		cfg.Robust = true
+	}
+	robustParsing = cfg.Robust
+	specialTemplates = cfg.templateList()
+
+	if isReplSlotState(filename) {
+		CmdReplSlot(filename)
+		return
+	}
+	if isSnapBuildFile(filename) {
+		CmdSnapBuild(filename)
+		return
+	}
+	if isRewriteMappingFile(filename) {
+		CmdRewriteMapping(filename)
+		return
+	}
+	if isRelcacheInitFile(filename) {
+		CmdRelcacheInit(filename)
+		return
+	}
+	if isPGControl(filename) {
+		CmdPGControl(filename)
+		return
+	}
+	size, err := fileSize(filename)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	totalPages := int(fi.Size() / PageSize)
-	if fi.Size()%PageSize != 0 {
-		fmt.Fprintf(os.Stderr, "Warning: file size %d is not a multiple of %d\n", fi.Size(), PageSize)
+	totalPages := size / PageSize
+	if size%PageSize != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: file size %d is not a multiple of %d\n", size, PageSize)
+	}
+	for _, w := range archWarnings(filename, totalPages) {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", w)
 	}
 
 	// Detect file type from page 0
@@ -93,58 +359,239 @@ func main() {
 		}
 	}
 
-	fmt.Printf("pgpageshell - PostgreSQL Page Inspector\n")
-	fmt.Printf("File: %s (%d bytes, %d pages, detected: %s)\n", filename, fi.Size(), totalPages, fileType)
-	fmt.Println()
-	printHelp()
-	fmt.Println()
+	interactive := shellOpts.Once == ""
+	if interactive {
+		fmt.Printf("pgpageshell - PostgreSQL Page Inspector\n")
+		fmt.Printf("File: %s (%d bytes, %d pages, detected: %s)\n", filename, size, totalPages,
+			fileTypeLabel(filename, fileType, totalPages))
+		if forks := relationForks(filename); len(forks) > 1 || len(forks[relFork(filename)]) > 1 {
+			fmt.Printf("Forks: %s (switch with fork <name>)\n", forksSummary(forks, relFork(filename)))
+		}
+		if relFork(filename) == ForkInit {
+			fmt.Println("Note: init fork of an unlogged relation; it is copied over the main fork on crash")
+			fmt.Println("      recovery, so an empty table fork or a lone index metapage is expected.")
+		}
+		if loadSidecar(filename) != nil {
+			fmt.Println("Sidecar index loaded: pages, stats and select use the cached page summaries.")
+		}
+		fmt.Println()
+		printHelp()
+		fmt.Println()
+	}
 
-	currentPage := 0
+	currentPage := shellOpts.StartPage
 	var page *Page
+	schema := shellOpts.Schema
+	var lastSearch []byte
 
+	if currentPage > 0 && currentPage >= totalPages {
+		fmt.Fprintf(os.Stderr, "Error: page %d out of range (0-%d)\n", currentPage, totalPages-1)
+		os.Exit(1)
+	}
 	if totalPages > 0 {
-		page, err = ReadPage(filename, 0)
+		page, err = ReadPage(filename, currentPage)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading page 0: %v\n", err)
-		} else {
-			fmt.Printf("[page 0 loaded, type: %s]\n", page.Detected)
+			fmt.Fprintf(os.Stderr, "Error reading page %d: %v\n", currentPage, err)
+		} else if interactive {
+			fmt.Printf("[page %d loaded, type: %s%s]\n", currentPage, page.TypeLabel(), corruptNote(page))
 		}
 	}
 
 	completer := readline.NewPrefixCompleter(
 		readline.PcItem("page"),
-		readline.PcItem("cat"),
+		readline.PcItem("next"),
+		readline.PcItem("fork", readline.PcItem("main"), readline.PcItem("fsm"), readline.PcItem("vm"), readline.PcItem("init")),
+		readline.PcItem("prev"),
+		readline.PcItem("cat",
+			readline.PcItem("--wide"),
+			readline.PcItem("--highlight"),
+		),
+		readline.PcItem("settype",
+			readline.PcItem("auto"),
+			readline.PcItem("heap"),
+			readline.PcItem("btree"),
+			readline.PcItem("hash"),
+			readline.PcItem("gist"),
+			readline.PcItem("gin"),
+			readline.PcItem("spgist"),
+			readline.PcItem("brin"),
+		),
+		readline.PcItem("unset"),
+		readline.PcItem("foreach", readline.PcItem("page")),
+		readline.PcItem("if"),
+		readline.PcItem("set",
+			readline.PcItem("info_verbosity"),
+			readline.PcItem("robust"),
+			readline.PcItem("edit_mode"),
+			readline.PcItem("history_size"),
+		),
+		readline.PcItem("where"),
+		readline.PcItem("interpret"),
+		readline.PcItem("search",
+			readline.PcItem("hex"),
+			readline.PcItem("int2"),
+			readline.PcItem("int4"),
+			readline.PcItem("int8"),
+		),
 		readline.PcItem("format"),
-		readline.PcItem("info"),
+		readline.PcItem("info",
+			readline.PcItem("-v"),
+			readline.PcItem("-q"),
+			readline.PcItem("--raw-special"),
+		),
 		readline.PcItem("data"),
 		readline.PcItem("pages"),
+		readline.PcItem("map"),
+		readline.PcItem("heatmap", readline.PcItem("dead"), readline.PcItem("lsn")),
+		readline.PcItem("stats"),
+		readline.PcItem("pgstattuple"),
+		readline.PcItem("findbig"),
+		readline.PcItem("fillfactor"),
+		readline.PcItem("visibility", readline.PcItem("--mismatches")),
+		readline.PcItem("rebuildlp", readline.PcItem("--write")),
+		readline.PcItem("settuple"),
+		readline.PcItem("setlp"),
+		readline.PcItem("clearhints"),
+		readline.PcItem("copypage", readline.PcItem("--keep-checksum")),
+		readline.PcItem("truncate"),
+		readline.PcItem("extend"),
+		readline.PcItem("setflag", readline.PcItem("ALL_VISIBLE"), readline.PcItem("PAGE_FULL"), readline.PcItem("HAS_FREE_LINES")),
+		readline.PcItem("clearflag", readline.PcItem("ALL_VISIBLE"), readline.PcItem("PAGE_FULL"), readline.PcItem("HAS_FREE_LINES")),
+		readline.PcItem("select"),
+		readline.PcItem("assert", readline.PcItem("checksum", readline.PcItem("ok")), readline.PcItem("maxlsn")),
+		readline.PcItem("findflags", readline.PcItem("ALL_VISIBLE"), readline.PcItem("PAGE_FULL"), readline.PcItem("HAS_FREE_LINES")),
+		readline.PcItem("sidecar", readline.PcItem("write"), readline.PcItem("drop")),
+		readline.PcItem("verify"),
+		readline.PcItem("triage"),
+		readline.PcItem("carve"),
+		readline.PcItem("xcheck"),
+		readline.PcItem("indexcheck"),
+		readline.PcItem("duptids"),
+		readline.PcItem("findtid"),
+		readline.PcItem("freezeaudit"),
+		readline.PcItem("multixact"),
+		readline.PcItem("toastreport"),
+		readline.PcItem("journal"),
+		readline.PcItem("refresh"),
+		readline.PcItem("watch"),
+		readline.PcItem("replay", readline.PcItem("hex")),
+		readline.PcItem("futurelsn"),
+		readline.PcItem("hintstats"),
+		readline.PcItem("btlevels"),
+		readline.PcItem("btcheck",
+			readline.PcItem("int2"),
+			readline.PcItem("int4"),
+			readline.PcItem("int8"),
+			readline.PcItem("text"),
+		),
+		readline.PcItem("spgchain"),
+		readline.PcItem("schema"),
+		readline.PcItem("brinranges", readline.PcItem("minmax"), readline.PcItem("inclusion")),
+		readline.PcItem("gistcheck"),
+		readline.PcItem("tid"),
+		readline.PcItem("hashbitmap"),
+		readline.PcItem("walk",
+			readline.PcItem("right"),
+			readline.PcItem("left"),
+		),
 		readline.PcItem("help"),
 		readline.PcItem("quit"),
 		readline.PcItem("exit"),
 	)
 
-	rl, err := readline.NewEx(&readline.Config{
+	// The history keeps this Config and trims itself to HistoryLimit as
+	// lines are added, so set history_size takes effect by changing it.
+	rlConfig := &readline.Config{
 		Prompt:            fmt.Sprintf("pgpageshell(page %d)> ", currentPage),
 		HistoryFile:       "/tmp/pgpageshell_history",
 		AutoComplete:      completer,
 		InterruptPrompt:   "^C",
 		EOFPrompt:         "quit",
 		HistorySearchFold: true,
-	})
+		HistoryLimit:      cfg.HistorySize,
+		VimMode:           cfg.EditMode == "vi",
+		FuncFilterInputRune: func(r rune) (rune, bool) {
+			return cfg.FilterInputRune(r)
+		},
+	}
+	rl, err := readline.NewEx(rlConfig)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing readline: %v\n", err)
 		os.Exit(1)
 	}
 	defer rl.Close()
 
+	vars := map[string]string{}
+	lookupVar := func(name string) (string, bool) {
+		switch name {
+		case "curpage":
+			return strconv.FormatInt(currentPage, 10), true
+		case "maxpage":
+			return strconv.FormatInt(totalPages-1, 10), true
+		case "npages":
+			return strconv.FormatInt(totalPages, 10), true
+		}
+		v, ok := vars[name]
+		return v, ok
+	}
+
+	// Lines queued by if run before the next prompt. With --once its
+	// commands are queued, and the session ends when the queue runs out.
+	// A running foreach queues one page's lines at a time; the lines that
+	// were queued after it wait in afterLoop until it is done.
+	var pending, afterLoop []string
+	if !interactive {
+		pending = splitCommands(shellOpts.Once)
+	}
+	var loop *foreachLoop
+	readLine := func() (string, error) {
+		for len(pending) == 0 && loop != nil {
+			lines, err := loop.next()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			if lines == nil {
+				loop.stop()
+				loop, pending, afterLoop = nil, afterLoop, nil
+				break
+			}
+			pending = lines
+		}
+		if len(pending) > 0 {
+			line := pending[0]
+			pending = pending[1:]
+			return line, nil
+		}
+		if !interactive {
+			return "", io.EOF
+		}
+		return rl.Readline()
+	}
+
+	// A failed assert makes a batch session exit with status 1 once all
+	// of its commands have run.
+	assertFailed := false
+
+	// Every page the session loads is journaled for the journal command.
+	journal := newPageJournal()
+
 	for {
-		rl.SetPrompt(fmt.Sprintf("pgpageshell(page %d)> ", currentPage))
-		line, err := rl.Readline()
+		journal.record(filename, page)
+		if fork := relFork(filename); fork != ForkMain {
+			rl.SetPrompt(fmt.Sprintf("pgpageshell(%s page %d)> ", fork, currentPage))
+		} else {
+			rl.SetPrompt(fmt.Sprintf("pgpageshell(page %d)> ", currentPage))
+		}
+		line, err := readLine()
 		if err == readline.ErrInterrupt {
 			continue
 		}
 		if err == io.EOF {
-			fmt.Println("Bye.")
+			if interactive {
+				fmt.Println("Bye.")
+			} else if assertFailed {
+				os.Exit(1)
+			}
 			return
 		}
 		if err != nil {
@@ -155,10 +602,24 @@ func main() {
 		if line == "" {
 			continue
 		}
+		if line, err = expandVars(line, lookupVar); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			continue
+		}
 
 		parts := strings.Fields(line)
 		cmd := strings.ToLower(parts[0])
 
+		// Whole-file scans can be limited to a page range
+		var sr scanRange
+		if scanCommands[cmd] {
+			var rangeErr error
+			if parts, sr, rangeErr = parseScanRange(parts); rangeErr != nil {
+				fmt.Printf("Error: %v\n", rangeErr)
+				continue
+			}
+		}
+
 		switch cmd {
 		case "quit", "exit", "q":
 			fmt.Println("Bye.")
@@ -169,10 +630,14 @@ func main() {
 
 		case "page", "p":
 			if len(parts) < 2 {
-				fmt.Printf("Current page: %d (of %d, type: %s)\n", currentPage, totalPages, page.Detected)
+				if page == nil {
+					fmt.Printf("No page loaded (file has %d pages).\n", totalPages)
+					continue
+				}
+				fmt.Printf("Current page: %d (of %d, type: %s)\n", currentPage, totalPages, page.TypeLabel())
 				continue
 			}
-			n, err := strconv.Atoi(parts[1])
+			n, err := strconv.ParseInt(parts[1], 10, 64)
 			if err != nil || n < 0 || n >= totalPages {
 				fmt.Printf("Invalid page number. Valid range: 0-%d\n", totalPages-1)
 				continue
@@ -183,14 +648,107 @@ func main() {
 				continue
 			}
 			currentPage = n
-			fmt.Printf("[page %d loaded, type: %s]\n", n, page.Detected)
+			fmt.Printf("[page %d loaded, type: %s%s]\n", n, page.TypeLabel(), corruptNote(page))
+			prefetch(filename, totalPages, n+1)
+
+		case "fork":
+			forks := relationForks(filename)
+			if forks == nil {
+				fmt.Println("Not a relation file (<relfilenode>[_fork][.segment]); no forks to switch to.")
+				continue
+			}
+			if len(parts) < 2 {
+				fmt.Printf("Forks: %s\n", forksSummary(forks, relFork(filename)))
+				for _, fork := range relForkOrder {
+					if segs, ok := forks[fork]; ok {
+						fmt.Printf("  %-5s %s\n", fork, strings.Join(segs, " "))
+					}
+				}
+				continue
+			}
+			fork := strings.ToLower(parts[1])
+			switch fork {
+			case ForkMain, ForkFSM, ForkVM, ForkInit:
+			default:
+				fmt.Println("Usage: fork [main|fsm|vm|init]")
+				continue
+			}
+			segs, ok := forks[fork]
+			if !ok {
+				fmt.Printf("No %s fork for this relation.\n", fork)
+				continue
+			}
+			n, err := countPages(segs[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			filename, totalPages, currentPage, page = segs[0], n, 0, nil
+			if n > 0 {
+				if page, err = ReadPage(filename, 0); err != nil {
+					fmt.Printf("Error reading page 0: %v\n", err)
+				}
+			}
+			fmt.Printf("[fork %s: %s, %d pages", fork, filename, n)
+			if page != nil {
+				fmt.Printf("; page 0 loaded, type: %s%s", page.TypeLabel(), corruptNote(page))
+			}
+			fmt.Println("]")
+
+		case "next", "prev":
+			step := int64(1)
+			if cmd == "prev" {
+				step = -1
+			}
+			n := currentPage + step
+			if n < 0 || n >= totalPages {
+				fmt.Printf("No %s page (file has %d pages).\n", cmd, totalPages)
+				continue
+			}
+			page, err = ReadPage(filename, n)
+			if err != nil {
+				fmt.Printf("Error reading page %d: %v\n", n, err)
+				continue
+			}
+			currentPage = n
+			fmt.Printf("[page %d loaded, type: %s%s]\n", n, page.TypeLabel(), corruptNote(page))
+			// Keep reading ahead in the direction of travel
+			prefetch(filename, totalPages, n+step, n+2*step)
 
 		case "cat", "c":
 			if page == nil {
 				fmt.Println("No page loaded.")
 				continue
 			}
-			CmdCat(page)
+			bytesPerRow := 16
+			var mark []bool
+			badArgs := false
+			for _, arg := range parts[1:] {
+				switch arg {
+				case "--wide":
+					bytesPerRow = 32
+				case "--highlight":
+					if lastSearch == nil {
+						fmt.Println("No search pattern to highlight (use 'search' first).")
+						badArgs = true
+						break
+					}
+					mark = highlightMask(page, lastSearch)
+				default:
+					fmt.Println("Usage: cat [--wide] [--highlight]")
+					badArgs = true
+				}
+			}
+			if badArgs {
+				continue
+			}
+			// Fall back when the terminal is known to be too narrow
+			if w := screenWidth(); bytesPerRow == 32 && w > 0 && w < catLineWidth(bytesPerRow) {
+				fmt.Printf("[terminal is %d columns, --wide needs %d; using 16 bytes per row]\n",
+					w, catLineWidth(bytesPerRow))
+				bytesPerRow = 16
+			}
+			CmdCat(page, bytesPerRow, mark, colorOutput())
 
 		case "format", "f":
 			if page == nil {
@@ -204,34 +762,811 @@ func main() {
 				fmt.Println("No page loaded.")
 				continue
 			}
-			CmdInfo(page)
+			verbosity := cfg.InfoVerbosity
+			rawSpecial, bad := false, false
+			for _, arg := range parts[1:] {
+				switch arg {
+				case "-v":
+					verbosity = InfoVerbose
+				case "-q":
+					verbosity = InfoQuiet
+				case "--raw-special":
+					rawSpecial = true
+				default:
+					bad = true
+				}
+			}
+			if bad {
+				fmt.Println("Usage: info [-v|-q] [--raw-special]")
+				continue
+			}
+			CmdInfo(page, verbosity, rawSpecial)
 
 		case "data", "d":
 			if page == nil {
 				fmt.Println("No page loaded.")
 				continue
 			}
-			CmdData(page)
+			CmdData(page, schema)
 
 		case "pages":
-			for i := 0; i < totalPages; i++ {
-				pg, err := ReadPage(filename, i)
+			if relFork(filename) == ForkInit {
+				fmt.Println("  (init fork of an unlogged relation)")
+			}
+			scanMeta(filename, totalPages, sr, func(i int64, m pageMeta, err error) {
 				if err != nil {
 					fmt.Printf("  Page %3d: error: %v\n", i, err)
+					return
+				}
+				if sr.skipsLSN(m.LSN) {
+					return
+				}
+				corrupt := ""
+				if m.Anomalies > 0 {
+					corrupt = fmt.Sprintf("  CORRUPT (%d anomalies)", m.Anomalies)
+				}
+				fmt.Printf("  Page %3d: type=%-10s items=%-4d free=%-5d special=%-4d%s\n",
+					i, m.TypeLabel(), m.NumItems(), m.FreeSpace(), m.SpecialSize, corrupt)
+			})
+			printVMSummary(filename, totalPages, sr)
+
+		case "settype":
+			if len(parts) != 2 {
+				current := "auto"
+				if pageTypeOverride != noTypeOverride {
+					current = pageTypeOverride.String()
+				} else if templateOverride != "" {
+					current = templateOverride
+				}
+				fmt.Printf("Usage: settype <heap|btree|hash|gist|gin|spgist|brin|unknown|<template>|auto> (current: %s)\n", current)
+				continue
+			}
+			templateOverride = ""
+			if parts[1] == "auto" {
+				pageTypeOverride = noTypeOverride
+			} else if templateNamed(parts[1]) != nil {
+				pageTypeOverride = noTypeOverride
+				templateOverride = parts[1]
+			} else {
+				pt, err := ParsePageType(parts[1])
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					continue
+				}
+				pageTypeOverride = pt
+			}
+			if page == nil {
+				continue
+			}
+			page, err = ReadPage(filename, currentPage)
+			if err != nil {
+				fmt.Printf("Error reading page %d: %v\n", currentPage, err)
+				page = nil
+				continue
+			}
+			switch {
+			case templateOverride != "" && page.Template == nil:
+				fmt.Printf("[special area of %d bytes does not fit template %s; page %d stays %s]\n",
+					len(page.SpecialData()), templateOverride, currentPage, page.Detected)
+			case templateOverride != "":
+				fmt.Printf("[template %s forced for all pages; page %d was detected as %s]\n",
+					templateOverride, currentPage, page.AutoDetected)
+			case pageTypeOverride == noTypeOverride:
+				fmt.Printf("[auto-detection restored, page %d type: %s]\n", currentPage, page.Detected)
+			case page.Detected != pageTypeOverride:
+				fmt.Printf("[special area of %d bytes is too small for %s; page %d stays %s]\n",
+					len(page.SpecialData()), pageTypeOverride, currentPage, page.Detected)
+			default:
+				fmt.Printf("[type forced to %s for all pages; page %d was detected as %s]\n",
+					pageTypeOverride, currentPage, page.AutoDetected)
+			}
+
+		case "set":
+			if len(parts) == 1 {
+				for _, kv := range cfg.Settings() {
+					fmt.Printf("  %-16s = %s\n", kv[0], kv[1])
+				}
+				names := make([]string, 0, len(vars))
+				for name := range vars {
+					names = append(names, name)
+				}
+				slices.Sort(names)
+				for _, name := range names {
+					fmt.Printf("  $%-15s = %s\n", name, vars[name])
+				}
+				continue
+			}
+			key, value, ok := strings.Cut(strings.TrimSpace(line[len(parts[0]):]), "=")
+			if !ok {
+				if len(parts) != 3 {
+					fmt.Println("Usage: set [<key> <value>]")
+					continue
+				}
+				key, value = parts[1], parts[2]
+			}
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			if !isConfigKey(key) && isVarName(key) {
+				if slices.Contains(builtinVars, key) {
+					fmt.Printf("Error: $%s is read-only\n", key)
+					continue
+				}
+				vars[key] = value
+				continue
+			}
+			if err := cfg.Set(strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			rl.SetVimMode(cfg.EditMode == "vi")
+			rlConfig.HistoryLimit = cfg.HistorySize
+			if robustParsing != cfg.Robust || strings.HasPrefix(key, "special.") {
+				robustParsing = cfg.Robust
+				specialTemplates = cfg.templateList()
+				if page != nil {
+					if pg, err := ReadPage(filename, currentPage); err == nil {
+						page = pg
+					}
+				}
+			}
+
+		case "foreach":
+			if loop != nil {
+				fmt.Println("Error: foreach cannot be nested")
+				continue
+			}
+			l, err := newForeachLoop(filename, totalPages, strings.TrimSpace(line[len(parts[0]):]))
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			loop, afterLoop, pending = l, pending, nil
+
+		case "if":
+			if page == nil {
+				fmt.Println("No page loaded.")
+				continue
+			}
+			lines, err := ifLines(currentPage, page, strings.TrimSpace(line[len(parts[0]):]))
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			pending = append(lines, pending...)
+
+		case "unset":
+			if len(parts) != 2 {
+				fmt.Println("Usage: unset <name>")
+				continue
+			}
+			delete(vars, strings.TrimPrefix(parts[1], "$"))
+
+		case "map":
+			width := mapWidth(screenWidth(), totalPages)
+			if len(parts) > 1 {
+				n, err := strconv.Atoi(parts[1])
+				if err != nil || n < 1 {
+					fmt.Printf("Invalid width %q\n", parts[1])
+					continue
+				}
+				width = n
+			}
+			CmdMap(filename, totalPages, sr, width)
+
+		case "heatmap":
+			if len(parts) < 2 || len(parts) > 3 || (parts[1] != "dead" && parts[1] != "lsn") {
+				fmt.Println("Usage: heatmap dead|lsn [width]")
+				continue
+			}
+			width := mapWidth(screenWidth(), totalPages)
+			if len(parts) > 2 {
+				n, err := strconv.Atoi(parts[2])
+				if err != nil || n < 1 {
+					fmt.Printf("Invalid width %q\n", parts[2])
+					continue
+				}
+				width = n
+			}
+			color := colorOutput()
+			if parts[1] == "lsn" {
+				CmdHeatmapLSN(filename, totalPages, sr, width, color)
+			} else {
+				CmdHeatmapDead(filename, totalPages, sr, width, color)
+			}
+
+		case "where":
+			if page == nil {
+				fmt.Println("No page loaded.")
+				continue
+			}
+			if len(parts) != 2 {
+				fmt.Println("Usage: where <offset>")
+				continue
+			}
+			off, err := strconv.ParseInt(parts[1], 0, 32)
+			if err != nil || off < 0 || off >= PageSize {
+				fmt.Printf("Invalid offset. Valid range: 0-%d (decimal or 0x hex)\n", PageSize-1)
+				continue
+			}
+			CmdWhere(page, int(off))
+
+		case "interpret":
+			if page == nil {
+				fmt.Println("No page loaded.")
+				continue
+			}
+			if len(parts) < 2 || len(parts) > 3 {
+				fmt.Println("Usage: interpret <offset> [len]")
+				continue
+			}
+			off, err := strconv.ParseInt(parts[1], 0, 32)
+			if err != nil || off < 0 || off >= PageSize {
+				fmt.Printf("Invalid offset. Valid range: 0-%d (decimal or 0x hex)\n", PageSize-1)
+				continue
+			}
+			n := int64(8)
+			if len(parts) == 3 {
+				n, err = strconv.ParseInt(parts[2], 0, 32)
+				if err != nil || n < 1 {
+					fmt.Printf("Invalid length. Valid range: 1-%d\n", interpretMaxLen)
+					continue
+				}
+			}
+			CmdInterpret(page, int(off), int(n))
+
+		case "xcheck":
+			if len(parts) != 2 {
+				fmt.Println("Usage: xcheck <heap-file>")
+				continue
+			}
+			heapFile, err := resolveRelation(pgdataDir, parts[1])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			CmdXCheck(filename, totalPages, sr, heapFile)
+
+		case "toastreport":
+			if len(parts) != 2 {
+				fmt.Println("Usage: toastreport <toast-file>")
+				continue
+			}
+			toastFile, err := resolveRelation(pgdataDir, parts[1])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			CmdToastReport(filename, totalPages, toastFile, schema)
+
+		case "indexcheck":
+			if len(parts) != 3 {
+				fmt.Println("Usage: indexcheck <heap-file> <column>   (uses the schema as the heap's column types)")
+				continue
+			}
+			col, err := strconv.Atoi(parts[2])
+			if err != nil || col < 1 || col > len(schema) {
+				fmt.Printf("Invalid column. Set a schema covering the heap columns up to the key (current: %d columns).\n", len(schema))
+				continue
+			}
+			heapFile, err := resolveRelation(pgdataDir, parts[1])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			CmdIndexCheck(filename, totalPages, heapFile, schema, col)
+
+		case "findtid":
+			if len(parts) < 2 || len(parts) > 3 {
+				fmt.Println("Usage: findtid <block> [offset]")
+				continue
+			}
+			block, offset, err := parseFindTID(parts[1:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			CmdFindTID(filename, totalPages, sr, block, offset)
+
+		case "duptids":
+			if sr.SinceLSN != 0 {
+				// a duplicate can pair a new entry with an old one
+				fmt.Println("Error: duptids needs every page, --since-lsn is not supported")
+				continue
+			}
+			CmdDupTIDs(filename, totalPages, sr)
+
+		case "freezeaudit":
+			frozenXid, minMxid, err := freezeLimits(filename, parts[1:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			CmdFreezeAudit(filename, totalPages, sr, frozenXid, minMxid)
+
+		case "refresh":
+			if page == nil {
+				fmt.Println("No page loaded.")
+				continue
+			}
+			pg, err := CmdRefresh(filename, page)
+			if err != nil {
+				fmt.Printf("Error reading page %d: %v\n", currentPage, err)
+				continue
+			}
+			page = pg
+
+		case "watch":
+			if page == nil {
+				fmt.Println("No page loaded.")
+				continue
+			}
+			secs, count := 1.0, 10
+			badArgs := len(parts) > 3
+			if len(parts) > 1 {
+				v, err := strconv.ParseFloat(parts[1], 64)
+				badArgs = badArgs || err != nil || v <= 0
+				secs = v
+			}
+			if len(parts) > 2 {
+				v, err := strconv.Atoi(parts[2])
+				badArgs = badArgs || err != nil || v <= 0
+				count = v
+			}
+			if badArgs {
+				fmt.Println("Usage: watch [seconds] [count]")
+				continue
+			}
+			pg, err := CmdWatch(filename, page, time.Duration(secs*float64(time.Second)), count, journal)
+			if err != nil {
+				fmt.Printf("Error reading page %d: %v\n", currentPage, err)
+			}
+			page = pg
+
+		case "journal":
+			jpage := int64(-1)
+			if len(parts) > 1 {
+				n, err := strconv.ParseInt(parts[1], 10, 64)
+				if err != nil || n < 0 {
+					fmt.Println("Usage: journal [page]")
+					continue
+				}
+				jpage = n
+			}
+			journal.CmdJournal(filename, jpage)
+
+		case "multixact":
+			mxid, err := parseMultiXactArg(parts[1:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			CmdMultiXact(filename, totalPages, sr, mxid)
+
+		case "replay":
+			if page == nil {
+				fmt.Println("No page loaded.")
+				continue
+			}
+			if len(parts) < 2 {
+				fmt.Println("Usage: replay <record-file> | replay hex <bytes>")
+				continue
+			}
+			rec, err := loadXLogRecord(parts[1:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			CmdReplay(page, absBlockNumber(filename, currentPage), rec)
+
+		case "futurelsn":
+			ctlFile := ""
+			var insertLSN uint64
+			var lsnErr error
+			for _, arg := range parts[1:] {
+				if filepath.Base(arg) == PGControlFile {
+					ctlFile = arg
+				} else {
+					insertLSN, lsnErr = parseLSN(arg)
+				}
+			}
+			if lsnErr != nil {
+				fmt.Printf("Error: %v\n", lsnErr)
+				continue
+			}
+			if ctlFile == "" {
+				if ctlFile = findPGControl(filename); ctlFile == "" {
+					fmt.Println("No global/pg_control found above the file; usage: futurelsn [pg_control] [insert-lsn]")
+					continue
+				}
+			}
+			ctl, err := LoadPGControl(ctlFile)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			CmdFutureLSN(filename, totalPages, sr, ctl, insertLSN)
+
+		case "carve":
+			if page == nil {
+				fmt.Println("No page loaded.")
+				continue
+			}
+			CmdCarve(page, absBlockNumber(filename, currentPage))
+
+		case "rebuildlp":
+			if page == nil {
+				fmt.Println("No page loaded.")
+				continue
+			}
+			if len(parts) > 2 || (len(parts) == 2 && parts[1] != "--write") {
+				fmt.Println("Usage: rebuildlp [--write]")
+				continue
+			}
+			write := len(parts) == 2
+			if write && !allowWrites {
+				fmt.Println("Error: rebuildlp --write needs --allow-writes")
+				continue
+			}
+			if CmdRebuildLP(filename, currentPage, page, write) {
+				if pg, err := ReadPage(filename, currentPage); err == nil {
+					page = pg
+				}
+			}
+
+		case "settuple":
+			if page == nil {
+				fmt.Println("No page loaded.")
+				continue
+			}
+			if len(parts) < 3 {
+				fmt.Println("Usage: settuple <item> <field>=<value>|<field>+=<flags>|<field>-=<flags> ...")
+				continue
+			}
+			item, err := strconv.Atoi(parts[1])
+			if err != nil {
+				fmt.Printf("Invalid item number: %s\n", parts[1])
+				continue
+			}
+			if CmdSetTuple(filename, currentPage, page, item, parts[2:]) {
+				if pg, err := ReadPage(filename, currentPage); err == nil {
+					page = pg
+				}
+			}
+
+		case "setlp":
+			if page == nil {
+				fmt.Println("No page loaded.")
+				continue
+			}
+			if len(parts) < 3 {
+				fmt.Println("Usage: setlp <item> offset=N|len=N|flags=UNUSED|NORMAL|REDIRECT|DEAD ...")
+				continue
+			}
+			item, err := strconv.Atoi(parts[1])
+			if err != nil {
+				fmt.Printf("Invalid item number: %s\n", parts[1])
+				continue
+			}
+			if CmdSetLP(filename, currentPage, page, item, parts[2:]) {
+				if pg, err := ReadPage(filename, currentPage); err == nil {
+					page = pg
+				}
+			}
+
+		case "setflag", "clearflag":
+			if page == nil {
+				fmt.Println("No page loaded.")
+				continue
+			}
+			if len(parts) < 2 {
+				fmt.Printf("Usage: %s %s\n", parts[0], pdFlagUsage)
+				continue
+			}
+			if CmdSetPageFlags(filename, currentPage, page, parts[0] == "setflag", strings.Join(parts[1:], " ")) {
+				if pg, err := ReadPage(filename, currentPage); err == nil {
+					page = pg
+				}
+			}
+
+		case "clearhints":
+			if page == nil {
+				fmt.Println("No page loaded.")
+				continue
+			}
+			if CmdClearHints(filename, currentPage, page) {
+				if pg, err := ReadPage(filename, currentPage); err == nil {
+					page = pg
+				}
+			}
+
+		case "copypage":
+			keep := len(parts) > 1 && parts[1] == "--keep-checksum"
+			args := parts[1:]
+			if keep {
+				args = args[1:]
+			}
+			if len(args) != 2 {
+				fmt.Println("Usage: copypage [--keep-checksum] [file:]<src> [file:]<dst>")
+				continue
+			}
+			src, err := parsePageRef(args[0], filename)
+			if err == nil {
+				var dst pageRef
+				if dst, err = parsePageRef(args[1], filename); err == nil {
+					if CmdCopyPage(src, dst, keep) && dst.File == filename && dst.Page == currentPage {
+						if pg, err := ReadPage(filename, currentPage); err == nil {
+							page = pg
+						}
+					}
+				}
+			}
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+
+		case "truncate", "extend":
+			var n int64
+			err := fmt.Errorf("missing page count")
+			if len(parts) == 2 {
+				n, err = strconv.ParseInt(parts[1], 10, 64)
+			}
+			if err != nil {
+				fmt.Printf("Usage: %s <npages>\n", parts[0])
+				continue
+			}
+			var resized bool
+			if parts[0] == "truncate" {
+				resized = CmdTruncate(filename, n)
+			} else {
+				resized = CmdExtend(filename, n)
+			}
+			if !resized {
+				continue
+			}
+			if total, err := countPages(filename); err == nil {
+				totalPages = total
+			}
+			prefetch(filename, totalPages)
+			page = nil
+			if totalPages > 0 {
+				currentPage = min(currentPage, totalPages-1)
+				if pg, err := ReadPage(filename, currentPage); err == nil {
+					page = pg
+				}
+			}
+
+		case "search", "/":
+			arg, sr, err := cutScanRange(line[len(parts[0]):])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			pat, err := parseSearchPattern(arg)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println("Usage: search [--offset N] [--limit N] [--since-lsn X/X] \"text\" | hex <bytes> | int2|int4|int8 <n>")
+				continue
+			}
+			lastSearch = pat
+			CmdSearch(filename, totalPages, sr, pat)
+
+		case "stats":
+			rest, size, err := cutSampleOption(parts[1:])
+			if err == nil && len(rest) > 0 {
+				err = fmt.Errorf("usage: stats [--sample N|P%%]")
+			}
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			if size == "" {
+				CmdStats(filename, totalPages, sr)
+				continue
+			}
+			n, err := sampleSizeFor(size, totalPages, sr)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			CmdStatsSample(filename, totalPages, sr, n)
+
+		case "pgstattuple":
+			CmdPgstattuple(filename, totalPages)
+
+		case "sidecar":
+			switch {
+			case len(parts) == 1:
+				CmdSidecar(filename)
+			case len(parts) == 2 && parts[1] == "write":
+				sc, err := writeSidecar(filename)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					continue
+				}
+				fmt.Printf("Sidecar index of %d pages written to %s\n", sc.Header.Pages, shownPath(sc.Path))
+			case len(parts) == 2 && parts[1] == "drop":
+				if err := dropSidecar(filename); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					continue
+				}
+				fmt.Println("Sidecar index removed.")
+			default:
+				fmt.Println("Usage: sidecar [write|drop]")
+			}
+
+		case "verify":
+			CmdVerify(filename, totalPages, sr)
+
+		case "triage":
+			CmdTriage(filename, totalPages, sr)
+
+		case "hintstats":
+			CmdHintStats(filename, totalPages, sr)
+
+		case "select":
+			arg, sr, err := cutScanRange(line[len(parts[0]):])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			q, err := parseQuery(arg)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			CmdSelect(filename, totalPages, sr, q)
+
+		case "assert":
+			arg, sr, err := cutScanRange(line[len(parts[0]):])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				assertFailed = true
+				continue
+			}
+			if !CmdAssert(filename, totalPages, sr, arg) {
+				assertFailed = true
+			}
+
+		case "findflags":
+			if len(parts) < 2 {
+				fmt.Println("Usage: findflags [!]ALL_VISIBLE|PAGE_FULL|HAS_FREE_LINES[,...]")
+				continue
+			}
+			set, clear, err := parseFlagSpec(strings.Join(parts[1:], " "))
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			CmdFindFlags(filename, totalPages, sr, set, clear)
+
+		case "findbig":
+			if len(parts) != 2 {
+				fmt.Println("Usage: findbig <bytes>")
+				continue
+			}
+			n, err := strconv.Atoi(parts[1])
+			if err != nil || n < 0 {
+				fmt.Printf("Invalid size %q\n", parts[1])
+				continue
+			}
+			CmdFindBig(filename, totalPages, sr, n)
+
+		case "fillfactor":
+			expected := 0
+			if len(parts) > 2 {
+				fmt.Println("Usage: fillfactor [expected]")
+				continue
+			}
+			if len(parts) == 2 {
+				n, err := strconv.Atoi(strings.TrimSuffix(parts[1], "%"))
+				if err != nil || n < 10 || n > 100 {
+					fmt.Printf("Invalid fillfactor %q (10-100)\n", parts[1])
 					continue
 				}
-				h := &pg.Header
-				numItems := 0
-				if h.Lower > PageHeaderSize {
-					numItems = int(h.Lower-PageHeaderSize) / ItemIdSize
+				expected = n
+			}
+			CmdFillfactor(filename, totalPages, sr, expected)
+
+		case "visibility":
+			if len(parts) > 2 || (len(parts) == 2 && parts[1] != "--mismatches") {
+				fmt.Println("Usage: visibility [--mismatches]")
+				continue
+			}
+			CmdVisibility(filename, totalPages, sr, len(parts) == 2)
+
+		case "btlevels":
+			CmdBTLevels(filename, totalPages)
+
+		case "btcheck":
+			if len(parts) < 2 || !slices.Contains(orderedTypes, parts[1]) {
+				fmt.Printf("Usage: btcheck <%s>\n", strings.Join(orderedTypes, "|"))
+				continue
+			}
+			CmdBTCheck(filename, totalPages, parts[1])
+
+		case "spgchain":
+			// spgchain <offset> follows a chain on the current page;
+			// spgchain <block> <offset> starts from a node downlink.
+			args := parts[1:]
+			block := absBlockNumber(filename, currentPage)
+			if len(args) == 2 {
+				b, err := strconv.ParseUint(args[0], 10, 32)
+				if err != nil {
+					fmt.Println("Usage: spgchain [block] <offset>")
+					continue
 				}
-				freeSpace := 0
-				if h.Upper > h.Lower {
-					freeSpace = int(h.Upper - h.Lower)
+				block = uint32(b)
+				args = args[1:]
+			}
+			if len(args) != 1 {
+				fmt.Println("Usage: spgchain [block] <offset>")
+				continue
+			}
+			off, err := strconv.Atoi(args[0])
+			if err != nil || off < 1 {
+				fmt.Println("Usage: spgchain [block] <offset>")
+				continue
+			}
+			CmdSPGChain(filename, totalPages, block, off)
+
+		case "schema":
+			if len(parts) < 2 {
+				if len(schema) == 0 {
+					fmt.Println("No schema set.")
+				} else {
+					fmt.Printf("Schema: %s\n", strings.Join(schema, ","))
 				}
-				fmt.Printf("  Page %3d: type=%-7s items=%-4d free=%-5d special=%-4d\n",
-					i, pg.Detected, numItems, freeSpace, pg.SpecialSize())
+				continue
+			}
+			if parts[1] == "clear" {
+				schema = nil
+				fmt.Println("Schema cleared.")
+				continue
+			}
+			cols, err := ParseSchema(strings.Join(parts[1:], ""))
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			schema = cols
+			fmt.Printf("Schema: %s\n", strings.Join(schema, ","))
+
+		case "brinranges":
+			if len(parts) > 2 {
+				fmt.Println("Usage: brinranges [minmax|inclusion[,...]]")
+				continue
+			}
+			opclasses, err := parseBRINOpclasses(strings.Join(parts[1:], ""), schema)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
 			}
+			CmdBRINRanges(filename, totalPages, schema, opclasses)
+
+		case "gistcheck":
+			CmdGiSTCheck(filename, totalPages)
+
+		case "tid":
+			if len(parts) < 2 {
+				fmt.Println("Usage: tid <(block,offset)|ItemPointerData hex|block>")
+				continue
+			}
+			if err := CmdTID(strings.Join(parts[1:], " "), filename); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+
+		case "hashbitmap":
+			CmdHashBitmap(filename, totalPages)
+
+		case "walk", "w":
+			if len(parts) < 2 || (parts[1] != "right" && parts[1] != "left") {
+				fmt.Println("Usage: walk right|left")
+				continue
+			}
+			if page == nil {
+				fmt.Println("No page loaded.")
+				continue
+			}
+			CmdWalk(filename, totalPages, currentPage, parts[1])
 
 		default:
 			fmt.Printf("Unknown command: %s (type 'help' for commands)\n", cmd)
@@ -239,14 +1574,83 @@ func main() {
 	}
 }
 
+// scanCommands are the shell commands that scan the whole file and accept
+// the scanRange options (--offset, --limit, --since-lsn) to visit only part
+// of it.
+var scanCommands = map[string]bool{
+	"pages": true, "map": true, "heatmap": true, "xcheck": true, "duptids": true, "findtid": true,
+	"freezeaudit": true, "multixact": true, "futurelsn": true, "stats": true, "verify": true,
+	"triage": true, "hintstats": true, "findbig": true, "findflags": true,
+	"fillfactor": true, "visibility": true,
+}
+
 func printHelp() {
 	fmt.Println("Commands:")
 	fmt.Println("  page <n>    - select page number (0-based)")
-	fmt.Println("  cat         - hex dump of current page")
+	fmt.Println("  next/prev   - select the following/preceding page (read ahead in the background)")
+	fmt.Println("  fork [main|fsm|vm|init] - list the relation's forks and segments, or switch to a fork")
+	fmt.Println("  cat [--wide] [--highlight] - hex dump of current page (32 bytes/row, mark search hits)")
 	fmt.Println("  format      - ASCII art page layout")
-	fmt.Println("  info        - page header and special region details")
+	fmt.Println("  info [-v|-q] [--raw-special] - page header and special region details (verbose/quiet, raw special hex)")
 	fmt.Println("  data        - line pointers and tuple data")
 	fmt.Println("  pages       - list all pages with summary")
+	fmt.Println("  map [width] - one character per page (H heap, B btree leaf, M meta, . empty, X corrupt, ...)")
+	fmt.Println("  heatmap dead [width] - per-page density of dead tuples and LP_DEAD line pointers")
+	fmt.Println("  heatmap lsn [width]  - per-page pd_lsn recency relative to the file's newest page")
+	fmt.Println("  where <off> - explain which structure a byte offset belongs to")
+	fmt.Println("  interpret <off> [len] - show bytes as int16/32/64, float4/8 (LE and BE), hex and ASCII")
+	fmt.Println("  xcheck <heap> - check every index TID points to a used line pointer in <heap>")
+	fmt.Println("  indexcheck <heap> <col> - check every live heap tuple's key (schema types) is in this btree")
+	fmt.Println("  duptids     - find heap TIDs referenced by more than one index entry")
+	fmt.Println("  findtid <block> [offset] - list index entries pointing at a heap block or TID")
+	fmt.Println("  freezeaudit [xid [mxid]] - find tuples older than relfrozenxid/relminmxid (or fetch via --dsn)")
+	fmt.Println("  toastreport <toast-file> - external values of this heap (per column with a schema), missing and orphaned TOAST values")
+	fmt.Println("  multixact [mxid] - list tuples whose xmax is a multixact, with members from pg_multixact")
+	fmt.Println("  refresh     - re-read the current page and show what changed (items, flags, header)")
+	fmt.Println("  watch [secs] [count] - re-read the current page count times and show each change")
+	fmt.Println("  journal [page] - page loads of this session with LSN, item count and free space changes")
+	fmt.Println("  replay <rec> - apply a raw WAL record (file or 'hex ...') to a copy of the page and diff")
+	fmt.Println("  futurelsn [pg_control] [lsn] - flag pages with pd_lsn beyond the last checkpoint or given LSN")
+	fmt.Println("  carve       - scan raw page bytes for heap tuple headers, ignoring line pointers")
+	fmt.Println("  rebuildlp [--write] - propose a line pointer array from carved tuples (write: --allow-writes)")
+	fmt.Println("  setlp <item> <edit> ...   - patch a line pointer, e.g. flags=DEAD offset=0 len=0 (--allow-writes)")
+	fmt.Println("  settuple <item> <edit> ... - edit a heap tuple header, e.g. xmax=0 infomask+=XMAX_INVALID (--allow-writes)")
+	fmt.Println("  clearhints  - clear xmin/xmax hint bits of the page's tuples, as heap_mask (--allow-writes)")
+	fmt.Println("  copypage [--keep-checksum] [file:]<src> [file:]<dst> - copy a page image onto another block (--allow-writes)")
+	fmt.Println("  truncate <npages> - cut the file down to npages pages (--allow-writes)")
+	fmt.Println("  extend <npages>   - append npages zeroed pages (--allow-writes)")
+	fmt.Println("  setflag/clearflag <flag>[,...] - set or clear pd_flags bits of the page (--allow-writes)")
+	fmt.Println("  search <pat> - find \"text\", hex <bytes> or int2|int4|int8 <n> in all pages")
+	fmt.Println("  stats [--sample N|P%] - whole-file page, line pointer and visibility statistics, or estimates from a sample")
+	fmt.Println("  pgstattuple - the pgstattuple() fields, computed from the file")
+	fmt.Println("  sidecar [write|drop] - show, (re)build or remove the cached page summaries used by pages/stats/select")
+	fmt.Println("  verify      - check every page's header bounds and checksum")
+	fmt.Println("  triage      - classify problem pages (checksum, zeroed, torn, bounds, items, special)")
+	fmt.Println("  hintstats   - count heap tuple hint bits across the file")
+	fmt.Println("  findbig <bytes> - list tuples longer than <bytes> with their TID and size")
+	fmt.Println("  fillfactor [expected] - estimate the fillfactor heap pages were packed to")
+	fmt.Println("  visibility [--mismatches] - pg_visibility() rows: VM bits vs PD_ALL_VISIBLE, with mismatches")
+	fmt.Println("  select <cols> [where ...] [order by <col> [desc]] [limit n] - query per-page metadata")
+	fmt.Println("  assert checksum ok | maxlsn <op> <lsn> | <cond> - check an invariant (batch mode exits 1 on failure)")
+	fmt.Println("  findflags [!]<flag>[,...] - list pages with pd_flags set (or clear, with !), VM alongside")
+	fmt.Println("  btlevels    - btree page count per level and health overview")
+	fmt.Println("  btcheck <t> - verify single-column btree key order (t: int2|int4|int8|text)")
+	fmt.Println("  spgchain [blk] <off> - follow an SP-GiST leaf tuple chain")
+	fmt.Println("  schema <t,..> - set key column types for typed decoding (or 'clear')")
+	fmt.Println("  brinranges [opclass,..] - list BRIN block ranges with summary values (minmax|inclusion)")
+	fmt.Println("  gistcheck   - verify GiST rightlinks/NSNs and report incomplete splits")
+	fmt.Println("  tid <ctid|hex|blk> - ctid <-> ItemPointerData bytes, and the segment file/page of a block")
+	fmt.Println("  hashbitmap  - list free/in-use hash overflow pages and check them against bucket chains")
+	fmt.Println("  walk right|left - follow index sibling links from the current page")
+	fmt.Println("  settype <t> - force page type or config template for all pages ('auto' to detect)")
+	fmt.Println("  set [k v]   - show or change settings (info_verbosity, robust, edit_mode, ...)")
+	fmt.Println("  set <name> <value> - define $name for arguments, e.g. page $name+5 ($curpage, $maxpage, $npages)")
+	fmt.Println("  unset <name> - remove a variable")
+	fmt.Println("  foreach page [a..b] [where <cond>] { cmd; ... } - run commands on each (matching) page")
+	fmt.Println("  if <cond> then <cmd>; ... [else <cmd>; ...] - run commands if the current page matches")
+	fmt.Println("  Whole-file scans (pages, map, heatmap, search, stats, verify, triage, hintstats, findbig,")
+	fmt.Println("  xcheck, duptids, freezeaudit, futurelsn) take --offset N and --limit N in pages,")
+	fmt.Println("  and all but duptids --since-lsn X/X to skip pages with an older pd_lsn.")
 	fmt.Println("  help        - show this help")
 	fmt.Println("  quit/exit   - exit")
 }
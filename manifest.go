@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// backupManifest is the backup_manifest pg_basebackup writes (format
+// version 1, or 2 with System-Identifier since PostgreSQL 17).
+type backupManifest struct {
+	Version          int            `json:"PostgreSQL-Backup-Manifest-Version"`
+	SystemIdentifier uint64         `json:"System-Identifier"`
+	Files            []manifestFile `json:"Files"`
+	WALRanges        []struct {
+		Timeline int    `json:"Timeline"`
+		StartLSN string `json:"Start-LSN"`
+		EndLSN   string `json:"End-LSN"`
+	} `json:"WAL-Ranges"`
+	Checksum string `json:"Manifest-Checksum"`
+}
+
+// manifestFile is one entry of a manifest's Files list. Paths that are not
+// valid UTF-8 are stored hex-encoded in Encoded-Path.
+type manifestFile struct {
+	Path        string `json:"Path"`
+	EncodedPath string `json:"Encoded-Path"`
+	Size        int64  `json:"Size"`
+	Algorithm   string `json:"Checksum-Algorithm"`
+	Checksum    string `json:"Checksum"`
+}
+
+// manifestIgnored are the top-level entries pg_verifybackup does not
+// expect in the manifest.
+var manifestIgnored = map[string]bool{
+	"backup_manifest": true, "pg_wal": true, "postgresql.auto.conf": true,
+	"recovery.signal": true, "standby.signal": true,
+}
+
+// manifestChecksum returns the hex checksum of the file at path with the
+// manifest's algorithm, as pg_checksum_final() encodes it.
+func manifestChecksum(path, algorithm string) (string, error) {
+	var h hash.Hash
+	switch strings.ToUpper(algorithm) {
+	case "CRC32C":
+		h = crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case "SHA224":
+		h = sha256.New224()
+	case "SHA256":
+		h = sha256.New()
+	case "SHA384":
+		h = sha512.New384()
+	case "SHA512":
+		h = sha512.New()
+	default:
+		return "", fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if c, ok := h.(hash.Hash32); ok {
+		// The CRC is copied out in memory order, not big-endian.
+		return hex.EncodeToString(binary.LittleEndian.AppendUint32(nil, c.Sum32())), nil
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyManifestChecksum checks Manifest-Checksum, the SHA-256 of the
+// manifest up to the line that holds it.
+func verifyManifestChecksum(data []byte, m *backupManifest) string {
+	i := bytes.LastIndex(data, []byte("\n\"Manifest-Checksum\""))
+	if i < 0 || m.Checksum == "" {
+		return "missing"
+	}
+	sum := sha256.Sum256(data[:i+1])
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), m.Checksum) {
+		return "MISMATCH (computed " + hex.EncodeToString(sum[:]) + ")"
+	}
+	return "ok"
+}
+
+// isRelationDataFile reports whether a manifest path is a relation
+// segment, whose pages can be checked one by one.
+func isRelationDataFile(path string) bool {
+	if !strings.HasPrefix(path, "base/") && !strings.HasPrefix(path, "global/") && !strings.HasPrefix(path, "pg_tblspc/") {
+		return false
+	}
+	_, _, _, ok := parseRelFileName(path)
+	return ok
+}
+
+// manifestPageProblems checks the pages of a relation file that does not
+// match its manifest entry: header bounds, checksums, and pd_lsn past the
+// end of the backup's WAL, which means the page changed after the backup.
+func manifestPageProblems(filename string, endLSN uint64) []string {
+	totalPages, err := countPages(filename)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var probs []string
+	for i := int64(0); i < totalPages; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil {
+			probs = append(probs, fmt.Sprintf("page %d: %v", i, err))
+			continue
+		}
+		if isNewPage(pg) {
+			continue
+		}
+		for _, a := range pg.Anomalies(AnnotationHeader, AnnotationFlags, AnnotationChecksum) {
+			probs = append(probs, fmt.Sprintf("page %d: %s", i, a))
+		}
+		if endLSN != 0 && pg.Header.LSN > endLSN {
+			probs = append(probs, fmt.Sprintf("page %d: pd_lsn %s is past the backup end LSN %s (changed after the backup)",
+				i, lsnStr(pg.Header.LSN), lsnStr(endLSN)))
+		}
+	}
+	return probs
+}
+
+// CmdManifestVerify checks the files of a backup directory against the
+// backup_manifest pg_basebackup wrote: the manifest's own checksum, every
+// file's presence, size and checksum, and files the manifest does not
+// list. For relation files that do not match, the pages are checked as
+// verify does, to show which pages are damaged. It returns the number of
+// problems found.
+func CmdManifestVerify(manifestPath, backupDir string) (int, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return 0, err
+	}
+	var m backupManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return 0, fmt.Errorf("%s: %w", manifestPath, err)
+	}
+	var endLSN uint64
+	for _, r := range m.WALRanges {
+		if lsn, err := parseLSN(r.EndLSN); err == nil {
+			endLSN = max(endLSN, lsn)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("=== Manifest Verify (%s) ===\n", shownPath(manifestPath))
+	fmt.Printf("  Backup directory   : %s\n", shownPath(backupDir))
+	fmt.Printf("  Manifest version   : %d", m.Version)
+	if m.SystemIdentifier != 0 {
+		fmt.Printf(", system identifier %d", m.SystemIdentifier)
+	}
+	fmt.Println()
+	for _, r := range m.WALRanges {
+		fmt.Printf("  WAL range          : timeline %d, %s - %s\n", r.Timeline, r.StartLSN, r.EndLSN)
+	}
+	problems := 0
+	mc := verifyManifestChecksum(data, &m)
+	if mc != "ok" {
+		problems++
+	}
+	fmt.Printf("  Manifest checksum  : %s\n", mc)
+	fmt.Println()
+
+	listed := map[string]bool{}
+	var missing, badSize, badSum, ok, unchecked int
+	report := func(path, format string, args ...interface{}) {
+		problems++
+		fmt.Printf("  %s: %s\n", path, fmt.Sprintf(format, args...))
+	}
+	pageDetail := func(path, full string) {
+		if !isRelationDataFile(path) {
+			return
+		}
+		probs := manifestPageProblems(full, endLSN)
+		for i, pr := range probs {
+			if i == 20 {
+				fmt.Printf("    ... %d more\n", len(probs)-i)
+				break
+			}
+			fmt.Printf("    %s\n", pr)
+		}
+		if len(probs) == 0 {
+			fmt.Println("    (no page-level problems found: headers, checksums and LSNs look sane)")
+		}
+	}
+	for _, f := range m.Files {
+		path := f.Path
+		if f.EncodedPath != "" {
+			raw, err := hex.DecodeString(f.EncodedPath)
+			if err != nil {
+				report(f.EncodedPath, "invalid Encoded-Path")
+				continue
+			}
+			path = string(raw)
+		}
+		listed[path] = true
+		full := filepath.Join(backupDir, filepath.FromSlash(path))
+		fi, err := os.Stat(full)
+		switch {
+		case err != nil:
+			missing++
+			report(path, "missing (%v)", err)
+			continue
+		case fi.Size() != f.Size:
+			badSize++
+			report(path, "size %d, manifest says %d", fi.Size(), f.Size)
+			if isRelationDataFile(path) {
+				fmt.Printf("    %d pages on disk, %d in the manifest", fi.Size()/PageSize, f.Size/PageSize)
+				if part := fi.Size() % PageSize; part != 0 {
+					fmt.Printf(", %d trailing bytes of a partial page", part)
+				}
+				fmt.Println()
+			}
+			pageDetail(path, full)
+			continue
+		case f.Algorithm == "" || strings.EqualFold(f.Algorithm, "NONE"):
+			unchecked++
+			continue
+		}
+		sum, err := manifestChecksum(full, f.Algorithm)
+		switch {
+		case err != nil:
+			report(path, "%v", err)
+		case !strings.EqualFold(sum, f.Checksum):
+			badSum++
+			report(path, "%s checksum %s, manifest says %s", f.Algorithm, sum, f.Checksum)
+			pageDetail(path, full)
+		default:
+			ok++
+		}
+	}
+
+	// Tablespaces are symlinks in pg_tblspc, followed as pg_verifybackup does.
+	extra := 0
+	var walk func(dir, prefix string)
+	walk = func(dir, prefix string) {
+		filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if p == dir {
+				return nil
+			}
+			rel, _ := filepath.Rel(dir, p)
+			rel = filepath.ToSlash(filepath.Join(prefix, rel))
+			switch {
+			case !strings.Contains(rel, "/") && manifestIgnored[rel]:
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			case d.IsDir():
+				return nil
+			case d.Type()&fs.ModeSymlink != 0:
+				// The trailing separator makes WalkDir follow the link.
+				if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+					walk(p+string(filepath.Separator), rel)
+					return nil
+				}
+			}
+			if !listed[rel] && filepath.Clean(p) != filepath.Clean(manifestPath) {
+				extra++
+				report(rel, "present in the backup but not in the manifest")
+			}
+			return nil
+		})
+	}
+	walk(backupDir, "")
+
+	if problems == 0 {
+		fmt.Println("  No problems found.")
+	}
+	fmt.Println()
+	fmt.Printf("  Files in manifest  : %d\n", len(m.Files))
+	fmt.Printf("  Matching           : %d", ok)
+	if unchecked > 0 {
+		fmt.Printf(" (+%d with the right size and no checksum)", unchecked)
+	}
+	fmt.Println()
+	fmt.Printf("  Missing            : %d\n", missing)
+	fmt.Printf("  Size mismatches    : %d\n", badSize)
+	fmt.Printf("  Checksum mismatches: %d\n", badSum)
+	fmt.Printf("  Not in manifest    : %d\n", extra)
+	fmt.Println()
+	return problems, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mapLegend lists the minimap characters in the order they are explained.
+// Access methods get an upper-case letter for leaf (data) pages and the
+// lower-case one for internal pages.
+var mapLegend = []struct {
+	ch    byte
+	about string
+}{
+	{'H', "heap"},
+	{'B', "btree leaf"}, {'b', "btree internal"},
+	{'K', "hash bucket"}, {'k', "hash overflow"},
+	{'G', "GiST leaf"}, {'g', "GiST internal"},
+	{'N', "GIN leaf"}, {'n', "GIN internal"},
+	{'S', "SP-GiST leaf"}, {'s', "SP-GiST internal"},
+	{'R', "BRIN regular"},
+	{'M', "meta"}, {'m', "bitmap/revmap"},
+	{'.', "new or empty"},
+	{'?', "unknown"},
+	{'E', "encrypted?"},
+	{'X', "corrupt"},
+	{'!', "unreadable"},
+	{' ', "before --since-lsn"},
+}
+
+// mapChar picks the minimap character for a page.
+func mapChar(p *Page) byte {
+	if p.Encrypted {
+		return 'E'
+	}
+	if p.IsCorrupt() {
+		return 'X'
+	}
+	if isNewPage(p) {
+		return '.'
+	}
+	sub := detectPageSubtype(p)
+	switch sub {
+	case "meta":
+		return 'M'
+	case "bitmap", "revmap":
+		return 'm'
+	}
+	if len(p.Items) == 0 {
+		return '.'
+	}
+	internal := sub == "internal" || sub == "data-internal" || sub == "entry-internal" || sub == "overflow"
+	var c byte
+	switch p.Detected {
+	case PageTypeHeap:
+		return 'H'
+	case PageTypeBTree:
+		c = 'B'
+	case PageTypeHash:
+		c = 'K'
+	case PageTypeGiST:
+		c = 'G'
+	case PageTypeGIN:
+		c = 'N'
+	case PageTypeSPGiST:
+		c = 'S'
+	case PageTypeBRIN:
+		return 'R'
+	default:
+		return '?'
+	}
+	if internal {
+		c += 'a' - 'A'
+	}
+	return c
+}
+
+// CmdMap prints one character per page, width pages to a row, each row
+// prefixed with the number of its first page, followed by a legend of the
+// characters that occur.
+func CmdMap(filename string, totalPages int64, sr scanRange, width int) {
+	fmt.Println()
+	fmt.Printf("=== Page Map (%s) ===\n", sr.String(totalPages))
+
+	digits := len(fmt.Sprint(max(totalPages-1, 0)))
+	seen := map[byte]int{}
+	var row strings.Builder
+	first, end := sr.bounds(totalPages)
+	for i := first; i < end; i++ {
+		c := byte('!')
+		if pg, err := ReadPage(filename, i); err == nil && sr.skips(pg) {
+			c = ' '
+		} else if err == nil {
+			c = mapChar(pg)
+		}
+		seen[c]++
+		row.WriteByte(c)
+		if row.Len() == width || i == end-1 {
+			fmt.Printf("  %*d %s\n", digits, i+1-int64(row.Len()), row.String())
+			row.Reset()
+		}
+	}
+
+	fmt.Println()
+	for _, l := range mapLegend {
+		if n := seen[l.ch]; n > 0 {
+			fmt.Printf("  %c %-18s: %d\n", l.ch, l.about, n)
+		}
+	}
+	fmt.Println()
+}
+
+// mapWidth returns how many pages fit on a row of a terminal cols wide,
+// rounded down to a multiple of 10 so columns line up between rows.
+func mapWidth(cols int, totalPages int64) int {
+	if cols <= 0 {
+		cols = 80
+	}
+	w := cols - 4 - len(fmt.Sprint(max(totalPages-1, 0)))
+	w -= w % 10
+	return max(w, 10)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+// ---- pg_multixact (offsets and members SLRUs) ----
+
+const (
+	SlruPagesPerSegment = 32
+
+	// pg_multixact/offsets: one MultiXactOffset per multixact.
+	MultiXactOffsetsPerPage = PageSize / 4
+
+	// pg_multixact/members: groups of four flag bytes followed by four xids.
+	MultiXactMembersPerGroup     = 4
+	multiXactMemberGroupSize     = MultiXactMembersPerGroup + 4*MultiXactMembersPerGroup
+	MultiXactMemberGroupsPerPage = PageSize / multiXactMemberGroupSize
+	MultiXactMembersPerPage      = MultiXactMemberGroupsPerPage * MultiXactMembersPerGroup
+
+	// multiXactMaxMembers caps the member count of one multixact; a larger
+	// difference between two offsets means damaged or truncated SLRU files.
+	multiXactMaxMembers = 1 << 16
+)
+
+// MultiXactMember is one member of a multixact: a transaction and the
+// lock (or update) it holds.
+type MultiXactMember struct {
+	Xid    uint32
+	Status uint8
+}
+
+func multiXactStatusStr(s uint8) string {
+	switch s {
+	case 0:
+		return "keysh"
+	case 1:
+		return "sh"
+	case 2:
+		return "fornokeyupd"
+	case 3:
+		return "forupd"
+	case 4:
+		return "nokeyupd"
+	case 5:
+		return "upd"
+	}
+	return fmt.Sprintf("status %d", s)
+}
+
+func (m MultiXactMember) String() string {
+	return fmt.Sprintf("%d (%s)", m.Xid, multiXactStatusStr(m.Status))
+}
+
+// slruRead reads n bytes at offset off of page pageno of the SLRU in dir,
+// whose segments are named by four hex digits.
+func slruRead(dir string, pageno int64, off, n int) ([]byte, error) {
+	seg := fmt.Sprintf("%04X", pageno/SlruPagesPerSegment)
+	f, err := os.Open(filepath.Join(dir, seg))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, (pageno%SlruPagesPerSegment)*PageSize+int64(off)); err != nil {
+		return nil, fmt.Errorf("%s page %d: %w", filepath.Join(filepath.Base(dir), seg), pageno%SlruPagesPerSegment, err)
+	}
+	return buf, nil
+}
+
+func multiXactOffset(mxdir string, mxid uint32) (uint32, error) {
+	b, err := slruRead(filepath.Join(mxdir, "offsets"), int64(mxid/MultiXactOffsetsPerPage), int(mxid%MultiXactOffsetsPerPage)*4, 4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+// MultiXactMembers returns the members of a multixact, as
+// GetMultiXactIdMembers reads them: from its offset up to the next
+// multixact's offset.
+func MultiXactMembers(mxdir string, mxid uint32) ([]MultiXactMember, error) {
+	off, err := multiXactOffset(mxdir, mxid)
+	if err != nil {
+		return nil, err
+	}
+	if off == 0 {
+		return nil, fmt.Errorf("no offset recorded (not created yet, or truncated away)")
+	}
+	next := mxid + 1
+	if next == 0 {
+		next = 1 // FirstMultiXactId
+	}
+	nextOff, err := multiXactOffset(mxdir, next)
+	if err != nil || nextOff == 0 {
+		return nil, fmt.Errorf("member count unknown: multixact %d has no offset yet", next)
+	}
+	if nextOff-off > multiXactMaxMembers {
+		return nil, fmt.Errorf("implausible member count %d (offset %d, next offset %d)", nextOff-off, off, nextOff)
+	}
+
+	var members []MultiXactMember
+	for o := off; o != nextOff; o++ {
+		in := o % MultiXactMembersPerPage
+		group, slot := int(in/MultiXactMembersPerGroup), int(in%MultiXactMembersPerGroup)
+		b, err := slruRead(filepath.Join(mxdir, "members"), int64(o/MultiXactMembersPerPage), group*multiXactMemberGroupSize, multiXactMemberGroupSize)
+		if err != nil {
+			return members, err
+		}
+		members = append(members, MultiXactMember{
+			Xid:    binary.LittleEndian.Uint32(b[MultiXactMembersPerGroup+4*slot:]),
+			Status: b[slot],
+		})
+	}
+	return members, nil
+}
+
+// findMultiXactDir returns the pg_multixact directory of the data
+// directory the relation file lives in, or "" if there is none.
+func findMultiXactDir(filename string) string {
+	ctl := findPGControl(filename)
+	if ctl == "" {
+		return ""
+	}
+	dir := filepath.Join(filepath.Dir(filepath.Dir(ctl)), "pg_multixact")
+	if _, err := os.Stat(dir); err != nil {
+		return ""
+	}
+	return dir
+}
+
+// parseMultiXactArg parses the optional multixact ID argument; 0 means
+// every multixact.
+func parseMultiXactArg(args []string) (uint32, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	m, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil || m == 0 {
+		return 0, fmt.Errorf("invalid multixact ID %q", args[0])
+	}
+	return uint32(m), nil
+}
+
+// CmdMultiXact lists the heap tuples whose xmax is the given multixact,
+// or any multixact when mxid is 0, and then each multixact's members read
+// from the cluster's pg_multixact.
+func CmdMultiXact(filename string, totalPages int64, sr scanRange, mxid uint32) {
+	fmt.Println()
+	if mxid != 0 {
+		fmt.Printf("=== Tuples with Multixact %d ===\n", mxid)
+	} else {
+		fmt.Println("=== Tuples with a Multixact xmax ===")
+	}
+
+	tuples, found := 0, 0
+	seen := map[uint32]int{}
+	first, end := sr.bounds(totalPages)
+	for i := first; i < end; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil || pg.Detected != PageTypeHeap || sr.skips(pg) {
+			continue
+		}
+		for n, lp := range pg.Items {
+			if lp.Flags() != LPNormal || lp.Length() < HeapTupleHdrSize || int(lp.Offset())+HeapTupleHdrSize > PageSize {
+				continue
+			}
+			tuples++
+			t := pg.ParseHeapTupleHeader(lp.Offset())
+			if t.Infomask&HeapXmaxIsMulti == 0 || (mxid != 0 && t.Xmax != mxid) {
+				continue
+			}
+			found++
+			seen[t.Xmax]++
+			what := "updated or deleted"
+			if t.Infomask&HeapXmaxLockOnly != 0 {
+				what = "locked only"
+			}
+			if t.Infomask&HeapXmaxInvalid != 0 {
+				what += ", XMAX_INVALID"
+			}
+			fmt.Printf("  page %d item %d: xmax multixact %d (%s)\n", i, n+1, t.Xmax, what)
+		}
+	}
+	if found == 0 {
+		fmt.Println("  No tuples found.")
+	}
+
+	if len(seen) > 0 {
+		fmt.Println()
+		fmt.Println("=== Multixact Members ===")
+		mxdir := findMultiXactDir(filename)
+		if mxdir == "" {
+			fmt.Println("  (no pg_multixact found above the file; start with --pgdata to decode members)")
+		}
+		ids := make([]uint32, 0, len(seen))
+		for m := range seen {
+			ids = append(ids, m)
+		}
+		slices.Sort(ids)
+		for _, m := range ids {
+			fmt.Printf("  %d (%d tuples)", m, seen[m])
+			if mxdir == "" {
+				fmt.Println()
+				continue
+			}
+			members, err := MultiXactMembers(mxdir, m)
+			for _, mem := range members {
+				fmt.Printf("  %s", mem)
+			}
+			if err != nil {
+				fmt.Printf("  ERROR: %v", err)
+			}
+			fmt.Println()
+		}
+	}
+	fmt.Println()
+	fmt.Printf("  Tuples checked     : %d\n", tuples)
+	fmt.Printf("  Multixact xmax     : %d\n", found)
+	fmt.Printf("  Distinct multixacts: %d\n", len(seen))
+	fmt.Println()
+}
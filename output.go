@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+
+	"github.com/chzyer/readline"
+)
+
+// deterministic makes text output depend only on the files read and the
+// command line (--deterministic), for golden-file comparisons: widths do
+// not follow the terminal, there is no color, and paths of the user's
+// cache directory and whether a sidecar index answered are not shown.
+// Orderings are stable either way.
+var deterministic bool
+
+// screenWidth is the terminal width, or 0 (unknown, use the default) in
+// deterministic mode.
+func screenWidth() int {
+	if deterministic {
+		return 0
+	}
+	return readline.GetScreenWidth()
+}
+
+// colorOutput reports whether output may use ANSI colors.
+func colorOutput() bool {
+	return !deterministic && readline.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// shownPath returns path for display, or a placeholder in deterministic
+// mode; it is used for machine-specific paths such as sidecar indexes.
+func shownPath(path string) string {
+	if deterministic {
+		return "(path omitted)"
+	}
+	return path
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"strings"
 )
 
@@ -85,6 +86,12 @@ const (
 	HeapMovedIn        = 0x8000
 )
 
+// Special t_ctid offset numbers (itemptr.h / htup_details.h)
+const (
+	SpecTokenOffsetNumber       = 0xFFFE
+	MovedPartitionsOffsetNumber = 0xFFFD
+)
+
 // ---- Heap tuple t_infomask2 bits ----
 
 const (
@@ -109,6 +116,8 @@ const (
 	PDHasFreeLines = 0x0001
 	PDPageFull     = 0x0002
 	PDAllVisible   = 0x0004
+
+	PDValidFlagBits = 0x0007 // PD_VALID_FLAG_BITS; PageIsVerified rejects others
 )
 
 // ---- B-tree constants ----
@@ -198,6 +207,77 @@ const (
 
 // ---- Structures ----
 
+// fieldSpan names a fixed-size field of an on-disk struct by its byte
+// range relative to the start of the struct.
+type fieldSpan struct {
+	Name       string
+	Start, End int
+}
+
+// pageHeaderFields lays out PageHeaderData.
+var pageHeaderFields = []fieldSpan{
+	{"pd_lsn", 0, 8},
+	{"pd_checksum", 8, 10},
+	{"pd_flags", 10, 12},
+	{"pd_lower", 12, 14},
+	{"pd_upper", 14, 16},
+	{"pd_special", 16, 18},
+	{"pd_pagesize_version", 18, 20},
+	{"pd_prune_xid", 20, 24},
+}
+
+// heapTupleHeaderFields lays out the fixed part of HeapTupleHeaderData.
+var heapTupleHeaderFields = []fieldSpan{
+	{"t_xmin", 0, 4},
+	{"t_xmax", 4, 8},
+	{"t_cid", 8, 12},
+	{"t_ctid", 12, 18},
+	{"t_infomask2", 18, 20},
+	{"t_infomask", 20, 22},
+	{"t_hoff", 22, 23},
+}
+
+// indexTupleHeaderFields lays out IndexTupleData.
+var indexTupleHeaderFields = []fieldSpan{
+	{"t_tid", 0, 6},
+	{"t_info", 6, 8},
+}
+
+// specialFields lays out the special region of each index page type.
+var specialFields = map[PageType][]fieldSpan{
+	PageTypeBTree: {
+		{"btpo_prev", 0, 4}, {"btpo_next", 4, 8}, {"btpo_level", 8, 12},
+		{"btpo_flags", 12, 14}, {"btpo_cycleid", 14, 16},
+	},
+	PageTypeHash: {
+		{"hasho_prevblkno", 0, 4}, {"hasho_nextblkno", 4, 8}, {"hasho_bucket", 8, 12},
+		{"hasho_flag", 12, 14}, {"hasho_page_id", 14, 16},
+	},
+	PageTypeGiST: {
+		{"nsn", 0, 8}, {"rightlink", 8, 12}, {"flags", 12, 14}, {"gist_page_id", 14, 16},
+	},
+	PageTypeGIN: {
+		{"rightlink", 0, 4}, {"maxoff", 4, 6}, {"flags", 6, 8},
+	},
+	PageTypeSPGiST: {
+		{"flags", 0, 2}, {"nRedirection", 2, 4}, {"nPlaceholder", 4, 6}, {"spgist_page_id", 6, 8},
+	},
+	PageTypeBRIN: {
+		{"vector[0] (unused)", 0, 2}, {"vector[1] (unused)", 2, 4}, {"flags", 4, 6}, {"page_type", 6, 8},
+	},
+}
+
+// fieldAt returns the field containing byte off (relative to the struct
+// start) and the byte's position within it.
+func fieldAt(fields []fieldSpan, off int) (fieldSpan, int, bool) {
+	for _, f := range fields {
+		if off >= f.Start && off < f.End {
+			return f, off - f.Start, true
+		}
+	}
+	return fieldSpan{}, 0, false
+}
+
 type PageHeader struct {
 	LSN         uint64
 	Checksum    uint16
@@ -244,6 +324,37 @@ type HeapTupleHeader struct {
 
 func (t *HeapTupleHeader) NAttrs() int { return int(t.Infomask2 & HeapNattsMask) }
 
+// IsMovedByVacuum reports whether HEAP_MOVED_OFF or HEAP_MOVED_IN is set.
+// Such tuples were moved by the old-style VACUUM FULL removed in 9.0, and
+// the t_cid slot holds t_xvac, the XID of that VACUUM, instead.
+func (t *HeapTupleHeader) IsMovedByVacuum() bool {
+	return t.Infomask&(HeapMovedOff|HeapMovedIn) != 0
+}
+
+// Field3Name names the union in the t_cid slot for this tuple.
+func (t *HeapTupleHeader) Field3Name() string {
+	if t.IsMovedByVacuum() {
+		return "t_xvac"
+	}
+	return "t_cid"
+}
+
+// IsSpeculative reports whether t_ctid holds a speculative insertion token
+// (INSERT ... ON CONFLICT in progress) instead of a tuple pointer.
+func (t *HeapTupleHeader) IsSpeculative() bool { return t.CtidOffset == SpecTokenOffsetNumber }
+
+// CtidStr formats t_ctid, recognising the special offset numbers that do
+// not point at another tuple version.
+func (t *HeapTupleHeader) CtidStr() string {
+	switch {
+	case t.IsSpeculative():
+		return fmt.Sprintf("speculative insertion token %d", t.CtidBlock)
+	case t.CtidOffset == MovedPartitionsOffsetNumber:
+		return fmt.Sprintf("(%d, %d) moved to another partition", t.CtidBlock, t.CtidOffset)
+	}
+	return fmt.Sprintf("(%d, %d)", t.CtidBlock, t.CtidOffset)
+}
+
 func (t *HeapTupleHeader) InfomaskFlags() []string {
 	var flags []string
 	m := t.Infomask
@@ -339,15 +450,261 @@ func (it *IndexTupleHeader) InfoFlags() []string {
 	return flags
 }
 
+// BTPageOpaque mirrors BTPageOpaqueData, the btree special region.
+type BTPageOpaque struct {
+	Prev, Next uint32
+	Level      uint32
+	Flags      uint16
+	CycleID    uint16
+}
+
+// GiSTPageOpaque mirrors GISTPageOpaqueData, the GiST special region.
+type GiSTPageOpaque struct {
+	NSN       uint64
+	Rightlink uint32
+	Flags     uint16
+}
+
 // Page holds a full 8KB page in memory.
 type Page struct {
 	Data     [PageSize]byte
 	Header   PageHeader
 	Items    []ItemId
-	PageNum  int
+	PageNum  int64
 	Detected PageType
+
+	// AutoDetected is the type found by detecting this page alone; it
+	// differs from Detected when a type override is in effect (Forced) or
+	// the file's inferred type was applied (FromFileType).
+	AutoDetected PageType
+	Forced       bool
+	FromFileType bool
+
+	// RawHeader holds the header as stored; Header differs from it only
+	// when robust parsing clamped insane bounds.
+	RawHeader   PageHeader
+	Annotations []Annotation
+
+	// ClusterChecksums is the data checksum setting of the cluster the
+	// file belongs to, when its pg_control could be found.
+	ClusterChecksums checksumMode
+
+	// ReadAttempts is how many reads --live needed to get a consistent
+	// copy of the page; Unstable is set if it never got one.
+	ReadAttempts int
+	Unstable     bool
+
+	// Encrypted is set for pages that do not parse and whose bytes look
+	// like ciphertext (see looksEncrypted).
+	Encrypted bool
+
+	// Template is the config-defined special layout the page matched; the
+	// page is then decoded as Unknown apart from its special area.
+	Template *specialTemplate
+}
+
+// robustParsing clamps insane header bounds before line pointers are read
+// and the page type is detected (--robust / set robust on). Anomalies are
+// recorded either way.
+var robustParsing = false
+
+// AnnotationKind says which part of a page an annotation is about.
+type AnnotationKind string
+
+const (
+	AnnotationHeader   AnnotationKind = "header"
+	AnnotationFlags    AnnotationKind = "flags"
+	AnnotationItem     AnnotationKind = "line_pointer"
+	AnnotationChecksum AnnotationKind = "checksum"
+)
+
+// annotationLabels are the prefixes info prints annotations with.
+var annotationLabels = map[AnnotationKind]string{
+	AnnotationHeader:   "header corrupt",
+	AnnotationFlags:    "bad pd_flags",
+	AnnotationItem:     "bad line pointer",
+	AnnotationChecksum: "checksum failure",
+}
+
+// Annotation is one problem found while reading a page. Item is the line
+// pointer number of line pointer annotations.
+type Annotation struct {
+	Kind    AnnotationKind `json:"kind"`
+	Item    int            `json:"item,omitempty"`
+	Message string         `json:"message"`
+}
+
+func (a Annotation) String() string {
+	if a.Item > 0 {
+		return fmt.Sprintf("item %d: %s", a.Item, a.Message)
+	}
+	return a.Message
+}
+
+func (p *Page) annotate(kind AnnotationKind, item int, format string, args ...interface{}) {
+	p.Annotations = append(p.Annotations, Annotation{Kind: kind, Item: item, Message: fmt.Sprintf(format, args...)})
+}
+
+// Anomalies returns the annotations of the given kinds as text, in the
+// order they were found.
+func (p *Page) Anomalies(kinds ...AnnotationKind) []string {
+	var out []string
+	for _, a := range p.Annotations {
+		if slices.Contains(kinds, a.Kind) {
+			out = append(out, a.String())
+		}
+	}
+	return out
+}
+
+// ChecksumProblem is the page's checksum annotation, or "" if it has none.
+// Only pages read with ReadPage are checked.
+func (p *Page) ChecksumProblem() string {
+	if pr := p.Anomalies(AnnotationChecksum); len(pr) > 0 {
+		return pr[0]
+	}
+	return ""
+}
+
+// AnnotationSummary counts the annotations by kind, e.g. "2 header, 1
+// checksum".
+func (p *Page) AnnotationSummary() string {
+	var parts []string
+	for _, k := range []AnnotationKind{AnnotationHeader, AnnotationFlags, AnnotationItem, AnnotationChecksum} {
+		if n := len(p.Anomalies(k)); n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, strings.ReplaceAll(string(k), "_", " ")))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// IsCorrupt reports whether any anomaly was found: header bounds, pd_flags,
+// line pointers or, for pages read with ReadPage, the checksum.
+func (p *Page) IsCorrupt() bool { return len(p.Annotations) > 0 }
+
+// checkHeader records anomalies in the header bounds and, in robust mode,
+// clamps them to a consistent layout: 24 <= lower <= upper <= special <= 8192.
+func (p *Page) checkHeader() {
+	h := &p.Header
+	if h.Upper == 0 {
+		return // new page, nothing to check
+	}
+	bad := func(format string, args ...interface{}) { p.annotate(AnnotationHeader, 0, format, args...) }
+	if h.PageSz() != PageSize {
+		bad("pd_pagesize_version size %d, expected %d", h.PageSz(), PageSize)
+	}
+	if h.LayoutVersion() != 4 {
+		bad("pd_pagesize_version layout version %d, expected 4", h.LayoutVersion())
+	}
+	special, upper, lower := h.Special, h.Upper, h.Lower
+	if special > PageSize || special < PageHeaderSize {
+		bad("pd_special %d outside [%d, %d]", special, PageHeaderSize, PageSize)
+		special = PageSize
+	}
+	if upper > special {
+		bad("pd_upper %d beyond pd_special %d", upper, special)
+		upper = special
+	}
+	if lower < PageHeaderSize {
+		bad("pd_lower %d inside the page header", lower)
+		lower = PageHeaderSize
+	}
+	if lower > upper {
+		bad("pd_lower %d beyond pd_upper %d", lower, upper)
+		lower = upper
+	}
+	if h.Lower >= PageHeaderSize && (h.Lower-PageHeaderSize)%ItemIdSize != 0 {
+		bad("pd_lower %d not on a line pointer boundary", h.Lower)
+	}
+	if extra := h.Flags &^ PDValidFlagBits; extra != 0 {
+		p.annotate(AnnotationFlags, 0, "pd_flags 0x%04X has unknown bits 0x%04X", h.Flags, extra)
+	}
+	if robustParsing {
+		h.Special, h.Upper, h.Lower = special, upper, lower
+		h.PageSizeVer = PageSize | uint16(h.LayoutVersion())
+	}
+}
+
+// checkItems records line pointers that point outside the tuple area.
+// Meta, bitmap and revmap pages keep their contents where the line pointer
+// array would be and are skipped.
+func (p *Page) checkItems() {
+	h := &p.Header
+	if h.Upper == 0 {
+		return
+	}
+	switch detectPageSubtype(p) {
+	case "meta", "bitmap", "revmap":
+		return
+	}
+	limit := int(h.Special)
+	if limit > PageSize || limit < PageHeaderSize {
+		limit = PageSize
+	}
+	for i, lp := range p.Items {
+		var msg string
+		switch lp.Flags() {
+		case LPNormal:
+			end := int(lp.Offset()) + int(lp.Length())
+			switch {
+			case lp.Length() == 0:
+				msg = "NORMAL with zero length"
+			case lp.Offset() < h.Upper && lp.Offset() >= h.Lower:
+				msg = fmt.Sprintf("offset %d in free space", lp.Offset())
+			case lp.Offset() < h.Lower:
+				msg = fmt.Sprintf("offset %d before pd_lower %d", lp.Offset(), h.Lower)
+			case end > limit:
+				msg = fmt.Sprintf("offset %d + length %d runs past the tuple area", lp.Offset(), lp.Length())
+			}
+		case LPRedirect:
+			if lp.Offset() == 0 || int(lp.Offset()) > len(p.Items) {
+				msg = fmt.Sprintf("redirect to invalid item %d", lp.Offset())
+			}
+		}
+		if msg != "" {
+			p.annotate(AnnotationItem, i+1, "%s", msg)
+		}
+	}
+}
+
+// pageTypeOverride forces the decoder for every page read while set
+// (settype / --type); noTypeOverride means auto-detect.
+const noTypeOverride PageType = -1
+
+var pageTypeOverride = noTypeOverride
+
+// ParsePageType parses a page type name as printed by PageType.String.
+func ParsePageType(s string) (PageType, error) {
+	for pt := PageTypeHeap; pt <= PageTypeUnknown; pt++ {
+		if pt.String() == s {
+			return pt, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown page type %q (heap, btree, hash, gist, gin, spgist, brin, unknown)", s)
+}
+
+// minSpecialSize is the special area size the decoders of pt rely on.
+func minSpecialSize(pt PageType) int {
+	switch pt {
+	case PageTypeBTree:
+		return BTreeOpaqueSize
+	case PageTypeHash:
+		return HashOpaqueSize
+	case PageTypeGiST:
+		return GistOpaqueSize
+	case PageTypeGIN:
+		return GINOpaqueSize
+	case PageTypeSPGiST:
+		return SPGistOpaqueSize
+	case PageTypeBRIN:
+		return BRINSpecialSize
+	}
+	return 0
 }
 
+// IsOverridden reports whether the page is decoded with a forced type.
+func (p *Page) IsOverridden() bool { return p.Forced }
+
 func ParsePage(data [PageSize]byte) *Page {
 	p := &Page{Data: data}
 	le := binary.LittleEndian
@@ -362,56 +719,100 @@ func ParsePage(data [PageSize]byte) *Page {
 	p.Header.Special = le.Uint16(data[16:18])
 	p.Header.PageSizeVer = le.Uint16(data[18:20])
 	p.Header.PruneXID = le.Uint32(data[20:24])
+	p.RawHeader = p.Header
+	p.checkHeader()
 
 	numItems := 0
 	if p.Header.Lower > PageHeaderSize {
 		numItems = int(p.Header.Lower-PageHeaderSize) / ItemIdSize
 	}
+	// Never read line pointers past the end of the page, even unclamped.
+	if maxItems := (PageSize - PageHeaderSize) / ItemIdSize; numItems > maxItems {
+		numItems = maxItems
+	}
 	p.Items = make([]ItemId, numItems)
 	for i := 0; i < numItems; i++ {
 		off := PageHeaderSize + i*ItemIdSize
 		p.Items[i] = ItemId{Raw: le.Uint32(data[off : off+4])}
 	}
 
-	p.Detected = p.detectPageType()
+	p.AutoDetected = p.detectPageType()
+	p.Detected = p.AutoDetected
+	// Only force types whose special area fits, so decoders stay in bounds.
+	if pageTypeOverride != noTypeOverride && len(p.SpecialData()) >= minSpecialSize(pageTypeOverride) {
+		p.Detected = pageTypeOverride
+		p.Forced = true
+	}
+	if !p.Forced {
+		if p.Template = matchTemplate(p); p.Template != nil {
+			p.Detected = PageTypeUnknown
+			p.Forced = templateOverride != ""
+		}
+	}
+	p.checkItems()
+	p.Encrypted = p.looksEncrypted()
 	return p
 }
 
 func (p *Page) detectPageType() PageType {
+	return p.detect(func(string, ...interface{}) {})
+}
+
+// DetectionReasons re-runs page type detection and returns the steps that
+// led to the result, for verbose output.
+func (p *Page) DetectionReasons() []string {
+	var reasons []string
+	p.detect(func(format string, args ...interface{}) {
+		reasons = append(reasons, fmt.Sprintf(format, args...))
+	})
+	return reasons
+}
+
+// detect implements the page type heuristic, reporting each decision
+// through why.
+func (p *Page) detect(why func(format string, args ...interface{})) PageType {
 	h := &p.Header
 	pageSize := int(h.PageSz())
-	if pageSize == 0 {
+	if pageSize == 0 || pageSize > PageSize {
+		why("pd_pagesize_version size %d unusable, assuming %d", pageSize, PageSize)
 		pageSize = PageSize
 	}
 	specialSize := pageSize - int(h.Special)
 
 	if specialSize == 0 {
+		why("pd_special == page size: no special space -> heap")
 		return PageTypeHeap
 	}
 	if int(h.Special) >= pageSize || h.Special < PageHeaderSize {
+		why("pd_special %d outside [%d, %d) -> unknown", h.Special, PageHeaderSize, pageSize)
 		return PageTypeUnknown
 	}
 
 	special := p.Data[h.Special:]
 	le := binary.LittleEndian
+	why("special space is %d bytes at offset %d", specialSize, h.Special)
 
 	// 8-byte special: could be BRIN, SP-GiST, or GIN
 	if specialSize == 8 {
 		// BRIN: page type at vector[3] (offset 6)
 		brinType := le.Uint16(special[6:8])
 		if brinType == BRINPageTypeMeta || brinType == BRINPageTypeRevmap || brinType == BRINPageTypeRegular {
+			why("special[6:8] = 0x%04X is a BRIN page type -> brin", brinType)
 			return PageTypeBRIN
 		}
 		// SP-GiST: page_id at offset 6
 		spgistID := le.Uint16(special[6:8])
 		if spgistID == SPGistPageID {
+			why("special[6:8] = 0x%04X is SPGIST_PAGE_ID -> spgist", spgistID)
 			return PageTypeSPGiST
 		}
 		// GIN: flags at offset 6, valid flags in bits 0-7
 		ginFlags := le.Uint16(special[6:8])
 		if ginFlags == 0 || (ginFlags&0xFF00 == 0 && ginFlags&0x00FF != 0) {
+			why("special[6:8] = 0x%04X is not BRIN/SP-GiST but valid GIN flags -> gin", ginFlags)
 			return PageTypeGIN
 		}
+		why("special[6:8] = 0x%04X matches no 8-byte special layout", ginFlags)
 	}
 
 	// 16-byte special: could be B-tree, Hash, or GiST
@@ -419,26 +820,31 @@ func (p *Page) detectPageType() PageType {
 		// Hash: hasho_page_id at offset 14
 		hashID := le.Uint16(special[14:16])
 		if hashID == HashPageID {
+			why("special[14:16] = 0x%04X is HASHO_PAGE_ID -> hash", hashID)
 			return PageTypeHash
 		}
 		// GiST: gist_page_id at offset 14
 		gistID := le.Uint16(special[14:16])
 		if gistID == GistPageID {
+			why("special[14:16] = 0x%04X is GIST_PAGE_ID -> gist", gistID)
 			return PageTypeGiST
 		}
 		// B-tree: btpo_flags at offset 12, valid bits 0-8
 		btFlags := le.Uint16(special[12:14])
 		if btFlags&0xFE00 == 0 {
+			why("special[14:16] = 0x%04X is no page id; btpo_flags 0x%04X valid -> btree", gistID, btFlags)
 			return PageTypeBTree
 		}
+		why("special[12:14] = 0x%04X has bits outside btpo_flags", btFlags)
 	}
 
+	why("no known layout for a %d-byte special space -> unknown", specialSize)
 	return PageTypeUnknown
 }
 
 func (p *Page) SpecialSize() int {
 	pageSize := int(p.Header.PageSz())
-	if pageSize == 0 {
+	if pageSize == 0 || pageSize > PageSize {
 		pageSize = PageSize
 	}
 	return pageSize - int(p.Header.Special)
@@ -446,7 +852,7 @@ func (p *Page) SpecialSize() int {
 
 func (p *Page) SpecialData() []byte {
 	pageSize := int(p.Header.PageSz())
-	if pageSize == 0 {
+	if pageSize == 0 || pageSize > PageSize {
 		pageSize = PageSize
 	}
 	if int(p.Header.Special) >= pageSize {
@@ -455,6 +861,38 @@ func (p *Page) SpecialData() []byte {
 	return p.Data[p.Header.Special:pageSize]
 }
 
+// BTreeOpaque decodes BTPageOpaqueData from the special region. The
+// second return value is false if the page has no usable btree special.
+func (p *Page) BTreeOpaque() (BTPageOpaque, bool) {
+	special := p.SpecialData()
+	if p.Detected != PageTypeBTree || len(special) < BTreeOpaqueSize {
+		return BTPageOpaque{}, false
+	}
+	le := binary.LittleEndian
+	return BTPageOpaque{
+		Prev:    le.Uint32(special[0:4]),
+		Next:    le.Uint32(special[4:8]),
+		Level:   le.Uint32(special[8:12]),
+		Flags:   le.Uint16(special[12:14]),
+		CycleID: le.Uint16(special[14:16]),
+	}, true
+}
+
+// GiSTOpaque decodes GISTPageOpaqueData from the special region. The
+// second return value is false if the page has no usable GiST special.
+func (p *Page) GiSTOpaque() (GiSTPageOpaque, bool) {
+	special := p.SpecialData()
+	if p.Detected != PageTypeGiST || len(special) < GistOpaqueSize {
+		return GiSTPageOpaque{}, false
+	}
+	le := binary.LittleEndian
+	return GiSTPageOpaque{
+		NSN:       uint64(le.Uint32(special[0:4]))<<32 | uint64(le.Uint32(special[4:8])),
+		Rightlink: le.Uint32(special[8:12]),
+		Flags:     le.Uint16(special[12:14]),
+	}, true
+}
+
 func (p *Page) ParseHeapTupleHeader(offset uint16) HeapTupleHeader {
 	d := p.Data[offset:]
 	le := binary.LittleEndian
@@ -484,35 +922,72 @@ func (p *Page) ParseIndexTupleHeader(offset uint16) IndexTupleHeader {
 	return it
 }
 
-func ReadPage(filename string, pageNum int) (*Page, error) {
+// ReadPage reads and parses one page, applying the file's inferred type to
+// pages that cannot be identified confidently on their own.
+func ReadPage(filename string, pageNum int64) (*Page, error) {
+	p, err := readPageRaw(filename, pageNum)
+	if err != nil {
+		return nil, err
+	}
+	p.applyFileType(inferredFileType(filename))
+	p.ClusterChecksums = clusterChecksums(filename)
+	if pr := checksumProblem(p, absBlockNumber(filename, pageNum)); pr != "" {
+		p.annotate(AnnotationChecksum, 0, "%s", pr)
+	}
+	return p, nil
+}
+
+func readPageRaw(filename string, pageNum int64) (*Page, error) {
+	data, reads, unstable, ok, err := takePrefetched(filename, pageNum)
+	if !ok {
+		data, reads, unstable, err = readPageLive(filename, pageNum)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := decryptPageData(filename, pageNum, &data); err != nil {
+		return nil, err
+	}
+
+	p := ParsePage(data)
+	p.PageNum = pageNum
+	p.ReadAttempts, p.Unstable = reads, unstable
+	return p, nil
+}
+
+func readPageData(filename string, pageNum int64) ([PageSize]byte, error) {
+	if directIO {
+		return readPageDirect(filename, pageNum)
+	}
+	var data [PageSize]byte
 	f, err := os.Open(filename)
 	if err != nil {
-		return nil, fmt.Errorf("open: %w", err)
+		return data, fmt.Errorf("open: %w", err)
 	}
 	defer f.Close()
 
-	offset := int64(pageNum) * PageSize
+	offset, err := pageFileOffset(filename, pageNum)
+	if err != nil {
+		return data, err
+	}
 	if _, err = f.Seek(offset, io.SeekStart); err != nil {
-		return nil, fmt.Errorf("seek to page %d: %w", pageNum, err)
+		return data, fmt.Errorf("seek to page %d: %w", pageNum, err)
 	}
 
-	var data [PageSize]byte
 	n, err := io.ReadFull(f, data[:])
 	if err != nil {
-		return nil, fmt.Errorf("read page %d (got %d bytes): %w", pageNum, n, err)
+		return data, fmt.Errorf("read page %d (got %d bytes): %w", pageNum, n, err)
 	}
-
-	p := ParsePage(data)
-	p.PageNum = pageNum
-	return p, nil
+	adviseRead(filename, pageNum)
+	return data, nil
 }
 
-func FilePageCount(filename string) (int, error) {
-	fi, err := os.Stat(filename)
+func FilePageCount(filename string) (int64, error) {
+	size, err := fileSize(filename)
 	if err != nil {
 		return 0, err
 	}
-	return int(fi.Size() / PageSize), nil
+	return size / PageSize, nil
 }
 
 func FlagsString(flags uint16) string {
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// CmdSetPageFlags sets (or, with set false, clears) the pd_flags bits named
+// in arg on page pageNum, to reproduce or repair PD_ALL_VISIBLE and
+// visibility map disagreements. Without --allow-writes it only shows the
+// change. It returns whether the page was written.
+func CmdSetPageFlags(filename string, pageNum int64, p *Page, set bool, arg string) bool {
+	bits, negated, err := parseFlagSpec(arg)
+	if err == nil && negated != 0 {
+		err = fmt.Errorf("'!' is not allowed here")
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false
+	}
+	if isNewPage(p) {
+		fmt.Printf("Error: page %d is not initialized\n", pageNum)
+		return false
+	}
+	flags := p.RawHeader.Flags
+	if set {
+		flags |= bits
+	} else {
+		flags &^= bits
+	}
+
+	fmt.Println()
+	fmt.Printf("=== Page Flags (page %d) ===\n", pageNum)
+	if flags == p.RawHeader.Flags {
+		fmt.Printf("  pd_flags           : 0x%04X [%s] (unchanged)\n", flags, FlagsString(flags))
+		fmt.Println()
+		return false
+	}
+	fmt.Printf("  pd_flags           : 0x%04X [%s] -> 0x%04X [%s]\n",
+		p.RawHeader.Flags, FlagsString(p.RawHeader.Flags), flags, FlagsString(flags))
+	if vm := findVisibilityMap(filename); vm != nil && bits&PDAllVisible != 0 && p.Detected == PageTypeHeap {
+		v, f := vm.Status(absBlockNumber(filename, pageNum))
+		fmt.Printf("  Visibility map     : all_visible %t, all_frozen %t\n", v, f)
+	}
+	data := p.Data
+	binary.LittleEndian.PutUint16(data[10:12], flags)
+	return commitPage(filename, pageNum, p, data)
+}
+
+// pdFlagUsage is the argument list of setflag and clearflag.
+var pdFlagUsage = strings.Join([]string{"ALL_VISIBLE", "PAGE_FULL", "HAS_FREE_LINES"}, "|") + "[,...]"
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---- pg_control (src/include/catalog/pg_control.h) ----
+
+// Offsets into ControlFileData, stable for 12-17. CheckPoint gained
+// wal_level in 17 but it fills what was padding after fullPageWrites.
+const (
+	PGControlFile = "pg_control"
+
+	ctlVersionOff       = 8
+	ctlCatalogOff       = 12
+	ctlStateOff         = 16
+	ctlTimeOff          = 24
+	ctlCheckPointOff    = 32
+	ctlRedoOff          = 40 // checkPointCopy.redo
+	ctlTLIOff           = 48 // checkPointCopy.ThisTimeLineID
+	ctlNextXidOff       = 64 // checkPointCopy.nextXid (FullTransactionId)
+	ctlNextOidOff       = 72
+	ctlMinRecoveryOff   = 136
+	ctlWalLevelOff      = 172
+	ctlWalLogHintsOff   = 176
+	ctlBlckszOff        = 216
+	ctlDataChecksumOff  = 252
+	ctlCRCOff           = 288
+	ctlSize             = ctlCRCOff + 4
+	pgControlVersion12  = 1201
+	pgControlVersion13  = 1300
+	pgControlVersion17  = 1700
+	DBStateShutdowned   = 1
+	DBStateShutdownedIR = 2
+)
+
+// ControlFile holds the ControlFileData fields pgpageshell uses.
+type ControlFile struct {
+	SystemID            uint64
+	Version             uint32
+	CatalogVersion      uint32
+	State               uint32
+	Time                int64
+	CheckPoint          uint64
+	Redo                uint64
+	TimeLineID          uint32
+	NextXid             uint64
+	NextOid             uint32
+	MinRecoveryPoint    uint64
+	WalLevel            uint32
+	WalLogHints         bool
+	Blcksz              uint32
+	DataChecksumVersion uint32
+	CRC                 uint32
+	ComputedCRC         uint32
+}
+
+// ParsePGControl decodes a pg_control file.
+func ParsePGControl(data []byte) (ControlFile, error) {
+	le := binary.LittleEndian
+	if len(data) < ctlSize {
+		return ControlFile{}, fmt.Errorf("file too short for ControlFileData (%d bytes)", len(data))
+	}
+	c := ControlFile{
+		SystemID:            le.Uint64(data[0:]),
+		Version:             le.Uint32(data[ctlVersionOff:]),
+		CatalogVersion:      le.Uint32(data[ctlCatalogOff:]),
+		State:               le.Uint32(data[ctlStateOff:]),
+		Time:                int64(le.Uint64(data[ctlTimeOff:])),
+		CheckPoint:          le.Uint64(data[ctlCheckPointOff:]),
+		Redo:                le.Uint64(data[ctlRedoOff:]),
+		TimeLineID:          le.Uint32(data[ctlTLIOff:]),
+		NextXid:             le.Uint64(data[ctlNextXidOff:]),
+		NextOid:             le.Uint32(data[ctlNextOidOff:]),
+		MinRecoveryPoint:    le.Uint64(data[ctlMinRecoveryOff:]),
+		WalLevel:            le.Uint32(data[ctlWalLevelOff:]),
+		WalLogHints:         data[ctlWalLogHintsOff] != 0,
+		Blcksz:              le.Uint32(data[ctlBlckszOff:]),
+		DataChecksumVersion: le.Uint32(data[ctlDataChecksumOff:]),
+		CRC:                 le.Uint32(data[ctlCRCOff:]),
+		ComputedCRC:         crc32.Checksum(data[:ctlCRCOff], crc32cTable),
+	}
+	switch c.Version {
+	case pgControlVersion12, pgControlVersion13, pgControlVersion17:
+	default:
+		return c, fmt.Errorf("unsupported pg_control_version %d", c.Version)
+	}
+	return c, nil
+}
+
+// LoadPGControl reads and decodes a pg_control file, failing on a CRC
+// mismatch since the offsets could then not be trusted either.
+func LoadPGControl(filename string) (ControlFile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return ControlFile{}, err
+	}
+	c, err := ParsePGControl(data)
+	if err != nil {
+		return c, err
+	}
+	if c.CRC != c.ComputedCRC {
+		return c, fmt.Errorf("pg_control CRC mismatch (stored 0x%08X, computed 0x%08X)", c.CRC, c.ComputedCRC)
+	}
+	return c, nil
+}
+
+// isPGControl reports whether the file is named like the control file.
+func isPGControl(filename string) bool { return filepath.Base(filename) == PGControlFile }
+
+// findPGControl looks for global/pg_control in the data directory that a
+// relation file (base/<db>/<relfilenode>, global/<relfilenode> or a
+// tablespace path) lives in. It returns "" if there is none.
+func findPGControl(filename string) string {
+	if pgdataDir != "" {
+		p := filepath.Join(pgdataDir, "global", PGControlFile)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return ""
+	}
+	for i := 0; i < 6; i++ {
+		p := filepath.Join(dir, "global", PGControlFile)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return ""
+}
+
+// parseLSN parses an LSN written as %X/%X.
+func parseLSN(s string) (uint64, error) {
+	hi, lo, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid LSN %q (expected X/X)", s)
+	}
+	h, err1 := strconv.ParseUint(hi, 16, 32)
+	l, err2 := strconv.ParseUint(lo, 16, 32)
+	if err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("invalid LSN %q (expected X/X)", s)
+	}
+	return h<<32 | l, nil
+}
+
+func dbStateStr(s uint32) string {
+	names := []string{"starting up", "shut down", "shut down in recovery", "shutting down",
+		"in crash recovery", "in archive recovery", "in production"}
+	if int(s) < len(names) {
+		return names[s]
+	}
+	return fmt.Sprintf("unknown (%d)", s)
+}
+
+func walLevelStr(l uint32) string {
+	switch l {
+	case 0:
+		return "minimal"
+	case 1:
+		return "replica"
+	case 2:
+		return "logical"
+	}
+	return fmt.Sprintf("unknown (%d)", l)
+}
+
+// CmdPGControl prints a pg_control file.
+func CmdPGControl(filename string) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	c, err := ParsePGControl(data)
+
+	fmt.Println()
+	fmt.Println("=== Control File (ControlFileData) ===")
+	if err != nil {
+		fmt.Printf("  ERROR: %v\n", err)
+		fmt.Println()
+		return
+	}
+	fmt.Printf("  crc                  : 0x%08X", c.CRC)
+	if c.CRC == c.ComputedCRC {
+		fmt.Print(" (valid)")
+	} else {
+		fmt.Printf(" (MISMATCH! computed 0x%08X)", c.ComputedCRC)
+	}
+	fmt.Println()
+	fmt.Printf("  system_identifier    : %d\n", c.SystemID)
+	fmt.Printf("  pg_control_version   : %d\n", c.Version)
+	fmt.Printf("  catalog_version_no   : %d\n", c.CatalogVersion)
+	fmt.Printf("  state                : %s\n", dbStateStr(c.State))
+	fmt.Printf("  time                 : %s\n", time.Unix(c.Time, 0).UTC().Format(time.RFC3339))
+	fmt.Printf("  checkPoint           : %s\n", lsnStr(c.CheckPoint))
+	fmt.Printf("  checkPointCopy.redo  : %s (timeline %d)\n", lsnStr(c.Redo), c.TimeLineID)
+	fmt.Printf("  nextXid              : %d:%d\n", c.NextXid>>32, uint32(c.NextXid))
+	fmt.Printf("  nextOid              : %d\n", c.NextOid)
+	fmt.Printf("  minRecoveryPoint     : %s\n", lsnStr(c.MinRecoveryPoint))
+	fmt.Printf("  wal_level            : %s\n", walLevelStr(c.WalLevel))
+	fmt.Printf("  wal_log_hints        : %t\n", c.WalLogHints)
+	fmt.Printf("  blcksz               : %d\n", c.Blcksz)
+	fmt.Printf("  data_checksum_version: %d\n", c.DataChecksumVersion)
+	fmt.Println()
+}
+
+// CmdFutureLSN flags pages whose pd_lsn lies beyond limit: the insert LSN
+// if one was given, otherwise the cluster's last checkpoint. Past a clean
+// shutdown nothing can be newer than the checkpoint, so such pages come
+// from another cluster or a later backup.
+func CmdFutureLSN(filename string, totalPages int64, sr scanRange, ctl ControlFile, insertLSN uint64) {
+	limit, what := ctl.CheckPoint, "last checkpoint"
+	if insertLSN != 0 {
+		limit, what = insertLSN, "insert LSN"
+	}
+	fmt.Println()
+	fmt.Printf("=== Future LSN Check (%s %s, cluster %s) ===\n", what, lsnStr(limit), dbStateStr(ctl.State))
+	if insertLSN == 0 && ctl.State != DBStateShutdowned && ctl.State != DBStateShutdownedIR {
+		fmt.Println("  Note: the cluster was not shut down cleanly; pages changed after the last")
+		fmt.Println("  checkpoint are expected. Pass the current insert LSN for a strict check.")
+	}
+
+	future := 0
+	var maxLSN uint64
+	first, end := sr.bounds(totalPages)
+	for i := first; i < end; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil || isNewPage(pg) || sr.skips(pg) {
+			continue
+		}
+		lsn := pg.Header.LSN
+		if lsn > maxLSN {
+			maxLSN = lsn
+		}
+		if lsn > limit {
+			future++
+			fmt.Printf("  page %d: pd_lsn %s is beyond the %s\n", i, lsnStr(lsn), what)
+		}
+	}
+
+	if future == 0 {
+		fmt.Printf("  No page LSN beyond the %s.\n", what)
+	}
+	fmt.Println()
+	fmt.Printf("  Highest page LSN   : %s\n", lsnStr(maxLSN))
+	fmt.Printf("  Future pages       : %d\n", future)
+	fmt.Println()
+}
+
+// checksumMode is a cluster's data checksum setting as far as known.
+type checksumMode int
+
+const (
+	checksumsUnknown checksumMode = iota
+	checksumsDisabled
+	checksumsEnabled
+)
+
+var (
+	clusterChecksumsMu sync.Mutex
+	clusterChecksumsBy = map[string]checksumMode{}
+)
+
+// clusterChecksums returns the data checksum setting recorded in the
+// pg_control of the data directory containing filename, read once per file.
+func clusterChecksums(filename string) checksumMode {
+	clusterChecksumsMu.Lock()
+	defer clusterChecksumsMu.Unlock()
+	if m, ok := clusterChecksumsBy[filename]; ok {
+		return m
+	}
+	m := checksumsUnknown
+	if ctlFile := findPGControl(filename); ctlFile != "" {
+		if ctl, err := LoadPGControl(ctlFile); err == nil {
+			m = checksumsDisabled
+			if ctl.DataChecksumVersion != 0 {
+				m = checksumsEnabled
+			}
+		}
+	}
+	clusterChecksumsBy[filename] = m
+	return m
+}
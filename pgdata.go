@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// pgdataDir is the data directory given with --pgdata. Relations can then
+// be named <db-oid>/<relfilenode> or <spc-oid>/<db-oid>/<relfilenode>
+// (with an optional _fork and .segment suffix) instead of by path.
+var pgdataDir string
+
+// Tablespace OIDs with a directory of their own in the data directory.
+const (
+	DefaultTablespaceOid = 1663 // base/
+	GlobalTablespaceOid  = 1664 // global/
+)
+
+// tablespace is a user tablespace's pg_tblspc entry, resolved.
+type tablespace struct {
+	Oid  uint32
+	Link string // pg_tblspc/<oid>
+	Dir  string // the PG_<major>_<catversion> directory behind the link
+}
+
+// tablespaces lists the tablespaces of a data directory, following the
+// pg_tblspc symlinks (in-place tablespaces are plain directories). Of the
+// version directories behind a link, the one matching the cluster's
+// catalog version is used, since pg_upgrade leaves the old one behind.
+func tablespaces(pgdata string) ([]tablespace, error) {
+	entries, err := os.ReadDir(filepath.Join(pgdata, "pg_tblspc"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	catSuffix := ""
+	if ctl, err := LoadPGControl(filepath.Join(pgdata, "global", PGControlFile)); err == nil {
+		catSuffix = fmt.Sprintf("_%d", ctl.CatalogVersion)
+	}
+
+	var spcs []tablespace
+	for _, e := range entries {
+		oid, err := strconv.ParseUint(e.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+		link := filepath.Join(pgdata, "pg_tblspc", e.Name())
+		target, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			return nil, fmt.Errorf("tablespace %d: %w", oid, err)
+		}
+		versions, err := os.ReadDir(target)
+		if err != nil {
+			return nil, fmt.Errorf("tablespace %d: %w", oid, err)
+		}
+		dir := ""
+		for _, v := range versions {
+			if !v.IsDir() || !strings.HasPrefix(v.Name(), "PG_") {
+				continue
+			}
+			if dir == "" || (catSuffix != "" && strings.HasSuffix(v.Name(), catSuffix)) {
+				dir = filepath.Join(target, v.Name())
+			}
+		}
+		if dir != "" {
+			spcs = append(spcs, tablespace{Oid: uint32(oid), Link: link, Dir: dir})
+		}
+	}
+	return spcs, nil
+}
+
+// tablespaceDatabaseDir returns the directory holding database db's
+// relations in tablespace spc.
+func tablespaceDatabaseDir(pgdata string, spcs []tablespace, spc uint32, db string) (string, error) {
+	switch spc {
+	case DefaultTablespaceOid:
+		return filepath.Join(pgdata, "base", db), nil
+	case GlobalTablespaceOid:
+		return filepath.Join(pgdata, "global"), nil
+	}
+	for _, t := range spcs {
+		if t.Oid == spc {
+			return filepath.Join(t.Dir, db), nil
+		}
+	}
+	return "", fmt.Errorf("no tablespace %d in %s/pg_tblspc", spc, pgdata)
+}
+
+// optionTakesValue reports whether a subcommand option consumes the next
+// argument, which then is not a file operand.
+func optionTakesValue(arg string) bool {
+	switch arg {
+	case "--sample", "--block", "--listen", "--interval":
+		return true
+	}
+	return isScanRangeOption(arg)
+}
+
+// resolveRelationArgs applies resolveRelation to the file operands of a
+// command line, leaving options and their values as typed.
+func resolveRelationArgs(pgdata string, args []string) error {
+	for i := 0; i < len(args); i++ {
+		if strings.HasPrefix(args[i], "--") {
+			if optionTakesValue(args[i]) {
+				i++
+			}
+			continue
+		}
+		p, err := resolveRelation(pgdata, args[i])
+		if err != nil {
+			return err
+		}
+		args[i] = p
+	}
+	return nil
+}
+
+// resolveRelation finds the file of a relation named relative to the data
+// directory: a path such as base/5/16400, <db>/<relfilenode> looked up in
+// base/ and then in every tablespace, or <spc>/<db>/<relfilenode>.
+// Arguments that are existing paths or not shaped like a relation name are
+// returned unchanged.
+func resolveRelation(pgdata, arg string) (string, error) {
+	if pgdata == "" {
+		return arg, nil
+	}
+	if _, err := os.Stat(arg); err == nil {
+		return arg, nil
+	}
+	if p := filepath.Join(pgdata, arg); !filepath.IsAbs(arg) {
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+
+	parts := strings.Split(arg, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return arg, nil
+	}
+	for _, p := range parts[:len(parts)-1] {
+		if _, err := strconv.ParseUint(p, 10, 32); err != nil {
+			return arg, nil
+		}
+	}
+	if _, _, _, ok := parseRelFileName(parts[len(parts)-1]); !ok {
+		return arg, nil
+	}
+
+	spcs, err := tablespaces(pgdata)
+	if err != nil {
+		return "", err
+	}
+	var candidates []uint32
+	if len(parts) == 3 {
+		spc, _ := strconv.ParseUint(parts[0], 10, 32)
+		candidates = []uint32{uint32(spc)}
+		parts = parts[1:]
+	} else {
+		candidates = []uint32{DefaultTablespaceOid}
+		for _, t := range spcs {
+			candidates = append(candidates, t.Oid)
+		}
+	}
+	for _, spc := range candidates {
+		dir, err := tablespaceDatabaseDir(pgdata, spcs, spc, parts[0])
+		if err != nil {
+			return "", err
+		}
+		p := filepath.Join(dir, parts[1])
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("relation %s not found in %s or its tablespaces", arg, pgdata)
+}
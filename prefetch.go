@@ -0,0 +1,80 @@
+package main
+
+import (
+	"slices"
+	"sync"
+)
+
+// prefetchLimit bounds how many pages may sit read ahead at once.
+const prefetchLimit = 16
+
+type pageKey struct {
+	filename string
+	pageNum  int64
+}
+
+// prefetchedPage is a read started in the background; done is closed when
+// the read result fields are set.
+type prefetchedPage struct {
+	done     chan struct{}
+	data     [PageSize]byte
+	reads    int
+	unstable bool
+	err      error
+}
+
+// The readahead buffer holds pages read ahead of navigation. A page is
+// handed out once and then dropped, so what is served is never older than
+// the navigation step that asked for it, even on a live cluster.
+var (
+	prefetchMu    sync.Mutex
+	prefetchPages = map[pageKey]*prefetchedPage{}
+)
+
+// prefetch starts background reads of the given pages of filename that are
+// in range and not already pending. Pages read ahead for an earlier step but
+// not asked for again are dropped.
+func prefetch(filename string, totalPages int64, pages ...int64) {
+	prefetchMu.Lock()
+	defer prefetchMu.Unlock()
+	for k := range prefetchPages {
+		if k.filename == filename && !slices.Contains(pages, k.pageNum) {
+			delete(prefetchPages, k)
+		}
+	}
+	for _, n := range pages {
+		k := pageKey{filename, n}
+		if n < 0 || n >= totalPages || prefetchPages[k] != nil || len(prefetchPages) >= prefetchLimit {
+			continue
+		}
+		pp := &prefetchedPage{done: make(chan struct{})}
+		prefetchPages[k] = pp
+		go func() {
+			pp.data, pp.reads, pp.unstable, pp.err = readPageLive(filename, n)
+			close(pp.done)
+		}()
+	}
+}
+
+// takePrefetched returns a read-ahead copy of the page, waiting for it if
+// the read is still in flight. ok is false if the page was not prefetched.
+func takePrefetched(filename string, pageNum int64) (data [PageSize]byte, reads int, unstable, ok bool, err error) {
+	k := pageKey{filename, pageNum}
+	prefetchMu.Lock()
+	pp := prefetchPages[k]
+	delete(prefetchPages, k)
+	prefetchMu.Unlock()
+	if pp == nil {
+		return data, 0, false, false, nil
+	}
+	<-pp.done
+	return pp.data, pp.reads, pp.unstable, true, pp.err
+}
+
+// dropPrefetched forgets any read-ahead copy of the page, so that one
+// read before a write to it is not served afterwards.
+func dropPrefetched(filename string, pageNum int64) {
+	prefetchMu.Lock()
+	delete(prefetchPages, pageKey{filename, pageNum})
+	prefetchMu.Unlock()
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The select command runs a small query over per-page metadata:
+//
+//	select <col>[, ...] | * [where <cond>] [order by <col> [asc|desc]] [limit <n>]
+//
+// Conditions compare a column with a literal or another column (=, !=, <>,
+// <, <=, >, >=) and combine with and, or, not and parentheses. Strings are
+// single-quoted; an LSN is written as a string ('0/16B3748').
+
+// queryValue is a column value or literal: a number or a string.
+type queryValue struct {
+	n   int64
+	s   string
+	str bool
+}
+
+// queryColumn is a per-page value select can show, filter and sort on.
+type queryColumn struct {
+	name   string
+	width  int
+	str    bool
+	get    func(i int64, m pageMeta) queryValue
+	format func(v queryValue) string // display form; nil prints the value
+}
+
+func queryNum(n int64) queryValue { return queryValue{n: n} }
+
+func queryFlag(m pageMeta, bit uint16) queryValue {
+	if m.Flags&bit != 0 {
+		return queryNum(1)
+	}
+	return queryNum(0)
+}
+
+var queryColumns = []queryColumn{
+	{name: "page", width: 8, get: func(i int64, m pageMeta) queryValue { return queryNum(i) }},
+	{name: "type", width: 8, str: true, get: func(i int64, m pageMeta) queryValue {
+		return queryValue{s: m.TypeLabel(), str: true}
+	}},
+	{name: "items", width: 6, get: func(i int64, m pageMeta) queryValue { return queryNum(int64(m.NumItems())) }},
+	{name: "normal", width: 6, get: func(i int64, m pageMeta) queryValue { return queryNum(int64(m.Normal)) }},
+	{name: "dead", width: 6, get: func(i int64, m pageMeta) queryValue { return queryNum(int64(m.Dead)) }},
+	{name: "unused", width: 6, get: func(i int64, m pageMeta) queryValue { return queryNum(int64(m.Unused)) }},
+	{name: "redirect", width: 8, get: func(i int64, m pageMeta) queryValue { return queryNum(int64(m.Redirect)) }},
+	{name: "free", width: 6, get: func(i int64, m pageMeta) queryValue { return queryNum(int64(m.FreeSpace())) }},
+	{name: "special", width: 7, get: func(i int64, m pageMeta) queryValue { return queryNum(int64(m.SpecialSize)) }},
+	{name: "lsn", width: 17, get: func(i int64, m pageMeta) queryValue { return queryNum(int64(m.LSN)) },
+		format: func(v queryValue) string { return lsnStr(uint64(v.n)) }},
+	{name: "all_visible", width: 11, get: func(i int64, m pageMeta) queryValue { return queryFlag(m, PDAllVisible) }},
+	{name: "page_full", width: 9, get: func(i int64, m pageMeta) queryValue { return queryFlag(m, PDPageFull) }},
+	{name: "has_free_lines", width: 14, get: func(i int64, m pageMeta) queryValue { return queryFlag(m, PDHasFreeLines) }},
+	{name: "anomalies", width: 9, get: func(i int64, m pageMeta) queryValue { return queryNum(int64(m.Anomalies)) }},
+}
+
+func queryColumnNames() string {
+	names := make([]string, len(queryColumns))
+	for i, c := range queryColumns {
+		names[i] = c.name
+	}
+	return strings.Join(names, ", ")
+}
+
+func lookupQueryColumn(name string) *queryColumn {
+	for i := range queryColumns {
+		if queryColumns[i].name == name {
+			return &queryColumns[i]
+		}
+	}
+	return nil
+}
+
+func (c *queryColumn) display(v queryValue) string {
+	switch {
+	case c.format != nil:
+		return c.format(v)
+	case v.str:
+		return v.s
+	}
+	return strconv.FormatInt(v.n, 10)
+}
+
+// queryExpr is a parsed where condition.
+type queryExpr interface {
+	eval(i int64, m pageMeta) bool
+}
+
+type queryAnd struct{ l, r queryExpr }
+type queryOr struct{ l, r queryExpr }
+type queryNot struct{ e queryExpr }
+
+func (e queryAnd) eval(i int64, m pageMeta) bool { return e.l.eval(i, m) && e.r.eval(i, m) }
+func (e queryOr) eval(i int64, m pageMeta) bool  { return e.l.eval(i, m) || e.r.eval(i, m) }
+func (e queryNot) eval(i int64, m pageMeta) bool { return !e.e.eval(i, m) }
+
+// queryOperand is one side of a comparison: a column or a literal.
+type queryOperand struct {
+	col *queryColumn
+	lit queryValue
+}
+
+func (o queryOperand) value(i int64, m pageMeta) queryValue {
+	if o.col != nil {
+		return o.col.get(i, m)
+	}
+	return o.lit
+}
+
+type queryCmp struct {
+	l, r queryOperand
+	op   string
+}
+
+func (e queryCmp) eval(i int64, m pageMeta) bool {
+	a, b := e.l.value(i, m), e.r.value(i, m)
+	c := 0
+	if a.str {
+		c = strings.Compare(strings.ToLower(a.s), strings.ToLower(b.s))
+	} else if a.n < b.n {
+		c = -1
+	} else if a.n > b.n {
+		c = 1
+	}
+	return compareHolds(e.op, c)
+}
+
+// compareHolds reports whether a comparison with operator op holds for
+// operands that compare as c (-1, 0 or 1).
+func compareHolds(op string, c int) bool {
+	switch op {
+	case "=", "==":
+		return c == 0
+	case "!=", "<>":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+func isCompareOp(s string) bool {
+	switch s {
+	case "=", "==", "!=", "<>", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+// pageQuery is a parsed select command.
+type pageQuery struct {
+	cols  []*queryColumn
+	where queryExpr
+	order *queryColumn
+	desc  bool
+	limit int
+}
+
+// queryToken is a lexical token; kind is 'i' (identifier or keyword,
+// lowercased), 'n' (number), 's' (string) or 'o' (operator/punctuation).
+type queryToken struct {
+	kind byte
+	text string
+}
+
+func tokenizeQuery(s string) ([]queryToken, error) {
+	var toks []queryToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			toks = append(toks, queryToken{'s', s[i+1 : i+1+end]})
+			i += end + 2
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			j := i + 1
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			toks = append(toks, queryToken{'n', s[i:j]})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || s[j] >= 'a' && s[j] <= 'z' || s[j] >= 'A' && s[j] <= 'Z' || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			toks = append(toks, queryToken{'i', strings.ToLower(s[i:j])})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"<=", ">=", "<>", "!=", "==", "=", "<", ">", "(", ")", ",", "*"} {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q", c)
+			}
+			toks = append(toks, queryToken{'o', op})
+			i += len(op)
+		}
+	}
+	return toks, nil
+}
+
+// queryParser is a recursive descent parser over the tokens of a query.
+type queryParser struct {
+	toks []queryToken
+	pos  int
+}
+
+func (p *queryParser) peek() queryToken {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return queryToken{}
+}
+
+// accept consumes the next token if it is the keyword or operator text.
+func (p *queryParser) accept(text string) bool {
+	if t := p.peek(); (t.kind == 'i' || t.kind == 'o') && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expect(text string) error {
+	if !p.accept(text) {
+		return fmt.Errorf("expected %q at %s", text, p.where())
+	}
+	return nil
+}
+
+// where describes the parse position for error messages.
+func (p *queryParser) where() string {
+	if p.pos >= len(p.toks) {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q", p.toks[p.pos].text)
+}
+
+func (p *queryParser) column() (*queryColumn, error) {
+	t := p.peek()
+	if t.kind != 'i' {
+		return nil, fmt.Errorf("expected a column at %s", p.where())
+	}
+	c := lookupQueryColumn(t.text)
+	if c == nil {
+		return nil, fmt.Errorf("unknown column %q (%s)", t.text, queryColumnNames())
+	}
+	p.pos++
+	return c, nil
+}
+
+// parseQuery parses the text after "select".
+func parseQuery(s string) (*pageQuery, error) {
+	toks, err := tokenizeQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{toks: toks}
+	q := &pageQuery{}
+
+	if p.accept("*") {
+		for i := range queryColumns {
+			q.cols = append(q.cols, &queryColumns[i])
+		}
+	} else {
+		for {
+			c, err := p.column()
+			if err != nil {
+				return nil, err
+			}
+			q.cols = append(q.cols, c)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if p.accept("where") {
+		if q.where, err = p.or(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("order") {
+		if err := p.expect("by"); err != nil {
+			return nil, err
+		}
+		if q.order, err = p.column(); err != nil {
+			return nil, err
+		}
+		if p.accept("desc") {
+			q.desc = true
+		} else {
+			p.accept("asc")
+		}
+	}
+	if p.accept("limit") {
+		t := p.peek()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != 'n' || err != nil || n < 1 {
+			return nil, fmt.Errorf("expected a positive limit at %s", p.where())
+		}
+		p.pos++
+		q.limit = n
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %s", p.where())
+	}
+	return q, nil
+}
+
+func (p *queryParser) or() (queryExpr, error) {
+	l, err := p.and()
+	for err == nil && p.accept("or") {
+		var r queryExpr
+		if r, err = p.and(); err == nil {
+			l = queryOr{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *queryParser) and() (queryExpr, error) {
+	l, err := p.not()
+	for err == nil && p.accept("and") {
+		var r queryExpr
+		if r, err = p.not(); err == nil {
+			l = queryAnd{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *queryParser) not() (queryExpr, error) {
+	if p.accept("not") {
+		e, err := p.not()
+		return queryNot{e}, err
+	}
+	if p.accept("(") {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	}
+	return p.comparison()
+}
+
+func (p *queryParser) operand() (queryOperand, error) {
+	t := p.peek()
+	switch t.kind {
+	case 'n':
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return queryOperand{}, fmt.Errorf("bad number %q", t.text)
+		}
+		p.pos++
+		return queryOperand{lit: queryNum(n)}, nil
+	case 's':
+		p.pos++
+		return queryOperand{lit: queryValue{s: t.text, str: true}}, nil
+	}
+	c, err := p.column()
+	return queryOperand{col: c}, err
+}
+
+func (p *queryParser) comparison() (queryExpr, error) {
+	l, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != 'o' || !isCompareOp(t.text) {
+		return nil, fmt.Errorf("expected a comparison operator at %s", p.where())
+	}
+	p.pos++
+	r, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	if l.col == nil && r.col == nil {
+		return nil, fmt.Errorf("comparison needs a column")
+	}
+	if err := queryCoerce(&l, r); err != nil {
+		return nil, err
+	}
+	if err := queryCoerce(&r, l); err != nil {
+		return nil, err
+	}
+	return queryCmp{l, r, t.text}, nil
+}
+
+// queryCoerce makes a literal compare with the column on the other side:
+// a string compared with lsn is parsed as an LSN, and other mismatches
+// are errors.
+func queryCoerce(lit *queryOperand, other queryOperand) error {
+	if lit.col != nil || other.col == nil || lit.lit.str == other.col.str {
+		return nil
+	}
+	if other.col.name == "lsn" {
+		lsn, err := parseLSN(lit.lit.s)
+		if err != nil {
+			return err
+		}
+		lit.lit = queryNum(int64(lsn))
+		return nil
+	}
+	if other.col.str {
+		return fmt.Errorf("%s is compared with a string, quote the value", other.col.name)
+	}
+	return fmt.Errorf("%s is a number, not a string", other.col.name)
+}
+
+// CmdSelect runs a parsed query over the pages in range. Without order by,
+// rows print as pages are scanned; with it, they are collected (only the
+// first limit of them when a limit is given) and printed sorted.
+func CmdSelect(filename string, totalPages int64, sr scanRange, q *pageQuery) {
+	printCells := func(cell func(c *queryColumn) string) {
+		var b strings.Builder
+		for _, c := range q.cols {
+			fmt.Fprintf(&b, "  %-*s", c.width, cell(c))
+		}
+		fmt.Println(strings.TrimRight(b.String(), " "))
+	}
+	fmt.Println()
+	printCells(func(c *queryColumn) string { return c.name })
+	printCells(func(c *queryColumn) string { return strings.Repeat("-", len(c.name)) })
+
+	type row struct {
+		page int64
+		m    pageMeta
+	}
+	printRow := func(r row) {
+		printCells(func(c *queryColumn) string { return c.display(c.get(r.page, r.m)) })
+	}
+	before := func(a, b row) bool {
+		if q.desc {
+			a, b = b, a
+		}
+		va, vb := q.order.get(a.page, a.m), q.order.get(b.page, b.m)
+		if va.str {
+			return va.s < vb.s
+		}
+		return va.n < vb.n
+	}
+
+	matched, printed, unreadable := 0, 0, 0
+	var rows []row
+	scanMeta(filename, totalPages, sr, func(i int64, m pageMeta, err error) {
+		if err != nil {
+			unreadable++
+			return
+		}
+		if sr.skipsLSN(m.LSN) || (q.where != nil && !q.where.eval(i, m)) {
+			return
+		}
+		matched++
+		switch {
+		case q.order != nil && q.limit > 0:
+			rows = keepTop(rows, row{i, m}, q.limit, before)
+		case q.order != nil:
+			rows = append(rows, row{i, m})
+		case q.limit == 0 || printed < q.limit:
+			printRow(row{i, m})
+			printed++
+		}
+	})
+	if q.order != nil {
+		sort.SliceStable(rows, func(a, b int) bool { return before(rows[a], rows[b]) })
+		for _, r := range rows {
+			printRow(r)
+		}
+		printed = len(rows)
+	}
+
+	fmt.Println()
+	fmt.Printf("  %d of %d matching pages shown", printed, matched)
+	if unreadable > 0 {
+		fmt.Printf(", %d unreadable", unreadable)
+	}
+	fmt.Println()
+	fmt.Println()
+}
+
+// parseQueryCondition parses a where condition on its own, as used by
+// foreach, if and assert.
+func parseQueryCondition(s string) (queryExpr, error) {
+	toks, err := tokenizeQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{toks: toks}
+	e, err := p.or()
+	if err == nil && p.pos < len(p.toks) {
+		err = fmt.Errorf("unexpected %s", p.where())
+	}
+	return e, err
+}
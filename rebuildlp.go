@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// allowWrites permits the commands that modify relation files
+// (--allow-writes); everything else only reads.
+var allowWrites = false
+
+// rebuiltItem is one line pointer of a reconstructed array.
+type rebuiltItem struct {
+	Slot   int // 1-based offset number
+	Offset int
+	Length int
+	Source string
+	Header HeapTupleHeader
+}
+
+// rebuildLinePointers proposes a line pointer array for a heap page from
+// the tuple headers found in its tuple area (see CarveTuples). Offset
+// numbers matter, index entries and t_ctid chains point at them, so each
+// tuple gets, in order of preference:
+//
+//   - the offset number in its own t_ctid, if it was never updated (an
+//     updated tuple's t_ctid points at its new version);
+//   - the slot of a surviving line pointer that references it;
+//   - the lowest free slot, tuples taken from the end of the page down,
+//     the order PostgreSQL fills an empty page in.
+//
+// A tuple's length is that of the surviving line pointer when one exists
+// and fits, otherwise the distance to the next tuple, which may include up
+// to 7 bytes of alignment padding. Slots left over become LP_UNUSED; HOT
+// redirects cannot be recovered.
+func rebuildLinePointers(p *Page, blkno uint32) (items []rebuiltItem, special int) {
+	special = inferHeaderBounds(p).Special
+	var found []CarvedTuple
+	for _, c := range CarveTuples(p) {
+		if c.Offset < PageHeaderSize+ItemIdSize || c.Offset >= special {
+			continue
+		}
+		c.End = min(c.End, special)
+		found = append(found, c)
+	}
+
+	used := map[int]bool{}
+	assigned := make([]rebuiltItem, len(found))
+	for i, c := range found {
+		t := c.Header
+		assigned[i] = rebuiltItem{Offset: c.Offset, Length: c.End - c.Offset, Header: t}
+		if c.Item > 0 {
+			if lp := p.Items[c.Item-1]; int(lp.Length()) >= int(t.Hoff) && int(lp.Length()) <= c.End-c.Offset {
+				assigned[i].Length = int(lp.Length())
+			}
+		}
+		notUpdated := t.Xmax == 0 || t.Infomask&HeapXmaxInvalid != 0
+		if slot := int(t.CtidOffset); t.CtidBlock == blkno && notUpdated && slot <= MaxHeapTuplesPerPage && !used[slot] {
+			assigned[i].Slot, assigned[i].Source = slot, "t_ctid"
+			used[slot] = true
+		}
+	}
+	for i, c := range found {
+		if assigned[i].Slot == 0 && c.Item > 0 && !used[c.Item] {
+			assigned[i].Slot, assigned[i].Source = c.Item, "old line pointer"
+			used[c.Item] = true
+		}
+	}
+	next := 1
+	for i := len(found) - 1; i >= 0; i-- {
+		if assigned[i].Slot != 0 {
+			continue
+		}
+		for used[next] {
+			next++
+		}
+		assigned[i].Slot, assigned[i].Source = next, "page order"
+		used[next] = true
+	}
+	sort.Slice(assigned, func(i, j int) bool { return assigned[i].Slot < assigned[j].Slot })
+	return assigned, special
+}
+
+// rebuiltPage returns p's bytes with the line pointer array replaced by
+// items and pd_lower, pd_upper and pd_special set to match.
+func rebuiltPage(p *Page, items []rebuiltItem, special int) [PageSize]byte {
+	data := p.Data
+	le := binary.LittleEndian
+	slots, upper := 0, special
+	if len(items) > 0 {
+		slots = items[len(items)-1].Slot
+	}
+	lower := PageHeaderSize + slots*ItemIdSize
+	for _, it := range items {
+		upper = min(upper, it.Offset)
+	}
+	// Clear the old array, then fill in the slots that have a tuple.
+	oldEnd := min(max(int(p.RawHeader.Lower), lower), upper)
+	clear(data[PageHeaderSize:oldEnd])
+	for _, it := range items {
+		off := PageHeaderSize + (it.Slot-1)*ItemIdSize
+		le.PutUint32(data[off:], uint32(it.Offset)|uint32(LPNormal)<<15|uint32(it.Length)<<17)
+	}
+	le.PutUint16(data[12:14], uint16(lower))
+	le.PutUint16(data[14:16], uint16(upper))
+	le.PutUint16(data[16:18], uint16(special))
+	return data
+}
+
+// writePage overwrites page pageNum of filename with data, after saving
+// the current contents to a backup file in the user cache directory. The
+// checksum is recomputed if the page had one or the cluster uses them.
+func writePage(filename string, pageNum int64, old *Page, data [PageSize]byte) (backup string, err error) {
+	if old.Header.Checksum != 0 || clusterChecksums(filename) == checksumsEnabled {
+		binary.LittleEndian.PutUint16(data[8:10], PageChecksum(&data, absBlockNumber(filename, pageNum)))
+	}
+	return writePageImage(filename, pageNum, old, data)
+}
+
+// writePageImage is writePage without the checksum update: data is
+// written exactly as given.
+func writePageImage(filename string, pageNum int64, old *Page, data [PageSize]byte) (backup string, err error) {
+	backup, err = saveBackup(fmt.Sprintf("%s.%d", filepath.Base(filename), pageNum), old.Data[:])
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY, 0)
+	if err != nil {
+		return backup, err
+	}
+	defer forgetFileType(filename)
+	defer dropPrefetched(filename, pageNum)
+	if _, err := f.WriteAt(data[:], pageNum*PageSize); err != nil {
+		f.Close()
+		return backup, err
+	}
+	return backup, f.Close()
+}
+
+// commitPage ends a command that changes page pageNum of filename, once the
+// change has been shown: without --allow-writes it only says the page was
+// not written, otherwise it writes data with writePage and reports the
+// backup and the outcome. It returns whether the page was written.
+func commitPage(filename string, pageNum int64, old *Page, data [PageSize]byte) bool {
+	return commitWrite(writePage, filename, pageNum, old, data)
+}
+
+// commitPageImage is commitPage writing data exactly as given, with
+// writePageImage.
+func commitPageImage(filename string, pageNum int64, old *Page, data [PageSize]byte) bool {
+	return commitWrite(writePageImage, filename, pageNum, old, data)
+}
+
+func commitWrite(write func(string, int64, *Page, [PageSize]byte) (string, error), filename string, pageNum int64, old *Page, data [PageSize]byte) bool {
+	if !allowWrites {
+		fmt.Println("  (not written: start with --allow-writes to apply)")
+		fmt.Println()
+		return false
+	}
+	backup, err := write(filename, pageNum, old, data)
+	if backup != "" {
+		fmt.Printf("  Original page      : saved to %s\n", shownPath(backup))
+	}
+	if err != nil {
+		fmt.Printf("  Error: %v\n", err)
+		fmt.Println()
+		return false
+	}
+	fmt.Printf("  Written            : page %d of %s\n", pageNum, shownPath(filename))
+	fmt.Println()
+	return true
+}
+
+// saveBackup stores data as <name>.<time> in the backup directory under the
+// user cache directory and returns its path. An earlier backup taken in the
+// same second is never replaced.
+func saveBackup(name string, data []byte) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "pgpageshell", "backup")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	base := filepath.Join(dir, name+"."+time.Now().Format("20060102T150405"))
+	backup := base
+	for n := 1; ; n++ {
+		f, err := os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if os.IsExist(err) {
+			backup = fmt.Sprintf("%s.%d", base, n)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return backup, err
+	}
+}
+
+// CmdRebuildLP prints the line pointer array proposed for page pageNum
+// next to the current one, and writes it to the file if write is set
+// (--allow-writes). It returns whether the page was written.
+func CmdRebuildLP(filename string, pageNum int64, p *Page, write bool) bool {
+	blkno := absBlockNumber(filename, pageNum)
+	items, special := rebuildLinePointers(p, blkno)
+
+	fmt.Println()
+	fmt.Printf("=== Rebuilt Line Pointers (page %d, %d tuples found) ===\n", pageNum, len(items))
+	if len(items) == 0 {
+		fmt.Println("  (no plausible heap tuple headers found; nothing to rebuild)")
+		fmt.Println()
+		return false
+	}
+	fmt.Printf("  %-6s %-8s %-6s %-6s %-10s %-16s %s\n", "Index", "Status", "Offset", "Length", "Xmin", "Source", "Current")
+	fmt.Printf("  %-6s %-8s %-6s %-6s %-10s %-16s %s\n", "-----", "--------", "------", "------", "----", "------", "-------")
+	slot := 1
+	current := func(n int) string {
+		if n > len(p.Items) {
+			return "-"
+		}
+		lp := p.Items[n-1]
+		return fmt.Sprintf("%s %d/%d", lp.FlagsStr(), lp.Offset(), lp.Length())
+	}
+	for _, it := range items {
+		for ; slot < it.Slot; slot++ {
+			fmt.Printf("  %-6d %-8s %-6s %-6s %-10s %-16s %s\n", slot, "UNUSED", "-", "-", "-", "gap", current(slot))
+		}
+		fmt.Printf("  %-6d %-8s %-6d %-6d %-10d %-16s %s\n", it.Slot, "NORMAL", it.Offset, it.Length, it.Header.Xmin, it.Source, current(it.Slot))
+		slot++
+	}
+
+	data := rebuiltPage(p, items, special)
+	le := binary.LittleEndian
+	fmt.Println()
+	fmt.Printf("  pd_lower           : %d (currently %d)\n", le.Uint16(data[12:14]), p.RawHeader.Lower)
+	fmt.Printf("  pd_upper           : %d (currently %d)\n", le.Uint16(data[14:16]), p.RawHeader.Upper)
+	fmt.Printf("  pd_special         : %d (currently %d)\n", le.Uint16(data[16:18]), p.RawHeader.Special)
+	fmt.Println("  Lengths not taken from a surviving line pointer may include alignment padding.")
+
+	if !write {
+		fmt.Println()
+		return false
+	}
+	return commitPage(filename, pageNum, p, data)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// relationName is the table given with --relation; its files are looked
+// up through --dsn and opened instead of paths typed on the command line.
+var relationName string
+
+// relationFile is one file of a relation opened by name: the table, its
+// indexes, its TOAST table and TOAST index, and any further segments.
+type relationFile struct {
+	Kind string // "table", the index access method, "toast" or "toast <am>"
+	Name string // the relation name as regclass prints it
+	Path string
+}
+
+// Label names the file for the GUI and JSON export, e.g. "orders_pkey (btree)".
+func (f relationFile) Label() string { return fmt.Sprintf("%s (%s)", f.Name, f.Kind) }
+
+// relationFilesQuery lists kind, name and pg_relation_filepath of rel, its
+// indexes, its TOAST table and the TOAST table's indexes, table first.
+// Relations without storage (views, partitioned tables) have a NULL path.
+const relationFilesQuery = `WITH r AS (SELECT %s::regclass::oid AS oid)
+SELECT 'table', c.oid::regclass, pg_relation_filepath(c.oid), 0 FROM pg_class c, r WHERE c.oid = r.oid
+UNION ALL
+SELECT am.amname, i.indexrelid::regclass, pg_relation_filepath(i.indexrelid), 1
+  FROM pg_index i JOIN pg_class ic ON ic.oid = i.indexrelid JOIN pg_am am ON am.oid = ic.relam, r
+ WHERE i.indrelid = r.oid
+UNION ALL
+SELECT 'toast', t.oid::regclass, pg_relation_filepath(t.oid), 2
+  FROM pg_class c JOIN pg_class t ON t.oid = c.reltoastrelid, r WHERE c.oid = r.oid
+UNION ALL
+SELECT 'toast ' || am.amname, i.indexrelid::regclass, pg_relation_filepath(i.indexrelid), 3
+  FROM pg_class c JOIN pg_index i ON i.indrelid = c.reltoastrelid
+  JOIN pg_class ic ON ic.oid = i.indexrelid JOIN pg_am am ON am.oid = ic.relam, r
+ WHERE c.oid = r.oid
+ORDER BY 4, 2`
+
+// sqlLiteral quotes s as an SQL string literal.
+func sqlLiteral(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+
+// resolveRelationFiles asks the server behind dsn where the files of the
+// table name (as written in SQL, e.g. public.orders) are and returns them,
+// table first, with the extra 1 GB segments that exist on disk. Paths are
+// under pgdata, or the server's data_directory when pgdata is empty.
+func resolveRelationFiles(dsn, pgdata, name string) ([]relationFile, error) {
+	if pgdata == "" {
+		row, err := psqlQuery(dsn, "SELECT current_setting('data_directory')")
+		if err != nil {
+			return nil, fmt.Errorf("data_directory: %w (give --pgdata)", err)
+		}
+		pgdata = row[0]
+	}
+	rows, err := psqlRows(dsn, fmt.Sprintf(relationFilesQuery, sqlLiteral(name)))
+	if err != nil {
+		return nil, err
+	}
+	var files []relationFile
+	for _, row := range rows {
+		if len(row) != 4 {
+			return nil, fmt.Errorf("unexpected psql output %q", strings.Join(row, "|"))
+		}
+		kind, rel, relPath := row[0], row[1], row[2]
+		if relPath == "" {
+			if kind == "table" {
+				return nil, fmt.Errorf("%s has no storage (a view or partitioned table?)", rel)
+			}
+			continue
+		}
+		path := filepath.Join(pgdata, relPath)
+		if _, err := os.Stat(path); err != nil {
+			if kind == "table" {
+				return nil, fmt.Errorf("%s: %w (give --pgdata if the data directory is mounted elsewhere)", rel, err)
+			}
+			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", rel, err)
+			continue
+		}
+		files = append(files, relationFile{Kind: kind, Name: rel, Path: path})
+		for seg := 1; ; seg++ {
+			p := path + "." + strconv.Itoa(seg)
+			if _, err := os.Stat(p); err != nil {
+				break
+			}
+			files = append(files, relationFile{Kind: fmt.Sprintf("%s, segment %d", kind, seg), Name: rel, Path: p})
+		}
+	}
+	return files, nil
+}
+
+// printRelationFiles lists the files a --relation resolved to on stderr,
+// so they can be opened on their own later.
+func printRelationFiles(name string, files []relationFile) {
+	fmt.Fprintf(os.Stderr, "Relation %s:\n", name)
+	for _, f := range files {
+		fmt.Fprintf(os.Stderr, "  %-20s %-32s %s\n", f.Kind, f.Name, f.Path)
+	}
+}
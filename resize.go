@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CmdTruncate cuts filename down to npages pages, dropping damaged or
+// unwanted trailing blocks (and any partial page at the end). The removed
+// bytes are saved to a backup file first. Without --allow-writes it only
+// shows what would be removed. It returns whether the file was changed.
+func CmdTruncate(filename string, npages int64) bool {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false
+	}
+	total := fi.Size() / PageSize
+	if npages < 0 || npages > total {
+		fmt.Printf("Error: invalid page count %d (0-%d)\n", npages, total)
+		return false
+	}
+
+	fmt.Println()
+	fmt.Printf("=== Truncate (%s, %d -> %d pages) ===\n", shownPath(filename), total, npages)
+	if fi.Size() == npages*PageSize {
+		fmt.Println("  Nothing to remove.")
+		fmt.Println()
+		return false
+	}
+	if npages < total {
+		var initialized, failed int
+		var maxLSN uint64
+		for n := npages; n < total; n++ {
+			p, err := ReadPage(filename, n)
+			switch {
+			case err != nil:
+				failed++
+			case !isNewPage(p):
+				initialized++
+				maxLSN = max(maxLSN, p.Header.LSN)
+			}
+		}
+		fmt.Printf("  Pages removed      : %d-%d (%d initialized, %d new", npages, total-1, initialized, total-npages-int64(initialized)-int64(failed))
+		if failed > 0 {
+			fmt.Printf(", %d unreadable", failed)
+		}
+		fmt.Println(")")
+		if initialized > 0 {
+			fmt.Printf("  Highest pd_lsn     : %s\n", lsnStr(maxLSN))
+		}
+	}
+	if partial := fi.Size() - total*PageSize; partial > 0 {
+		fmt.Printf("  Partial page       : %d trailing bytes removed\n", partial)
+	}
+	if !allowWrites {
+		fmt.Println("  (not written: start with --allow-writes to apply)")
+		fmt.Println()
+		return false
+	}
+
+	f, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		fmt.Printf("  Error: %v\n", err)
+		fmt.Println()
+		return false
+	}
+	defer f.Close()
+	tail := make([]byte, fi.Size()-npages*PageSize)
+	if _, err := f.ReadAt(tail, npages*PageSize); err != nil {
+		fmt.Printf("  Error: %v\n", err)
+		fmt.Println()
+		return false
+	}
+	backup, err := saveBackup(fmt.Sprintf("%s.%d-end", filepath.Base(filename), npages), tail)
+	if err != nil {
+		fmt.Printf("  Error: %v\n", err)
+		fmt.Println()
+		return false
+	}
+	fmt.Printf("  Removed bytes      : saved to %s\n", shownPath(backup))
+	defer forgetFileType(filename)
+	if err := f.Truncate(npages * PageSize); err != nil {
+		fmt.Printf("  Error: %v\n", err)
+		fmt.Println()
+		return false
+	}
+	fmt.Printf("  Written            : %s is now %d pages\n", shownPath(filename), npages)
+	fmt.Println()
+	return true
+}
+
+// CmdExtend appends npages zeroed (new) blocks to filename, as
+// PostgreSQL's smgrzeroextend does; the server initializes them on first
+// use. Without --allow-writes it only shows the change. It returns whether
+// the file was changed.
+func CmdExtend(filename string, npages int64) bool {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false
+	}
+	total := fi.Size() / PageSize
+	_, _, _, relFile := parseRelFileName(filename)
+	switch {
+	case npages <= 0:
+		fmt.Printf("Error: invalid page count %d\n", npages)
+		return false
+	case fi.Size()%PageSize != 0:
+		fmt.Printf("Error: file ends in a partial page (%d bytes); truncate it to %d pages first\n", fi.Size()%PageSize, total)
+		return false
+	case relFile && total+npages > RelSegSize:
+		fmt.Printf("Error: a segment file holds at most %d pages; the next ones belong in the following segment\n", RelSegSize)
+		return false
+	}
+
+	fmt.Println()
+	fmt.Printf("=== Extend (%s, %d -> %d pages) ===\n", shownPath(filename), total, total+npages)
+	fmt.Printf("  Pages added        : %d-%d (zeroed)\n", total, total+npages-1)
+	if !allowWrites {
+		fmt.Println("  (not written: start with --allow-writes to apply)")
+		fmt.Println()
+		return false
+	}
+	defer forgetFileType(filename)
+	if err := os.Truncate(filename, (total+npages)*PageSize); err != nil {
+		fmt.Printf("  Error: %v\n", err)
+		fmt.Println()
+		return false
+	}
+	fmt.Printf("  Written            : %s is now %d pages\n", shownPath(filename), total+npages)
+	fmt.Println()
+	return true
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// sampleZ is the normal quantile of the 95% confidence intervals.
+const sampleZ = 1.96
+
+// cutSampleOption removes "--sample <size>" from args and returns the
+// size as given, or "" without the option.
+func cutSampleOption(args []string) ([]string, string, error) {
+	i := slices.Index(args, "--sample")
+	if i < 0 {
+		return args, "", nil
+	}
+	if i+1 >= len(args) {
+		return nil, "", fmt.Errorf("--sample needs a page count or a percentage")
+	}
+	size := args[i+1]
+	return append(slices.Clone(args[:i]), args[i+2:]...), size, nil
+}
+
+// parseSampleSize turns a --sample size, a page count ("5000") or a share
+// of the pages in range ("1%", "0.5%"), into a number of pages, at least
+// one and at most population.
+func parseSampleSize(s string, population int64) (int64, error) {
+	var n int64
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		f, err := strconv.ParseFloat(pct, 64)
+		if err != nil || f <= 0 || f > 100 {
+			return 0, fmt.Errorf("invalid sample %q", s)
+		}
+		n = int64(math.Ceil(float64(population) * f / 100))
+	} else {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v < 1 {
+			return 0, fmt.Errorf("invalid sample %q", s)
+		}
+		n = v
+	}
+	return max(min(n, population), 1), nil
+}
+
+// sampleSizeFor validates a --sample size for the scan range and returns
+// it as a page count.
+func sampleSizeFor(size string, totalPages int64, sr scanRange) (int64, error) {
+	if sr.SinceLSN != 0 {
+		// which pages qualify is only known after reading them
+		return 0, fmt.Errorf("--sample cannot be combined with --since-lsn")
+	}
+	first, end := sr.bounds(totalPages)
+	if end <= first {
+		return 0, fmt.Errorf("no pages to sample")
+	}
+	return parseSampleSize(size, end-first)
+}
+
+// samplePages picks n distinct pages of [first, end) uniformly at random
+// (Floyd's algorithm, so huge files need no permutation) and returns them
+// in file order. With --deterministic the same pages are picked every
+// run.
+func samplePages(first, end, n int64) []int64 {
+	seed := rand.Uint64()
+	if deterministic {
+		seed = 1
+	}
+	rng := rand.New(rand.NewPCG(seed, seed))
+	population := end - first
+	picked := make(map[int64]bool, n)
+	for j := population - n; j < population; j++ {
+		t := rng.Int64N(j + 1)
+		if picked[t] {
+			t = j
+		}
+		picked[t] = true
+	}
+	pages := make([]int64, 0, n)
+	for p := range picked {
+		pages = append(pages, first+p)
+	}
+	slices.Sort(pages)
+	return pages
+}
+
+// sampleColumn holds one per-page quantity over the sampled pages.
+type sampleColumn []float64
+
+func (c sampleColumn) sum() float64 {
+	s := 0.0
+	for _, v := range c {
+		s += v
+	}
+	return s
+}
+
+func (c sampleColumn) mean() float64 { return c.sum() / float64(len(c)) }
+
+// sampleEstimator extrapolates from n pages sampled out of population.
+type sampleEstimator struct {
+	n, population int64
+}
+
+// fpc is the finite population correction, 1 - n/N: sampling every page
+// leaves no uncertainty.
+func (e sampleEstimator) fpc() float64 {
+	return 1 - float64(e.n)/float64(e.population)
+}
+
+// total estimates the sum of c over all pages and its standard error.
+func (e sampleEstimator) total(c sampleColumn) (float64, float64) {
+	m := c.mean()
+	if e.n < 2 {
+		return m * float64(e.population), math.NaN()
+	}
+	ss := 0.0
+	for _, v := range c {
+		ss += (v - m) * (v - m)
+	}
+	se := float64(e.population) * math.Sqrt(e.fpc()*ss/float64(e.n-1)/float64(e.n))
+	return m * float64(e.population), se
+}
+
+// ratio estimates sum(y)/sum(x) over all pages, such as bytes per row, and
+// its standard error (linearized ratio estimator).
+func (e sampleEstimator) ratio(y, x sampleColumn) (float64, float64) {
+	sx := x.sum()
+	if sx == 0 {
+		return math.NaN(), math.NaN()
+	}
+	r := y.sum() / sx
+	if e.n < 2 {
+		return r, math.NaN()
+	}
+	ss := 0.0
+	for i := range y {
+		d := y[i] - r*x[i]
+		ss += d * d
+	}
+	mx := sx / float64(e.n)
+	return r, math.Sqrt(e.fpc()*ss/float64(e.n-1)/float64(e.n)) / mx
+}
+
+// ciStr formats an estimate with its 95% confidence interval, using
+// format for the numbers; bounds are clamped at zero.
+func ciStr(v, se float64, format string) string {
+	if math.IsNaN(v) {
+		return "n/a"
+	}
+	s := fmt.Sprintf(format, v)
+	if math.IsNaN(se) {
+		return s + " (sample too small for an interval)"
+	}
+	lo, hi := max(v-sampleZ*se, 0), v+sampleZ*se
+	return fmt.Sprintf("%s (95%% CI "+format+" - "+format+")", s, lo, hi)
+}
+
+// CmdStatsSample estimates file statistics from n pages sampled at random
+// from the range: heap pages, live rows and dead tuples, the dead share and
+// the average row width, each with a 95% confidence interval. Unreadable
+// sampled pages count as holding nothing. Tuple liveness is judged from
+// hint bits, as in pgstattuple.
+func CmdStatsSample(filename string, totalPages int64, sr scanRange, n int64) {
+	first, end := sr.bounds(totalPages)
+	population := end - first
+	pages := samplePages(first, end, n)
+	est := sampleEstimator{n: int64(len(pages)), population: population}
+
+	var heap, live, dead, liveBytes, free sampleColumn
+	unreadable := 0
+	for _, i := range pages {
+		var h, l, d, lb, f float64
+		pg, err := ReadPage(filename, i)
+		if err != nil {
+			unreadable++
+		} else {
+			f = float64(exactFreeSpace(pg))
+			if pg.Detected == PageTypeHeap {
+				h = 1
+				for _, lp := range pg.Items {
+					switch lp.Flags() {
+					case LPDead:
+						d++
+					case LPNormal:
+						if lp.Length() < HeapTupleHdrSize || int(lp.Offset())+int(lp.Length()) > PageSize {
+							continue
+						}
+						if heapTupleLive(pg.ParseHeapTupleHeader(lp.Offset())) {
+							l++
+							lb += float64(lp.Length())
+						} else {
+							d++
+						}
+					}
+				}
+			}
+		}
+		heap = append(heap, h)
+		live = append(live, l)
+		dead = append(dead, d)
+		liveBytes = append(liveBytes, lb)
+		free = append(free, f)
+	}
+	tuples := make(sampleColumn, len(live))
+	for i := range live {
+		tuples[i] = live[i] + dead[i]
+	}
+
+	fmt.Println()
+	fmt.Println("=== File Statistics (sampled) ===")
+	fmt.Printf("  Pages              : %s\n", sr.String(totalPages))
+	fmt.Printf("  Sampled            : %d (%.2f%%)\n", len(pages), 100*float64(len(pages))/float64(population))
+	if unreadable > 0 {
+		fmt.Printf("  Unreadable sampled : %d\n", unreadable)
+	}
+	v, se := est.total(heap)
+	fmt.Printf("  Heap pages         : %s\n", ciStr(v, se, "%.0f"))
+	v, se = est.total(live)
+	fmt.Printf("  Live rows          : %s\n", ciStr(v, se, "%.0f"))
+	v, se = est.total(dead)
+	fmt.Printf("  Dead tuples        : %s\n", ciStr(v, se, "%.0f"))
+	v, se = est.ratio(dead, tuples)
+	fmt.Printf("  Dead share         : %s\n", ciStr(100*v, 100*se, "%.2f%%"))
+	v, se = est.ratio(liveBytes, live)
+	fmt.Printf("  Avg row width      : %s\n", ciStr(v, se, "%.1f"))
+	v, se = est.total(free)
+	fmt.Printf("  Free space / page  : %s\n", ciStr(v/float64(population), se/float64(population), "%.0f"))
+	fmt.Println()
+	fmt.Println("  Rows are judged live or dead from hint bits; dead tuples include LP_DEAD")
+	fmt.Println("  line pointers. Row width is the tuple length, header included.")
+	fmt.Println()
+}
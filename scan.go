@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// scanRange restricts a whole-file scan to part of the file: --offset
+// skips that many pages, --limit stops after that many, and --since-lsn
+// passes over pages whose pd_lsn is older than the given LSN, so a
+// mostly static relation can be re-checked for what changed since the
+// last run. The zero value scans every page.
+type scanRange struct {
+	Offset, Limit int64
+	SinceLSN      uint64
+}
+
+// skips reports whether a page in range is passed over for --since-lsn.
+func (r scanRange) skips(p *Page) bool {
+	return r.skipsLSN(p.Header.LSN)
+}
+
+func (r scanRange) skipsLSN(lsn uint64) bool {
+	return r.SinceLSN != 0 && lsn < r.SinceLSN
+}
+
+// bounds returns the first page to scan and the one past the last.
+func (r scanRange) bounds(totalPages int64) (first, end int64) {
+	first = min(r.Offset, totalPages)
+	end = totalPages
+	if r.Limit > 0 {
+		end = min(first+r.Limit, totalPages)
+	}
+	return first, end
+}
+
+// String describes the range for report headers, e.g. "pages 100-199 of
+// 5000"; a whole-file range reads "5000 pages".
+func (r scanRange) String(totalPages int64) string {
+	first, end := r.bounds(totalPages)
+	var s string
+	switch {
+	case first == 0 && end == totalPages:
+		s = fmt.Sprintf("%d pages", totalPages)
+	case first == end:
+		s = fmt.Sprintf("no pages of %d", totalPages)
+	default:
+		s = fmt.Sprintf("pages %d-%d of %d", first, end-1, totalPages)
+	}
+	if r.SinceLSN != 0 {
+		s += ", pd_lsn >= " + lsnStr(r.SinceLSN)
+	}
+	return s
+}
+
+// whole reports whether the range covers every page of the file.
+func (r scanRange) whole(totalPages int64) bool {
+	first, end := r.bounds(totalPages)
+	return first == 0 && end == totalPages && r.SinceLSN == 0
+}
+
+// isScanRangeOption reports whether arg is one of the scanRange options.
+func isScanRangeOption(arg string) bool {
+	return arg == "--offset" || arg == "--limit" || arg == "--since-lsn"
+}
+
+// parseScanRange removes --offset N, --limit N and --since-lsn LSN from
+// args.
+func parseScanRange(args []string) ([]string, scanRange, error) {
+	var r scanRange
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if !isScanRangeOption(args[i]) {
+			rest = append(rest, args[i])
+			continue
+		}
+		if i+1 >= len(args) && args[i] == "--since-lsn" {
+			return nil, r, fmt.Errorf("--since-lsn requires an LSN (X/X)")
+		}
+		if i+1 >= len(args) {
+			return nil, r, fmt.Errorf("%s requires a page count", args[i])
+		}
+		if args[i] == "--since-lsn" {
+			lsn, err := parseLSN(args[i+1])
+			if err != nil {
+				return nil, r, err
+			}
+			r.SinceLSN = lsn
+			i++
+			continue
+		}
+		n, err := strconv.ParseInt(args[i+1], 10, 64)
+		if err != nil || n < 0 || (args[i] == "--limit" && n == 0) {
+			return nil, r, fmt.Errorf("invalid %s %q", args[i], args[i+1])
+		}
+		if args[i] == "--offset" {
+			r.Offset = n
+		} else {
+			r.Limit = n
+		}
+		i++
+	}
+	return rest, r, nil
+}
+
+// cutScanRange consumes scanRange options given before the rest of a
+// command line, leaving the remainder (a search pattern, say) as typed.
+func cutScanRange(s string) (string, scanRange, error) {
+	var r scanRange
+	for {
+		t := strings.TrimLeft(s, " \t")
+		f := strings.Fields(t)
+		if len(f) == 0 || !isScanRangeOption(f[0]) {
+			return s, r, nil
+		}
+		_, opt, err := parseScanRange(f[:min(len(f), 2)])
+		if err != nil {
+			return "", r, err
+		}
+		switch f[0] {
+		case "--offset":
+			r.Offset = opt.Offset
+		case "--limit":
+			r.Limit = opt.Limit
+		default:
+			r.SinceLSN = opt.SinceLSN
+		}
+		t = strings.TrimLeft(t[len(f[0]):], " \t")
+		s = t[len(f[1]):]
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseSearchPattern turns the search argument into the bytes to look for:
+//
+//	"some text"        raw ASCII bytes (quotes optional)
+//	hex 48 65 6c 6c    hex bytes, spaces optional, 0x prefix allowed
+//	int2|int4|int8 N   little-endian integer encoding
+func parseSearchPattern(arg string) ([]byte, error) {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("missing pattern")
+	}
+	switch fields[0] {
+	case "hex":
+		s := strings.TrimPrefix(strings.Join(fields[1:], ""), "0x")
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("bad hex pattern: %w", err)
+		}
+		if len(b) == 0 {
+			return nil, fmt.Errorf("empty hex pattern")
+		}
+		return b, nil
+	case "int2", "int4", "int8":
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("usage: %s <value>", fields[0])
+		}
+		v, err := strconv.ParseInt(fields[1], 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad integer: %w", err)
+		}
+		le := binary.LittleEndian
+		switch fields[0] {
+		case "int2":
+			return le.AppendUint16(nil, uint16(v)), nil
+		case "int4":
+			return le.AppendUint32(nil, uint32(v)), nil
+		}
+		return le.AppendUint64(nil, uint64(v)), nil
+	}
+	// Plain text keeps its inner spacing
+	s := strings.TrimSpace(arg)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	if s == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+	return []byte(s), nil
+}
+
+// findAll returns the start offsets of every (possibly overlapping)
+// occurrence of pat in data.
+func findAll(data, pat []byte) []int {
+	var hits []int
+	for off := 0; ; off++ {
+		i := bytes.Index(data[off:], pat)
+		if i < 0 {
+			return hits
+		}
+		off += i
+		hits = append(hits, off)
+	}
+}
+
+// CmdSearch scans every page of the file for pat and prints the page and
+// offset of each hit.
+func CmdSearch(filename string, totalPages int64, sr scanRange, pat []byte) {
+	fmt.Println()
+	fmt.Printf("=== Search for % x (%d bytes, %s) ===\n", pat, len(pat), sr.String(totalPages))
+	total, pagesHit := 0, 0
+	first, end := sr.bounds(totalPages)
+	for i := first; i < end; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil || sr.skips(pg) {
+			continue
+		}
+		hits := findAll(pg.Data[:], pat)
+		if len(hits) == 0 {
+			continue
+		}
+		pagesHit++
+		total += len(hits)
+		for _, off := range hits {
+			fmt.Printf("  page %-6d offset %5d (0x%04X)  %s\n", i, off, off, whereShort(pg, off))
+		}
+	}
+	fmt.Printf("\n  %d hits on %d pages\n\n", total, pagesHit)
+}
+
+// whereShort names the page region containing the byte at off.
+func whereShort(p *Page, off int) string {
+	h := &p.Header
+	switch {
+	case off < PageHeaderSize:
+		return "page header"
+	case off < int(h.Lower):
+		return fmt.Sprintf("line pointer %d", (off-PageHeaderSize)/ItemIdSize+1)
+	case off < int(h.Upper):
+		return "free space"
+	case off >= int(h.Special) && p.SpecialSize() > 0:
+		return "special space"
+	}
+	for i, lp := range p.Items {
+		start := int(lp.Offset())
+		if lp.Length() > 0 && off >= start && off < start+int(lp.Length()) {
+			return fmt.Sprintf("item %d +%d", i+1, off-start)
+		}
+	}
+	return "tuple area (unreferenced)"
+}
+
+// highlightMask marks every byte of the page covered by an occurrence of pat.
+func highlightMask(p *Page, pat []byte) []bool {
+	mask := make([]bool, PageSize)
+	for _, off := range findAll(p.Data[:], pat) {
+		for j := off; j < off+len(pat); j++ {
+			mask[j] = true
+		}
+	}
+	return mask
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// lpFlagNames maps the lp_flags names shown by items to their values.
+var lpFlagNames = map[string]uint32{"UNUSED": LPUnused, "NORMAL": LPNormal, "REDIRECT": LPRedirect, "DEAD": LPDead}
+
+// applyItemIdEdit applies one setlp edit, "offset=N", "len=N" or
+// "flags=UNUSED|NORMAL|REDIRECT|DEAD", to lp.
+func applyItemIdEdit(lp *ItemId, edit string) error {
+	field, value, ok := strings.Cut(edit, "=")
+	if !ok {
+		return fmt.Errorf("invalid edit %q (offset=N, len=N, flags=NAME)", edit)
+	}
+	off, flags, length := uint32(lp.Offset()), uint32(lp.Flags()), uint32(lp.Length())
+	switch strings.ToLower(field) {
+	case "offset", "off", "lp_off":
+		v, err := strconv.ParseUint(value, 0, 15)
+		if err != nil {
+			return fmt.Errorf("invalid offset %q (0-32767)", value)
+		}
+		off = uint32(v)
+	case "len", "length", "lp_len":
+		v, err := strconv.ParseUint(value, 0, 15)
+		if err != nil {
+			return fmt.Errorf("invalid length %q (0-32767)", value)
+		}
+		length = uint32(v)
+	case "flags", "lp_flags":
+		v, ok := lpFlagNames[strings.TrimPrefix(strings.ToUpper(value), "LP_")]
+		if n, err := strconv.ParseUint(value, 0, 2); err == nil {
+			v, ok = uint32(n), true
+		}
+		if !ok {
+			return fmt.Errorf("invalid flags %q (UNUSED, NORMAL, REDIRECT, DEAD)", value)
+		}
+		flags = v
+	default:
+		return fmt.Errorf("unknown field %q (offset, len, flags)", field)
+	}
+	lp.Raw = off | flags<<15 | length<<17
+	return nil
+}
+
+// itemIdWarnings lists what PostgreSQL would find wrong with line pointer
+// lp of p, so an edit does not trade one inconsistency for another.
+func itemIdWarnings(p *Page, lp ItemId) []string {
+	var warnings []string
+	off, length := int(lp.Offset()), int(lp.Length())
+	switch lp.Flags() {
+	case LPNormal:
+		if off < int(p.Header.Upper) || off+length > int(p.Header.Special) {
+			warnings = append(warnings, fmt.Sprintf("storage %d-%d is outside the tuple area %d-%d", off, off+length, p.Header.Upper, p.Header.Special))
+		}
+		if off%maxAlignment != 0 {
+			warnings = append(warnings, fmt.Sprintf("offset %d is not MAXALIGNed", off))
+		}
+		if p.Detected == PageTypeHeap && length < HeapTupleHdrSize {
+			warnings = append(warnings, fmt.Sprintf("length %d is shorter than a heap tuple header", length))
+		}
+	case LPRedirect:
+		if off < 1 || off > len(p.Items) {
+			warnings = append(warnings, fmt.Sprintf("redirect target %d is not an item of the page (1-%d)", off, len(p.Items)))
+		}
+		if length != 0 {
+			warnings = append(warnings, "a redirect has lp_len 0")
+		}
+	case LPUnused:
+		if off != 0 || length != 0 {
+			warnings = append(warnings, "an unused line pointer has lp_off and lp_len 0")
+		}
+	case LPDead:
+		if p.Detected == PageTypeHeap && (off != 0 || length != 0) {
+			warnings = append(warnings, "a dead heap line pointer has lp_off and lp_len 0")
+		}
+	}
+	return warnings
+}
+
+// CmdSetLP patches line pointer item (1-based) of page pageNum, e.g. to
+// turn a REDIRECT into DEAD or fix a length that is off by alignment.
+// Without --allow-writes it only shows what would change. It returns
+// whether the page was written.
+func CmdSetLP(filename string, pageNum int64, p *Page, item int, edits []string) bool {
+	if item < 1 || item > len(p.Items) {
+		fmt.Printf("Error: item %d out of range (1-%d)\n", item, len(p.Items))
+		return false
+	}
+	before := p.Items[item-1]
+	after := before
+	for _, e := range edits {
+		if err := applyItemIdEdit(&after, e); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+	}
+	show := func(lp ItemId) string {
+		return fmt.Sprintf("%s off=%d len=%d (0x%08X)", lp.FlagsStr(), lp.Offset(), lp.Length(), lp.Raw)
+	}
+
+	fmt.Println()
+	fmt.Printf("=== Set Line Pointer (page %d, item %d) ===\n", pageNum, item)
+	if after == before {
+		fmt.Printf("  Line pointer       : %s (unchanged)\n", show(before))
+		fmt.Println()
+		return false
+	}
+	fmt.Printf("  Line pointer       : %s\n", show(before))
+	fmt.Printf("  New value          : %s\n", show(after))
+	if warnings := itemIdWarnings(p, after); len(warnings) > 0 {
+		fmt.Println("  Warnings:")
+		for _, w := range warnings {
+			fmt.Printf("    - %s\n", w)
+		}
+	}
+	data := p.Data
+	binary.LittleEndian.PutUint32(data[PageHeaderSize+(item-1)*ItemIdSize:], after.Raw)
+	return commitPage(filename, pageNum, p, data)
+}
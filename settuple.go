@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// infomaskNames and infomask2Names map the flag names shown by data to
+// their bits, for settuple.
+var (
+	infomaskNames = map[string]uint16{
+		"HAS_NULL": HeapHasNull, "HAS_VARWIDTH": HeapHasVarWidth, "HAS_EXTERNAL": HeapHasExternal,
+		"HAS_OID_OLD": HeapHasOidOld, "XMAX_KEYSHR_LOCK": HeapXmaxKeyShrLock, "COMBO_CID": HeapComboCID,
+		"XMAX_EXCL_LOCK": HeapXmaxExclLock, "XMAX_LOCK_ONLY": HeapXmaxLockOnly,
+		"XMIN_COMMITTED": HeapXminCommitted, "XMIN_INVALID": HeapXminInvalid, "XMIN_FROZEN": HeapXminFrozen,
+		"XMAX_COMMITTED": HeapXmaxCommitted, "XMAX_INVALID": HeapXmaxInvalid, "XMAX_IS_MULTI": HeapXmaxIsMulti,
+		"UPDATED": HeapUpdated, "MOVED_OFF": HeapMovedOff, "MOVED_IN": HeapMovedIn,
+	}
+	infomask2Names = map[string]uint16{
+		"KEYS_UPDATED": HeapKeysUpdated, "HOT_UPDATED": HeapHotUpdated, "HEAP_ONLY": HeapOnlyTuple,
+	}
+)
+
+// parseMaskValue parses a number or flag names joined by '|' (with or
+// without the HEAP_ prefix) into a bit mask; byName tells which it was.
+func parseMaskValue(s string, names map[string]uint16) (uint16, bool, error) {
+	if v, err := strconv.ParseUint(s, 0, 16); err == nil {
+		return uint16(v), false, nil
+	}
+	var mask uint16
+	for _, name := range strings.Split(s, "|") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		bit, ok := names[name]
+		if !ok {
+			bit, ok = names[strings.TrimPrefix(name, "HEAP_")]
+		}
+		if !ok {
+			return 0, true, fmt.Errorf("unknown flag %q", name)
+		}
+		mask |= bit
+	}
+	return mask, true, nil
+}
+
+// applyTupleEdit applies one settuple edit, "<field>=<value>" or, for the
+// infomasks, "<field>+=<flags>" and "<field>-=<flags>", to t.
+func applyTupleEdit(t *HeapTupleHeader, edit string) error {
+	i := strings.IndexAny(edit, "+-=")
+	if i <= 0 {
+		return fmt.Errorf("invalid edit %q (<field>=<value>, <field>+=<flags>, <field>-=<flags>)", edit)
+	}
+	field, op, value := strings.ToLower(edit[:i]), edit[i:i+1], edit[i+1:]
+	if op != "=" {
+		if !strings.HasPrefix(value, "=") {
+			return fmt.Errorf("invalid edit %q", edit)
+		}
+		value = value[1:]
+	}
+	xid := func(dst *uint32) error {
+		if op != "=" {
+			return fmt.Errorf("%s only takes =", field)
+		}
+		v, err := strconv.ParseUint(value, 0, 32)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", field, value)
+		}
+		*dst = uint32(v)
+		return nil
+	}
+	// keep are the bits "= <flags>" leaves alone (natts in t_infomask2)
+	mask := func(dst *uint16, names map[string]uint16, keep uint16) error {
+		v, byName, err := parseMaskValue(value, names)
+		if err != nil {
+			return err
+		}
+		switch {
+		case op == "+":
+			*dst |= v
+		case op == "-":
+			*dst &^= v
+		case byName:
+			*dst = *dst&keep | v
+		default:
+			*dst = v
+		}
+		return nil
+	}
+	switch field {
+	case "xmin", "t_xmin":
+		return xid(&t.Xmin)
+	case "xmax", "t_xmax":
+		return xid(&t.Xmax)
+	case "cid", "t_cid", "xvac", "t_xvac":
+		return xid(&t.Field3)
+	case "ctid", "t_ctid":
+		if op != "=" {
+			return fmt.Errorf("ctid only takes =")
+		}
+		blk, off, ok := strings.Cut(strings.Trim(value, "()"), ",")
+		b, err1 := strconv.ParseUint(strings.TrimSpace(blk), 0, 32)
+		o, err2 := strconv.ParseUint(strings.TrimSpace(off), 0, 16)
+		if !ok || err1 != nil || err2 != nil {
+			return fmt.Errorf("invalid ctid %q (block,offset)", value)
+		}
+		t.CtidBlock, t.CtidOffset = uint32(b), uint16(o)
+		return nil
+	case "infomask", "t_infomask":
+		return mask(&t.Infomask, infomaskNames, 0)
+	case "infomask2", "t_infomask2":
+		return mask(&t.Infomask2, infomask2Names, HeapNattsMask)
+	}
+	return fmt.Errorf("unknown field %q (xmin, xmax, cid, ctid, infomask, infomask2)", field)
+}
+
+// putHeapTupleHeader stores the fixed fields of t at the start of d, the
+// inverse of ParseHeapTupleHeader.
+func putHeapTupleHeader(d []byte, t HeapTupleHeader) {
+	le := binary.LittleEndian
+	le.PutUint32(d[0:4], t.Xmin)
+	le.PutUint32(d[4:8], t.Xmax)
+	le.PutUint32(d[8:12], t.Field3)
+	le.PutUint16(d[12:14], uint16(t.CtidBlock>>16))
+	le.PutUint16(d[14:16], uint16(t.CtidBlock))
+	le.PutUint16(d[16:18], t.CtidOffset)
+	le.PutUint16(d[18:20], t.Infomask2)
+	le.PutUint16(d[20:22], t.Infomask)
+	d[22] = t.Hoff
+}
+
+// tupleHeaderChanges lists the fields that differ between before and after.
+func tupleHeaderChanges(before, after HeapTupleHeader) []string {
+	var changes []string
+	add := func(name, b, a string) {
+		if b != a {
+			changes = append(changes, fmt.Sprintf("%-12s : %s -> %s", name, b, a))
+		}
+	}
+	maskStr := func(m uint16, flags []string) string {
+		if len(flags) == 0 {
+			return fmt.Sprintf("0x%04X", m)
+		}
+		return fmt.Sprintf("0x%04X [%s]", m, strings.Join(flags, " | "))
+	}
+	add("t_xmin", fmt.Sprint(before.Xmin), fmt.Sprint(after.Xmin))
+	add("t_xmax", fmt.Sprint(before.Xmax), fmt.Sprint(after.Xmax))
+	add(before.Field3Name(), fmt.Sprint(before.Field3), fmt.Sprint(after.Field3))
+	add("t_ctid", before.CtidStr(), after.CtidStr())
+	add("t_infomask2", maskStr(before.Infomask2, before.Infomask2Flags()), maskStr(after.Infomask2, after.Infomask2Flags()))
+	add("t_infomask", maskStr(before.Infomask, before.InfomaskFlags()), maskStr(after.Infomask, after.InfomaskFlags()))
+	return changes
+}
+
+// CmdSetTuple edits the header of heap tuple item (1-based) of page
+// pageNum. Without --allow-writes it only shows what would change. It
+// returns whether the page was written.
+func CmdSetTuple(filename string, pageNum int64, p *Page, item int, edits []string) bool {
+	if p.Detected != PageTypeHeap {
+		fmt.Printf("Error: page %d is not a heap page (%s)\n", pageNum, p.TypeLabel())
+		return false
+	}
+	if item < 1 || item > len(p.Items) {
+		fmt.Printf("Error: item %d out of range (1-%d)\n", item, len(p.Items))
+		return false
+	}
+	lp := p.Items[item-1]
+	if lp.Flags() != LPNormal || lp.Length() < HeapTupleHdrSize || int(lp.Offset())+HeapTupleHdrSize > PageSize {
+		fmt.Printf("Error: item %d is %s with no tuple header to edit\n", item, lp.FlagsStr())
+		return false
+	}
+	before := p.ParseHeapTupleHeader(lp.Offset())
+	after := before
+	for _, e := range edits {
+		if err := applyTupleEdit(&after, e); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("=== Set Tuple (page %d, item %d at offset %d) ===\n", pageNum, item, lp.Offset())
+	changes := tupleHeaderChanges(before, after)
+	if len(changes) == 0 {
+		fmt.Println("  No change.")
+		fmt.Println()
+		return false
+	}
+	for _, c := range changes {
+		fmt.Printf("  %s\n", c)
+	}
+	data := p.Data
+	putHeapTupleHeader(data[lp.Offset():], after)
+	return commitPage(filename, pageNum, p, data)
+}
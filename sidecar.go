@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// sidecarMagic starts every sidecar index; the last byte is the format
+// version.
+var sidecarMagic = [8]byte{'P', 'G', 'P', 'S', 'I', 'D', 'X', 3}
+
+// pageMeta is what pages and stats need to know about a page, kept per
+// block in a sidecar index so that a large file is summarized without
+// reading it again.
+type pageMeta struct {
+	LSN                            uint64
+	Flags, Lower, Upper            uint16
+	SpecialSize                    uint16
+	Anomalies                      uint16
+	Normal, Dead, Unused, Redirect uint16
+	Type                           uint8
+	Unreadable                     bool
+	Encrypted                      bool
+}
+
+func newPageMeta(p *Page) pageMeta {
+	if p.Encrypted {
+		// The header and line pointers of ciphertext are noise; keep them
+		// out of counts and free space.
+		return pageMeta{
+			Anomalies: uint16(min(len(p.Annotations), 0xFFFF)),
+			Type:      uint8(PageTypeUnknown),
+			Encrypted: true,
+		}
+	}
+	m := pageMeta{
+		LSN:         p.Header.LSN,
+		Flags:       p.Header.Flags,
+		Lower:       p.Header.Lower,
+		Upper:       p.Header.Upper,
+		SpecialSize: uint16(max(p.SpecialSize(), 0)),
+		Anomalies:   uint16(min(len(p.Annotations), 0xFFFF)),
+		Type:        uint8(p.Detected),
+		Encrypted:   p.Encrypted,
+	}
+	for _, lp := range p.Items {
+		switch lp.Flags() {
+		case LPNormal:
+			m.Normal++
+		case LPDead:
+			m.Dead++
+		case LPUnused:
+			m.Unused++
+		case LPRedirect:
+			m.Redirect++
+		}
+	}
+	return m
+}
+
+// NumItems is the line pointer count implied by pd_lower.
+func (m pageMeta) NumItems() int {
+	if m.Lower <= PageHeaderSize {
+		return 0
+	}
+	return int(m.Lower-PageHeaderSize) / ItemIdSize
+}
+
+func (m pageMeta) FreeSpace() int {
+	if m.Upper <= m.Lower {
+		return 0
+	}
+	return int(m.Upper - m.Lower)
+}
+
+func (m pageMeta) PageType() PageType { return PageType(m.Type) }
+
+// TypeLabel is the page type for display, as Page.TypeLabel.
+func (m pageMeta) TypeLabel() string {
+	if m.Encrypted {
+		return "encrypted?"
+	}
+	return m.PageType().String()
+}
+
+// sidecarHeader identifies the file state and the settings a sidecar was
+// written with; it is only used while all of them still match.
+type sidecarHeader struct {
+	Magic     [8]byte
+	Size      int64
+	ModTime   int64 // UnixNano
+	PGVersion int32
+	Robust    bool
+	Pages     int64
+}
+
+// Sizes of the header and of each page's record in a sidecar index; the
+// record of page i is at sidecarHeaderSize + i*sidecarRecordSize.
+var (
+	sidecarHeaderSize = int64(binary.Size(sidecarHeader{}))
+	sidecarRecordSize = int64(binary.Size(pageMeta{}))
+)
+
+// sidecar is a validated sidecar index. Its page records stay on disk and
+// are streamed by scanMeta, so memory does not grow with the relation.
+type sidecar struct {
+	Path   string
+	Header sidecarHeader
+}
+
+var (
+	sidecarMu sync.Mutex
+	sidecarBy = map[string]*sidecar{}
+)
+
+// sidecarPath returns where the sidecar index of filename lives. Indexes
+// are kept in the user cache directory, never next to the relation:
+// PostgreSQL tools such as pg_checksums reject unknown files in a data
+// directory.
+func sidecarPath(filename string) (string, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, "pgpageshell", "sidecar", hex.EncodeToString(sum[:16])), nil
+}
+
+// sidecarUsable reports whether the current settings allow answering from
+// a sidecar index: --live and --direct-io ask for what is on disk now, and
+// a forced page type or template, a decryption helper or an image window
+// changes what every page decodes as.
+func sidecarUsable() bool {
+	return !liveReads && !directIO && pageTypeOverride == noTypeOverride && templateOverride == "" && pageDecryption == nil &&
+		len(imageWindows) == 0
+}
+
+// currentSidecarHeader describes filename as it is now.
+func currentSidecarHeader(filename string) (sidecarHeader, error) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return sidecarHeader{}, err
+	}
+	return sidecarHeader{
+		Magic:     sidecarMagic,
+		Size:      fi.Size(),
+		ModTime:   fi.ModTime().UnixNano(),
+		PGVersion: int32(pgVersion),
+		Robust:    robustParsing,
+		Pages:     fi.Size() / PageSize,
+	}, nil
+}
+
+// loadSidecar returns the sidecar index of filename if there is one that
+// is still valid: written for the file's current size and mtime and with
+// the current settings, and holding a record for every page. It returns
+// nil otherwise.
+func loadSidecar(filename string) *sidecar {
+	if !sidecarUsable() {
+		return nil
+	}
+	want, err := currentSidecarHeader(filename)
+	if err != nil {
+		return nil
+	}
+	sidecarMu.Lock()
+	defer sidecarMu.Unlock()
+	if sc, ok := sidecarBy[filename]; ok && sc.Header == want {
+		return sc
+	}
+	delete(sidecarBy, filename)
+
+	path, err := sidecarPath(filename)
+	if err != nil {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	sc := &sidecar{Path: path}
+	if err := binary.Read(f, binary.LittleEndian, &sc.Header); err != nil || sc.Header != want {
+		return nil
+	}
+	fi, err := f.Stat()
+	if err != nil || fi.Size() != sidecarHeaderSize+sc.Header.Pages*sidecarRecordSize {
+		return nil
+	}
+	sidecarBy[filename] = sc
+	return sc
+}
+
+// writeSidecar scans every page of filename and writes its sidecar index,
+// one record per page as it is read. The file is stat'ed before the scan,
+// so an index of a file that changed while it was read is never used.
+func writeSidecar(filename string) (*sidecar, error) {
+	h, err := currentSidecarHeader(filename)
+	if err != nil {
+		return nil, err
+	}
+	path, err := sidecarPath(filename)
+	if err != nil {
+		return nil, err
+	}
+	sc := &sidecar{Path: path, Header: h}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	err = binary.Write(w, binary.LittleEndian, &sc.Header)
+	for i := int64(0); i < h.Pages && err == nil; i++ {
+		m := pageMeta{Unreadable: true}
+		if pg, rerr := ReadPage(filename, i); rerr == nil {
+			m = newPageMeta(pg)
+		}
+		err = binary.Write(w, binary.LittleEndian, &m)
+	}
+	err = errors.Join(err, w.Flush(), tmp.Close())
+	if err != nil {
+		return nil, fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+
+	sidecarMu.Lock()
+	sidecarBy[filename] = sc
+	sidecarMu.Unlock()
+	return sc, nil
+}
+
+// dropSidecar removes the sidecar index of filename, if any.
+func dropSidecar(filename string) error {
+	sidecarMu.Lock()
+	delete(sidecarBy, filename)
+	sidecarMu.Unlock()
+	path, err := sidecarPath(filename)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// scanMeta calls fn for every page in the range with its summary, taken
+// from the sidecar index when there is a valid one and read from the file
+// otherwise. Pages unreadable when the index was written are read again,
+// so fn sees the actual error. Pages passed over by --since-lsn are
+// reported too; fn decides how to count them.
+func scanMeta(filename string, totalPages int64, sr scanRange, fn func(i int64, m pageMeta, err error)) {
+	first, end := sr.bounds(totalPages)
+	mr := newMetaReader(filename, first)
+	defer mr.Close()
+	for i := first; i < end; i++ {
+		m, err := mr.read(i)
+		fn(i, m, err)
+	}
+}
+
+// metaReader yields the summaries of consecutive pages the way scanMeta
+// does, for callers that take one page at a time.
+type metaReader struct {
+	filename string
+	records  *sidecarRecords
+}
+
+// newMetaReader starts reading summaries of filename at page first.
+func newMetaReader(filename string, first int64) *metaReader {
+	return &metaReader{filename: filename, records: openSidecarRecords(filename, first)}
+}
+
+// read returns the summary of page i, which must follow the page read
+// before it.
+func (r *metaReader) read(i int64) (pageMeta, error) {
+	var m pageMeta
+	if r.records != nil && i < r.records.pages && r.records.next(&m) && !m.Unreadable {
+		return m, nil
+	}
+	pg, err := ReadPage(r.filename, i)
+	if err != nil {
+		return pageMeta{}, err
+	}
+	return newPageMeta(pg), nil
+}
+
+func (r *metaReader) Close() error {
+	if r.records == nil {
+		return nil
+	}
+	return r.records.Close()
+}
+
+// sidecarRecords reads the page records of a sidecar index in order.
+type sidecarRecords struct {
+	f     *os.File
+	r     *bufio.Reader
+	pages int64
+	ok    bool
+}
+
+// openSidecarRecords positions a reader of filename's sidecar index at the
+// record of page first, or returns nil when there is no valid index.
+func openSidecarRecords(filename string, first int64) *sidecarRecords {
+	sc := loadSidecar(filename)
+	if sc == nil || first >= sc.Header.Pages {
+		return nil
+	}
+	f, err := os.Open(sc.Path)
+	if err != nil {
+		return nil
+	}
+	if _, err := f.Seek(sidecarHeaderSize+first*sidecarRecordSize, io.SeekStart); err != nil {
+		f.Close()
+		return nil
+	}
+	return &sidecarRecords{f: f, r: bufio.NewReader(f), pages: sc.Header.Pages, ok: true}
+}
+
+// next reads the following record into m. After a short read every later
+// call fails too, and the pages are read from the file instead.
+func (s *sidecarRecords) next(m *pageMeta) bool {
+	if s.ok && binary.Read(s.r, binary.LittleEndian, m) != nil {
+		s.ok = false
+	}
+	return s.ok
+}
+
+func (s *sidecarRecords) Close() error { return s.f.Close() }
+
+// CmdSidecar reports the state of the sidecar index of filename.
+func CmdSidecar(filename string) {
+	fmt.Println()
+	fmt.Println("=== Sidecar Index ===")
+	path, err := sidecarPath(filename)
+	if err != nil {
+		fmt.Printf("  Error: %v\n", err)
+		return
+	}
+	fmt.Printf("  Path               : %s\n", shownPath(path))
+	sc := loadSidecar(filename)
+	switch {
+	case sc != nil:
+		fmt.Printf("  State              : valid (%d pages)\n", sc.Header.Pages)
+	case !sidecarUsable():
+		fmt.Println("  State              : not used (--live, --direct-io, --decrypt-cmd or a forced page type)")
+	default:
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			fmt.Println("  State              : none ('sidecar write' creates it)")
+		} else {
+			fmt.Println("  State              : stale (file or settings changed; 'sidecar write' rebuilds it)")
+		}
+	}
+	fmt.Println()
+}
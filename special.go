@@ -23,11 +23,15 @@ func DecodeBTreeSpecial(data []byte) {
 	fmt.Println("  B-tree Page Opaque Data (BTPageOpaqueData):")
 	fmt.Printf("    btpo_prev    : %s\n", blockStr(prev))
 	fmt.Printf("    btpo_next    : %s\n", blockStr(next))
-	fmt.Printf("    btpo_level   : %d", level)
-	if level == 0 {
-		fmt.Print(" (leaf)")
+	if flags&BTPDeleted != 0 && btDeletedUsesLevelXact() {
+		fmt.Printf("    btpo.xact    : %d (deleting XID)\n", level)
+	} else {
+		fmt.Printf("    btpo_level   : %d", level)
+		if level == 0 {
+			fmt.Print(" (leaf)")
+		}
+		fmt.Println()
 	}
-	fmt.Println()
 	fmt.Printf("    btpo_flags   : 0x%04X", flags)
 	if fl := btreeFlags(flags); len(fl) > 0 {
 		fmt.Printf(" [%s]", strings.Join(fl, " | "))
@@ -70,36 +74,47 @@ func btreeFlags(f uint16) []string {
 
 // DecodeBTreeMeta decodes BTMetaPageData from the page content area (after header).
 func DecodeBTreeMeta(p *Page) {
-	// Meta page content starts at MAXALIGN(SizeOfPageHeaderData) = 24 rounded to 8 = 24
-	// Actually MAXALIGN(24) = 24 on 8-byte aligned systems
-	offset := 24 // MAXALIGN(PageHeaderSize)
-	if offset+44 > PageSize {
-		return
-	}
-	d := p.Data[offset:]
-	le := binary.LittleEndian
-
-	magic := le.Uint32(d[0:4])
-	version := le.Uint32(d[4:8])
-	root := le.Uint32(d[8:12])
-	level := le.Uint32(d[12:16])
-	fastroot := le.Uint32(d[16:20])
-	fastlevel := le.Uint32(d[20:24])
+	m := parseBTMetaData(p)
 
 	fmt.Println()
 	fmt.Println("  B-tree Meta Page Data (BTMetaPageData):")
-	fmt.Printf("    btm_magic          : 0x%06X", magic)
-	if magic == BTreeMagic {
+	fmt.Printf("    btm_magic                        : 0x%06X", m.Magic)
+	if m.Magic == BTreeMagic {
 		fmt.Print(" (valid)")
 	} else {
 		fmt.Print(" (INVALID!)")
 	}
 	fmt.Println()
-	fmt.Printf("    btm_version        : %d\n", version)
-	fmt.Printf("    btm_root           : %s\n", blockStr(root))
-	fmt.Printf("    btm_level          : %d\n", level)
-	fmt.Printf("    btm_fastroot       : %s\n", blockStr(fastroot))
-	fmt.Printf("    btm_fastlevel      : %d\n", fastlevel)
+	fmt.Printf("    btm_version                      : %d (%s)\n", m.Version, btMetaVersionNote(m.Version))
+	fmt.Printf("    btm_root                         : %s\n", blockStr(m.Root))
+	fmt.Printf("    btm_level                        : %d\n", m.Level)
+	fmt.Printf("    btm_fastroot                     : %s\n", blockStr(m.FastRoot))
+	fmt.Printf("    btm_fastlevel                    : %d\n", m.FastLevel)
+	fmt.Printf("    %-33s: %d (%s)\n", btMetaCleanupFieldName(), m.LastCleanup, btMetaCleanupNote(m))
+	fmt.Printf("    btm_last_cleanup_num_heap_tuples : %g (%s)\n", m.NumHeapTuples, btMetaHeapTuplesNote(m))
+	if btMetaHasAllEqualImage() {
+		note := "deduplication is safe"
+		if !m.AllEqualImage {
+			note = "deduplication disabled (an opclass or collation is not equalimage)"
+		}
+		fmt.Printf("    btm_allequalimage                : %t (%s)\n", m.AllEqualImage, note)
+	}
+}
+
+// DecodeBTreeDeleted decodes BTDeletedPageData, which deleted pages carry
+// in their body from PostgreSQL 14 on (flagged with BTP_HAS_FULLXID).
+func DecodeBTreeDeleted(p *Page) {
+	if btDeletedUsesLevelXact() {
+		return
+	}
+	bt, ok := p.BTreeOpaque()
+	if !ok || bt.Flags&BTPDeleted == 0 || bt.Flags&BTPHasFullXID == 0 {
+		return
+	}
+	safexid := binary.LittleEndian.Uint64(p.Data[PageHeaderSize : PageHeaderSize+8])
+	fmt.Println()
+	fmt.Println("  B-tree Deleted Page Data (BTDeletedPageData):")
+	fmt.Printf("    safexid      : %d:%d (epoch:xid)\n", safexid>>32, uint32(safexid))
 }
 
 // DecodeHashSpecial decodes HashPageOpaqueData (16 bytes).
@@ -184,6 +199,7 @@ func DecodeHashMeta(p *Page) {
 	ovflpoint := le.Uint32(d[36:40])
 	firstfree := le.Uint32(d[40:44])
 	nmaps := le.Uint32(d[44:48])
+	procid := le.Uint32(d[48:52])
 
 	fmt.Println()
 	fmt.Println("  Hash Meta Page Data (HashMetaPageData):")
@@ -206,6 +222,13 @@ func DecodeHashMeta(p *Page) {
 	fmt.Printf("    hashm_ovflpoint  : %d\n", ovflpoint)
 	fmt.Printf("    hashm_firstfree  : %d\n", firstfree)
 	fmt.Printf("    hashm_nmaps      : %d\n", nmaps)
+	fmt.Printf("    hashm_procid     : %d\n", procid)
+	m, err := parseHashMeta(p)
+	if err != nil {
+		fmt.Printf("    (hashm_spares and hashm_mapp not decoded: %v)\n", err)
+		return
+	}
+	printHashArrays(m)
 }
 
 func float64FromBits(bits uint64) float64 {
@@ -318,8 +341,9 @@ func ginFlags(f uint16) []string {
 //   nPendingHeapTuples(8)
 //   nTotalPages(4) nEntryPages(4) nDataPages(4) [pad 4]
 //   nEntries(8)
+// With --maxalign 4 there is no padding before nEntries.
 func DecodeGINMeta(p *Page) {
-	offset := 24
+	offset := maxAlign(PageHeaderSize)
 	if offset+48 > PageSize {
 		return
 	}
@@ -334,8 +358,9 @@ func DecodeGINMeta(p *Page) {
 	nTotalPages := le.Uint32(d[24:28])
 	nEntryPages := le.Uint32(d[28:32])
 	nDataPages := le.Uint32(d[32:36])
-	// 4 bytes padding at d[36:40] for int64 alignment
-	nEntries := int64(le.Uint64(d[40:48]))
+	ne, gv := ginMetaEntriesOff(), ginMetaVersionOff()
+	nEntries := int64(le.Uint64(d[ne : ne+8]))
+	ginVersion := int32(le.Uint32(d[gv : gv+4]))
 
 	fmt.Println()
 	fmt.Println("  GIN Meta Page Data (GinMetaPageData):")
@@ -348,6 +373,7 @@ func DecodeGINMeta(p *Page) {
 	fmt.Printf("    nEntryPages         : %d\n", nEntryPages)
 	fmt.Printf("    nDataPages          : %d\n", nDataPages)
 	fmt.Printf("    nEntries            : %d\n", nEntries)
+	fmt.Printf("    ginVersion          : %d\n", ginVersion)
 }
 
 // DecodeSPGiSTSpecial decodes SpGistPageOpaqueData (8 bytes).
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ---- SP-GiST tuple constants ----
+
+const (
+	SPGistLive        = 0
+	SPGistRedirect    = 1
+	SPGistDead        = 2
+	SPGistPlaceholder = 3
+
+	SPGistMagic = 0xBA0BABEE // SPGIST_MAGIC_NUMBER
+
+	SGLTOffsetMask      = 0x3FFF
+	SGLTHasNullMask     = 0x8000
+	InvalidOffsetNumber = 0
+)
+
+// SpGistLeafTuple mirrors the fixed part of SpGistLeafTupleData. Dead and
+// redirect tuples (SpGistDeadTupleData) share the same leading layout, with
+// HeapBlock/HeapOffset holding the redirect target instead of a heap TID.
+type SpGistLeafTuple struct {
+	TupState   uint8
+	Size       uint32
+	Info       uint16
+	HeapBlock  uint32
+	HeapOffset uint16
+}
+
+func (t *SpGistLeafTuple) NextOffset() uint16 { return t.Info & SGLTOffsetMask }
+func (t *SpGistLeafTuple) HasNulls() bool     { return t.Info&SGLTHasNullMask != 0 }
+
+func (t *SpGistLeafTuple) StateStr() string {
+	switch t.TupState {
+	case SPGistLive:
+		return "LIVE"
+	case SPGistRedirect:
+		return "REDIRECT"
+	case SPGistDead:
+		return "DEAD"
+	case SPGistPlaceholder:
+		return "PLACEHOLDER"
+	}
+	return "UNKNOWN"
+}
+
+// isSPGistLeaf reports whether p is an SP-GiST leaf page.
+func isSPGistLeaf(p *Page) bool {
+	special := p.SpecialData()
+	if p.Detected != PageTypeSPGiST || len(special) < SPGistOpaqueSize {
+		return false
+	}
+	return binary.LittleEndian.Uint16(special[0:2])&SPGistLeaf != 0
+}
+
+// ParseSpGistLeafTuple decodes the leaf tuple header at the given offset.
+func (p *Page) ParseSpGistLeafTuple(offset uint16) SpGistLeafTuple {
+	d := p.Data[offset:]
+	le := binary.LittleEndian
+	hdr := le.Uint32(d[0:4])
+	biHi := le.Uint16(d[6:8])
+	biLo := le.Uint16(d[8:10])
+	return SpGistLeafTuple{
+		TupState:   uint8(hdr & 0x03),
+		Size:       hdr >> 2,
+		Info:       le.Uint16(d[4:6]),
+		HeapBlock:  uint32(biHi)<<16 | uint32(biLo),
+		HeapOffset: le.Uint16(d[10:12]),
+	}
+}
+
+// spgistLeafAt returns the leaf tuple stored at the 1-based offset number,
+// or an error if the slot holds no usable tuple.
+func spgistLeafAt(p *Page, offnum int) (SpGistLeafTuple, error) {
+	if offnum < 1 || offnum > len(p.Items) {
+		return SpGistLeafTuple{}, fmt.Errorf("offset %d out of range (1-%d)", offnum, len(p.Items))
+	}
+	lp := p.Items[offnum-1]
+	if lp.Flags() != LPNormal {
+		return SpGistLeafTuple{}, fmt.Errorf("line pointer %d is %s", offnum, lp.FlagsStr())
+	}
+	if lp.Length() < 12 || int(lp.Offset())+int(lp.Length()) > PageSize {
+		return SpGistLeafTuple{}, fmt.Errorf("line pointer %d has bad length %d", offnum, lp.Length())
+	}
+	return p.ParseSpGistLeafTuple(lp.Offset()), nil
+}
+
+func printSPGistLeafTuples(p *Page) {
+	for i, lp := range p.Items {
+		fmt.Printf("\n--- Item %d (offset %d, length %d) ---\n", i+1, lp.Offset(), lp.Length())
+		t, err := spgistLeafAt(p, i+1)
+		if err != nil {
+			fmt.Printf("  [%v]\n", err)
+			continue
+		}
+		fmt.Println("  SP-GiST Leaf Tuple (SpGistLeafTupleData):")
+		fmt.Printf("    tupstate     : %s\n", t.StateStr())
+		fmt.Printf("    size         : %d\n", t.Size)
+		fmt.Printf("    nextOffset   : %d", t.NextOffset())
+		if t.NextOffset() == InvalidOffsetNumber {
+			fmt.Print(" (end of chain)")
+		}
+		fmt.Println()
+		switch t.TupState {
+		case SPGistRedirect:
+			fmt.Printf("    pointer      : (%d, %d)  -> redirect target\n", t.HeapBlock, t.HeapOffset)
+		case SPGistLive:
+			fmt.Printf("    heapPtr      : (%d, %d)  -> heap ctid\n", t.HeapBlock, t.HeapOffset)
+			if bm := int(lp.Offset()) + 12; t.HasNulls() && bm+indexNullBitmapSize <= PageSize {
+				// bit 0 is the leaf datum, the rest INCLUDE columns
+				bitmap := p.Data[bm : bm+indexNullBitmapSize]
+				fmt.Printf("    %s\n", nullBitmapLine(bitmap, bitmapHighestSet(bitmap), "at least, from this bitmap"))
+			}
+			keyStart := int(lp.Offset()) + sgltHdrSize(t.HasNulls())
+			keyEnd := int(lp.Offset()) + int(lp.Length())
+			if keyEnd > keyStart {
+				fmt.Printf("    Leaf datum (%d bytes):\n", keyEnd-keyStart)
+				printHexBlock(p.Data[keyStart:keyEnd], keyStart, "      ")
+			}
+		}
+	}
+}
+
+// CmdSPGChain follows the chain of SP-GiST leaf tuples starting at the
+// given block and offset (an inner tuple's node downlink), following
+// redirects to other pages and stopping at the end of the chain. Blocks
+// are numbered across the relation, as in downlinks; those of other
+// segment files than filename end the walk.
+func CmdSPGChain(filename string, totalPages int64, block uint32, offnum int) {
+	fmt.Println()
+	fmt.Printf("=== SP-GiST Leaf Chain from (%d, %d) ===\n", block, offnum)
+
+	type tid struct {
+		block  uint32
+		offnum int
+	}
+	visited := map[tid]bool{}
+	base := absBlockNumber(filename, 0)
+	var page *Page
+	count, live := 0, 0
+
+	for offnum != InvalidOffsetNumber {
+		cur := tid{block, offnum}
+		if visited[cur] {
+			fmt.Printf("  LOOP: (%d, %d) already visited\n", block, offnum)
+			break
+		}
+		visited[cur] = true
+
+		if page == nil || absBlockNumber(filename, page.PageNum) != block {
+			if block < base || int64(block-base) >= totalPages {
+				fmt.Printf("  block %d is not in this file (blocks %d-%d)\n", block, base, int64(base)+totalPages-1)
+				break
+			}
+			pg, err := ReadPage(filename, int64(block-base))
+			if err != nil {
+				fmt.Printf("  error reading block %d: %v\n", block, err)
+				break
+			}
+			if !isSPGistLeaf(pg) {
+				fmt.Printf("  block %d is not an SP-GiST leaf page (type: %s)\n", block, pg.Detected)
+				break
+			}
+			page = pg
+		}
+
+		t, err := spgistLeafAt(page, offnum)
+		if err != nil {
+			fmt.Printf("  (%d, %d): %v\n", block, offnum, err)
+			break
+		}
+		count++
+		switch t.TupState {
+		case SPGistRedirect:
+			fmt.Printf("  (%d, %d) %-11s -> (%d, %d)\n", block, offnum, t.StateStr(), t.HeapBlock, t.HeapOffset)
+			block, offnum = t.HeapBlock, int(t.HeapOffset)
+			continue
+		case SPGistLive:
+			live++
+			fmt.Printf("  (%d, %d) %-11s heap (%d, %d)  next=%d\n",
+				block, offnum, t.StateStr(), t.HeapBlock, t.HeapOffset, t.NextOffset())
+		default:
+			fmt.Printf("  (%d, %d) %-11s next=%d\n", block, offnum, t.StateStr(), t.NextOffset())
+		}
+		offnum = int(t.NextOffset())
+	}
+
+	fmt.Println()
+	fmt.Printf("  Tuples visited: %d (live: %d)\n", count, live)
+	fmt.Println()
+}
+
+// SP-GiST inner tuples (SpGistInnerTupleData): a bitfield word holding
+// tupstate:2, allTheSame:1, nNodes:13 and prefixSize:16, then uint16 size.
+// The MAXALIGNed prefix datum follows the header, then nNodes node tuples:
+// IndexTupleData whose t_tid is the downlink and whose data is the label
+// (absent when INDEX_NULL_MASK is set).
+const (
+	SGITHdrSize = 8 // MAXALIGN(sizeof(SpGistInnerTupleData))
+	SGNTHdrSize = 8 // MAXALIGN(sizeof(IndexTupleData))
+)
+
+// SpGistInnerTuple mirrors the header of SpGistInnerTupleData.
+type SpGistInnerTuple struct {
+	TupState   uint8
+	AllTheSame bool
+	NNodes     int
+	PrefixSize int
+	Size       int
+}
+
+// SpGistNode is one node of an inner tuple.
+type SpGistNode struct {
+	Offset   int // page offset of the node tuple
+	Downlink HeapTID
+	Size     int
+	Label    []byte // nil for a null label
+}
+
+// isSPGistInner reports whether p is an SP-GiST inner page.
+func isSPGistInner(p *Page) bool {
+	special := p.SpecialData()
+	if p.Detected != PageTypeSPGiST || len(special) < SPGistOpaqueSize {
+		return false
+	}
+	return binary.LittleEndian.Uint16(special[0:2])&(SPGistLeaf|SPGistMeta|SPGistDeleted) == 0
+}
+
+// ParseSpGistInnerTuple decodes the inner tuple header at the given offset.
+func (p *Page) ParseSpGistInnerTuple(offset uint16) SpGistInnerTuple {
+	d := p.Data[offset:]
+	w := binary.LittleEndian.Uint32(d[0:4])
+	return SpGistInnerTuple{
+		TupState:   uint8(w & 0x03),
+		AllTheSame: w&0x04 != 0,
+		NNodes:     int(w>>3) & 0x1FFF,
+		PrefixSize: int(w >> 16),
+		Size:       int(binary.LittleEndian.Uint16(d[4:6])),
+	}
+}
+
+// spgistNodes decodes the nodes of the inner tuple t stored at off,
+// stopping at the first one that does not fit in the tuple.
+func spgistNodes(p *Page, off int, t SpGistInnerTuple) ([]SpGistNode, error) {
+	var nodes []SpGistNode
+	end := min(off+t.Size, PageSize)
+	pos := off + SGITHdrSize + t.PrefixSize
+	for i := 0; i < t.NNodes; i++ {
+		if pos+SGNTHdrSize > end {
+			return nodes, fmt.Errorf("node %d at offset %d runs past the tuple end %d", i+1, pos, end)
+		}
+		info := binary.LittleEndian.Uint16(p.Data[pos+6:])
+		n := SpGistNode{Offset: pos, Downlink: readTID(p.Data[pos:]), Size: int(info & IndexSizeMask)}
+		if n.Size < SGNTHdrSize || pos+n.Size > end {
+			return nodes, fmt.Errorf("node %d at offset %d has bad size %d", i+1, pos, n.Size)
+		}
+		if info&IndexNullMask == 0 {
+			n.Label = p.Data[pos+SGNTHdrSize : pos+n.Size]
+		}
+		nodes = append(nodes, n)
+		pos += n.Size // SGITITERATE
+	}
+	return nodes, nil
+}
+
+// spgistInnerTypes returns the prefix and label types the core SP-GiST
+// opclasses use for an index on a column of type col: text_ops keeps a
+// text prefix and int2 labels (the next byte), inet_ops a cidr prefix, and
+// the range and box quad trees a centroid of the column type without labels.
+func spgistInnerTypes(col string) (prefix, label string) {
+	switch col {
+	case "text":
+		return "text", "int2"
+	case "inet", "cidr":
+		return "cidr", ""
+	}
+	return col, ""
+}
+
+// spgistLabelString formats a node label: a text_ops byte label as a
+// character, or the value decoded as typ, or hex without a type.
+func spgistLabelString(label []byte, typ string) string {
+	if label == nil {
+		return "NULL"
+	}
+	if typ == "" {
+		return fmt.Sprintf("% x", label)
+	}
+	d, _, err := DecodeDatum(typ, label)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	if typ == "int2" {
+		switch c := d.Int; {
+		case c == -1:
+			return "-1 (end of string)"
+		case c > 0x20 && c < 0x7F:
+			return fmt.Sprintf("'%c'", rune(c))
+		case c >= 0 && c <= 0xFF:
+			return fmt.Sprintf("0x%02X", c)
+		}
+	}
+	return d.String()
+}
+
+// printSPGistInnerTuples prints the inner tuples of p with their prefix
+// and nodes. With a single-column schema the prefix and labels are
+// decoded with the types of the column's core opclass; otherwise they are
+// shown as hex.
+func printSPGistInnerTuples(p *Page, schema []string) {
+	var prefixType, labelType string
+	if len(schema) == 1 {
+		prefixType, labelType = spgistInnerTypes(schema[0])
+	}
+	for i, lp := range p.Items {
+		fmt.Printf("\n--- Item %d (offset %d, length %d) ---\n", i+1, lp.Offset(), lp.Length())
+		if lp.Flags() != LPNormal {
+			fmt.Printf("  [line pointer is %s]\n", lp.FlagsStr())
+			continue
+		}
+		off := int(lp.Offset())
+		if lp.Length() < SGITHdrSize || off+int(lp.Length()) > PageSize {
+			fmt.Printf("  [line pointer has bad length %d]\n", lp.Length())
+			continue
+		}
+		t := p.ParseSpGistInnerTuple(lp.Offset())
+		if t.TupState != SPGistLive {
+			// Redirect, dead and placeholder tuples share the leaf layout.
+			d := p.ParseSpGistLeafTuple(lp.Offset())
+			fmt.Printf("  SP-GiST %s tuple", d.StateStr())
+			if d.TupState == SPGistRedirect {
+				fmt.Printf(" -> (%d, %d)", d.HeapBlock, d.HeapOffset)
+			}
+			fmt.Println()
+			continue
+		}
+		fmt.Println("  SP-GiST Inner Tuple (SpGistInnerTupleData):")
+		fmt.Printf("    allTheSame   : %t\n", t.AllTheSame)
+		fmt.Printf("    nNodes       : %d\n", t.NNodes)
+		fmt.Printf("    prefixSize   : %d\n", t.PrefixSize)
+		fmt.Printf("    size         : %d\n", t.Size)
+		if t.PrefixSize > 0 {
+			start := off + SGITHdrSize
+			prefix := p.Data[start:min(start+t.PrefixSize, PageSize)]
+			if prefixType != "" {
+				if d, _, err := DecodeDatum(prefixType, prefix); err == nil {
+					fmt.Printf("    prefix       : %s\n", d)
+				} else {
+					fmt.Printf("    prefix       : <%v>\n", err)
+				}
+			} else {
+				fmt.Printf("    Prefix datum (%d bytes):\n", len(prefix))
+				printHexBlock(prefix, start, "      ")
+			}
+		}
+		nodes, err := spgistNodes(p, off, t)
+		for j, n := range nodes {
+			down := "-"
+			if n.Downlink.Block != InvalidBlock {
+				down = n.Downlink.String()
+			}
+			fmt.Printf("    node %-3d     : label %-20s downlink %s\n", j+1, spgistLabelString(n.Label, labelType), down)
+		}
+		if err != nil {
+			fmt.Printf("    [%v]\n", err)
+		}
+	}
+	fmt.Println()
+	fmt.Println("  Downlinks point at an inner tuple or the head of a leaf chain ('spgchain <block> <offset>').")
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CmdStats scans the whole file and prints aggregate page, line pointer
+// and free space statistics. For heap files with a visibility map, the VM
+// bits are merged with the pages' PD_ALL_VISIBLE flags.
+func CmdStats(filename string, totalPages int64, sr scanRange) {
+	types := map[string]int{}
+	normal, dead, unused, redirect := 0, 0, 0, 0
+	freeSpace, heapPages, allVisibleFlag, errors, skipped := 0, 0, 0, 0, 0
+
+	vm := findVisibilityMap(filename)
+	base := absBlockNumber(filename, 0)
+	vmVisible, vmFrozen, vmMismatch := 0, 0, 0
+
+	scanMeta(filename, totalPages, sr, func(i int64, m pageMeta, err error) {
+		if err != nil {
+			errors++
+			return
+		}
+		if sr.skipsLSN(m.LSN) {
+			skipped++
+			return
+		}
+		types[m.TypeLabel()]++
+		freeSpace += m.FreeSpace()
+		normal += int(m.Normal)
+		dead += int(m.Dead)
+		unused += int(m.Unused)
+		redirect += int(m.Redirect)
+
+		if m.PageType() != PageTypeHeap {
+			return
+		}
+		heapPages++
+		pdAllVisible := m.Flags&PDAllVisible != 0
+		if pdAllVisible {
+			allVisibleFlag++
+		}
+		if vm != nil {
+			v, f := vm.Status(base + uint32(i))
+			if v {
+				vmVisible++
+			}
+			if f {
+				vmFrozen++
+			}
+			// A set VM bit without PD_ALL_VISIBLE is corruption; the
+			// reverse is legal but means VACUUM has work to do.
+			if v && !pdAllVisible {
+				vmMismatch++
+			}
+		}
+	})
+
+	fmt.Println()
+	fmt.Println("=== File Statistics ===")
+	if sr.whole(totalPages) {
+		fmt.Printf("  Pages              : %d\n", totalPages)
+	} else {
+		fmt.Printf("  Pages              : %s\n", sr.String(totalPages))
+	}
+	if sr.SinceLSN != 0 {
+		fmt.Printf("  Skipped (older)    : %d\n", skipped)
+	}
+	if sc := loadSidecar(filename); sc != nil && !deterministic {
+		fmt.Printf("  Source             : sidecar index (%s)\n", sc.Path)
+	}
+	names := make([]string, 0, len(types))
+	for t := range types {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	for _, t := range names {
+		fmt.Printf("    %-16s : %d\n", t, types[t])
+	}
+	if errors > 0 {
+		fmt.Printf("    %-16s : %d\n", "unreadable", errors)
+	}
+	fmt.Printf("  Line pointers      : %d (NORMAL: %d, DEAD: %d, UNUSED: %d, REDIRECT: %d)\n",
+		normal+dead+unused+redirect, normal, dead, unused, redirect)
+	avg := 0
+	if first, end := sr.bounds(totalPages); end-first > int64(skipped) {
+		avg = freeSpace / int(end-first-int64(skipped))
+	}
+	fmt.Printf("  Free space         : %d bytes (avg %d per page)\n", freeSpace, avg)
+
+	if heapPages > 0 {
+		fmt.Printf("  PD_ALL_VISIBLE     : %d/%d heap pages\n", allVisibleFlag, heapPages)
+		if vm != nil {
+			fmt.Printf("  VM all-visible     : %d/%d heap pages\n", vmVisible, heapPages)
+			fmt.Printf("  VM all-frozen      : %d/%d heap pages\n", vmFrozen, heapPages)
+			fmt.Printf("  VM set, PD clear   : %d", vmMismatch)
+			if vmMismatch > 0 {
+				fmt.Print(" (INCONSISTENT: VM bit set without PD_ALL_VISIBLE)")
+			}
+			fmt.Println()
+			fmt.Printf("  Visibility map     : %s\n", shownPath(vm.Filename))
+		}
+	}
+	fmt.Println()
+}
+
+// CmdHintStats aggregates heap tuple hint bits across the file. Tuples
+// without xmin or xmax hints will have them set (and their page dirtied)
+// by the first reader, which is the I/O this report helps estimate.
+func CmdHintStats(filename string, totalPages int64, sr scanRange) {
+	tuples := 0
+	xminCommitted, xminInvalid, xminFrozen, xminNone := 0, 0, 0, 0
+	xmaxCommitted, xmaxInvalid, xmaxNone := 0, 0, 0
+	heapPages, dirtyPages := 0, 0
+
+	first, end := sr.bounds(totalPages)
+	for i := first; i < end; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil || pg.Detected != PageTypeHeap || sr.skips(pg) {
+			continue
+		}
+		heapPages++
+		needsHints := false
+		for _, lp := range pg.Items {
+			if lp.Flags() != LPNormal || lp.Length() < HeapTupleHdrSize ||
+				int(lp.Offset())+int(lp.Length()) > PageSize {
+				continue
+			}
+			t := pg.ParseHeapTupleHeader(lp.Offset())
+			tuples++
+			switch t.Infomask & HeapXminFrozen {
+			case HeapXminFrozen:
+				xminFrozen++
+			case HeapXminCommitted:
+				xminCommitted++
+			case HeapXminInvalid:
+				xminInvalid++
+			default:
+				xminNone++
+				needsHints = true
+			}
+			switch {
+			case t.Infomask&HeapXmaxCommitted != 0:
+				xmaxCommitted++
+			case t.Infomask&HeapXmaxInvalid != 0:
+				xmaxInvalid++
+			default:
+				xmaxNone++
+				needsHints = true
+			}
+		}
+		if needsHints {
+			dirtyPages++
+		}
+	}
+
+	pct := func(n int) float64 {
+		if tuples == 0 {
+			return 0
+		}
+		return float64(n) * 100 / float64(tuples)
+	}
+
+	fmt.Println()
+	fmt.Println("=== Hint Bit Statistics ===")
+	fmt.Printf("  Heap pages         : %d\n", heapPages)
+	fmt.Printf("  Tuples             : %d\n", tuples)
+	fmt.Println()
+	fmt.Printf("  XMIN_COMMITTED     : %-8d (%5.1f%%)\n", xminCommitted, pct(xminCommitted))
+	fmt.Printf("  XMIN_INVALID       : %-8d (%5.1f%%)\n", xminInvalid, pct(xminInvalid))
+	fmt.Printf("  XMIN_FROZEN        : %-8d (%5.1f%%)\n", xminFrozen, pct(xminFrozen))
+	fmt.Printf("  xmin not hinted    : %-8d (%5.1f%%)\n", xminNone, pct(xminNone))
+	fmt.Println()
+	fmt.Printf("  XMAX_COMMITTED     : %-8d (%5.1f%%)\n", xmaxCommitted, pct(xmaxCommitted))
+	fmt.Printf("  XMAX_INVALID       : %-8d (%5.1f%%)\n", xmaxInvalid, pct(xmaxInvalid))
+	fmt.Printf("  xmax not hinted    : %-8d (%5.1f%%)\n", xmaxNone, pct(xmaxNone))
+	fmt.Println()
+	fmt.Printf("  Pages needing hint writes: %d of %d (~%d KB to write back)\n",
+		dirtyPages, heapPages, dirtyPages*PageSize/1024)
+	fmt.Println()
+}
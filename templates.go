@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// specialTemplate is a user-defined special-area layout, for pages of
+// index access methods pgpageshell does not know (bloom, rum, ...). It is
+// defined in the config file:
+//
+//	special.<name>.size        = <bytes>
+//	special.<name>.page_id     = <offset> <type> <value>
+//	special.<name>.field.<fld> = <offset> <type>
+//
+// and may describe the tuple header of a table access method too:
+//
+//	special.<name>.tuple.<fld> = <offset> <type>
+//	special.<name>.tuple_size  = <bytes>
+//
+// A template with a page_id matches pages whose special area holds that
+// value at that offset (and has the given size, if one is set); one with
+// only a size matches pages of that special size no built-in layout
+// claims.
+type specialTemplate struct {
+	Name   string
+	Size   int
+	ID     *templateField
+	IDVal  uint64
+	Fields []templateField
+
+	// Tuple lays out the header of each tuple, TupleSize bytes long
+	// (the end of the last field if 0).
+	Tuple     []templateField
+	TupleSize int
+}
+
+// templateField is one field of a special template.
+type templateField struct {
+	Name   string
+	Offset int
+	Type   string
+	Size   int
+}
+
+// templateFieldSizes are the scalar field types and their sizes; bytes:N
+// is the only other type.
+var templateFieldSizes = map[string]int{
+	"u8": 1, "u16": 2, "u32": 4, "u64": 8,
+	"i16": 2, "i32": 4, "i64": 8, "lsn": 8,
+}
+
+// specialTemplates are the templates of the loaded config, by name.
+var specialTemplates []*specialTemplate
+
+// templateOverride names the template every page is decoded with while set
+// ("settype <template>"); table access methods whose pages carry no
+// special area cannot be matched otherwise.
+var templateOverride string
+
+// templateNamed returns the loaded template called name, or nil.
+func templateNamed(name string) *specialTemplate {
+	for _, t := range specialTemplates {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// parseTemplateField parses "<offset> <type>" for field name.
+func parseTemplateField(name string, words []string) (templateField, error) {
+	if len(words) != 2 {
+		return templateField{}, fmt.Errorf("expected <offset> <type>")
+	}
+	off, err := strconv.Atoi(words[0])
+	if err != nil || off < 0 {
+		return templateField{}, fmt.Errorf("invalid offset %q", words[0])
+	}
+	f := templateField{Name: name, Offset: off, Type: words[1]}
+	if n, ok := strings.CutPrefix(f.Type, "bytes:"); ok {
+		if f.Size, err = strconv.Atoi(n); err != nil || f.Size <= 0 {
+			return templateField{}, fmt.Errorf("invalid type %q", f.Type)
+		}
+		return f, nil
+	}
+	if f.Size = templateFieldSizes[f.Type]; f.Size == 0 {
+		return templateField{}, fmt.Errorf("invalid type %q (u8, u16, u32, u64, i16, i32, i64, lsn, bytes:N)", f.Type)
+	}
+	return f, nil
+}
+
+func (f templateField) String() string { return fmt.Sprintf("%d %s", f.Offset, f.Type) }
+
+// raw reads an integer field from special; ok is false if the field does
+// not fit.
+func (f templateField) raw(special []byte) (uint64, bool) {
+	if f.Offset+f.Size > len(special) {
+		return 0, false
+	}
+	b := special[f.Offset : f.Offset+f.Size]
+	le := binary.LittleEndian
+	switch f.Size {
+	case 1:
+		return uint64(b[0]), true
+	case 2:
+		return uint64(le.Uint16(b)), true
+	case 4:
+		return uint64(le.Uint32(b)), true
+	case 8:
+		return le.Uint64(b), true
+	}
+	return 0, false
+}
+
+// Value formats the field as found in special (or a tuple).
+func (f templateField) Value(special []byte) string {
+	if f.Offset+f.Size > len(special) {
+		return "(out of range)"
+	}
+	if strings.HasPrefix(f.Type, "bytes:") {
+		return fmt.Sprintf("% x", special[f.Offset:f.Offset+f.Size])
+	}
+	v, _ := f.raw(special)
+	switch f.Type {
+	case "i16":
+		return fmt.Sprint(int16(v))
+	case "i32":
+		return fmt.Sprint(int32(v))
+	case "i64":
+		return fmt.Sprint(int64(v))
+	case "lsn":
+		return lsnStr(v)
+	}
+	return fmt.Sprintf("%d (0x%0*X)", v, f.Size*2, v)
+}
+
+// setTemplate assigns the part of a special template named by key (the
+// config key without "special.").
+func (c *Config) setTemplate(key, value string) error {
+	name, part, ok := strings.Cut(key, ".")
+	if !ok || name == "" {
+		return fmt.Errorf("invalid key special.%s (special.<name>.size, .page_id, .field.<field>, .tuple.<field> or .tuple_size)", key)
+	}
+	t := c.Templates[name]
+	if t == nil {
+		t = &specialTemplate{Name: name}
+	}
+	words := strings.Fields(value)
+	switch {
+	case part == "size":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > PageSize-PageHeaderSize {
+			return fmt.Errorf("invalid size %q", value)
+		}
+		t.Size = n
+	case part == "page_id":
+		if len(words) != 3 {
+			return fmt.Errorf("special.%s: expected <offset> <type> <value>", key)
+		}
+		f, err := parseTemplateField("page_id", words[:2])
+		if err == nil && (strings.HasPrefix(f.Type, "bytes:") || f.Type == "lsn") {
+			err = fmt.Errorf("page_id must be an integer type")
+		}
+		if err != nil {
+			return fmt.Errorf("special.%s: %w", key, err)
+		}
+		v, err := strconv.ParseUint(words[2], 0, f.Size*8)
+		if err != nil {
+			return fmt.Errorf("special.%s: invalid value %q", key, words[2])
+		}
+		t.ID, t.IDVal = &f, v
+	case part == "tuple_size":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > PageSize {
+			return fmt.Errorf("invalid tuple_size %q", value)
+		}
+		t.TupleSize = n
+	case strings.HasPrefix(part, "field."), strings.HasPrefix(part, "tuple."):
+		kind, fname, _ := strings.Cut(part, ".")
+		if fname == "" {
+			return fmt.Errorf("special.%s: missing field name", key)
+		}
+		f, err := parseTemplateField(fname, words)
+		if err != nil {
+			return fmt.Errorf("special.%s: %w", key, err)
+		}
+		fields := &t.Fields
+		if kind == "tuple" {
+			fields = &t.Tuple
+		}
+		replaced := false
+		for i := range *fields {
+			if (*fields)[i].Name == fname {
+				(*fields)[i], replaced = f, true
+			}
+		}
+		if !replaced {
+			*fields = append(*fields, f)
+		}
+	default:
+		return fmt.Errorf("invalid key special.%s (special.<name>.size, .page_id, .field.<field>, .tuple.<field> or .tuple_size)", key)
+	}
+	if c.Templates == nil {
+		c.Templates = map[string]*specialTemplate{}
+	}
+	c.Templates[name] = t
+	return nil
+}
+
+// templateSettings lists the templates as key/value pairs for Settings.
+func (c *Config) templateSettings() [][2]string {
+	var settings [][2]string
+	for _, t := range c.templateList() {
+		prefix := "special." + t.Name + "."
+		if t.Size > 0 {
+			settings = append(settings, [2]string{prefix + "size", strconv.Itoa(t.Size)})
+		}
+		if t.ID != nil {
+			settings = append(settings, [2]string{prefix + "page_id", fmt.Sprintf("%s 0x%X", t.ID, t.IDVal)})
+		}
+		for _, f := range t.Fields {
+			settings = append(settings, [2]string{prefix + "field." + f.Name, f.String()})
+		}
+		if t.TupleSize > 0 {
+			settings = append(settings, [2]string{prefix + "tuple_size", strconv.Itoa(t.TupleSize)})
+		}
+		for _, f := range t.Tuple {
+			settings = append(settings, [2]string{prefix + "tuple." + f.Name, f.String()})
+		}
+	}
+	return settings
+}
+
+// templateList returns the templates sorted by name.
+func (c *Config) templateList() []*specialTemplate {
+	list := make([]*specialTemplate, 0, len(c.Templates))
+	for _, t := range c.Templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// matches reports whether the template describes the special area of p.
+func (t *specialTemplate) matches(p *Page, special []byte) bool {
+	if t.Size > 0 && len(special) != t.Size {
+		return false
+	}
+	if t.ID == nil {
+		// A size alone says little; leave pages a built-in layout claims.
+		return t.Size > 0 && p.Detected == PageTypeUnknown
+	}
+	v, ok := t.ID.raw(special)
+	if !ok || v != t.IDVal {
+		return false
+	}
+	// A page id is as good as the built-in checks, except the strong ones.
+	return p.AutoDetected == PageTypeUnknown || p.DetectionConfidence() != "high"
+}
+
+// matchTemplate returns the first template (by name) matching p; those
+// with a page id are tried first. A forced template applies to every page
+// whose special area has its size.
+func matchTemplate(p *Page) *specialTemplate {
+	special := p.SpecialData()
+	if templateOverride != "" {
+		if t := templateNamed(templateOverride); t != nil && (t.Size == 0 || len(special) == t.Size) {
+			return t
+		}
+		return nil
+	}
+	if len(specialTemplates) == 0 || len(special) == 0 {
+		return nil
+	}
+	for _, withID := range []bool{true, false} {
+		for _, t := range specialTemplates {
+			if (t.ID != nil) == withID && t.matches(p, special) {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// spans lays out the template's fields for where.
+func (t *specialTemplate) spans() []fieldSpan {
+	var spans []fieldSpan
+	if t.ID != nil {
+		spans = append(spans, fieldSpan{t.ID.Name, t.ID.Offset, t.ID.Offset + t.ID.Size})
+	}
+	for _, f := range t.Fields {
+		spans = append(spans, fieldSpan{f.Name, f.Offset, f.Offset + f.Size})
+	}
+	return spans
+}
+
+// DecodeTemplateSpecial prints the special area of p through its
+// template.
+func DecodeTemplateSpecial(p *Page) {
+	t, special := p.Template, p.SpecialData()
+	fmt.Printf("  Template %q (config):\n", t.Name)
+	width := len("page_id")
+	for _, f := range t.Fields {
+		width = max(width, len(f.Name))
+	}
+	if t.ID != nil {
+		fmt.Printf("    %-*s : %s\n", width, "page_id", t.ID.Value(special))
+	}
+	for _, f := range t.Fields {
+		fmt.Printf("    %-*s : %s\n", width, f.Name, f.Value(special))
+	}
+}
+
+// tupleHeaderSize is the length of the template's tuple header.
+func (t *specialTemplate) tupleHeaderSize() int {
+	if t.TupleSize > 0 {
+		return t.TupleSize
+	}
+	n := 0
+	for _, f := range t.Tuple {
+		n = max(n, f.Offset+f.Size)
+	}
+	return n
+}
+
+// printTemplateTuples prints the tuples of p with the tuple header layout
+// of its template, followed by the rest of each tuple in hex.
+func printTemplateTuples(p *Page) {
+	t := p.Template
+	fmt.Println()
+	fmt.Printf("=== Tuples (template %q) ===\n", t.Name)
+	width := 0
+	for _, f := range t.Tuple {
+		width = max(width, len(f.Name))
+	}
+	for i, lp := range p.Items {
+		fmt.Printf("\n--- Tuple %d (offset %d, length %d) ---\n", i+1, lp.Offset(), lp.Length())
+		switch {
+		case lp.Flags() == LPUnused:
+			fmt.Println("  [UNUSED - no data]")
+			continue
+		case lp.Flags() == LPRedirect:
+			fmt.Printf("  [REDIRECT -> line pointer %d]\n", lp.Offset())
+			continue
+		case lp.Length() == 0 || lp.Offset() == 0:
+			if lp.Flags() == LPDead {
+				fmt.Println("  [DEAD - no storage]")
+			} else {
+				fmt.Println("  [no storage]")
+			}
+			continue
+		case int(lp.Offset())+int(lp.Length()) > PageSize:
+			fmt.Println("  [ERROR: tuple extends beyond page]")
+			continue
+		case lp.Flags() == LPDead:
+			fmt.Println("  [DEAD - has storage]")
+		}
+		start := int(lp.Offset())
+		tuple := p.Data[start : start+int(lp.Length())]
+		fmt.Println("  Tuple Header:")
+		for _, f := range t.Tuple {
+			fmt.Printf("    %-*s : %s\n", width, f.Name, f.Value(tuple))
+		}
+		if hdr := t.tupleHeaderSize(); hdr < len(tuple) {
+			fmt.Printf("    Data (%d bytes at offset %d):\n", len(tuple)-hdr, start+hdr)
+			printHexBlock(tuple[hdr:], start+hdr, "      ")
+		}
+	}
+}
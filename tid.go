@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTIDArg parses what the tid command accepts: a ctid written
+// "(block,offset)" or "block,offset", 12 hex digits of an ItemPointerData
+// as it appears in a page dump (spaces and a 0x prefix allowed), or a bare
+// block number. hasOffset is false for a block number.
+func parseTIDArg(s string) (tid HeapTID, hasOffset bool, err error) {
+	s = strings.TrimSpace(s)
+	if b, o, ok := strings.Cut(strings.Trim(s, "()"), ","); ok {
+		blk, err1 := strconv.ParseUint(strings.TrimSpace(b), 10, 32)
+		off, err2 := strconv.ParseUint(strings.TrimSpace(o), 10, 16)
+		if err1 != nil || err2 != nil {
+			return HeapTID{}, false, fmt.Errorf("invalid ctid %q", s)
+		}
+		return HeapTID{uint32(blk), uint16(off)}, true, nil
+	}
+	digits := strings.ReplaceAll(strings.TrimPrefix(strings.ToLower(s), "0x"), " ", "")
+	if len(digits) == 2*ItemPointerSz {
+		raw, err := hex.DecodeString(digits)
+		if err != nil {
+			return HeapTID{}, false, fmt.Errorf("invalid ItemPointerData hex %q", s)
+		}
+		return readTID(raw), true, nil
+	}
+	blk, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return HeapTID{}, false, fmt.Errorf("%q is not a ctid, 6-byte ItemPointerData hex or block number", s)
+	}
+	return HeapTID{Block: uint32(blk)}, false, nil
+}
+
+// itemPointerBytes encodes t as ItemPointerData: bi_hi, bi_lo and
+// ip_posid, each a little-endian uint16.
+func itemPointerBytes(t HeapTID) []byte {
+	b := make([]byte, ItemPointerSz)
+	binary.LittleEndian.PutUint16(b[0:2], uint16(t.Block>>16))
+	binary.LittleEndian.PutUint16(b[2:4], uint16(t.Block))
+	binary.LittleEndian.PutUint16(b[4:6], t.Offset)
+	return b
+}
+
+// CmdTID converts between a ctid and its ItemPointerData bytes and shows
+// which 1 GB segment file, page and byte offset the block lives at. With
+// filename (any segment of the relation fork) the segment file is named.
+func CmdTID(arg, filename string) error {
+	t, hasOffset, err := parseTIDArg(arg)
+	if err != nil {
+		return err
+	}
+	seg, page := int64(t.Block)/RelSegSize, int64(t.Block)%RelSegSize
+
+	fmt.Println()
+	if hasOffset {
+		fmt.Printf("=== TID %s ===\n", t)
+		fmt.Printf("  ItemPointerData    : % x (bi_hi %d, bi_lo %d, ip_posid %d)\n",
+			itemPointerBytes(t), t.Block>>16, t.Block&0xFFFF, t.Offset)
+		fmt.Printf("  ctid               : '(%d,%d)'\n", t.Block, t.Offset)
+	} else {
+		fmt.Printf("=== Block %d ===\n", t.Block)
+	}
+	fmt.Printf("  Segment            : %d (%d blocks per 1 GB segment)\n", seg, RelSegSize)
+	fmt.Printf("  Page in segment    : %d, byte offset %d\n", page, page*PageSize)
+	if filename != "" {
+		if _, fork, _, ok := parseRelFileName(filename); ok {
+			path := relForkPath(filename, fork)
+			if seg > 0 {
+				path += "." + strconv.FormatInt(seg, 10)
+			}
+			fmt.Printf("  Segment file       : %s", shownPath(path))
+			if relSegment(filename) == int(seg) {
+				fmt.Print(" (this file)")
+			}
+			fmt.Println()
+		}
+	}
+	fmt.Println()
+	return nil
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// VARTAG_ONDISK: a varattrib_1b_e header (0x01, tag) followed by an
+	// unaligned varatt_external.
+	VarTagOnDisk       = 18
+	toastPointerSize   = 2 + 16
+	toastExtSizeMask14 = 0x3FFFFFFF // va_extinfo holds the compression method above
+)
+
+// ToastPointer is a decoded varatt_external: where a toasted value lives
+// and how big it is.
+type ToastPointer struct {
+	RawSize  int32 // original size including the varlena header
+	ExtSize  uint32
+	ValueID  uint32
+	ToastRel uint32
+	TID      HeapTID
+	Column   int // 1-based, 0 when found without a schema
+}
+
+func parseToastPointer(d []byte) (ToastPointer, bool) {
+	if len(d) < toastPointerSize || d[0] != 0x01 || d[1] != VarTagOnDisk {
+		return ToastPointer{}, false
+	}
+	le := binary.LittleEndian
+	tp := ToastPointer{
+		RawSize:  int32(le.Uint32(d[2:6])),
+		ExtSize:  le.Uint32(d[6:10]),
+		ValueID:  le.Uint32(d[10:14]),
+		ToastRel: le.Uint32(d[14:18]),
+	}
+	if pgVersion >= 14 {
+		tp.ExtSize &= toastExtSizeMask14
+	}
+	// stored size is at most the raw payload, and nonzero oids
+	ok := tp.RawSize > 4 && tp.ExtSize > 0 && int64(tp.ExtSize) <= int64(tp.RawSize)-4 &&
+		tp.ValueID != 0 && tp.ToastRel != 0
+	return tp, ok
+}
+
+// varlenaSize returns the stored size of the varlena at the start of d,
+// whatever its form: short, 4-byte (possibly compressed) or a TOAST
+// pointer.
+func varlenaSize(d []byte) (int, error) {
+	if len(d) == 0 {
+		return 0, fmt.Errorf("empty varlena")
+	}
+	switch b := d[0]; {
+	case b == 0x01:
+		if len(d) < 2 {
+			return 0, fmt.Errorf("truncated external varlena")
+		}
+		if d[1] != VarTagOnDisk {
+			return 0, fmt.Errorf("external varlena with in-memory tag %d", d[1])
+		}
+		return toastPointerSize, nil
+	case b&0x01 == 0x01:
+		return int(b >> 1), nil
+	}
+	if len(d) < 4 {
+		return 0, fmt.Errorf("truncated varlena header")
+	}
+	return int(binary.LittleEndian.Uint32(d) >> 2), nil
+}
+
+// heapToastPointers returns the TOAST pointers of the heap tuple at lp.
+// With a schema the columns are walked in order, so each pointer is tied
+// to its column; without one the tuple data is searched for the pointer
+// byte pattern, which may miss pointers or, rarely, see one in user data.
+func heapToastPointers(p *Page, lp ItemId, t HeapTupleHeader, schema []string) ([]ToastPointer, error) {
+	start, end := int(lp.Offset()), int(lp.Offset())+int(lp.Length())
+	if start+int(t.Hoff) > end || end > PageSize {
+		return nil, fmt.Errorf("bad tuple bounds")
+	}
+	data := p.Data[start+int(t.Hoff) : end]
+	var out []ToastPointer
+	if len(schema) == 0 {
+		for off := 0; off+toastPointerSize <= len(data); off++ {
+			if tp, ok := parseToastPointer(data[off:]); ok {
+				out = append(out, tp)
+				off += toastPointerSize - 1
+			}
+		}
+		return out, nil
+	}
+	off := 0
+	for i := 0; i < min(len(schema), t.NAttrs()); i++ {
+		if t.Infomask&HeapHasNull != 0 && p.Data[start+HeapTupleHdrSize+i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		typ := schema[i]
+		if !isVarlenaType(typ) {
+			var err error
+			if _, off, err = DecodeDatumAt(typ, data, off); err != nil {
+				return out, fmt.Errorf("column %d: %w", i+1, err)
+			}
+			continue
+		}
+		if off < len(data) && data[off] == 0 {
+			off = (off + 3) &^ 3 // 4-byte header varlenas are int-aligned
+		}
+		if off >= len(data) {
+			return out, fmt.Errorf("column %d: offset %d beyond data (%d bytes)", i+1, off, len(data))
+		}
+		n, err := varlenaSize(data[off:])
+		if err != nil || n < 1 || off+n > len(data) {
+			return out, fmt.Errorf("column %d: bad varlena at offset %d", i+1, off)
+		}
+		if tp, ok := parseToastPointer(data[off:]); ok {
+			tp.Column = i + 1
+			out = append(out, tp)
+		}
+		off += n
+	}
+	return out, nil
+}
+
+// toastValue is what the TOAST file holds for one chunk_id.
+type toastValue struct {
+	Chunks  int
+	Bytes   int64
+	Live    bool // some chunk not known dead
+	Claimed bool // referenced by a heap tuple
+}
+
+// toastChunks reads every chunk of a TOAST table file, keyed by chunk_id.
+func toastChunks(toastFile string) (map[uint32]*toastValue, int, error) {
+	total, err := countPages(toastFile)
+	if err != nil {
+		return nil, 0, err
+	}
+	le := binary.LittleEndian
+	values := map[uint32]*toastValue{}
+	bad := 0
+	for i := int64(0); i < total; i++ {
+		pg, err := ReadPage(toastFile, i)
+		if err != nil || pg.Detected != PageTypeHeap {
+			continue
+		}
+		for _, lp := range pg.Items {
+			if lp.Flags() != LPNormal || lp.Length() < HeapTupleHdrSize || int(lp.Offset())+int(lp.Length()) > PageSize {
+				continue
+			}
+			t := pg.ParseHeapTupleHeader(lp.Offset())
+			start, end := int(lp.Offset())+int(t.Hoff), int(lp.Offset())+int(lp.Length())
+			if start+9 > end {
+				bad++
+				continue
+			}
+			d := pg.Data[start:end]
+			id := le.Uint32(d[0:4])
+			off := 8
+			n, err := varlenaSize(d[off:])
+			hdr := 1
+			if d[off]&0x01 == 0 {
+				hdr = 4
+			}
+			if err != nil || n < hdr || off+n > len(d) {
+				bad++
+				continue
+			}
+			v := values[id]
+			if v == nil {
+				v = &toastValue{}
+				values[id] = v
+			}
+			v.Chunks++
+			v.Bytes += int64(n - hdr)
+			v.Live = v.Live || heapTupleLive(t)
+		}
+	}
+	return values, bad, nil
+}
+
+// CmdToastReport reports how a heap file uses its TOAST table: external
+// values and their stored and raw sizes (per column with a schema),
+// pointers whose value is missing from the TOAST file or whose chunks do
+// not add up to the stored size, and TOAST values no heap tuple points to.
+func CmdToastReport(heapFile string, totalPages int64, toastFile string, schema []string) {
+	fmt.Println()
+	fmt.Printf("=== TOAST Report (heap: %s, toast: %s) ===\n", shownPath(heapFile), shownPath(toastFile))
+	values, badChunks, err := toastChunks(toastFile)
+	if err != nil {
+		fmt.Printf("  Error: %v\n\n", err)
+		return
+	}
+
+	type colStats struct {
+		Values         int
+		Stored, RawSum int64
+	}
+	cols := map[int]*colStats{}
+	var pointers []ToastPointer
+	tuples, withExternal, undecodable := 0, 0, 0
+	relids := map[uint32]int{}
+	for i := int64(0); i < totalPages; i++ {
+		pg, err := ReadPage(heapFile, i)
+		if err != nil || pg.Detected != PageTypeHeap {
+			continue
+		}
+		for n, lp := range pg.Items {
+			if lp.Flags() != LPNormal || lp.Length() < HeapTupleHdrSize || int(lp.Offset())+int(lp.Length()) > PageSize {
+				continue
+			}
+			tuples++
+			t := pg.ParseHeapTupleHeader(lp.Offset())
+			if t.Infomask&HeapHasExternal == 0 {
+				continue
+			}
+			withExternal++
+			tps, err := heapToastPointers(pg, lp, t, schema)
+			if err != nil {
+				undecodable++
+			}
+			for _, tp := range tps {
+				tp.TID = HeapTID{Block: uint32(i), Offset: uint16(n + 1)}
+				pointers = append(pointers, tp)
+				relids[tp.ToastRel]++
+				c := cols[tp.Column]
+				if c == nil {
+					c = &colStats{}
+					cols[tp.Column] = c
+				}
+				c.Values++
+				c.Stored += int64(tp.ExtSize)
+				c.RawSum += int64(tp.RawSize) - 4
+			}
+		}
+	}
+
+	if len(schema) > 0 && len(cols) > 0 {
+		fmt.Printf("  %-8s %-12s %10s %14s %14s\n", "Column", "Type", "External", "Stored bytes", "Raw bytes")
+		keys := make([]int, 0, len(cols))
+		for k := range cols {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			c := cols[k]
+			fmt.Printf("  %-8d %-12s %10d %14d %14d\n", k, schema[k-1], c.Values, c.Stored, c.RawSum)
+		}
+		fmt.Println()
+	}
+
+	var missing, mismatched []string
+	var stored, raw int64
+	for _, tp := range pointers {
+		stored += int64(tp.ExtSize)
+		raw += int64(tp.RawSize) - 4
+		v := values[tp.ValueID]
+		where := fmt.Sprintf("%s value %d", tp.TID, tp.ValueID)
+		if tp.Column > 0 {
+			where = fmt.Sprintf("%s column %d value %d", tp.TID, tp.Column, tp.ValueID)
+		}
+		switch {
+		case v == nil:
+			missing = append(missing, where)
+		case v.Bytes != int64(tp.ExtSize):
+			mismatched = append(mismatched, fmt.Sprintf("%s: %d bytes in %d chunks, pointer says %d", where, v.Bytes, v.Chunks, tp.ExtSize))
+		}
+		if v != nil {
+			v.Claimed = true
+		}
+	}
+	var orphans []uint32
+	var orphanBytes int64
+	for id, v := range values {
+		if !v.Claimed && v.Live {
+			orphans = append(orphans, id)
+			orphanBytes += v.Bytes
+		}
+	}
+	slices.Sort(orphans)
+
+	chunks, toastBytes := 0, int64(0)
+	for _, v := range values {
+		chunks += v.Chunks
+		toastBytes += v.Bytes
+	}
+	fmt.Printf("  %-18s : %d\n", "Heap tuples", tuples)
+	fmt.Printf("  %-18s : %d\n", "With TOAST ptrs", withExternal)
+	if undecodable > 0 {
+		fmt.Printf("  %-18s : %d (schema does not match)\n", "Undecodable", undecodable)
+	}
+	fmt.Printf("  %-18s : %d\n", "External values", len(pointers))
+	fmt.Printf("  %-18s : %d bytes stored, %d raw\n", "External size", stored, raw)
+	if len(relids) > 1 {
+		var ids []string
+		for id, n := range relids {
+			ids = append(ids, fmt.Sprintf("%d (%d)", id, n))
+		}
+		slices.Sort(ids)
+		fmt.Printf("  %-18s : %s\n", "va_toastrelid", strings.Join(ids, ", "))
+	}
+	fmt.Printf("  %-18s : %d values, %d chunks, %d bytes\n", "TOAST file", len(values), chunks, toastBytes)
+	if badChunks > 0 {
+		fmt.Printf("  %-18s : %d\n", "Bad chunks", badChunks)
+	}
+	printToastList("Missing values", missing, "pointers whose value has no chunks")
+	printToastList("Size mismatches", mismatched, "chunks do not add up to va_extsize")
+	var orphanList []string
+	for _, id := range orphans {
+		orphanList = append(orphanList, fmt.Sprintf("value %d: %d chunks, %d bytes", id, values[id].Chunks, values[id].Bytes))
+	}
+	printToastList("Orphaned values", orphanList, fmt.Sprintf("%d bytes of live chunks no heap tuple points to", orphanBytes))
+	if len(schema) == 0 {
+		fmt.Println("  (no schema: TOAST pointers found by byte pattern; set one for per-column totals)")
+	}
+	fmt.Println()
+}
+
+// printToastList prints a count line and the first few entries.
+func printToastList(label string, items []string, what string) {
+	const show = 10
+	fmt.Printf("  %-18s : %d", label, len(items))
+	if len(items) > 0 {
+		fmt.Printf(" (%s)", what)
+	}
+	fmt.Println()
+	for i, s := range items {
+		if i == show {
+			fmt.Printf("    ... %d more\n", len(items)-show)
+			break
+		}
+		fmt.Printf("    %s\n", s)
+	}
+}
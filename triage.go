@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Triage categories, in report order.
+const (
+	triageChecksum   = "checksum failure"
+	triageZeroed     = "zeroed"
+	triageTorn       = "torn"
+	triageHeader     = "bad header bounds"
+	triageItems      = "invalid item pointers"
+	triageUnknown    = "unknown special"
+	triageEncrypted  = "encrypted?"
+	triageUnstable   = "unstable (live)"
+	triageSectorSize = 512
+)
+
+var triageCategories = []string{triageChecksum, triageZeroed, triageTorn, triageHeader, triageItems, triageUnknown, triageEncrypted, triageUnstable}
+
+// isZeroPage reports whether every byte of the page is zero.
+func isZeroPage(p *Page) bool {
+	for _, b := range p.Data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// zeroSectors returns the 512-byte sectors of the tuple area that are
+// entirely zero. Only whole sectors past pd_lower and pd_upper count: a
+// sector that takes in free space may be zero up to the first tuple on a
+// healthy page. Tuple headers are never all zero, so on an initialized
+// page these point at a partially written (torn) page.
+func zeroSectors(p *Page) []int {
+	upper := max(int(p.Header.Upper), int(p.Header.Lower))
+	if int(p.Header.Upper) < PageHeaderSize || upper >= PageSize {
+		return nil
+	}
+	var zero []int
+	for s := (upper + triageSectorSize - 1) / triageSectorSize; s < PageSize/triageSectorSize; s++ {
+		allZero := true
+		for _, b := range p.Data[s*triageSectorSize : (s+1)*triageSectorSize] {
+			if b != 0 {
+				allZero = false
+				break
+			}
+		}
+		if allZero {
+			zero = append(zero, s)
+		}
+	}
+	return zero
+}
+
+// triagePage returns the categories a page falls into, with one detail line
+// per category.
+func triagePage(p *Page) map[string]string {
+	cats := map[string]string{}
+	if p.Unstable {
+		// A page caught mid-write says nothing about what is on disk.
+		cats[triageUnstable] = fmt.Sprintf("still changing after %d reads", p.ReadAttempts)
+		return cats
+	}
+	if isZeroPage(p) {
+		cats[triageZeroed] = "all 8192 bytes are zero"
+		return cats
+	}
+	if p.Encrypted {
+		// Whatever the random bytes decode as is noise.
+		cats[triageEncrypted] = "header does not parse and the bytes look random (TDE?)"
+		return cats
+	}
+	if isNewPage(p) {
+		cats[triageZeroed] = "pd_upper is 0 but the page has non-zero bytes"
+	}
+	if pr := p.ChecksumProblem(); pr != "" {
+		cats[triageChecksum] = pr
+	}
+	if zs := zeroSectors(p); len(zs) > 0 {
+		s := make([]string, len(zs))
+		for i, z := range zs {
+			s[i] = fmt.Sprint(z)
+		}
+		cats[triageTorn] = fmt.Sprintf("zero sector(s) %s in the tuple area", strings.Join(s, ","))
+	}
+	if hdr := p.Anomalies(AnnotationHeader, AnnotationFlags); len(hdr) > 0 {
+		cats[triageHeader] = strings.Join(hdr, "; ")
+	}
+	if items := p.Anomalies(AnnotationItem); len(items) > 0 {
+		cats[triageItems] = fmt.Sprintf("%d bad line pointer(s), first: %s", len(items), items[0])
+	}
+	if p.SpecialSize() > 0 && p.Detected == PageTypeUnknown {
+		cats[triageUnknown] = fmt.Sprintf("%d-byte special area matches no known layout", p.SpecialSize())
+	}
+	return cats
+}
+
+// triagePreviewPages is how many page numbers per category the summary
+// lists; only those are kept so huge files do not pile up page lists.
+const triagePreviewPages = 10
+
+// CmdTriage scans the whole file and groups problematic pages by category.
+// Each problem page is shown with its byte entropy in bits per byte:
+// near 8 for random bytes (encrypted or overwritten), lower for a damaged
+// page that still has structure.
+func CmdTriage(filename string, totalPages int64, sr scanRange) {
+	fmt.Println()
+	fmt.Printf("=== Triage (%s) ===\n", sr.String(totalPages))
+
+	counts := map[string]int{}
+	pages := map[string][]int64{}
+	problem, skipped := 0, int64(0)
+	first, end := sr.bounds(totalPages)
+	for i := first; i < end; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil {
+			fmt.Printf("  page %d: %v\n", i, err)
+			problem++
+			continue
+		}
+		if sr.skips(pg) {
+			skipped++
+			continue
+		}
+		cats := triagePage(pg)
+		if len(cats) == 0 {
+			continue
+		}
+		problem++
+		entropy := pg.Entropy()
+		for _, c := range triageCategories {
+			if d, ok := cats[c]; ok {
+				counts[c]++
+				if len(pages[c]) < triagePreviewPages {
+					pages[c] = append(pages[c], i)
+				}
+				fmt.Printf("  page %-6d %-22s %s (entropy %.2f)\n", i, c, d, entropy)
+			}
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("  %-22s %6s  %s\n", "Category", "Pages", "First pages")
+	fmt.Printf("  %-22s %6s  %s\n", "--------", "-----", "-----------")
+	for _, c := range triageCategories {
+		if c == triageUnstable && !liveReads {
+			continue
+		}
+		fmt.Printf("  %-22s %6d  %s\n", c, counts[c], pageListPreview(pages[c], counts[c]))
+	}
+	fmt.Println()
+	fmt.Printf("  Problem pages      : %d of %d\n", problem, end-first-skipped)
+	if sr.SinceLSN != 0 {
+		fmt.Printf("  Skipped (older)    : %d\n", skipped)
+	}
+	fmt.Println()
+}
+
+// pageListPreview formats the first page numbers of a category of total
+// pages, eliding the rest.
+func pageListPreview(pages []int64, total int) string {
+	if len(pages) == 0 {
+		return "-"
+	}
+	s := make([]string, 0, len(pages)+1)
+	for _, n := range pages {
+		s = append(s, fmt.Sprint(n))
+	}
+	if total > len(pages) {
+		s = append(s, fmt.Sprintf("... (+%d)", total-len(pages)))
+	}
+	return strings.Join(s, ", ")
+}
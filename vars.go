@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Shell variables are assigned with "set <name> <value>" (names that are
+// not config keys) and used in command arguments as $name. An argument
+// that is an integer expression as a whole is evaluated: "page $start+5",
+// "page ($curpage+$maxpage)/2"; inside a longer argument, $((...))
+// evaluates one. Quoted text is never expanded, and \$ stands for a
+// literal $. The built-ins $curpage, $maxpage and $npages follow the
+// shell's state and cannot be assigned.
+
+var builtinVars = []string{"curpage", "maxpage", "npages"}
+
+func isVarName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// isConfigKey reports whether set <key> changes a setting rather than a
+// variable.
+func isConfigKey(key string) bool {
+	if strings.HasPrefix(key, "bind.") || strings.HasPrefix(key, "special.") {
+		return true
+	}
+	for _, kv := range (&Config{}).Settings() {
+		if kv[0] == key {
+			return true
+		}
+	}
+	return false
+}
+
+// expandVars replaces the variable references in the arguments of line
+// (every word but the command) with their values, leaving the spacing of
+// the line as typed. lookup returns a variable's value and whether it is
+// defined. The ends of an a..b range expand on their own, and the body of
+// a foreach is left for when its lines run.
+func expandVars(line string, lookup func(name string) (string, bool)) (string, error) {
+	if strings.HasPrefix(line, "foreach") {
+		if i := strings.Index(line, "{"); i >= 0 {
+			head, err := expandVars(line[:i], lookup)
+			return head + " " + line[i:], err
+		}
+	}
+	if !strings.Contains(line, "$") {
+		return line, nil
+	}
+	var b strings.Builder
+	i := 0
+	for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+		i++
+	}
+	b.WriteString(line[:i])
+	for i < len(line) {
+		if line[i] == ' ' || line[i] == '\t' {
+			b.WriteByte(line[i])
+			i++
+			continue
+		}
+		end := wordEnd(line, i)
+		w, err := expandWord(line[i:end], lookup)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(w)
+		i = end
+	}
+	return b.String(), nil
+}
+
+// wordEnd returns the end of the word starting at line[i]: the first blank
+// outside quotes.
+func wordEnd(line string, i int) int {
+	var quote byte
+	for ; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '\\' && i+1 < len(line):
+			i++
+		case c == ' ' || c == '\t':
+			return i
+		}
+	}
+	return i
+}
+
+// expandWord expands one argument. An unquoted argument whose $ parts are
+// all lone $names or integer expressions, range ends included, becomes
+// their values; any other argument has its $name and $((...)) references
+// replaced in place, and everything else kept.
+func expandWord(w string, lookup func(name string) (string, bool)) (string, error) {
+	if !strings.Contains(w, "$") {
+		return w, nil
+	}
+	if !strings.ContainsAny(w, "\"'\\") {
+		ends := strings.Split(w, "..")
+		whole := true
+		for j, e := range ends {
+			if !strings.Contains(e, "$") {
+				continue
+			}
+			if name, ok := strings.CutPrefix(e, "$"); ok && isVarName(name) {
+				v, ok := lookup(name)
+				if !ok {
+					return "", fmt.Errorf("undefined variable $%s", name)
+				}
+				ends[j] = v
+				continue
+			}
+			n, err := evalArith(e, lookup)
+			if err != nil {
+				whole = false
+				break
+			}
+			ends[j] = strconv.FormatInt(n, 10)
+		}
+		if whole {
+			return strings.Join(ends, ".."), nil
+		}
+	}
+	return expandRefs(w, lookup)
+}
+
+// expandRefs replaces the $name and $((...)) references of w outside
+// quotes, turns \$ into $ and leaves any other $ as it is.
+func expandRefs(w string, lookup func(name string) (string, bool)) (string, error) {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(w); i++ {
+		c := w[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '\\' && i+1 < len(w) && w[i+1] == '$':
+			i++
+			c = '$'
+		case c == '$' && strings.HasPrefix(w[i:], "$(("):
+			close := arithEnd(w, i+3)
+			if close < 0 {
+				return "", fmt.Errorf("%s: missing ))", w)
+			}
+			n, err := evalArith(w[i+3:close], lookup)
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", w[i:close+2], err)
+			}
+			b.WriteString(strconv.FormatInt(n, 10))
+			i = close + 1
+			continue
+		case c == '$' && i+1 < len(w) && isVarName(w[i+1:i+2]):
+			j := i + 2
+			for j < len(w) && isVarName(w[i+1:j+1]) {
+				j++
+			}
+			name := w[i+1 : j]
+			v, ok := lookup(name)
+			if !ok {
+				return "", fmt.Errorf("undefined variable $%s", name)
+			}
+			b.WriteString(v)
+			i = j - 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), nil
+}
+
+// arithEnd returns the index of the "))" closing a $(( whose expression
+// starts at w[start], or -1.
+func arithEnd(w string, start int) int {
+	depth := 0
+	for i := start; i < len(w); i++ {
+		switch w[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				if i+1 < len(w) && w[i+1] == ')' {
+					return i
+				}
+				return -1
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// evalArith evaluates an integer expression of numbers (decimal or 0x
+// hex), $variables, + - * / %, unary minus and parentheses.
+func evalArith(s string, lookup func(name string) (string, bool)) (int64, error) {
+	p := &arithParser{s: s, lookup: lookup}
+	n, err := p.sum()
+	if err == nil && p.pos < len(p.s) {
+		err = fmt.Errorf("unexpected %q", p.s[p.pos:])
+	}
+	return n, err
+}
+
+type arithParser struct {
+	s      string
+	pos    int
+	lookup func(name string) (string, bool)
+}
+
+func (p *arithParser) next() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *arithParser) sum() (int64, error) {
+	n, err := p.product()
+	for err == nil && (p.next() == '+' || p.next() == '-') {
+		op := p.next()
+		p.pos++
+		var m int64
+		if m, err = p.product(); op == '+' {
+			n += m
+		} else {
+			n -= m
+		}
+	}
+	return n, err
+}
+
+func (p *arithParser) product() (int64, error) {
+	n, err := p.unary()
+	for err == nil && (p.next() == '*' || p.next() == '/' || p.next() == '%') {
+		op := p.next()
+		p.pos++
+		var m int64
+		if m, err = p.unary(); err != nil {
+			break
+		}
+		switch {
+		case op == '*':
+			n *= m
+		case m == 0:
+			err = fmt.Errorf("division by zero")
+		case op == '/':
+			n /= m
+		default:
+			n %= m
+		}
+	}
+	return n, err
+}
+
+func (p *arithParser) unary() (int64, error) {
+	switch p.next() {
+	case '-':
+		p.pos++
+		n, err := p.unary()
+		return -n, err
+	case '(':
+		p.pos++
+		n, err := p.sum()
+		if err == nil && p.next() != ')' {
+			err = fmt.Errorf("missing )")
+		}
+		p.pos++
+		return n, err
+	case '$':
+		p.pos++
+		start := p.pos
+		for p.pos < len(p.s) && isVarName(p.s[start:p.pos+1]) {
+			p.pos++
+		}
+		name := p.s[start:p.pos]
+		v, ok := p.lookup(name)
+		if !ok {
+			return 0, fmt.Errorf("undefined variable $%s", name)
+		}
+		n, err := parseArithInt(v)
+		if err != nil {
+			return 0, fmt.Errorf("$%s is %q, not a number", name, v)
+		}
+		return n, nil
+	}
+	start := p.pos
+	for p.pos < len(p.s) && (p.s[p.pos] >= '0' && p.s[p.pos] <= '9' || p.s[p.pos] == 'x' || p.s[p.pos] == 'X' ||
+		p.s[p.pos] >= 'a' && p.s[p.pos] <= 'f' || p.s[p.pos] >= 'A' && p.s[p.pos] <= 'F') {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, fmt.Errorf("expected a number at %q", p.s[start:])
+	}
+	return parseArithInt(p.s[start:p.pos])
+}
+
+// parseArithInt parses a decimal or 0x-prefixed hex integer; a leading
+// zero does not make it octal.
+func parseArithInt(s string) (int64, error) {
+	if h, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+		return strconv.ParseInt(h, 16, 64)
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Supported PostgreSQL major versions for version-dependent decoding.
+const (
+	MinPGVersion    = 12
+	LatestPGVersion = 17
+)
+
+// pgVersion selects the on-disk layout variant used where PostgreSQL
+// changed the meaning of a field between major versions. It is set once
+// from --pg-version and defaults to the latest release.
+var pgVersion = LatestPGVersion
+
+func parsePGVersion(s string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < MinPGVersion || v > LatestPGVersion {
+		return 0, fmt.Errorf("unsupported PostgreSQL version %q (%d-%d)", s, MinPGVersion, LatestPGVersion)
+	}
+	return v, nil
+}
+
+// btMetaCleanupFieldName names the BTMetaPageData field at offset 24:
+// PostgreSQL 14 replaced btm_oldest_btpo_xact with the deleted page count.
+func btMetaCleanupFieldName() string {
+	if pgVersion < 14 {
+		return "btm_oldest_btpo_xact"
+	}
+	return "btm_last_cleanup_num_delpages"
+}
+
+// btMetaHasAllEqualImage reports whether btm_allequalimage exists (13+).
+func btMetaHasAllEqualImage() bool { return pgVersion >= 13 }
+
+// btDeletedUsesLevelXact reports whether deleted btree pages keep their
+// deletion XID in the btpo_level slot (the btpo.xact union before 14).
+// From 14 on, the full XID lives in BTDeletedPageData in the page body.
+func btDeletedUsesLevelXact() bool { return pgVersion < 14 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tupleNeedsFreeze reports whether a heap tuple still holds an xid that a
+// later VACUUM must freeze, as heap_tuple_needs_eventual_freeze; a page
+// marked all-frozen must have none (pg_check_frozen).
+func tupleNeedsFreeze(t HeapTupleHeader) bool {
+	if t.Xmin >= FirstNormalTransactionId && t.Infomask&HeapXminFrozen != HeapXminFrozen {
+		return true
+	}
+	return t.Xmax != 0 && t.Infomask&HeapXmaxInvalid == 0
+}
+
+// visibilityMismatches lists what is wrong with the visibility state of a
+// heap page: the VM bits against PD_ALL_VISIBLE and against the page
+// contents.
+func visibilityMismatches(p *Page, allVisible, allFrozen bool) []string {
+	var problems []string
+	pdVisible := p.Header.Flags&PDAllVisible != 0
+	switch {
+	case allVisible && !pdVisible:
+		problems = append(problems, "vm set, pd clear")
+	case !allVisible && pdVisible:
+		problems = append(problems, "pd set, vm clear")
+	}
+	if allFrozen && !allVisible {
+		problems = append(problems, "frozen, not visible")
+	}
+	if !allVisible && !allFrozen {
+		return problems
+	}
+	dead, unfrozen := 0, 0
+	for _, lp := range p.Items {
+		switch lp.Flags() {
+		case LPDead:
+			dead++
+		case LPNormal:
+			if allFrozen && lp.Length() >= HeapTupleHdrSize && int(lp.Offset())+HeapTupleHdrSize <= PageSize &&
+				tupleNeedsFreeze(p.ParseHeapTupleHeader(lp.Offset())) {
+				unfrozen++
+			}
+		}
+	}
+	if dead > 0 {
+		problems = append(problems, fmt.Sprintf("%d dead item(s)", dead))
+	}
+	if unfrozen > 0 {
+		problems = append(problems, fmt.Sprintf("%d unfrozen tuple(s)", unfrozen))
+	}
+	return problems
+}
+
+// CmdVisibility prints what pg_visibility(rel) returns for every heap
+// block - the VM's all_visible and all_frozen bits and PD_ALL_VISIBLE -
+// with a mismatch column naming inconsistencies: a VM bit without
+// PD_ALL_VISIBLE (or the reverse, which the next VACUUM repairs), dead
+// items on an all-visible page and tuples still needing freezing on an
+// all-frozen one (what pg_check_visible/pg_check_frozen look for). With
+// onlyMismatches only the inconsistent blocks are listed. It returns the
+// number of blocks with a mismatch.
+func CmdVisibility(filename string, totalPages int64, sr scanRange, onlyMismatches bool) int {
+	fmt.Println()
+	fmt.Printf("=== Visibility (%s) ===\n", sr.String(totalPages))
+	vm := findVisibilityMap(filename)
+	if vm == nil {
+		fmt.Println("  No visibility map fork found (needs the main fork of a heap with its _vm file).")
+		fmt.Println()
+		return 0
+	}
+	fmt.Printf("  Visibility map     : %s\n", shownPath(vm.Filename))
+	fmt.Println()
+	fmt.Printf("  %7s | %-11s | %-10s | %-14s | %s\n", "blkno", "all_visible", "all_frozen", "pd_all_visible", "mismatch")
+	fmt.Printf("  %s+%s+%s+%s+%s\n", strings.Repeat("-", 8), strings.Repeat("-", 13), strings.Repeat("-", 12), strings.Repeat("-", 16), strings.Repeat("-", 10))
+
+	tf := map[bool]string{false: "f", true: "t"}
+	base := absBlockNumber(filename, 0)
+	blocks, visible, frozen, pdVisible, mismatched, skipped := 0, 0, 0, 0, 0, 0
+	first, end := sr.bounds(totalPages)
+	for i := first; i < end; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil {
+			fmt.Printf("  page %d: %v\n", i, err)
+			continue
+		}
+		if pg.Detected != PageTypeHeap {
+			continue
+		}
+		if sr.skips(pg) {
+			skipped++
+			continue
+		}
+		blkno := base + uint32(i)
+		v, f := vm.Status(blkno)
+		pd := pg.Header.Flags&PDAllVisible != 0
+		problems := visibilityMismatches(pg, v, f)
+		blocks++
+		if v {
+			visible++
+		}
+		if f {
+			frozen++
+		}
+		if pd {
+			pdVisible++
+		}
+		if len(problems) > 0 {
+			mismatched++
+		} else if onlyMismatches {
+			continue
+		}
+		row := fmt.Sprintf("  %7d | %-11s | %-10s | %-14s | %s", blkno, tf[v], tf[f], tf[pd], strings.Join(problems, ", "))
+		fmt.Println(strings.TrimRight(row, " "))
+	}
+	if onlyMismatches && mismatched == 0 {
+		fmt.Println("  (no mismatches)")
+	}
+
+	fmt.Println()
+	fmt.Printf("  Heap blocks        : %d\n", blocks)
+	fmt.Printf("  All-visible (VM)   : %d\n", visible)
+	fmt.Printf("  All-frozen (VM)    : %d\n", frozen)
+	fmt.Printf("  PD_ALL_VISIBLE     : %d\n", pdVisible)
+	fmt.Printf("  Mismatches         : %d\n", mismatched)
+	if sr.SinceLSN != 0 {
+		fmt.Printf("  Skipped (older)    : %d\n", skipped)
+	}
+	fmt.Println()
+	return mismatched
+}
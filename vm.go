@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ---- Visibility map constants ----
+
+const (
+	VMAllVisible        = 0x01
+	VMAllFrozen         = 0x02
+	VMMapSize           = PageSize - PageHeaderSize // bytes of map data per VM page
+	VMHeapBlocksPerPage = VMMapSize * 4             // 2 bits per heap block
+)
+
+// VisibilityMap holds the contents of a relation's _vm fork.
+type VisibilityMap struct {
+	Filename string
+	data     []byte
+}
+
+// LoadVisibilityMap reads a whole _vm fork into memory. VM forks are tiny
+// compared to their heap (one 8 KB page covers ~255 MB of heap).
+func LoadVisibilityMap(filename string) (*VisibilityMap, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &VisibilityMap{Filename: filename, data: data}, nil
+}
+
+// findVisibilityMap locates and loads the _vm fork belonging to a main
+// fork file. It returns nil if there is none.
+func findVisibilityMap(filename string) *VisibilityMap {
+	if relFork(filename) != ForkMain {
+		return nil
+	}
+	path := relForkPath(filename, ForkVM)
+	if path == "" {
+		return nil
+	}
+	vm, err := LoadVisibilityMap(path)
+	if err != nil {
+		return nil
+	}
+	return vm
+}
+
+// Status returns the VM bits for a heap block. Blocks beyond the end of
+// the map are reported as neither all-visible nor all-frozen.
+func (vm *VisibilityMap) Status(heapBlk uint32) (allVisible, allFrozen bool) {
+	mapPage := int(heapBlk / VMHeapBlocksPerPage)
+	mapByte := int(heapBlk%VMHeapBlocksPerPage) / 4
+	shift := (heapBlk % 4) * 2
+	off := mapPage*PageSize + PageHeaderSize + mapByte
+	if off >= len(vm.data) {
+		return false, false
+	}
+	bits := vm.data[off] >> shift
+	return bits&VMAllVisible != 0, bits&VMAllFrozen != 0
+}
+
+// blockRanges formats a sorted list of block numbers as "0-10, 15, 20-22",
+// truncated after max ranges.
+func blockRanges(blocks []int64, max int) string {
+	if len(blocks) == 0 {
+		return "none"
+	}
+	var parts []string
+	start, prev := blocks[0], blocks[0]
+	flush := func() {
+		if start == prev {
+			parts = append(parts, fmt.Sprintf("%d", start))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", start, prev))
+		}
+	}
+	for _, b := range blocks[1:] {
+		if b == prev+1 {
+			prev = b
+			continue
+		}
+		flush()
+		start, prev = b, b
+	}
+	flush()
+	if len(parts) > max {
+		return strings.Join(parts[:max], ", ") + fmt.Sprintf(", ... (%d more ranges)", len(parts)-max)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// printVMSummary prints which heap blocks of the file's page range sr are
+// marked all-visible and all-frozen in its visibility map, if one exists.
+func printVMSummary(filename string, totalPages int64, sr scanRange) {
+	vm := findVisibilityMap(filename)
+	if vm == nil {
+		return
+	}
+	base := absBlockNumber(filename, 0)
+	first, end := sr.bounds(totalPages)
+	var visible, frozen []int64
+	for i := first; i < end; i++ {
+		v, f := vm.Status(base + uint32(i))
+		if v {
+			visible = append(visible, i)
+		}
+		if f {
+			frozen = append(frozen, i)
+		}
+	}
+	fmt.Printf("  VM (%s): %d/%d all-visible [%s], %d/%d all-frozen [%s]\n",
+		shownPath(vm.Filename), len(visible), end-first, blockRanges(visible, 8),
+		len(frozen), end-first, blockRanges(frozen, 8))
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// ---- WAL records (src/include/access/xlogrecord.h) ----
+
+const (
+	SizeOfXLogRecord = 24
+
+	XLRMaxBlockID         = 32
+	XLRBlockIDTopLevelXID = 252
+	XLRBlockIDOrigin      = 253
+	XLRBlockIDDataLong    = 254
+	XLRBlockIDDataShort   = 255
+
+	BkpBlockForkMask = 0x0F
+	BkpBlockHasImage = 0x10
+	BkpBlockHasData  = 0x20
+	BkpBlockWillInit = 0x40
+	BkpBlockSameRel  = 0x80
+
+	BkpImageHasHole = 0x01
+
+	RMHeap2ID = 9
+	RMHeapID  = 10
+	RMBTreeID = 11
+
+	XLRInfoMask = 0x0F // bits of xl_info reserved for xlog.c
+)
+
+// RelFileLocator identifies a relation's storage (RelFileNode before 16).
+type RelFileLocator struct {
+	Spc, DB, Rel uint32
+}
+
+func (r RelFileLocator) String() string { return fmt.Sprintf("%d/%d/%d", r.Spc, r.DB, r.Rel) }
+
+// XLogBlockRef is one block reference of a record: its header fields plus
+// the full-page image and block data payloads.
+type XLogBlockRef struct {
+	ID         uint8
+	ForkFlags  uint8
+	Rel        RelFileLocator
+	Block      uint32
+	BimgInfo   uint8
+	HoleOffset uint16
+	HoleLength uint16
+	Image      []byte // nil without a full-page image
+	Data       []byte
+}
+
+func (b *XLogBlockRef) Fork() int      { return int(b.ForkFlags & BkpBlockForkMask) }
+func (b *XLogBlockRef) HasImage() bool { return b.ForkFlags&BkpBlockHasImage != 0 }
+
+// ImageApplies reports whether redo restores the image (BKPIMAGE_APPLY);
+// images taken only for wal_consistency_checking do not.
+func (b *XLogBlockRef) ImageApplies() bool {
+	if pgVersion >= 15 {
+		return b.BimgInfo&0x02 != 0
+	}
+	return b.BimgInfo&0x04 != 0
+}
+
+// ImageCompressed reports whether the image is pglz, lz4 or zstd compressed.
+func (b *XLogBlockRef) ImageCompressed() bool {
+	if pgVersion >= 15 {
+		return b.BimgInfo&(0x04|0x08|0x10) != 0
+	}
+	return b.BimgInfo&0x02 != 0
+}
+
+// XLogRecord is a decoded WAL record.
+type XLogRecord struct {
+	TotLen      uint32
+	Xid         uint32
+	Prev        uint64
+	Info        uint8
+	Rmid        uint8
+	CRC         uint32
+	ComputedCRC uint32
+	Blocks      []XLogBlockRef
+	MainData    []byte
+}
+
+// Block returns the block reference with the given id, or nil.
+func (r *XLogRecord) Block(id uint8) *XLogBlockRef {
+	for i := range r.Blocks {
+		if r.Blocks[i].ID == id {
+			return &r.Blocks[i]
+		}
+	}
+	return nil
+}
+
+// ParseXLogRecord decodes the record at the start of data, following
+// DecodeXLogRecord(): the fixed header, the block and data headers, then
+// each block's image and data and finally the main data.
+func ParseXLogRecord(data []byte) (*XLogRecord, error) {
+	le := binary.LittleEndian
+	if len(data) < SizeOfXLogRecord {
+		return nil, fmt.Errorf("record too short (%d bytes)", len(data))
+	}
+	r := &XLogRecord{
+		TotLen: le.Uint32(data[0:4]),
+		Xid:    le.Uint32(data[4:8]),
+		Prev:   le.Uint64(data[8:16]),
+		Info:   data[16],
+		Rmid:   data[17],
+		CRC:    le.Uint32(data[20:24]),
+	}
+	tot := int(r.TotLen)
+	if tot < SizeOfXLogRecord || tot > len(data) {
+		return nil, fmt.Errorf("xl_tot_len %d out of range (have %d bytes)", tot, len(data))
+	}
+	data = data[:tot]
+	r.ComputedCRC = crc32.Update(crc32.Checksum(data[SizeOfXLogRecord:], crc32cTable), crc32cTable, data[:20])
+
+	off := SizeOfXLogRecord
+	need := func(n int) error {
+		if off+n > tot {
+			return fmt.Errorf("record header truncated at offset %d", off)
+		}
+		return nil
+	}
+	type lengths struct{ image, data int }
+	var sizes []lengths
+	var lastRel RelFileLocator
+	mainLen, datatotal := 0, 0
+
+headers:
+	for tot-off > datatotal {
+		if err := need(1); err != nil {
+			return nil, err
+		}
+		id := data[off]
+		off++
+		switch {
+		case id == XLRBlockIDDataShort:
+			if err := need(1); err != nil {
+				return nil, err
+			}
+			mainLen = int(data[off])
+			off++
+			datatotal += mainLen
+			break headers
+		case id == XLRBlockIDDataLong:
+			if err := need(4); err != nil {
+				return nil, err
+			}
+			mainLen = int(le.Uint32(data[off:]))
+			off += 4
+			datatotal += mainLen
+			break headers
+		case id == XLRBlockIDOrigin:
+			off += 2
+		case id == XLRBlockIDTopLevelXID:
+			off += 4
+		case id <= XLRMaxBlockID:
+			if err := need(3); err != nil {
+				return nil, err
+			}
+			b := XLogBlockRef{ID: id, ForkFlags: data[off]}
+			sz := lengths{data: int(le.Uint16(data[off+1:]))}
+			off += 3
+			if b.HasImage() {
+				if err := need(5); err != nil {
+					return nil, err
+				}
+				sz.image = int(le.Uint16(data[off:]))
+				b.HoleOffset = le.Uint16(data[off+2:])
+				b.BimgInfo = data[off+4]
+				off += 5
+				if b.BimgInfo&BkpImageHasHole != 0 {
+					if b.ImageCompressed() {
+						if err := need(2); err != nil {
+							return nil, err
+						}
+						b.HoleLength = le.Uint16(data[off:])
+						off += 2
+					} else {
+						b.HoleLength = uint16(PageSize - sz.image)
+					}
+				}
+			}
+			if b.ForkFlags&BkpBlockSameRel == 0 {
+				if err := need(12); err != nil {
+					return nil, err
+				}
+				lastRel = RelFileLocator{le.Uint32(data[off:]), le.Uint32(data[off+4:]), le.Uint32(data[off+8:])}
+				off += 12
+			}
+			b.Rel = lastRel
+			if err := need(4); err != nil {
+				return nil, err
+			}
+			b.Block = le.Uint32(data[off:])
+			off += 4
+			datatotal += sz.image + sz.data
+			r.Blocks = append(r.Blocks, b)
+			sizes = append(sizes, sz)
+		default:
+			return nil, fmt.Errorf("invalid block_id %d at offset %d", id, off-1)
+		}
+	}
+
+	if off+datatotal != tot {
+		return nil, fmt.Errorf("payload lengths (%d) do not match xl_tot_len", off+datatotal)
+	}
+	for i := range r.Blocks {
+		if n := sizes[i].image; n > 0 {
+			r.Blocks[i].Image = data[off : off+n]
+			off += n
+		}
+		r.Blocks[i].Data = data[off : off+sizes[i].data]
+		off += sizes[i].data
+	}
+	r.MainData = data[off : off+mainLen]
+	return r, nil
+}
+
+// RecordDesc names the record type, e.g. "Heap/INSERT+INIT".
+func (r *XLogRecord) RecordDesc() string {
+	info := r.Info &^ XLRInfoMask
+	switch r.Rmid {
+	case RMHeapID:
+		names := []string{"INSERT", "DELETE", "UPDATE", "TRUNCATE", "HOT_UPDATE", "CONFIRM", "LOCK", "INPLACE"}
+		s := "Heap/" + names[(info&XLogHeapOpMask)>>4]
+		if info&XLogHeapInitPage != 0 {
+			s += "+INIT"
+		}
+		return s
+	case RMHeap2ID:
+		return fmt.Sprintf("Heap2/0x%02X", info)
+	case RMBTreeID:
+		switch info {
+		case XLogBTreeInsertLeaf:
+			return "Btree/INSERT_LEAF"
+		case XLogBTreeInsertUpper:
+			return "Btree/INSERT_UPPER"
+		case XLogBTreeInsertMeta:
+			return "Btree/INSERT_META"
+		case XLogBTreeInsertPost:
+			return "Btree/INSERT_POST"
+		}
+		return fmt.Sprintf("Btree/0x%02X", info)
+	}
+	return fmt.Sprintf("rmgr %d/0x%02X", r.Rmid, info)
+}
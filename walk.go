@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// siblingLinks returns the left and right sibling block numbers of an
+// index page, using InvalidBlock where a direction has no link. ok is
+// false for page types that do not chain siblings.
+func siblingLinks(p *Page) (left, right uint32, ok bool) {
+	special := p.SpecialData()
+	le := binary.LittleEndian
+
+	switch p.Detected {
+	case PageTypeBTree:
+		op, ok := p.BTreeOpaque()
+		if !ok || op.Flags&BTPMeta != 0 {
+			return 0, 0, false
+		}
+		// btree uses P_NONE (0) for "no sibling"
+		left, right = op.Prev, op.Next
+		if left == 0 {
+			left = InvalidBlock
+		}
+		if right == 0 {
+			right = InvalidBlock
+		}
+		return left, right, true
+	case PageTypeHash:
+		if len(special) < HashOpaqueSize {
+			return 0, 0, false
+		}
+		flag := le.Uint16(special[12:14])
+		if flag&0x000F != LHBucketPage && flag&0x000F != LHOverflowPage {
+			return 0, 0, false
+		}
+		left = le.Uint32(special[0:4])
+		if flag&0x000F == LHBucketPage {
+			// a bucket page's hasho_prevblkno holds hashm_maxbucket
+			left = InvalidBlock
+		}
+		return left, le.Uint32(special[4:8]), true
+	case PageTypeGiST:
+		op, ok := p.GiSTOpaque()
+		if !ok {
+			return 0, 0, false
+		}
+		return InvalidBlock, op.Rightlink, true
+	case PageTypeGIN:
+		if len(special) < GINOpaqueSize || isMeta(p) {
+			return 0, 0, false
+		}
+		return InvalidBlock, le.Uint32(special[0:4]), true
+	}
+	return 0, 0, false
+}
+
+// CmdWalk follows sibling links from the start page in the given
+// direction ("right" or "left") until an invalid block, printing one line
+// per visited page and stopping if a page is reached twice. Links are
+// block numbers across the relation; one into another segment file than
+// filename ends the walk.
+func CmdWalk(filename string, totalPages int64, start int64, dir string) {
+	base := absBlockNumber(filename, 0)
+	fmt.Println()
+	fmt.Printf("=== Sibling Walk (%s from block %d) ===\n", dir, int64(base)+start)
+
+	visited := map[int64]bool{}
+	blk := start
+	steps := 0
+	for {
+		if visited[blk] {
+			fmt.Printf("  LOOP: block %d already visited\n", int64(base)+blk)
+			break
+		}
+		visited[blk] = true
+
+		pg, err := ReadPage(filename, blk)
+		if err != nil {
+			fmt.Printf("  error reading block %d: %v\n", int64(base)+blk, err)
+			break
+		}
+		left, right, ok := siblingLinks(pg)
+		if !ok {
+			fmt.Printf("  block %d (%s) has no sibling links\n", int64(base)+blk, pg.Detected)
+			break
+		}
+		next := right
+		if dir == "left" {
+			next = left
+		}
+		inFile := next != InvalidBlock && next >= base && int64(next-base) < totalPages
+		if inFile {
+			prefetch(filename, totalPages, int64(next-base))
+		}
+		subtype := detectPageSubtype(pg)
+		fmt.Printf("  block %-6d %-7s %-15s items=%-4d left=%-6s right=%s\n",
+			int64(base)+blk, pg.Detected, subtype, len(pg.Items), blockStr(left), blockStr(right))
+		steps++
+
+		if next == InvalidBlock {
+			break
+		}
+		if !inFile {
+			fmt.Printf("  link to %d leaves this file (blocks %d-%d)\n", next, base, int64(base)+totalPages-1)
+			break
+		}
+		blk = int64(next - base)
+	}
+	fmt.Printf("\n  Pages visited: %d\n\n", steps)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// CmdRefresh re-reads the current page and shows how it differs from the
+// copy the shell had, returning the new copy.
+func CmdRefresh(filename string, old *Page) (*Page, error) {
+	p, err := ReadPage(filename, old.PageNum)
+	if err != nil {
+		return nil, err
+	}
+	printPageDiff(fmt.Sprintf("Page %d Changes", old.PageNum), old, p)
+	return p, nil
+}
+
+// CmdWatch re-reads the current page count times, every interval, and
+// prints the differences each time it changed, journaling each changed
+// copy; it returns the last copy read.
+func CmdWatch(filename string, old *Page, interval time.Duration, count int, journal *pageJournal) (*Page, error) {
+	fmt.Println()
+	fmt.Printf("=== Watching page %d (every %s, %d reads) ===\n", old.PageNum, interval, count)
+	changes := 0
+	for n := 1; n <= count; n++ {
+		time.Sleep(interval)
+		p, err := ReadPage(filename, old.PageNum)
+		if err != nil {
+			return old, err
+		}
+		if p.Data != old.Data {
+			changes++
+			journal.record(filename, p)
+			at := time.Now().Format("15:04:05.000")
+			if deterministic {
+				at = fmt.Sprintf("read %d", n)
+			}
+			printPageDiff(fmt.Sprintf("Page %d Changes (%s)", old.PageNum, at), old, p)
+		}
+		old = p
+	}
+	fmt.Println()
+	fmt.Printf("  %d of %d reads changed the page\n", changes, count)
+	fmt.Println()
+	return old, nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// CmdWhere explains which on-page structure the byte at off belongs to.
+func CmdWhere(p *Page, off int) {
+	fmt.Println()
+	fmt.Printf("=== Offset %d (0x%04X) = 0x%02X ===\n", off, off, p.Data[off])
+	for _, line := range whereDetail(p, off) {
+		fmt.Printf("  %s\n", line)
+	}
+	fmt.Println()
+	printByteValues(p, off)
+	fmt.Println()
+}
+
+// printByteValues prints the little-endian integers that start at byte off,
+// whatever structure they fall in, as a binary inspector would.
+func printByteValues(p *Page, off int) {
+	for _, v := range byteValues(p, off) {
+		fmt.Printf("  %-18s : %s\n", v.Label, v.Value)
+	}
+}
+
+// byteValues returns the little-endian integers that start at byte off, at
+// every width that fits before the end of the page.
+func byteValues(p *Page, off int) []ByteValue {
+	d := p.Data[off:]
+	le := binary.LittleEndian
+	v8 := fmt.Sprintf("%d / %d", int8(d[0]), d[0])
+	if d[0] >= 0x20 && d[0] < 0x7F {
+		v8 += fmt.Sprintf(" '%c'", d[0])
+	}
+	vals := []ByteValue{{"int8 / uint8", v8}}
+	if len(d) >= 2 {
+		vals = append(vals, ByteValue{"int16 / uint16", fmt.Sprintf("%d / %d", int16(le.Uint16(d)), le.Uint16(d))})
+	}
+	if len(d) >= 4 {
+		vals = append(vals, ByteValue{"int32 / uint32", fmt.Sprintf("%d / %d", int32(le.Uint32(d)), le.Uint32(d))})
+	}
+	if len(d) >= 8 {
+		vals = append(vals, ByteValue{"int64 / uint64", fmt.Sprintf("%d / %d", int64(le.Uint64(d)), le.Uint64(d))})
+	}
+	return vals
+}
+
+// fieldValue decodes field f of the struct that starts at byte base: flag
+// words by name, LSNs and TIDs in their usual notation, other fields as
+// little-endian integers.
+func fieldValue(p *Page, base int, f fieldSpan) string {
+	if base+f.End > PageSize {
+		return "(past end of page)"
+	}
+	d := p.Data[base+f.Start : base+f.End]
+	le := binary.LittleEndian
+	withFlags := func(v uint16, flags []string) string {
+		if len(flags) == 0 {
+			return fmt.Sprintf("0x%04X", v)
+		}
+		return fmt.Sprintf("0x%04X [%s]", v, strings.Join(flags, " | "))
+	}
+	switch {
+	case f.Name == "pd_lsn" || f.Name == "nsn":
+		return lsnStr(uint64(le.Uint32(d))<<32 | uint64(le.Uint32(d[4:])))
+	case f.Name == "pd_flags":
+		return fmt.Sprintf("0x%04X [%s]", le.Uint16(d), FlagsString(le.Uint16(d)))
+	case f.Name == "t_ctid" || f.Name == "t_tid":
+		return fmt.Sprintf("(%d,%d)", uint32(le.Uint16(d))<<16|uint32(le.Uint16(d[2:])), le.Uint16(d[4:]))
+	case f.Name == "t_infomask":
+		t := HeapTupleHeader{Infomask: le.Uint16(d)}
+		return withFlags(t.Infomask, t.InfomaskFlags())
+	case f.Name == "t_infomask2":
+		t := HeapTupleHeader{Infomask2: le.Uint16(d)}
+		return withFlags(t.Infomask2, t.Infomask2Flags()) + fmt.Sprintf(", natts %d", t.NAttrs())
+	case f.Name == "t_info":
+		it := IndexTupleHeader{Info: le.Uint16(d)}
+		return withFlags(it.Info, it.InfoFlags()) + fmt.Sprintf(", size %d", it.Size())
+	case f.Name == "btpo_flags":
+		return withFlags(le.Uint16(d), btreeFlags(le.Uint16(d)))
+	case f.Name == "hasho_flag":
+		return withFlags(le.Uint16(d), hashFlags(le.Uint16(d)))
+	case f.Name == "flags" && p.Template == nil && len(d) == 2:
+		switch p.Detected {
+		case PageTypeGiST:
+			return withFlags(le.Uint16(d), gistFlags(le.Uint16(d)))
+		case PageTypeGIN:
+			return withFlags(le.Uint16(d), ginFlags(le.Uint16(d)))
+		case PageTypeSPGiST:
+			return withFlags(le.Uint16(d), spgistFlags(le.Uint16(d)))
+		}
+	}
+	switch len(d) {
+	case 1:
+		return fmt.Sprintf("%d (0x%02X)", d[0], d[0])
+	case 2:
+		return fmt.Sprintf("%d (0x%04X)", le.Uint16(d), le.Uint16(d))
+	case 4:
+		return fmt.Sprintf("%d (0x%08X)", le.Uint32(d), le.Uint32(d))
+	case 8:
+		return fmt.Sprintf("%d (0x%016X)", le.Uint64(d), le.Uint64(d))
+	}
+	return fmt.Sprintf("% X", d)
+}
+
+// whereDetail describes the structure containing byte off, from the
+// outermost region down to the individual field.
+func whereDetail(p *Page, off int) []string {
+	h := &p.Header
+	fieldLine := func(f fieldSpan, k, base int) string {
+		return fmt.Sprintf("field %s (byte %d of %d, struct offset %d) = %s", f.Name, k, f.End-f.Start, f.Start, fieldValue(p, base, f))
+	}
+
+	if off < PageHeaderSize {
+		f, k, _ := fieldAt(pageHeaderFields, off)
+		return []string{"region: page header (PageHeaderData)", fieldLine(f, k, 0)}
+	}
+
+	if p.SpecialSize() > 0 && off >= int(h.Special) && int(h.Special) >= PageHeaderSize {
+		rel := off - int(h.Special)
+		lines := []string{fmt.Sprintf("region: special space (%s), byte %d of %d", p.TypeLabel(), rel, p.SpecialSize())}
+		fields := specialFields[p.Detected]
+		if p.Template != nil {
+			fields = p.Template.spans()
+		}
+		if f, k, ok := fieldAt(fields, rel); ok {
+			lines = append(lines, fieldLine(f, k, int(h.Special)))
+		}
+		return lines
+	}
+
+	subtype := detectPageSubtype(p)
+	if subtype == "meta" || subtype == "bitmap" || subtype == "revmap" {
+		for _, f := range buildMetaFields(p, subtype) {
+			if off >= f.StartByte && off < f.EndByte {
+				return []string{
+					fmt.Sprintf("region: %s content", subtype),
+					fmt.Sprintf("field %s (byte %d of %d) = %s", f.Name, off-f.StartByte, f.Size, f.Value),
+				}
+			}
+		}
+	}
+
+	if off < int(h.Lower) {
+		n := (off - PageHeaderSize) / ItemIdSize
+		k := (off - PageHeaderSize) % ItemIdSize
+		lines := []string{fmt.Sprintf("region: line pointer array, ItemId %d (byte %d of 4)", n+1, k)}
+		if n < len(p.Items) {
+			lp := p.Items[n]
+			lines = append(lines, fmt.Sprintf("%s offset=%d length=%d", lp.FlagsStr(), lp.Offset(), lp.Length()))
+		}
+		return lines
+	}
+	if off < int(h.Upper) {
+		return []string{fmt.Sprintf("region: free space, byte %d of %d", off-int(h.Lower), int(h.Upper)-int(h.Lower))}
+	}
+
+	for i, lp := range p.Items {
+		start := int(lp.Offset())
+		end := start + int(lp.Length())
+		if lp.Flags() == LPRedirect || lp.Length() == 0 || off < start || off >= end {
+			continue
+		}
+		rel := off - start
+		lines := []string{fmt.Sprintf("region: tuple area, item %d (%s), byte %d of %d", i+1, lp.FlagsStr(), rel, lp.Length())}
+		return append(lines, whereInTuple(p, lp, rel)...)
+	}
+	return []string{"region: tuple area, not referenced by any line pointer (padding or dead space)"}
+}
+
+// whereInTuple describes byte rel of the tuple stored at lp.
+func whereInTuple(p *Page, lp ItemId, rel int) []string {
+	isIndex := p.Detected != PageTypeHeap && p.Detected != PageTypeUnknown
+	if !isIndex {
+		if lp.Length() < HeapTupleHdrSize {
+			return nil
+		}
+		t := p.ParseHeapTupleHeader(lp.Offset())
+		if f, k, ok := fieldAt(heapTupleHeaderFields, rel); ok {
+			if f.Name == "t_cid" {
+				f.Name = t.Field3Name()
+			}
+			return []string{fmt.Sprintf("heap tuple header field %s (byte %d of %d) = %s", f.Name, k, f.End-f.Start, fieldValue(p, int(lp.Offset()), f))}
+		}
+		if rel < int(t.Hoff) {
+			bitmapEnd := HeapTupleHdrSize
+			if t.Infomask&HeapHasNull != 0 {
+				bitmapEnd += (t.NAttrs() + 7) / 8
+			}
+			if rel < bitmapEnd {
+				return []string{fmt.Sprintf("null bitmap byte %d (attributes %d-%d)",
+					rel-HeapTupleHdrSize, (rel-HeapTupleHdrSize)*8+1, (rel-HeapTupleHdrSize)*8+8)}
+			}
+			return []string{"header alignment padding (before t_hoff)"}
+		}
+		return []string{fmt.Sprintf("user data byte %d (t_hoff = %d)", rel-int(t.Hoff), t.Hoff)}
+	}
+
+	if isSPGistLeaf(p) {
+		t := p.ParseSpGistLeafTuple(lp.Offset())
+		hasNulls := t.HasNulls()
+		switch {
+		case rel < 12:
+			return []string{"SP-GiST leaf tuple header"}
+		case hasNulls && rel < 12+indexNullBitmapSize:
+			return []string{fmt.Sprintf("null bitmap byte %d", rel-12)}
+		case rel < sgltHdrSize(hasNulls):
+			return []string{"SP-GiST leaf tuple header alignment padding"}
+		}
+		return []string{fmt.Sprintf("leaf datum byte %d", rel-sgltHdrSize(hasNulls))}
+	}
+	if f, k, ok := fieldAt(indexTupleHeaderFields, rel); ok {
+		return []string{fmt.Sprintf("index tuple header field %s (byte %d of %d) = %s", f.Name, k, f.End-f.Start, fieldValue(p, int(lp.Offset()), f))}
+	}
+	it := p.ParseIndexTupleHeader(lp.Offset())
+	hasNulls := it.HasNulls()
+	switch {
+	case hasNulls && rel < IndexTupleHdrSize+indexNullBitmapSize:
+		return []string{fmt.Sprintf("null bitmap byte %d (columns %d-%d)", rel-IndexTupleHdrSize,
+			(rel-IndexTupleHdrSize)*8+1, (rel-IndexTupleHdrSize)*8+8)}
+	case rel < indexDataOff(hasNulls):
+		return []string{"null bitmap alignment padding"}
+	}
+	return []string{fmt.Sprintf("key data byte %d", rel-indexDataOff(hasNulls))}
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// B-tree posting list tuples (nbtree.h, PG13+): INDEX_ALT_TID_MASK in t_info
+// plus BT_IS_POSTING in the t_tid offset field, which also holds the TID
+// count; the t_tid block field holds the posting list's offset. Pivot
+// tuples set INDEX_ALT_TID_MASK without BT_IS_POSTING and keep their
+// number of key attributes in the offset field; on v4 indexes (PG12+)
+// BT_PIVOT_HEAP_TID_ATTR says a heap TID tiebreaker ends the tuple.
+const (
+	BTIsPosting        = 0x2000
+	BTPivotHeapTIDAttr = 0x1000
+	BTOffsetMask       = 0x0FFF
+	ItemPointerSz      = 6
+)
+
+// HeapTID is an ItemPointerData pointing at a heap line pointer.
+type HeapTID struct {
+	Block  uint32
+	Offset uint16
+}
+
+func (t HeapTID) String() string { return fmt.Sprintf("(%d, %d)", t.Block, t.Offset) }
+
+// IndexEntry is one heap TID found in an index leaf tuple. Posting list
+// tuples contribute one entry per TID, all with the same Page and Item.
+type IndexEntry struct {
+	Page int64
+	Item int // 1-based line pointer number
+	TID  HeapTID
+}
+
+func readTID(d []byte) HeapTID {
+	le := binary.LittleEndian
+	return HeapTID{
+		Block:  uint32(le.Uint16(d[0:2]))<<16 | uint32(le.Uint16(d[2:4])),
+		Offset: le.Uint16(d[4:6]),
+	}
+}
+
+// indexHeapTIDs returns the heap TIDs referenced by a leaf page of a btree,
+// hash, GiST or SP-GiST index, skipping high keys, pivot tuples and items
+// already marked LP_DEAD. supported is false for page types whose tuples do
+// not carry heap TIDs this way (GIN, BRIN, heap).
+func indexHeapTIDs(p *Page, pageNum int64) (entries []IndexEntry, killed int, supported bool) {
+	special := p.SpecialData()
+	le := binary.LittleEndian
+	first := 0
+	switch p.Detected {
+	case PageTypeBTree:
+		op, ok := p.BTreeOpaque()
+		if !ok || op.Flags&BTPLeaf == 0 || op.Flags&(BTPMeta|BTPDeleted|BTPHalfDead) != 0 {
+			return nil, 0, true
+		}
+		if op.Next != 0 {
+			first = 1 // high key
+		}
+	case PageTypeHash:
+		if len(special) < HashOpaqueSize || le.Uint16(special[12:14])&(LHBucketPage|LHOverflowPage) == 0 {
+			return nil, 0, true
+		}
+	case PageTypeGiST:
+		op, ok := p.GiSTOpaque()
+		if !ok || op.Flags&GistFLeaf == 0 || op.Flags&GistFDeleted != 0 {
+			return nil, 0, true
+		}
+	case PageTypeSPGiST:
+		if !isSPGistLeaf(p) {
+			return nil, 0, true
+		}
+		for i := range p.Items {
+			t, err := spgistLeafAt(p, i+1)
+			if err != nil || t.TupState != SPGistLive {
+				continue
+			}
+			entries = append(entries, IndexEntry{pageNum, i + 1, HeapTID{t.HeapBlock, t.HeapOffset}})
+		}
+		return entries, 0, true
+	default:
+		return nil, 0, false
+	}
+
+	for i := first; i < len(p.Items); i++ {
+		lp := p.Items[i]
+		if lp.Flags() == LPDead {
+			killed++
+			continue
+		}
+		if lp.Flags() != LPNormal || lp.Length() < uint16(IndexTupleHdrSize) ||
+			int(lp.Offset())+int(lp.Length()) > PageSize {
+			continue
+		}
+		start := int(lp.Offset())
+		it := p.ParseIndexTupleHeader(lp.Offset())
+		if p.Detected == PageTypeBTree && it.Info&IndexAMReservedBit != 0 {
+			if it.TidOffset&BTIsPosting == 0 {
+				continue // pivot tuple
+			}
+			n := int(it.TidOffset & BTOffsetMask)
+			off := start + int(it.TidBlock)
+			for j := 0; j < n && off+ItemPointerSz <= start+int(lp.Length()); j++ {
+				entries = append(entries, IndexEntry{pageNum, i + 1, readTID(p.Data[off:])})
+				off += ItemPointerSz
+			}
+			continue
+		}
+		entries = append(entries, IndexEntry{pageNum, i + 1, HeapTID{it.TidBlock, it.TidOffset}})
+	}
+	return entries, killed, true
+}
+
+// heapCacheBlocks bounds how many heap blocks' line pointer arrays a
+// heapRelation keeps; index order rarely revisits a block much later.
+const heapCacheBlocks = 8192
+
+// heapRelation reads line pointer arrays of a heap relation by absolute
+// block number, following segment files (<relfilenode>.1, ...) when the
+// file name allows it.
+type heapRelation struct {
+	filename string
+	cache    map[uint32][]ItemId
+}
+
+func newHeapRelation(filename string) *heapRelation {
+	return &heapRelation{filename: filename, cache: map[uint32][]ItemId{}}
+}
+
+func (h *heapRelation) segmentPath(seg int) string {
+	if seg == 0 {
+		return h.filename
+	}
+	node, fork, _, ok := parseRelFileName(h.filename)
+	if !ok {
+		return ""
+	}
+	name := node
+	if fork != ForkMain {
+		name += "_" + fork
+	}
+	return filepath.Join(filepath.Dir(h.filename), fmt.Sprintf("%s.%d", name, seg))
+}
+
+// items returns the line pointers of a heap block; ok is false if the
+// block lies beyond the end of the relation.
+func (h *heapRelation) items(block uint32) (items []ItemId, ok bool) {
+	if items, ok := h.cache[block]; ok {
+		return items, items != nil
+	}
+	path := h.segmentPath(int(block / RelSegSize))
+	if path != "" {
+		if fi, err := os.Stat(path); err == nil && int64(block%RelSegSize) < fi.Size()/PageSize {
+			if pg, err := ReadPage(path, int64(block%RelSegSize)); err == nil {
+				items = pg.Items
+				if items == nil {
+					items = []ItemId{}
+				}
+			}
+		}
+	}
+	if len(h.cache) >= heapCacheBlocks {
+		clear(h.cache)
+	}
+	h.cache[block] = items
+	return items, items != nil
+}
+
+// danglingReason explains why tid does not reference a usable heap line
+// pointer, or returns "" if it does.
+func (h *heapRelation) danglingReason(tid HeapTID) string {
+	items, ok := h.items(tid.Block)
+	switch {
+	case !ok:
+		return "block beyond end of heap"
+	case tid.Offset == 0 || int(tid.Offset) > len(items):
+		return fmt.Sprintf("offset beyond line pointer array (%d items)", len(items))
+	case items[tid.Offset-1].Flags() == LPUnused:
+		return "heap line pointer is unused"
+	}
+	return ""
+}
+
+// CmdXCheck verifies that every heap TID in the index file points to an
+// existing, non-unused line pointer of the heap file.
+func CmdXCheck(indexFile string, totalPages int64, sr scanRange, heapFile string) {
+	fmt.Println()
+	fmt.Printf("=== Index -> Heap Cross Check (heap: %s) ===\n", heapFile)
+
+	heap := newHeapRelation(heapFile)
+	leafPages, checked, killed, dangling, unsupported := 0, 0, 0, 0, 0
+	first, end := sr.bounds(totalPages)
+	for i := first; i < end; i++ {
+		pg, err := ReadPage(indexFile, i)
+		if err != nil || sr.skips(pg) {
+			continue
+		}
+		entries, k, ok := indexHeapTIDs(pg, i)
+		if !ok {
+			if !isNewPage(pg) {
+				unsupported++
+			}
+			continue
+		}
+		if len(entries) > 0 || k > 0 {
+			leafPages++
+		}
+		killed += k
+		for _, e := range entries {
+			checked++
+			if reason := heap.danglingReason(e.TID); reason != "" {
+				dangling++
+				fmt.Printf("  index page %d item %d: heap tid %s: %s\n", e.Page, e.Item, e.TID, reason)
+			}
+		}
+	}
+
+	if dangling == 0 {
+		fmt.Println("  No dangling index entries found.")
+	}
+	fmt.Println()
+	fmt.Printf("  Leaf pages scanned : %d\n", leafPages)
+	fmt.Printf("  Heap TIDs checked  : %d\n", checked)
+	fmt.Printf("  Killed (LP_DEAD)   : %d (not checked)\n", killed)
+	fmt.Printf("  Dangling           : %d\n", dangling)
+	if unsupported > 0 {
+		fmt.Printf("  Skipped pages      : %d (GIN, BRIN or non-index pages carry no checkable TIDs)\n", unsupported)
+	}
+	fmt.Println()
+}
+
+// heapTupleLive reports whether a heap tuple may still be visible, judged
+// from its hint bits alone: xmin not known aborted and xmax absent, aborted
+// or only a locker. Tuples with unhinted xids are treated as live.
+func heapTupleLive(t HeapTupleHeader) bool {
+	if t.Infomask&HeapXminFrozen == HeapXminInvalid {
+		return false
+	}
+	if t.Xmax == InvalidXID || t.Infomask&(HeapXmaxInvalid|HeapXmaxLockOnly) != 0 {
+		return true
+	}
+	return t.Infomask&HeapXmaxCommitted == 0
+}
+
+// heapColumn decodes column col (0-based) of the heap tuple at lp, walking
+// the preceding columns with the given types. null is true for NULL values
+// and for columns beyond the tuple's natts (added after it was written).
+func heapColumn(p *Page, lp ItemId, t HeapTupleHeader, schema []string, col int) (d Datum, null bool, err error) {
+	if col >= t.NAttrs() {
+		return Datum{}, true, nil
+	}
+	start := int(lp.Offset())
+	end := start + int(lp.Length())
+	if start+int(t.Hoff) > end || end > PageSize {
+		return Datum{}, false, fmt.Errorf("bad tuple bounds")
+	}
+	isNull := func(n int) bool {
+		return t.Infomask&HeapHasNull != 0 && p.Data[start+HeapTupleHdrSize+n/8]&(1<<(n%8)) == 0
+	}
+	data := p.Data[start+int(t.Hoff) : end]
+	off := 0
+	for i := 0; i <= col; i++ {
+		if isNull(i) {
+			if i == col {
+				return Datum{}, true, nil
+			}
+			continue
+		}
+		d, off, err = DecodeDatumAt(schema[i], data, off)
+		if err != nil {
+			return Datum{}, false, fmt.Errorf("column %d: %w", i+1, err)
+		}
+	}
+	return d, false, nil
+}
+
+// CmdIndexCheck verifies that every live heap tuple's value in column col
+// (1-based, decoded with schema) is present in the leaf pages of the
+// single-column btree in indexFile. For tuples that are not heap-only the
+// index entry must also point at the tuple's own TID.
+func CmdIndexCheck(indexFile string, totalPages int64, heapFile string, schema []string, col int) {
+	typ := schema[col-1]
+	fmt.Println()
+	fmt.Printf("=== Heap -> Index Presence Check (heap: %s, column %d: %s) ===\n", heapFile, col, typ)
+
+	index := map[string]map[HeapTID]bool{}
+	leafPages, undecodable := 0, 0
+	for i := int64(0); i < totalPages; i++ {
+		pg, err := ReadPage(indexFile, i)
+		if err != nil || pg.Detected != PageTypeBTree {
+			continue
+		}
+		entries, _, _ := indexHeapTIDs(pg, i)
+		if len(entries) > 0 {
+			leafPages++
+		}
+		for _, e := range entries {
+			key, ok, err := btreeKeyAt(pg, e.Item-1, typ)
+			if err != nil {
+				undecodable++
+				continue
+			}
+			if !ok {
+				continue
+			}
+			k := key.String()
+			if index[k] == nil {
+				index[k] = map[HeapTID]bool{}
+			}
+			index[k][e.TID] = true
+		}
+	}
+	if leafPages == 0 {
+		fmt.Println("  No btree leaf entries found in the index file.")
+		fmt.Println()
+		return
+	}
+
+	heapPages, err := countPages(heapFile)
+	if err != nil {
+		fmt.Printf("  Error: %v\n", err)
+		return
+	}
+	base := absBlockNumber(heapFile, 0)
+	checked, nulls, missing, wrongTID, heapUndecodable := 0, 0, 0, 0, 0
+	for b := int64(0); b < heapPages; b++ {
+		pg, err := ReadPage(heapFile, b)
+		if err != nil || pg.Detected != PageTypeHeap {
+			continue
+		}
+		for i, lp := range pg.Items {
+			if lp.Flags() != LPNormal || lp.Length() < HeapTupleHdrSize {
+				continue
+			}
+			t := pg.ParseHeapTupleHeader(lp.Offset())
+			if !heapTupleLive(t) {
+				continue
+			}
+			tid := HeapTID{base + uint32(b), uint16(i + 1)}
+			key, null, err := heapColumn(pg, lp, t, schema, col-1)
+			if err != nil {
+				heapUndecodable++
+				fmt.Printf("  heap tid %s: cannot decode: %v\n", tid, err)
+				continue
+			}
+			if null {
+				nulls++
+				continue
+			}
+			checked++
+			tids, ok := index[key.String()]
+			switch {
+			case !ok:
+				missing++
+				fmt.Printf("  heap tid %s: key %s not found in index\n", tid, key)
+			case t.Infomask2&HeapOnlyTuple == 0 && !tids[tid]:
+				wrongTID++
+				fmt.Printf("  heap tid %s: key %s indexed, but no entry points to this tuple\n", tid, key)
+			}
+		}
+	}
+
+	if missing == 0 && wrongTID == 0 {
+		fmt.Println("  Every live heap tuple's key is present in the index.")
+	}
+	fmt.Println()
+	fmt.Printf("  Index leaf pages   : %d (%d distinct keys, %d undecodable)\n", leafPages, len(index), undecodable)
+	fmt.Printf("  Heap tuples checked: %d (%d NULL keys skipped, %d undecodable)\n", checked, nulls, heapUndecodable)
+	fmt.Printf("  Missing keys       : %d\n", missing)
+	fmt.Printf("  Missing TIDs       : %d\n", wrongTID)
+	fmt.Println()
+}
+
+// CmdDupTIDs reports heap TIDs referenced by more than one leaf entry of
+// the index. Every heap tuple has exactly one entry in a btree, hash, GiST
+// or SP-GiST index, so a repeat points at corruption such as a replayed or
+// torn page split.
+func CmdDupTIDs(filename string, totalPages int64, sr scanRange) {
+	fmt.Println()
+	fmt.Println("=== Duplicate Heap TIDs ===")
+
+	seen := map[HeapTID]IndexEntry{}
+	checked, dups, unsupported := 0, 0, 0
+	first, end := sr.bounds(totalPages)
+	for i := first; i < end; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil {
+			continue
+		}
+		entries, _, ok := indexHeapTIDs(pg, i)
+		if !ok {
+			if !isNewPage(pg) {
+				unsupported++
+			}
+			continue
+		}
+		for _, e := range entries {
+			checked++
+			if prev, ok := seen[e.TID]; ok {
+				dups++
+				where := fmt.Sprintf("page %d item %d", prev.Page, prev.Item)
+				if prev.Page == e.Page && prev.Item == e.Item {
+					where = "the same posting list"
+				}
+				fmt.Printf("  heap tid %s: page %d item %d, already in %s\n", e.TID, e.Page, e.Item, where)
+				continue
+			}
+			seen[e.TID] = e
+		}
+	}
+
+	if dups == 0 {
+		fmt.Println("  No duplicate heap TIDs found.")
+	}
+	fmt.Println()
+	fmt.Printf("  Heap TIDs checked  : %d (LP_DEAD items excluded)\n", checked)
+	fmt.Printf("  Duplicates         : %d\n", dups)
+	if unsupported > 0 {
+		fmt.Printf("  Skipped pages      : %d (GIN, BRIN or non-index pages carry no checkable TIDs)\n", unsupported)
+	}
+	fmt.Println()
+}
+
+// CmdFindTID lists the index entries, posting list TIDs included, that
+// reference heap block block, or only the exact TID (block, offset) when
+// offset is non-zero, to find the index entries of a problem heap tuple.
+func CmdFindTID(filename string, totalPages int64, sr scanRange, block uint32, offset uint16) {
+	target := fmt.Sprintf("Heap Block %d", block)
+	if offset != 0 {
+		target = "Heap TID " + HeapTID{block, offset}.String()
+	}
+	fmt.Println()
+	fmt.Printf("=== Index Entries for %s ===\n", target)
+
+	leafPages, matches, killed, unsupported := 0, 0, 0, 0
+	first, end := sr.bounds(totalPages)
+	for i := first; i < end; i++ {
+		pg, err := ReadPage(filename, i)
+		if err != nil || sr.skips(pg) {
+			continue
+		}
+		entries, k, ok := indexHeapTIDs(pg, i)
+		if !ok {
+			if !isNewPage(pg) {
+				unsupported++
+			}
+			continue
+		}
+		if len(entries) > 0 || k > 0 {
+			leafPages++
+		}
+		killed += k
+		for _, e := range entries {
+			if e.TID.Block != block || (offset != 0 && e.TID.Offset != offset) {
+				continue
+			}
+			matches++
+			fmt.Printf("  index page %d item %d: heap tid %s\n", e.Page, e.Item, e.TID)
+		}
+	}
+
+	if matches == 0 {
+		fmt.Println("  No index entries found.")
+	}
+	fmt.Println()
+	fmt.Printf("  Leaf pages scanned : %d\n", leafPages)
+	fmt.Printf("  Matches            : %d\n", matches)
+	fmt.Printf("  Killed (LP_DEAD)   : %d (not searched)\n", killed)
+	if unsupported > 0 {
+		fmt.Printf("  Skipped pages      : %d (GIN, BRIN or non-index pages carry no searchable TIDs)\n", unsupported)
+	}
+	fmt.Println()
+}
+
+// parseFindTID parses the <block> [offset] arguments of findtid.
+func parseFindTID(args []string) (block uint32, offset uint16, err error) {
+	b, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid block number %q", args[0])
+	}
+	if len(args) == 2 {
+		o, err := strconv.ParseUint(args[1], 10, 16)
+		if err != nil || o == 0 {
+			return 0, 0, fmt.Errorf("invalid offset number %q (1-based)", args[1])
+		}
+		offset = uint16(o)
+	}
+	return uint32(b), offset, nil
+}